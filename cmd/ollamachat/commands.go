@@ -0,0 +1,976 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/backup"
+	"github.com/ashprao/ollamachat/internal/config"
+	"github.com/ashprao/ollamachat/internal/export"
+	"github.com/ashprao/ollamachat/internal/importer"
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/semantic"
+	"github.com/ashprao/ollamachat/internal/storage"
+	"github.com/ashprao/ollamachat/internal/storage/storagebench"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+// runCommand runs a maintenance command and returns the process exit code
+func runCommand(name string, args []string) int {
+	switch name {
+	case "migrate-storage":
+		return runMigrateStorage(args)
+	case "bench-storage":
+		return runBenchStorage(args)
+	case "encrypt-storage":
+		return runEncryptStorage(args)
+	case "change-passphrase":
+		return runChangePassphrase(args)
+	case "backup":
+		return runBackup(args)
+	case "restore":
+		return runRestore(args)
+	case "export":
+		return runExport(args)
+	case "import":
+		return runImport(args)
+	case "sync-status":
+		return runSyncStatus(args)
+	case "usage":
+		return runUsage(args)
+	case "validate-config":
+		return runValidateConfig(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", name)
+		fmt.Fprintln(os.Stderr, "Run 'ollamachat -help' for usage.")
+		return 2
+	}
+}
+
+// runMigrateStorage copies a file store into a SQLite database, a sync folder or an
+// S3 bucket
+func runMigrateStorage(args []string) int {
+	flags := flag.NewFlagSet("migrate-storage", flag.ContinueOnError)
+	from := flags.String("from", "data", "Source file storage directory")
+	storageType := flags.String("type", "sqlite", "Destination backend (sqlite, sync, s3)")
+	to := flags.String("to", "", "Destination SQLite database (default: <from>/"+storage.DefaultSQLiteFile+"), sync folder or S3 cache directory")
+	verbose := flags.Bool("v", false, "Log storage operations")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	dest := *to
+	switch *storageType {
+	case "sqlite":
+		if dest == "" {
+			dest = filepath.Join(*from, storage.DefaultSQLiteFile)
+		}
+	case "sync":
+		if dest == "" {
+			fmt.Fprintln(os.Stderr, "-to is required: the shared folder to sync through")
+			return 2
+		}
+	case "s3":
+		if dest == "" {
+			fmt.Fprintln(os.Stderr, "-to is required: a new directory for the local cache of the bucket")
+			return 2
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unsupported destination type: %s\n", *storageType)
+		return 2
+	}
+
+	level := slog.LevelWarn
+	if *verbose {
+		level = slog.LevelInfo
+	}
+	log := logger.NewLogger(level)
+
+	if _, err := os.Stat(*from); err != nil {
+		fmt.Fprintf(os.Stderr, "source storage not found: %v\n", err)
+		return 1
+	}
+
+	src, err := storage.NewFileStorage(*from, nil, log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open file storage: %v\n", err)
+		return 1
+	}
+	defer src.Close()
+
+	dst, err := openBackend(*storageType, dest, dest, log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open %s storage: %v\n", *storageType, err)
+		return 1
+	}
+	defer dst.Close()
+
+	report, err := storage.CopyStorage(context.Background(), dst, src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migration failed: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Copied %d sessions (%d messages), %d personas and %d templates to %s\n",
+		report.Sessions, report.Messages, report.Personas, report.Templates, dest)
+	for _, skipped := range report.Skipped {
+		fmt.Printf("  skipped %s\n", skipped)
+	}
+	if len(report.Skipped) > 0 {
+		return 1
+	}
+
+	switch *storageType {
+	case "sync":
+		fmt.Printf("Set storage.type to \"sync\" and the storage directory to %s on each device to use it.\n", dest)
+	case "s3":
+		if pending := dst.(*storage.S3Storage).PendingUploads(); pending > 0 {
+			fmt.Printf("%d changes could not be uploaded yet; they are uploaded the next time the storage is opened.\n", pending)
+		}
+		fmt.Printf("Set storage.type to \"s3\" and the storage directory to %s to use it.\n", dest)
+	default:
+		fmt.Println("Set storage.type to \"sqlite\" in the config file (or pass -storage-type sqlite) to use it.")
+	}
+	return 0
+}
+
+// runEncryptStorage turns on encryption for a store and encrypts its existing sessions
+// in place. Run again on an encrypted store, it finishes an interrupted encryption.
+func runEncryptStorage(args []string) int {
+	flags := flag.NewFlagSet("encrypt-storage", flag.ContinueOnError)
+	dir := flags.String("storage", "data", "Storage directory (holds the key file)")
+	storageType := flags.String("type", "file", "Storage backend (file, sqlite)")
+	dbPath := flags.String("db", "", "SQLite database (default: <storage>/"+storage.DefaultSQLiteFile+")")
+	verbose := flags.Bool("v", false, "Log storage operations")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *storageType == "sync" {
+		fmt.Fprintln(os.Stderr, "sync storage does not support encryption")
+		return 1
+	}
+
+	level := slog.LevelWarn
+	if *verbose {
+		level = slog.LevelInfo
+	}
+	log := logger.NewLogger(level)
+
+	var cipher *storage.Cipher
+	var passphrase string
+	var err error
+	if storage.IsEncrypted(*dir) {
+		fmt.Printf("%s is already encrypted; encrypting any sessions left in plaintext.\n", *dir)
+		passphrase, err = readPassphrase("Passphrase: ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if cipher, err = storage.UnlockKey(*dir, passphrase); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to unlock storage: %v\n", err)
+			return 1
+		}
+	}
+
+	inner, err := openBackend(*storageType, *dir, *dbPath, log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open storage: %v\n", err)
+		return 1
+	}
+	defer inner.Close()
+
+	if cipher == nil {
+		fmt.Println("Choose a passphrase. It cannot be recovered: without it the chat history is lost.")
+		passphrase, err = readNewPassphrase("New passphrase: ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if cipher, err = storage.CreateKey(*dir, passphrase); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create key: %v\n", err)
+			return 1
+		}
+	}
+
+	ctx := context.Background()
+	stor := storage.NewEncryptedStorage(inner, cipher, log)
+	encrypted, err := stor.EncryptExisting(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "encryption failed after %d sessions: %v\n", encrypted, err)
+		fmt.Fprintln(os.Stderr, "Run the command again to finish.")
+		return 1
+	}
+
+	// Every session, trashed ones included, is now bound to the position of its
+	// values; the upgraded key refuses values that are not
+	if cipher.Legacy() {
+		if err := storage.UpgradeKey(*dir, passphrase); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to upgrade the key: %v\n", err)
+			return 1
+		}
+		fmt.Println("Upgraded the key file: encrypted values are now bound to their place in each session.")
+	}
+
+	// Drop pages of the database that still hold the plaintext
+	if vacuumer, ok := inner.(interface{ Vacuum(context.Context) error }); ok {
+		if err := vacuumer.Vacuum(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+	}
+
+	// Search indexes hold message text; they are rebuilt in memory while encrypted
+	for _, name := range []string{storage.SearchIndexFile, semantic.IndexFile} {
+		if err := os.Remove(filepath.Join(*dir, name)); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "warning: failed to remove %s: %v\n", name, err)
+		}
+	}
+
+	if _, err := storage.TightenPermissions(*dir); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to restrict permissions: %v\n", err)
+	}
+
+	fmt.Printf("Encrypted %d sessions in %s. The passphrase is asked for when the app starts.\n", encrypted, *dir)
+	for _, sub := range []string{"backups", "quarantine"} {
+		if _, err := os.Stat(filepath.Join(*dir, sub)); err == nil {
+			fmt.Printf("Note: %s still holds unencrypted copies of older files; delete it if they are not needed.\n", filepath.Join(*dir, sub))
+		}
+	}
+	return 0
+}
+
+// runBackup writes an archive of everything in a store
+func runBackup(args []string) int {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	dir := flags.String("storage", "data", "Storage directory")
+	storageType := flags.String("type", "file", "Storage backend (file, sqlite, sync, s3)")
+	dbPath := flags.String("db", "", "SQLite database (default: <storage>/"+storage.DefaultSQLiteFile+")")
+	output := flags.String("o", "", "Archive to write (default: "+backup.ArchiveName(time.Now())+" in the current directory)")
+	verbose := flags.Bool("v", false, "Log storage operations")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	path := *output
+	if path == "" {
+		path = backup.ArchiveName(time.Now())
+	}
+	if _, err := os.Stat(*dir); err != nil {
+		fmt.Fprintf(os.Stderr, "storage not found: %v\n", err)
+		return 1
+	}
+
+	stor, cipher, err := openCommandStorage(*storageType, *dir, *dbPath, *verbose, "Passphrase: ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer stor.Close()
+
+	opts, err := backupWriteOptions(*dir, cipher)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	manifest, err := backup.WriteFile(context.Background(), path, stor, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backup failed: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Backed up %d sessions (%d messages), %d personas and %d templates to %s\n",
+		manifest.Counts.Sessions, manifest.Counts.Messages, manifest.Counts.Personas, manifest.Counts.Templates, path)
+	if manifest.Encrypted {
+		fmt.Println("The archive is encrypted; restoring it asks for the current passphrase.")
+	}
+	return 0
+}
+
+// backupWriteOptions returns the options for archives of the store in dir. Archives of
+// an encrypted store stay encrypted with the same passphrase.
+func backupWriteOptions(dir string, cipher *storage.Cipher) (backup.WriteOptions, error) {
+	opts := backup.WriteOptions{}
+	if cipher != nil {
+		keyFile, err := os.ReadFile(filepath.Join(dir, storage.KeyFile))
+		if err != nil {
+			return opts, fmt.Errorf("failed to read key file: %w", err)
+		}
+		opts.Cipher = cipher
+		opts.KeyFile = keyFile
+	}
+	return opts, nil
+}
+
+// runRestore restores a backup archive into a store, merging with or replacing its content
+func runRestore(args []string) int {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	dir := flags.String("storage", "data", "Storage directory")
+	storageType := flags.String("type", "file", "Storage backend (file, sqlite, sync, s3)")
+	dbPath := flags.String("db", "", "SQLite database (default: <storage>/"+storage.DefaultSQLiteFile+")")
+	input := flags.String("i", "", "Archive to restore")
+	mode := flags.String("mode", string(backup.ModeMerge), "merge: add to the stored data; replace: delete the stored data first")
+	yes := flags.Bool("y", false, "Do not ask before replacing the stored data")
+	safetyDir := flags.String("safety-dir", "", "Where replace backs up the stored data first (default: <storage>/backups)")
+	verbose := flags.Bool("v", false, "Log storage operations")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *safetyDir == "" {
+		*safetyDir = filepath.Join(*dir, "backups")
+	}
+	if *input == "" && flags.NArg() == 1 {
+		*input = flags.Arg(0)
+	}
+	if *input == "" {
+		fmt.Fprintln(os.Stderr, "no archive given; use -i <archive>")
+		return 2
+	}
+	if *mode != string(backup.ModeMerge) && *mode != string(backup.ModeReplace) {
+		fmt.Fprintf(os.Stderr, "unknown mode %q (use merge or replace)\n", *mode)
+		return 2
+	}
+
+	archive, err := backup.OpenFile(*input)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	counts := archive.Manifest.Counts
+	fmt.Printf("Archive from %s: %d sessions (%d messages), %d personas, %d templates\n",
+		archive.Manifest.CreatedAt.Local().Format("2006-01-02 15:04"), counts.Sessions, counts.Messages, counts.Personas, counts.Templates)
+	if archive.Encrypted() {
+		passphrase, err := readPassphrase("Archive passphrase: ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if err := archive.Unlock(passphrase); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to unlock archive: %v\n", err)
+			return 1
+		}
+	}
+
+	if *mode == string(backup.ModeReplace) && !*yes {
+		fmt.Printf("Replace every session, persona, template and setting in %s? [y/N] ", *dir)
+		answer, _ := stdin.ReadString('\n')
+		if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+			fmt.Println("Restore cancelled.")
+			return 1
+		}
+	}
+
+	stor, cipher, err := openCommandStorage(*storageType, *dir, *dbPath, *verbose, "Storage passphrase: ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer stor.Close()
+
+	opts := backup.RestoreOptions{SafetyDir: *safetyDir}
+	if opts.Write, err = backupWriteOptions(*dir, cipher); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	report, err := backup.Restore(context.Background(), stor, archive, backup.Mode(*mode), opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore failed: %v\n", err)
+		return 1
+	}
+
+	if report.SafetyBackup != "" {
+		fmt.Printf("Backed up the stored data to %s first\n", report.SafetyBackup)
+	}
+	if report.Removed > 0 {
+		fmt.Printf("Removed %d stored items\n", report.Removed)
+	}
+	fmt.Printf("Restored %d sessions, %d personas, %d templates and %d MCP servers; %d items were already present\n",
+		report.Sessions, report.Personas, report.Templates, report.MCPServers, report.Unchanged)
+	if report.Settings {
+		fmt.Println("Preferences and agent settings were replaced.")
+	}
+	for _, renamed := range report.Renamed {
+		fmt.Printf("  ID in use, restored as a copy: %s\n", renamed)
+	}
+	for _, skipped := range report.Skipped {
+		fmt.Printf("  skipped %s\n", skipped)
+	}
+	if len(report.Skipped) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runExport writes sessions to a file in one of the export formats
+func runExport(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	dir := flags.String("storage", "data", "Storage directory")
+	storageType := flags.String("type", "file", "Storage backend (file, sqlite, sync, s3)")
+	dbPath := flags.String("db", "", "SQLite database (default: <storage>/"+storage.DefaultSQLiteFile+")")
+	format := flags.String("format", "markdown", "Export format ("+strings.Join(export.Names(), ", ")+")")
+	sessionIDs := flags.String("sessions", "", "Comma-separated IDs of the sessions to export (default: all sessions)")
+	output := flags.String("o", "", "File to write (default: standard output)")
+	verbose := flags.Bool("v", false, "Log storage operations")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	exporter, ok := export.Get(*format)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown format %q (use %s)\n", *format, strings.Join(export.Names(), ", "))
+		return 2
+	}
+	if _, err := os.Stat(*dir); err != nil {
+		fmt.Fprintf(os.Stderr, "storage not found: %v\n", err)
+		return 1
+	}
+
+	var ids []string
+	for _, id := range strings.Split(*sessionIDs, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	stor, _, err := openCommandStorage(*storageType, *dir, *dbPath, *verbose, "Passphrase: ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer stor.Close()
+
+	sessions, err := export.LoadSessions(context.Background(), stor, ids)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		file, err := os.OpenFile(*output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create %s: %v\n", *output, err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if err := exporter.Export(out, sessions); err != nil {
+		fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+		return 1
+	}
+	if *output != "" {
+		if err := out.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write %s: %v\n", *output, err)
+			return 1
+		}
+		fmt.Printf("Exported %d sessions as %s to %s\n", len(sessions), exporter.Title(), *output)
+	}
+	return 0
+}
+
+// runImport reads conversations exported from another chat tool, lists them with the
+// ones already stored marked, and saves the new ones after confirmation
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	dir := flags.String("storage", "data", "Storage directory")
+	storageType := flags.String("type", "file", "Storage backend (file, sqlite, sync, s3)")
+	dbPath := flags.String("db", "", "SQLite database (default: <storage>/"+storage.DefaultSQLiteFile+")")
+	input := flags.String("i", "", "File to import")
+	format := flags.String("format", "auto", "Import format (auto, "+strings.Join(importer.Names(), ", ")+")")
+	duplicates := flags.Bool("duplicates", false, "Also import conversations that are already stored")
+	dryRun := flags.Bool("dry-run", false, "Only list the conversations found; do not import")
+	yes := flags.Bool("y", false, "Do not ask before importing")
+	verbose := flags.Bool("v", false, "Log storage operations")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *input == "" && flags.NArg() == 1 {
+		*input = flags.Arg(0)
+	}
+	if *input == "" {
+		fmt.Fprintln(os.Stderr, "no file given; use -i <file>")
+		return 2
+	}
+
+	data, err := os.ReadFile(*input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read %s: %v\n", *input, err)
+		return 1
+	}
+	imp, sessions, err := importer.Parse(*format, data)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	stor, _, err := openCommandStorage(*storageType, *dir, *dbPath, *verbose, "Passphrase: ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer stor.Close()
+
+	candidates, err := importer.Preview(context.Background(), stor, sessions)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Printf("%s: %d conversations\n", imp.Title(), len(candidates))
+	var selected []models.ChatSession
+	for _, candidate := range candidates {
+		session := candidate.Session
+		note := ""
+		switch {
+		case candidate.Existing != "":
+			note = fmt.Sprintf("  [already stored as %q]", candidate.Existing)
+		case candidate.Duplicate:
+			note = "  [repeated in file]"
+		}
+		fmt.Printf("  %s  %-40s %4d messages  %s%s\n", session.CreatedAt.Local().Format("2006-01-02 15:04"),
+			truncate(session.Name, 40), len(session.Messages), session.Model, note)
+		if !candidate.Duplicate || *duplicates {
+			selected = append(selected, session)
+		}
+	}
+
+	if *dryRun {
+		return 0
+	}
+	if len(selected) == 0 {
+		fmt.Println("Nothing new to import.")
+		return 0
+	}
+	if !*yes {
+		fmt.Printf("Import %d conversations into %s? [y/N] ", len(selected), *dir)
+		answer, _ := stdin.ReadString('\n')
+		if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+			fmt.Println("Import cancelled.")
+			return 1
+		}
+	}
+
+	report, err := importer.Save(context.Background(), stor, selected)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Imported %d conversations with %d messages\n", report.Sessions, report.Messages)
+	for _, skipped := range report.Skipped {
+		fmt.Printf("  skipped %s\n", skipped)
+	}
+	if len(report.Skipped) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runSyncStatus lists the devices writing to a sync folder and the conflicts found
+// merging their changes
+func runSyncStatus(args []string) int {
+	flags := flag.NewFlagSet("sync-status", flag.ContinueOnError)
+	dir := flags.String("storage", "data", "Sync folder")
+	device := flags.String("device", "", "Name of this device, if set in storage.sync.device (default: this computer's ID)")
+	dismiss := flags.Bool("dismiss", false, "Stop reporting the listed conflicts on this device")
+	verbose := flags.Bool("v", false, "Log storage operations")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	level := slog.LevelWarn
+	if *verbose {
+		level = slog.LevelInfo
+	}
+	log := logger.NewLogger(level)
+
+	if _, err := os.Stat(filepath.Join(*dir, storage.SyncLogDir)); err != nil {
+		fmt.Fprintf(os.Stderr, "%s is not a sync folder: %v\n", *dir, err)
+		return 1
+	}
+	stor, err := storage.NewSyncStorage(*dir, *device, log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open sync storage: %v\n", err)
+		return 1
+	}
+	defer stor.Close()
+
+	fmt.Printf("%-30s %10s  %s\n", "Device", "Operations", "Last change")
+	for _, d := range stor.SyncDevices() {
+		name := d.Name
+		if d.Local {
+			name += " (this device)"
+		}
+		fmt.Printf("%-30s %10d  %s\n", truncate(name, 30), d.Operations, d.LastSeen.Local().Format("2006-01-02 15:04"))
+	}
+
+	conflicts := stor.SyncConflicts()
+	if len(conflicts) == 0 {
+		fmt.Println("\nNo conflicts.")
+		return 0
+	}
+	fmt.Printf("\n%d conflict(s):\n", len(conflicts))
+	ids := make([]string, 0, len(conflicts))
+	for _, c := range conflicts {
+		fmt.Printf("  %s  %s  %s (%s)\n", c.ID, c.Time.Local().Format("2006-01-02 15:04"), c.Subject, strings.Join(c.Devices, ", "))
+		fmt.Printf("      %s\n", c.Detail)
+		ids = append(ids, c.ID)
+	}
+
+	if *dismiss {
+		if err := stor.DismissSyncConflicts(ids); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to dismiss conflicts: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Dismissed %d conflict(s).\n", len(ids))
+	}
+	return 0
+}
+
+// runUsage reports the space taken by each session, the trash and the storage files
+func runUsage(args []string) int {
+	flags := flag.NewFlagSet("usage", flag.ContinueOnError)
+	dir := flags.String("storage", "data", "Storage directory")
+	storageType := flags.String("type", "file", "Storage backend (file, sqlite, sync, s3)")
+	dbPath := flags.String("db", "", "SQLite database (default: <storage>/"+storage.DefaultSQLiteFile+")")
+	limit := flags.Int("n", 20, "Number of sessions listed, largest first (0 lists all)")
+	verbose := flags.Bool("v", false, "Log storage operations")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if _, err := os.Stat(*dir); err != nil {
+		fmt.Fprintf(os.Stderr, "storage not found: %v\n", err)
+		return 1
+	}
+	stor, _, err := openCommandStorage(*storageType, *dir, *dbPath, *verbose, "Passphrase: ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer stor.Close()
+
+	paths := []string{*dir}
+	if *dbPath != "" {
+		paths = append(paths, *dbPath, *dbPath+"-wal", *dbPath+"-shm")
+	}
+	usage, err := storage.MeasureUsage(context.Background(), stor, paths...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to measure usage: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Sessions: %d (%s)\n", len(usage.Sessions), storage.FormatBytes(usage.SessionBytes))
+	fmt.Printf("Trash:    %d (%s)\n", usage.TrashCount, storage.FormatBytes(usage.TrashBytes))
+	fmt.Printf("On disk:  %s\n", storage.FormatBytes(usage.DiskBytes))
+	if len(usage.Sessions) == 0 {
+		return 0
+	}
+
+	fmt.Printf("\n%10s %8s  %-16s  %-6s  %s\n", "Size", "Messages", "Updated", "Pinned", "Name")
+	for i, summary := range usage.Sessions {
+		if *limit > 0 && i == *limit {
+			fmt.Printf("... and %d more\n", len(usage.Sessions)-i)
+			break
+		}
+		pinned := ""
+		if summary.Pinned {
+			pinned = "yes"
+		}
+		fmt.Printf("%10s %8d  %-16s  %-6s  %s\n", storage.FormatBytes(summary.Size), summary.MessageCount,
+			summary.UpdatedAt.Local().Format("2006-01-02 15:04"), pinned, truncate(summary.Name, 50))
+	}
+	return 0
+}
+
+// runValidateConfig checks the config file, with the OLLAMACHAT_* environment variables
+// applied, and reports every problem found. With -schema it prints the JSON Schema
+// of the config file instead.
+func runValidateConfig(args []string) int {
+	flags := flag.NewFlagSet("validate-config", flag.ContinueOnError)
+	path := flags.String("config", "", "Config file (default: $"+config.EnvConfigPath+", else "+config.DefaultConfigPath+")")
+	profile := flags.String("profile", "", "Check only this profile (default: the file alone and each profile in it)")
+	schema := flags.Bool("schema", false, "Print the JSON Schema of the config file and exit")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *schema {
+		if err := config.WriteSchema(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write schema: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	if *path == "" {
+		*path = os.Getenv(config.EnvConfigPath)
+	}
+	if *path == "" {
+		*path = config.DefaultConfigPath
+	}
+	// Loading would create a missing file with the defaults
+	if _, err := os.Stat(*path); err != nil {
+		fmt.Fprintf(os.Stderr, "config file not found: %v\n", err)
+		return 1
+	}
+
+	// Check the file as it is, then with each profile applied
+	profiles := []string{*profile}
+	if *profile == "" {
+		profiles[0] = config.NoProfile
+		if layered, err := config.Load(config.LoadOptions{Path: *path}); err == nil {
+			profiles = append(profiles, layered.ProfileNames()...)
+		}
+	}
+
+	problems := 0
+	seen := make(map[string]bool)
+	for i, name := range profiles {
+		label := *path
+		if name != config.NoProfile {
+			label += " [profile " + name + "]"
+		}
+		flags := []config.Override{{Flag: "-profile", Path: "app.profile", Value: name}}
+		layered, err := config.Load(config.LoadOptions{Path: *path, Flags: flags})
+		if err != nil {
+			fmt.Printf("%s: %v\n", label, err)
+			problems++
+			continue
+		}
+		if layered.FileError != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *path, layered.FileError)
+			return 1
+		}
+		if i == 0 {
+			for _, name := range layered.UnknownEnv {
+				fmt.Fprintf(os.Stderr, "warning: ignoring unknown variable %s\n", name)
+			}
+		}
+
+		err = layered.Validate()
+		var invalid *config.ValidationError
+		switch {
+		case err == nil:
+			fmt.Printf("%s: ok\n", label)
+		case errors.As(err, &invalid):
+			// Problems in the file itself are found with every profile; list them once
+			reported := 0
+			for _, problem := range invalid.Errors {
+				if seen[problem.Error()] {
+					continue
+				}
+				seen[problem.Error()] = true
+				fmt.Printf("%s: %v\n", label, problem)
+				reported++
+			}
+			if reported == 0 {
+				fmt.Printf("%s: no other problems\n", label)
+			}
+			problems += reported
+		default:
+			fmt.Printf("%s: %v\n", label, err)
+			problems++
+		}
+	}
+
+	if problems > 0 {
+		fmt.Printf("%d problem(s) found.\n", problems)
+		return 1
+	}
+	return 0
+}
+
+// truncate shortens text to at most n runes for table output
+func truncate(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// commandConfigPath is the config file commands read the S3 bucket settings from
+const commandConfigPath = "configs/config.yaml"
+
+// openBackend opens the store of the given type in dir
+func openBackend(storageType, dir, dbPath string, log *logger.Logger) (storage.Storage, error) {
+	switch storageType {
+	case "file":
+		return storage.NewFileStorage(dir, nil, log)
+	case "sqlite":
+		if dbPath == "" {
+			dbPath = filepath.Join(dir, storage.DefaultSQLiteFile)
+		}
+		return storage.NewSQLiteStorage(dbPath, log)
+	case "sync":
+		return storage.NewSyncStorage(dir, "", log)
+	case "s3":
+		// The bucket is configured in the config file; dir holds its local cache
+		cfg, err := config.LoadConfig(commandConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		return storage.NewS3Storage(storage.S3Settings{
+			Endpoint:  cfg.Storage.S3.Endpoint,
+			Bucket:    cfg.Storage.S3.Bucket,
+			Prefix:    cfg.Storage.S3.Prefix,
+			Region:    cfg.Storage.S3.Region,
+			AccessKey: cfg.Storage.S3.AccessKey,
+			SecretKey: cfg.Storage.S3.SecretKey,
+			CacheDir:  dir,
+		}, log)
+	default:
+		return nil, fmt.Errorf("unsupported storage type: %s", storageType)
+	}
+}
+
+// openCommandStorage opens a store for a command, asking for its passphrase when it
+// is encrypted. The cipher is nil for unencrypted stores.
+func openCommandStorage(storageType, dir, dbPath string, verbose bool, prompt string) (storage.Storage, *storage.Cipher, error) {
+	level := slog.LevelWarn
+	if verbose {
+		level = slog.LevelInfo
+	}
+	log := logger.NewLogger(level)
+
+	var cipher *storage.Cipher
+	if storage.IsEncrypted(dir) {
+		passphrase, err := readPassphrase(prompt)
+		if err != nil {
+			return nil, nil, err
+		}
+		if cipher, err = storage.UnlockKey(dir, passphrase); err != nil {
+			return nil, nil, fmt.Errorf("failed to unlock storage: %w", err)
+		}
+	}
+
+	stor, err := openBackend(storageType, dir, dbPath, log)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+	if cipher != nil {
+		stor = storage.NewEncryptedStorage(stor, cipher, log)
+	}
+	return stor, cipher, nil
+}
+
+// runChangePassphrase replaces the passphrase of an encrypted store
+func runChangePassphrase(args []string) int {
+	flags := flag.NewFlagSet("change-passphrase", flag.ContinueOnError)
+	dir := flags.String("storage", "data", "Storage directory (holds the key file)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if !storage.IsEncrypted(*dir) {
+		fmt.Fprintf(os.Stderr, "%s is not encrypted; run 'ollamachat encrypt-storage' first\n", *dir)
+		return 1
+	}
+
+	current, err := readPassphrase("Current passphrase: ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if _, err := storage.UnlockKey(*dir, current); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to unlock storage: %v\n", err)
+		return 1
+	}
+	next, err := readNewPassphrase("New passphrase: ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if err := storage.ChangePassphrase(*dir, current, next); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to change passphrase: %v\n", err)
+		return 1
+	}
+	fmt.Println("Passphrase changed.")
+	return 0
+}
+
+// runBenchStorage compares the I/O of persisting a streamed answer by rewriting the
+// session on every chunk against batched appends to the session journal
+func runBenchStorage(args []string) int {
+	w := storagebench.DefaultStreamingWorkload
+
+	flags := flag.NewFlagSet("bench-storage", flag.ContinueOnError)
+	storageType := flags.String("type", "file", "Storage backend to measure (file, sqlite, memory, sync)")
+	flags.IntVar(&w.HistoryMessages, "history", w.HistoryMessages, "Messages already in the session")
+	flags.IntVar(&w.MessageSize, "message-size", w.MessageSize, "Bytes per history message")
+	flags.IntVar(&w.Chunks, "chunks", w.Chunks, "Chunks in the streamed answer")
+	flags.IntVar(&w.ChunkSize, "chunk-size", w.ChunkSize, "Bytes per chunk")
+	flags.IntVar(&w.ChunksPerFlush, "chunks-per-flush", w.ChunksPerFlush, "Chunks between batched writes")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	ctx := context.Background()
+	log := logger.NewLogger(slog.LevelError)
+
+	fmt.Printf("Workload: %d history messages x %d B, %d chunks x %d B, write every %d chunks (%s storage)\n\n",
+		w.HistoryMessages, w.MessageSize, w.Chunks, w.ChunkSize, w.ChunksPerFlush, *storageType)
+	fmt.Printf("%-18s %8s %14s %12s\n", "Strategy", "Writes", "Bytes written", "Time")
+
+	strategies := []func(context.Context, storage.Storage, storagebench.StreamingWorkload) (storagebench.StreamingResult, error){
+		storagebench.MeasureRewritePerChunk,
+		storagebench.MeasureBatchedAppend,
+	}
+	for _, run := range strategies {
+		dir, err := os.MkdirTemp("", "ollamachat-bench-")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create temporary directory: %v\n", err)
+			return 1
+		}
+
+		result, err := benchOnce(ctx, *storageType, dir, log, w, run)
+		os.RemoveAll(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "benchmark failed: %v\n", err)
+			return 1
+		}
+
+		writes, written := "-", "-"
+		if result.Writes > 0 {
+			writes = fmt.Sprintf("%d", result.Writes)
+			written = fmt.Sprintf("%d", result.BytesWritten)
+		}
+		fmt.Printf("%-18s %8s %14s %12s\n", result.Strategy, writes, written, result.Elapsed.Round(time.Millisecond))
+	}
+	return 0
+}
+
+// benchOnce runs one streaming strategy against a fresh storage in dir
+func benchOnce(ctx context.Context, storageType, dir string, log *logger.Logger, w storagebench.StreamingWorkload,
+	run func(context.Context, storage.Storage, storagebench.StreamingWorkload) (storagebench.StreamingResult, error)) (storagebench.StreamingResult, error) {
+	var s storage.Storage
+	var err error
+	switch storageType {
+	case "file":
+		s, err = storage.NewFileStorage(dir, nil, log)
+	case "sqlite":
+		s, err = storage.NewSQLiteStorage(filepath.Join(dir, storage.DefaultSQLiteFile), log)
+	case "memory":
+		s = storage.NewMemoryStorage(log)
+	case "sync":
+		s, err = storage.NewSyncStorage(dir, "bench", log)
+	default:
+		err = fmt.Errorf("unsupported storage type: %s", storageType)
+	}
+	if err != nil {
+		return storagebench.StreamingResult{}, err
+	}
+	defer s.Close()
+
+	return run(ctx, s, w)
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// disableEcho is not supported on this platform; input is echoed
+func disableEcho(file *os.File) (func(), error) {
+	return nil, errors.New("echo cannot be disabled on this platform")
+}
@@ -5,17 +5,28 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/ashprao/ollamachat/internal/app"
 )
 
 func main() {
+	// Maintenance commands run without starting the GUI
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
 	// Parse command line flags
-	var configPath = flag.String("config", "", "Path to configuration file (default: configs/config.yaml)")
+	var configPath = flag.String("config", "", "Path to configuration file (default: $OLLAMACHAT_CONFIG, else configs/config.yaml)")
 	var logLevel = flag.String("log-level", "", "Log level (debug, info, warn, error)")
 	var storagePath = flag.String("storage", "", "Storage directory path")
+	var storageType = flag.String("storage-type", "", "Storage backend (file, sqlite, memory, sync, s3)")
 	var providerType = flag.String("provider", "", "LLM provider type (ollama)")
 	var baseURL = flag.String("base-url", "", "Base URL for LLM provider")
+	var profile = flag.String("profile", "", "Profile from the config file to apply (\"none\" for no profile)")
+	var sets listFlag
+	flag.Var(&sets, "set", "Set a configuration value, as path=value (repeatable)")
+	var printConfig = flag.Bool("print-config", false, "Print the effective configuration and where each value came from")
 	var version = flag.Bool("version", false, "Show version information")
 	var help = flag.Bool("help", false, "Show help information")
 
@@ -39,8 +50,22 @@ func main() {
 		ConfigPath:   *configPath,
 		LogLevel:     *logLevel,
 		StoragePath:  *storagePath,
+		StorageType:  *storageType,
 		ProviderType: *providerType,
 		BaseURL:      *baseURL,
+		Profile:      *profile,
+		Set:          sets,
+	}
+
+	// Show the merged configuration
+	if *printConfig {
+		layered, err := app.LoadConfig(appConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to load configuration: %v\n", err)
+			os.Exit(1)
+		}
+		layered.Write(os.Stdout)
+		os.Exit(0)
 	}
 
 	// Create and run application
@@ -62,33 +87,93 @@ func main() {
 	}
 }
 
+// listFlag collects the values of a flag given more than once
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func showHelp() {
 	fmt.Println("OllamaChat - A modern chat interface for Ollama")
 	fmt.Println()
 	fmt.Println("Usage:")
 	fmt.Println("  ollamachat [flags]")
+	fmt.Println("  ollamachat <command> [flags]")
 	fmt.Println()
 	fmt.Println("Flags:")
 	fmt.Println("  -config string")
-	fmt.Println("        Path to configuration file (default: configs/config.yaml)")
+	fmt.Println("        Path to configuration file (default: $OLLAMACHAT_CONFIG, else configs/config.yaml)")
 	fmt.Println("  -log-level string")
-	fmt.Println("        Log level: debug, info, warn, error (default: info)")
+	fmt.Println("        Log level: debug, info, warn, error (default: from config, else info)")
 	fmt.Println("  -storage string")
 	fmt.Println("        Storage directory path (default: data)")
+	fmt.Println("  -storage-type string")
+	fmt.Println("        Storage backend: file, sqlite, memory, sync, s3 (default: from config, else file)")
 	fmt.Println("  -provider string")
-	fmt.Println("        LLM provider type: ollama (default: ollama)")
+	fmt.Println("        LLM provider type: ollama, openai (default: from config, else ollama)")
 	fmt.Println("  -base-url string")
-	fmt.Println("        Base URL for LLM provider (default: http://localhost:11434)")
+	fmt.Println("        Base URL of the selected LLM provider (default: http://localhost:11434)")
+	fmt.Println("  -profile string")
+	fmt.Println("        Apply a named profile from the config file; \"none\" ignores app.profile")
+	fmt.Println("  -set path=value")
+	fmt.Println("        Set any configuration value, such as -set ui.theme=dark (repeatable)")
+	fmt.Println("  -print-config")
+	fmt.Println("        Print the effective configuration and where each value came from")
 	fmt.Println("  -version")
 	fmt.Println("        Show version information")
 	fmt.Println("  -help")
 	fmt.Println("        Show this help message")
 	fmt.Println()
+	fmt.Println("Configuration precedence: defaults, then the config file, then the selected profile, then")
+	fmt.Println("OLLAMACHAT_* environment variables (such as OLLAMACHAT_LLM_OLLAMA_BASE_URL for")
+	fmt.Println("llm.ollama.base_url), then flags.")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  migrate-storage")
+	fmt.Println("        Copy a file store into a SQLite database, sync folder or S3 bucket (see: ollamachat migrate-storage -h)")
+	fmt.Println("  bench-storage")
+	fmt.Println("        Measure the I/O of persisting a streamed answer (see: ollamachat bench-storage -h)")
+	fmt.Println("  encrypt-storage")
+	fmt.Println("        Encrypt the chat history with a passphrase (see: ollamachat encrypt-storage -h)")
+	fmt.Println("  change-passphrase")
+	fmt.Println("        Change the passphrase of an encrypted store (see: ollamachat change-passphrase -h)")
+	fmt.Println("  backup")
+	fmt.Println("        Write an archive of all chats and settings (see: ollamachat backup -h)")
+	fmt.Println("  restore")
+	fmt.Println("        Restore an archive, merging with or replacing the stored data (see: ollamachat restore -h)")
+	fmt.Println("  export")
+	fmt.Println("        Export sessions as Markdown, HTML, JSON or JSONL (see: ollamachat export -h)")
+	fmt.Println("  import")
+	fmt.Println("        Import conversations from ChatGPT, Open WebUI or Ollama CLI transcripts (see: ollamachat import -h)")
+	fmt.Println("  sync-status")
+	fmt.Println("        List the devices sharing a sync folder and the conflicts between them (see: ollamachat sync-status -h)")
+	fmt.Println("  usage")
+	fmt.Println("        Report the space taken by each session and by the storage (see: ollamachat usage -h)")
+	fmt.Println("  validate-config")
+	fmt.Println("        Check the config file and list every problem, or print its JSON Schema (see: ollamachat validate-config -h)")
+	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  ollamachat")
 	fmt.Println("  ollamachat -config custom-config.yaml")
 	fmt.Println("  ollamachat -log-level debug -storage /tmp/chat-data")
 	fmt.Println("  ollamachat -base-url http://192.168.1.100:11434")
+	fmt.Println("  ollamachat -profile office")
+	fmt.Println("  OLLAMACHAT_UI_THEME=dark ollamachat -set llm.embeddings.enabled=true -print-config")
+	fmt.Println("  ollamachat migrate-storage -from data -to data/ollamachat.db")
+	fmt.Println("  ollamachat encrypt-storage -storage data")
+	fmt.Println("  ollamachat backup -storage data -o chats.zip")
+	fmt.Println("  ollamachat restore -storage data -mode merge -i chats.zip")
+	fmt.Println("  ollamachat export -storage data -format html -o chats.html")
+	fmt.Println("  ollamachat import -storage data -i conversations.json")
+	fmt.Println("  ollamachat sync-status -storage ~/Dropbox/ollamachat")
+	fmt.Println("  ollamachat usage -storage data -n 10")
+	fmt.Println("  ollamachat validate-config -config configs/config.yaml")
 	fmt.Println()
 	fmt.Println("For more information, visit: https://github.com/ashprao/ollamachat")
 }
@@ -2,25 +2,38 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log/slog"
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/theme"
 
+	"github.com/ashprao/ollamachat/internal/backup"
 	"github.com/ashprao/ollamachat/internal/config"
 	"github.com/ashprao/ollamachat/internal/constants"
 	"github.com/ashprao/ollamachat/internal/llm"
+	"github.com/ashprao/ollamachat/internal/retention"
+	"github.com/ashprao/ollamachat/internal/semantic"
 	"github.com/ashprao/ollamachat/internal/storage"
 	"github.com/ashprao/ollamachat/internal/ui"
 	"github.com/ashprao/ollamachat/pkg/logger"
 )
 
-// CustomTheme wraps the default theme to apply custom font size
+// CustomTheme wraps the default theme to apply custom font size and a fixed light or
+// dark variant
 type CustomTheme struct {
 	fyne.Theme
 	fontSize int
+	variant  *fyne.ThemeVariant // Nil to follow the system
 }
 
 // Size returns the custom font size for text
@@ -34,23 +47,50 @@ func (t *CustomTheme) Size(name fyne.ThemeSizeName) float32 {
 	return t.Theme.Size(name)
 }
 
-// NewCustomTheme creates a theme with custom font size
-func NewCustomTheme(fontSize int) fyne.Theme {
-	return &CustomTheme{
+// Color returns the color for the configured variant
+func (t *CustomTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant) color.Color {
+	if t.variant != nil {
+		variant = *t.variant
+	}
+	return t.Theme.Color(name, variant)
+}
+
+// NewCustomTheme creates a theme with custom font size and the named variant:
+// "light", "dark", or "auto" to follow the system
+func NewCustomTheme(fontSize int, name string) fyne.Theme {
+	t := &CustomTheme{
 		Theme:    theme.DefaultTheme(),
 		fontSize: fontSize,
 	}
+	switch name {
+	case "light":
+		variant := theme.VariantLight
+		t.variant = &variant
+	case "dark":
+		variant := theme.VariantDark
+		t.variant = &variant
+	}
+	return t
 }
 
 // App represents the main application container with all dependencies
 type App struct {
 	// Core dependencies
 	config          *config.Config
-	configPath      string // Add config path for saving
+	layers          *config.Layered // Where each value of config came from, for saving
+	appConfig       AppConfig       // Flags the configuration is reloaded with
 	logger          *logger.Logger
 	provider        llm.Provider
 	providerFactory *llm.DefaultProviderFactory
+	providerType    string
 	storage         storage.Storage
+	storagePath     string
+	storageType     string
+	semantic        *semantic.Indexer // Nil unless embeddings are enabled
+	backups         *backup.Scheduler // Nil unless scheduled backups are enabled
+	retention       *retention.Scheduler
+	configWatcher   *config.Watcher // Nil if the config file cannot be watched
+	configMu        sync.Mutex      // Serializes changes to the configuration, provider and storage
 
 	// UI components
 	fyneApp fyne.App
@@ -69,68 +109,109 @@ type AppConfig struct {
 	ConfigPath   string
 	LogLevel     string
 	StoragePath  string
+	StorageType  string
 	ProviderType string
 	BaseURL      string
+	Profile      string   // Named profile from the config file, or config.NoProfile
+	Set          []string // "path=value" overrides of any configuration value
 }
 
-// New creates a new application instance with all dependencies
-func New(appConfig AppConfig) (*App, error) {
-	// Initialize logger first
-	logLevel := appConfig.LogLevel
-	if logLevel == "" {
-		logLevel = "info"
+// LoadConfig merges the configuration for appConfig from the defaults, the config file,
+// OLLAMACHAT_* environment variables and the command-line flags, in that order of precedence
+func LoadConfig(appConfig AppConfig) (*config.Layered, error) {
+	var flags []config.Override
+	add := func(flag, path, value string) {
+		if value != "" {
+			flags = append(flags, config.Override{Flag: flag, Path: path, Value: value})
+		}
+	}
+	add("-log-level", "app.log_level", appConfig.LogLevel)
+	add("-storage-type", "storage.type", appConfig.StorageType)
+	add("-provider", "llm.provider", appConfig.ProviderType)
+	add("-profile", "app.profile", appConfig.Profile)
+	for _, set := range appConfig.Set {
+		path, value, ok := strings.Cut(set, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid -set %q: use path=value", set)
+		}
+		add("-set", strings.TrimSpace(path), value)
 	}
 
-	// Convert string log level to slog.Level
-	var level slog.Level
-	switch logLevel {
-	case "debug":
-		level = slog.LevelDebug
-	case "info":
-		level = slog.LevelInfo
-	case "warn":
-		level = slog.LevelWarn
-	case "error":
-		level = slog.LevelError
-	default:
-		level = slog.LevelInfo
+	layered, err := config.Load(config.LoadOptions{Path: appConfig.ConfigPath, Flags: flags})
+	if err != nil {
+		return nil, err
 	}
 
-	logger := logger.NewLogger(level)
+	// The base URL belongs to whichever provider is selected
+	if appConfig.BaseURL != "" {
+		provider := layered.LLM.Provider
+		if provider != "ollama" && provider != "openai" {
+			return nil, fmt.Errorf("-base-url does not apply to provider %q", provider)
+		}
+		err := layered.Override(config.Override{Flag: "-base-url", Path: "llm." + provider + ".base_url", Value: appConfig.BaseURL})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return layered, nil
+}
 
-	logger.Info("Starting application initialization")
+// storageBase returns the storage directory given on the command line, or the default
+func (c AppConfig) storageBase() string {
+	if c.StoragePath == "" {
+		return "data" // Default storage path
+	}
+	return c.StoragePath
+}
 
-	// Load configuration
-	configPath := appConfig.ConfigPath
-	if configPath == "" {
-		configPath = "configs/config.yaml"
+// createStorage creates the storage backend cfg selects, with its data in path, and
+// returns it with its type
+func createStorage(fyneApp fyne.App, logger *logger.Logger, cfg *config.Config, path string) (storage.Storage, string, error) {
+	storageType := cfg.Storage.Type
+	if storageType == "" {
+		storageType = "file"
 	}
 
-	cfg, err := config.LoadConfig(configPath)
+	storageFactory := storage.NewDefaultFileStorageFactory(fyneApp, logger)
+	stor, err := storageFactory.CreateStorage(storage.StorageConfig{
+		Type:     storageType,
+		BasePath: path,
+		Settings: map[string]interface{}{
+			"path":       cfg.Storage.Path,
+			"device":     cfg.Storage.Sync.Device,
+			"endpoint":   cfg.Storage.S3.Endpoint,
+			"bucket":     cfg.Storage.S3.Bucket,
+			"prefix":     cfg.Storage.S3.Prefix,
+			"region":     cfg.Storage.S3.Region,
+			"access_key": cfg.Storage.S3.AccessKey,
+			"secret_key": cfg.Storage.S3.SecretKey,
+		},
+	})
+	return stor, storageType, err
+}
+
+// New creates a new application instance with all dependencies
+func New(appConfig AppConfig) (*App, error) {
+	layered, err := LoadConfig(appConfig)
 	if err != nil {
-		logger.Warn("Failed to load config, creating default", "error", err)
-		// Create a default config structure
-		cfg = &config.Config{
-			App: config.AppConfig{
-				Name:     "OllamaChat",
-				Version:  "1.0.0",
-				LogLevel: "info",
-			},
-			LLM: config.LLMConfig{
-				Provider: constants.DefaultProvider,
-				Ollama: config.OllamaConfig{
-					BaseURL:      "http://localhost:11434",
-					DefaultModel: constants.DefaultModelName,
-				},
-			},
-			UI: config.UIConfig{
-				WindowWidth:  constants.DefaultWindowWidth, // Increased from 600 to accommodate session sidebar
-				WindowHeight: constants.DefaultWindowHeight,
-			},
-		}
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
 	}
+	cfg := layered.Config
 
-	logger.Info("Configuration loaded", "config_path", configPath)
+	// Initialize logger first
+	logger := logger.NewLogger(cfg.GetLogLevel())
+
+	logger.Info("Starting application initialization")
+	if layered.FileError != nil {
+		logger.Warn("Failed to load config file, using defaults", "path", layered.Path, "error", layered.FileError)
+	}
+	for _, name := range layered.UnknownEnv {
+		logger.Warn("Ignoring unknown configuration variable", "name", name)
+	}
+	logger.Info("Configuration loaded", "config_path", layered.Path)
+	if name := cfg.ActiveProfile(); name != "" {
+		logger.Info("Using profile", "profile", name)
+	}
 
 	// Create Fyne app
 	fyneApp := app.NewWithID("github.com.ashprao.ollamachat")
@@ -140,38 +221,30 @@ func New(appConfig AppConfig) (*App, error) {
 		float32(cfg.UI.WindowHeight),
 	))
 
-	// Initialize storage
-	storagePath := appConfig.StoragePath
-	if storagePath == "" {
-		storagePath = "data" // Default storage path
-	}
-
-	storageFactory := storage.NewDefaultFileStorageFactory(fyneApp, logger)
-	storageConfig := storage.StorageConfig{
-		Type:     "file",
-		BasePath: storagePath,
-	}
-
-	stor, err := storageFactory.CreateStorage(storageConfig)
+	// Initialize storage; a profile may keep its sessions in a directory of its own
+	storagePath := cfg.ProfileStoragePath(appConfig.storageBase())
+	stor, storageType, err := createStorage(fyneApp, logger, cfg, storagePath)
 	if err != nil {
+		if errors.Is(err, storage.ErrStorageLocked) {
+			logger.Error("Data directory is in use by another instance", "path", storagePath, "error", err)
+			return nil, err
+		}
 		logger.Error("Failed to create storage", "error", err)
 		return nil, fmt.Errorf("failed to create storage: %w", err)
 	}
 
-	logger.Info("Storage initialized", "type", "file", "path", storagePath)
+	logger.Info("Storage initialized", "type", storageType, "path", storagePath)
 
 	// Initialize LLM provider factory
 	providerFactory := llm.NewDefaultProviderFactory(cfg, logger)
 
-	// Get provider type from config or command line
-	providerType := appConfig.ProviderType
-	if providerType == "" {
-		providerType = cfg.LLM.Provider
-	}
+	// The -provider flag, if given, has already been applied to the config
+	providerType := cfg.LLM.Provider
 
 	// Validate provider configuration
 	if err := providerFactory.ValidateProviderConfig(providerType); err != nil {
 		logger.Error("Provider configuration validation failed", "provider", providerType, "error", err)
+		stor.Close()
 		return nil, fmt.Errorf("provider configuration validation failed: %w", err)
 	}
 
@@ -179,6 +252,7 @@ func New(appConfig AppConfig) (*App, error) {
 	provider, err := providerFactory.CreateProviderFromConfig(providerType)
 	if err != nil {
 		logger.Error("Failed to create LLM provider", "provider", providerType, "error", err)
+		stor.Close()
 		return nil, fmt.Errorf("failed to create LLM provider: %w", err)
 	}
 
@@ -190,31 +264,39 @@ func New(appConfig AppConfig) (*App, error) {
 		logger.Warn("Storage ping failed", "error", err)
 	}
 
+	// Refuse to open a data directory written by a newer version of the app
+	if _, err := stor.LoadAppPreferences(ctx); errors.Is(err, storage.ErrNewerSchema) {
+		logger.Error("Storage schema is newer than this application supports", "path", storagePath, "error", err)
+		stor.Close()
+		return nil, fmt.Errorf("cannot open data directory %s: %w", storagePath, err)
+	}
+
 	// Create app instance first (without chatUI)
 	app := &App{
 		config:          cfg,
-		configPath:      configPath, // Store config path for saving
+		layers:          layered,
+		appConfig:       appConfig,
 		logger:          logger,
 		provider:        provider,
 		providerFactory: providerFactory,
+		providerType:    providerType,
 		storage:         stor,
+		storagePath:     storagePath,
+		storageType:     storageType,
 		fyneApp:         fyneApp,
 		window:          window,
 	}
 
-	// Create ChatUI with provider information and app reference
-	chatUI := ui.NewChatUI(window, provider, stor, logger, providerFactory.GetAvailableProviders(), providerType, cfg, app)
-
-	// Set the chatUI in the app
-	app.chatUI = chatUI
-
-	// Apply font size from config
-	if cfg.UI.FontSize > 0 {
-		// Set custom theme with font size
-		fyneApp.Settings().SetTheme(NewCustomTheme(cfg.UI.FontSize))
-		logger.Info("Applied custom font size", "size", cfg.UI.FontSize)
+	// An encrypted store is opened once its passphrase is entered at startup
+	if storageType != "memory" && storage.IsEncrypted(storagePath) {
+		logger.Info("Storage is encrypted, waiting for passphrase", "path", storagePath)
+	} else {
+		app.openStorage(nil)
 	}
 
+	// Apply font size and theme from config
+	app.applyTheme()
+
 	logger.Info("Application initialization completed successfully")
 	return app, nil
 }
@@ -227,27 +309,208 @@ func (a *App) Run() error {
 
 	a.logger.Info("Starting application")
 	a.isRunning = true
+	a.watchConfig()
+
+	if a.chatUI == nil {
+		// The chat UI starts once the passphrase unlocks the store
+		a.window.Show()
+		a.promptPassphrase()
+	} else {
+		// Initialize the chat UI
+		if err := a.startChatUI(); err != nil {
+			return err
+		}
+
+		// Show window and run the application
+		a.window.Show()
+		a.showQuarantineNotice()
+	}
+	a.fyneApp.Run()
+
+	a.isRunning = false
+	return nil
+}
+
+// openStorage wraps the storage with encryption (when cipher is set) and the search
+// indexes, and creates the chat UI on top of it
+func (a *App) openStorage(cipher *storage.Cipher) {
+	stor := a.storage
+	indexDir := a.storagePath
+	if a.storageType == "memory" {
+		indexDir = ""
+	}
+	// Indexes of a synced folder are per device, so they are kept out of it
+	if cached, ok := stor.(interface{ CacheDir() string }); ok {
+		indexDir = cached.CacheDir()
+	}
+	if cipher != nil {
+		stor = storage.NewEncryptedStorage(stor, cipher, a.logger)
+		// The indexes hold message text in the clear, so they stay in memory
+		indexDir = ""
+	}
+
+	// Keep a full-text index of messages for the sidebar search
+	stor = storage.NewIndexedStorage(stor, indexDir, a.logger)
+
+	// Embed chat history for semantic search; embeddings always come from Ollama
+	if a.config.LLM.Embeddings.Enabled {
+		embedder := llm.NewOllamaProviderWithTimeout(a.config.LLM.Ollama.BaseURL, constants.EmbeddingTimeoutSeconds, a.logger)
+		a.semantic = semantic.NewIndexer(stor, embedder, a.config.LLM.Embeddings.Model, indexDir, a.logger)
+		a.logger.Info("Semantic search enabled", "model", a.config.LLM.Embeddings.Model)
+	}
+	a.storage = stor
+
+	// Apply the retention rules before the sessions are listed
+	a.retention = retention.NewScheduler(stor, a.retentionPolicy(), a.logger)
+	if _, err := a.retention.RunNow(context.Background()); err != nil {
+		a.logger.Error("Failed to apply retention rules", "error", err)
+	}
+
+	if a.config.Storage.Backup.Enabled {
+		a.startBackups(cipher)
+	}
+
+	// Create ChatUI with provider information and app reference
+	a.chatUI = ui.NewChatUI(a.window, a.provider, stor, a.logger, a.providerFactory.GetAvailableProviders(), a.providerType, a.config, a)
+	if a.semantic != nil {
+		a.chatUI.SetSemanticIndex(a.semantic)
+	}
 
-	// Initialize the chat UI
+	a.retention.OnApply(a.onRetentionApplied)
+	a.retention.Start()
+}
+
+// startBackups schedules backup archives of the storage. Archives of an encrypted
+// store are encrypted with the same key and passphrase.
+func (a *App) startBackups(cipher *storage.Cipher) {
+	opts := backup.WriteOptions{AppVersion: a.config.App.Version}
+	if cipher != nil {
+		keyFile, err := os.ReadFile(filepath.Join(a.storagePath, storage.KeyFile))
+		if err != nil {
+			a.logger.Error("Scheduled backups disabled: failed to read key file", "error", err)
+			return
+		}
+		opts.Cipher = cipher
+		opts.KeyFile = keyFile
+	}
+
+	cfg := a.config.Storage.Backup
+	a.backups = backup.NewScheduler(a.storage, cfg.Dir, a.config.BackupInterval(), a.config.BackupKeep(), opts, a.logger)
+	a.backups.Start()
+	a.logger.Info("Scheduled backups enabled", "dir", cfg.Dir, "interval", a.config.BackupInterval(), "keep", a.config.BackupKeep())
+}
+
+// retentionPolicy returns the configured retention rules
+func (a *App) retentionPolicy() retention.Policy {
+	rules := a.config.Storage.Retention
+	return retention.Policy{
+		MaxSessions:    rules.MaxSessions,
+		MaxAge:         time.Duration(rules.MaxAgeDays) * 24 * time.Hour,
+		MaxBytes:       int64(rules.MaxSizeMB) << 20,
+		TrashRetention: a.config.TrashRetention(),
+	}
+}
+
+// onRetentionApplied drops the sessions a scheduled retention run removed from the UI.
+// Scheduled runs call it in the background, so the UI is updated on its event path.
+func (a *App) onRetentionApplied(result retention.Result) {
+	ids := make([]string, len(result.Removed))
+	for i, removal := range result.Removed {
+		ids[i] = removal.ID
+	}
+	chatUI := a.chatUI
+	if chatUI == nil {
+		return
+	}
+	ui.RunOnUI(a.window, func() { chatUI.SessionsRemoved(ids) })
+}
+
+// UpdateRetention applies changed retention rules from the configuration right away
+// and schedules them in place of the old ones
+func (a *App) UpdateRetention() error {
+	if a.retention == nil {
+		return nil
+	}
+	a.retention.Stop()
+
+	a.retention = retention.NewScheduler(a.storage, a.retentionPolicy(), a.logger)
+	a.retention.OnApply(a.onRetentionApplied)
+	result, err := a.retention.RunNow(context.Background())
+	a.retention.Start()
+	if len(result.Removed) > 0 {
+		a.onRetentionApplied(result)
+	}
+	return err
+}
+
+// StorageUsage measures the space taken by the stored sessions and the storage files
+func (a *App) StorageUsage(ctx context.Context) (storage.Usage, error) {
+	var paths []string
+	if a.storageType != "memory" {
+		paths = append(paths, a.storagePath)
+		if a.storageType == "sqlite" && a.config.Storage.Path != "" {
+			path := a.config.Storage.Path
+			paths = append(paths, path, path+"-wal", path+"-shm")
+		}
+	}
+	return storage.MeasureUsage(ctx, a.storage, paths...)
+}
+
+// startChatUI loads sessions into the chat UI and shows it in the window
+func (a *App) startChatUI() error {
 	if err := a.chatUI.Initialize(); err != nil {
 		a.logger.Error("Failed to initialize chat UI", "error", err)
 		return fmt.Errorf("failed to initialize chat UI: %w", err)
 	}
 
 	a.logger.Info("Chat UI initialized successfully")
+	return nil
+}
 
-	// Show window and run the application
-	a.window.Show()
-	a.fyneApp.Run()
+// showQuarantineNotice tells the user about stored files that were found corrupt at startup
+func (a *App) showQuarantineNotice() {
+	stor := a.storage
+	for {
+		wrapped, ok := stor.(interface{ Unwrap() storage.Storage })
+		if !ok {
+			break
+		}
+		stor = wrapped.Unwrap()
+	}
+	reporter, ok := stor.(interface{ QuarantinedFiles() []string })
+	if !ok || len(reporter.QuarantinedFiles()) == 0 {
+		return
+	}
 
-	a.isRunning = false
-	return nil
+	files := reporter.QuarantinedFiles()
+	a.logger.Warn("Corrupt files were moved to quarantine", "files", files)
+	dialog.ShowInformation("Damaged Files Recovered",
+		fmt.Sprintf("%d damaged file(s) could not be read and were moved to the quarantine folder in the data directory:\n\n%s",
+			len(files), strings.Join(files, "\n")),
+		a.window)
 }
 
 // Shutdown gracefully shuts down the application
 func (a *App) Shutdown() error {
 	a.logger.Info("Shutting down application")
 
+	if a.configWatcher != nil {
+		a.configWatcher.Close()
+	}
+
+	if a.backups != nil {
+		a.backups.Stop()
+	}
+	if a.retention != nil {
+		a.retention.Stop()
+	}
+
+	if a.semantic != nil {
+		if err := a.semantic.Close(); err != nil {
+			a.logger.Error("Failed to close semantic index", "error", err)
+		}
+	}
+
 	if a.storage != nil {
 		if err := a.storage.Close(); err != nil {
 			a.logger.Error("Failed to close storage", "error", err)
@@ -301,6 +564,9 @@ func (a *App) IsRunning() bool {
 
 // SwitchProvider switches to a different LLM provider
 func (a *App) SwitchProvider(providerType string) error {
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+
 	a.logger.Info("Switching LLM provider", "from", a.provider.GetName(), "to", providerType)
 
 	// Validate the new provider configuration
@@ -341,83 +607,309 @@ func (a *App) GetCurrentProviderType() string {
 	return a.config.LLM.Provider
 }
 
-// ReloadConfigFromFile reloads configuration from file and updates current settings
+// ReloadConfigFromFile reloads configuration from file and updates current settings.
+// A configuration that fails to load or validate is rejected and the current one kept.
 func (a *App) ReloadConfigFromFile(configPath string) error {
 	a.logger.Info("Reloading configuration from file", "path", configPath)
 
-	// Load new configuration
-	newConfig, err := config.ReloadConfig(configPath)
-	if err != nil {
-		a.logger.Error("Failed to reload configuration", "error", err)
+	opts := a.appConfig
+	opts.ConfigPath = configPath
+	if err := a.applyConfig(opts); err != nil {
 		return fmt.Errorf("failed to reload configuration: %w", err)
 	}
+	return nil
+}
 
-	// Validate new configuration
-	if err := newConfig.ValidateConfig(); err != nil {
+// GetProfiles returns the names of the profiles in the config file
+func (a *App) GetProfiles() []string {
+	return a.config.ProfileNames()
+}
+
+// GetCurrentProfile returns the name of the profile in use, or "" for none
+func (a *App) GetCurrentProfile() string {
+	return a.config.ActiveProfile()
+}
+
+// SwitchProfile applies the named profile over the config file, or none when name
+// is empty, for the rest of this run. The provider is recreated for the profile's
+// settings, and the chat history reopened if the profile keeps its sessions apart.
+func (a *App) SwitchProfile(name string) error {
+	a.logger.Info("Switching profile", "from", a.config.ActiveProfile(), "to", name)
+
+	opts := a.appConfig
+	opts.Profile = name
+	if name == "" {
+		opts.Profile = config.NoProfile
+	}
+	if err := a.applyConfig(opts); err != nil {
+		return fmt.Errorf("failed to switch profile: %w", err)
+	}
+	a.logger.Info("Switched profile", "profile", a.config.ActiveProfile())
+	return nil
+}
+
+// applyConfig loads the configuration for opts and applies it to the running app.
+// Nothing is changed if it fails to load or validate, or the provider or storage it
+// selects cannot be created. Changed settings that need a restart are reported.
+func (a *App) applyConfig(opts AppConfig) error {
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+
+	// Load new configuration, with the environment and flags applied over it again
+	layered, err := LoadConfig(opts)
+	if err == nil {
+		err = layered.FileError
+	}
+	if err != nil {
+		a.logger.Error("Failed to load configuration", "error", err)
+		return err
+	}
+	newConfig := layered.Config
+
+	// Validate new configuration, locating each problem in the file
+	if err := layered.Validate(); err != nil {
 		a.logger.Error("New configuration validation failed", "error", err)
 		return fmt.Errorf("configuration validation failed: %w", err)
 	}
 
+	// Create the provider for changed provider settings before applying anything
+	var newProvider llm.Provider
+	if providerSettingsChanged(a.config, newConfig) {
+		factory := llm.NewDefaultProviderFactory(newConfig, a.logger)
+		if err := factory.ValidateProviderConfig(newConfig.LLM.Provider); err != nil {
+			a.logger.Error("Provider configuration validation failed", "provider", newConfig.LLM.Provider, "error", err)
+			return fmt.Errorf("provider configuration validation failed: %w", err)
+		}
+		newProvider, err = factory.CreateProviderFromConfig(newConfig.LLM.Provider)
+		if err != nil {
+			a.logger.Error("Failed to create new provider", "provider", newConfig.LLM.Provider, "error", err)
+			return fmt.Errorf("failed to create provider: %w", err)
+		}
+	}
+
+	// Open the storage for a new location before applying anything; sessions cannot
+	// move while a response is being written to them. The open storage holds its
+	// directory, so other storage settings for the same directory need a restart.
+	var newStorage storage.Storage
+	newStorageType := a.storageType
+	newStoragePath := newConfig.ProfileStoragePath(opts.storageBase())
+	var needRestart []string
+	if newStoragePath == a.storagePath && storageSettingsChanged(a.config, newConfig) {
+		a.logger.Warn("Storage settings changed; restart the app to apply them", "path", newStoragePath)
+		needRestart = append(needRestart, "storage")
+	}
+
+	// MCP servers are only started with the app
+	if !reflect.DeepEqual(a.config.MCP, newConfig.MCP) {
+		a.logger.Warn("MCP configuration changed; restart the app to apply it",
+			"enabled", newConfig.MCP.Enabled, "servers", len(newConfig.MCP.Servers))
+		needRestart = append(needRestart, "MCP")
+	}
+	if newStoragePath != a.storagePath {
+		if a.chatUI != nil && a.chatUI.Busy() {
+			return fmt.Errorf("wait for the current response to finish before changing the storage")
+		}
+		newStorage, newStorageType, err = createStorage(a.fyneApp, a.logger, newConfig, newStoragePath)
+		if err != nil {
+			a.logger.Error("Failed to create storage", "path", newStoragePath, "error", err)
+			return fmt.Errorf("failed to create storage: %w", err)
+		}
+	}
+
+	// Update the configuration in place, so that everything holding it sees the change
+	old := *a.config
+	*a.config = *newConfig
+	layered.Config = a.config
+	a.layers = layered
+	a.appConfig = opts
+
 	// Update logger level if it changed
-	if newConfig.App.LogLevel != a.config.App.LogLevel {
-		a.logger.Info("Updating log level", "old", a.config.App.LogLevel, "new", newConfig.App.LogLevel)
-		// Note: Logger level change would require recreating the logger
-		// For now, we'll just log the change
+	if a.config.App.LogLevel != old.App.LogLevel {
+		a.logger.Info("Updating log level", "old", old.App.LogLevel, "new", a.config.App.LogLevel)
+		a.logger.SetLevel(a.config.GetLogLevel())
 	}
 
 	// Update window size if it changed
-	if newConfig.UI.WindowWidth != a.config.UI.WindowWidth ||
-		newConfig.UI.WindowHeight != a.config.UI.WindowHeight {
+	if a.config.UI.WindowWidth != old.UI.WindowWidth ||
+		a.config.UI.WindowHeight != old.UI.WindowHeight {
 		a.logger.Info("Updating window size",
-			"old_size", fmt.Sprintf("%dx%d", a.config.UI.WindowWidth, a.config.UI.WindowHeight),
-			"new_size", fmt.Sprintf("%dx%d", newConfig.UI.WindowWidth, newConfig.UI.WindowHeight))
+			"old_size", fmt.Sprintf("%dx%d", old.UI.WindowWidth, old.UI.WindowHeight),
+			"new_size", fmt.Sprintf("%dx%d", a.config.UI.WindowWidth, a.config.UI.WindowHeight))
 		a.window.Resize(fyne.NewSize(
-			float32(newConfig.UI.WindowWidth),
-			float32(newConfig.UI.WindowHeight),
+			float32(a.config.UI.WindowWidth),
+			float32(a.config.UI.WindowHeight),
 		))
 	}
 
-	// Check if provider changed
-	if newConfig.LLM.Provider != a.config.LLM.Provider {
-		a.logger.Info("Provider configuration changed",
-			"old", a.config.LLM.Provider,
-			"new", newConfig.LLM.Provider)
+	// Update font size and theme if they changed
+	if a.config.UI.FontSize != old.UI.FontSize || a.config.UI.Theme != old.UI.Theme {
+		a.applyTheme()
+	}
 
-		// Switch to new provider
-		if err := a.SwitchProvider(newConfig.LLM.Provider); err != nil {
-			a.logger.Error("Failed to switch to new provider", "error", err)
-			return fmt.Errorf("failed to switch provider: %w", err)
+	// Switch to the provider created for the new settings
+	if newProvider != nil {
+		a.logger.Info("Provider configuration changed", "old", old.LLM.Provider, "new", a.config.LLM.Provider)
+		a.provider = newProvider
+		a.providerType = a.config.LLM.Provider
+		if a.chatUI != nil && newStorage == nil {
+			a.chatUI.UpdateProvider(newProvider)
 		}
 	}
 
-	// Update the configuration
-	a.config = newConfig
+	// Show the chat history of the new storage, with a chat UI built for the new settings
+	if newStorage != nil {
+		a.replaceStorage(newStorage, newStoragePath, newStorageType)
+		a.logger.Info("Configuration applied")
+		a.showRestartNotice(needRestart)
+		return nil
+	}
+
+	// Rebuild the semantic index for a new embedding model
+	if a.semantic != nil && a.config.LLM.Embeddings.Enabled && a.config.LLM.Embeddings.Model != old.LLM.Embeddings.Model {
+		a.semantic.SetModel(a.config.LLM.Embeddings.Model)
+	}
 
 	// Update ChatUI with new config
 	if a.chatUI != nil {
-		a.chatUI.UpdateConfig(newConfig)
+		a.chatUI.UpdateConfig(a.config)
+		if a.config.UI.SidebarWidth != old.UI.SidebarWidth {
+			a.chatUI.UpdateSidebarWidth(a.config.UI.SidebarWidth)
+		}
+		if a.config.UI.ShowTimestamps != old.UI.ShowTimestamps {
+			a.chatUI.RefreshMessageDisplay()
+		}
+	}
+
+	// Apply changed retention rules
+	if a.config.Storage.Retention != old.Storage.Retention || a.config.Storage.Trash != old.Storage.Trash {
+		if err := a.UpdateRetention(); err != nil {
+			a.logger.Error("Failed to apply retention rules", "error", err)
+		}
 	}
 
-	a.logger.Info("Configuration reloaded successfully")
+	a.logger.Info("Configuration applied")
+	a.showRestartNotice(needRestart)
 	return nil
 }
 
+// showRestartNotice tells the user that the changed settings in the named sections
+// only take effect when the app is restarted
+func (a *App) showRestartNotice(sections []string) {
+	if len(sections) == 0 {
+		return
+	}
+	dialog.ShowInformation("Restart Required",
+		fmt.Sprintf("The other changes are in effect, but the changed %s settings are only applied when the app is restarted.",
+			strings.Join(sections, " and ")),
+		a.window)
+}
+
+// providerSettingsChanged reports whether the LLM provider must be recreated to
+// apply newConfig
+func providerSettingsChanged(oldConfig, newConfig *config.Config) bool {
+	return oldConfig.LLM.Provider != newConfig.LLM.Provider ||
+		oldConfig.LLM.Ollama != newConfig.LLM.Ollama ||
+		oldConfig.LLM.OpenAI != newConfig.LLM.OpenAI ||
+		!reflect.DeepEqual(oldConfig.LLM.Eino, newConfig.LLM.Eino) ||
+		!reflect.DeepEqual(oldConfig.LLM.Settings, newConfig.LLM.Settings)
+}
+
+// storageSettingsChanged reports whether the storage must be reopened to apply newConfig
+func storageSettingsChanged(oldConfig, newConfig *config.Config) bool {
+	return oldConfig.Storage.Type != newConfig.Storage.Type ||
+		oldConfig.Storage.Path != newConfig.Storage.Path ||
+		oldConfig.Storage.Sync != newConfig.Storage.Sync ||
+		oldConfig.Storage.S3 != newConfig.Storage.S3
+}
+
+// replaceStorage closes the open storage and shows the chat history in stor instead.
+// An encrypted store is opened once its passphrase is entered.
+func (a *App) replaceStorage(stor storage.Storage, path, storageType string) {
+	a.logger.Info("Reopening chat history", "type", storageType, "path", path)
+
+	if a.backups != nil {
+		a.backups.Stop()
+		a.backups = nil
+	}
+	if a.retention != nil {
+		a.retention.Stop()
+		a.retention = nil
+	}
+	if a.semantic != nil {
+		if err := a.semantic.Close(); err != nil {
+			a.logger.Error("Failed to close semantic index", "error", err)
+		}
+		a.semantic = nil
+	}
+	if err := a.storage.Close(); err != nil {
+		a.logger.Error("Failed to close storage", "error", err)
+	}
+
+	a.storage, a.storagePath, a.storageType = stor, path, storageType
+	a.chatUI = nil
+	if storageType != "memory" && storage.IsEncrypted(path) {
+		a.promptPassphrase()
+		return
+	}
+	a.openStorage(nil)
+	if err := a.startChatUI(); err != nil {
+		dialog.ShowError(err, a.window)
+		return
+	}
+	a.showQuarantineNotice()
+}
+
+// watchConfig reloads the configuration whenever its file is changed outside the app.
+// A change that cannot be applied is reported and the current configuration kept.
+// The watcher only notices the change; it is applied on the UI event path, since it
+// may replace the provider, the storage and the chat UI.
+func (a *App) watchConfig() {
+	watcher, err := config.NewWatcher(a.layers.Path, func() {
+		ui.RunOnUI(a.window, a.reloadWatchedConfig)
+	})
+	if err != nil {
+		a.logger.Warn("Configuration hot reload is unavailable", "path", a.layers.Path, "error", err)
+		return
+	}
+	a.configWatcher = watcher
+	a.logger.Info("Watching configuration file for changes", "path", a.layers.Path)
+}
+
+// reloadWatchedConfig applies the config file after it changed outside the app
+func (a *App) reloadWatchedConfig() {
+	path := a.layers.Path
+	if err := a.ReloadConfigFromFile(path); err != nil {
+		dialog.ShowInformation("Configuration Not Applied",
+			fmt.Sprintf("The changes to %s were not applied and the current settings remain in effect:\n\n%v",
+				path, err),
+			a.window)
+	}
+}
+
 // SaveConfig saves the current configuration to file
 func (a *App) SaveConfig() error {
-	a.logger.Info("Saving configuration to file", "path", a.configPath)
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
 
-	if err := a.config.SaveConfig(a.configPath); err != nil {
+	a.logger.Info("Saving configuration to file", "path", a.layers.Path)
+
+	if err := a.layers.Save(); err != nil {
 		a.logger.Error("Failed to save configuration", "error", err)
 		return fmt.Errorf("failed to save configuration: %w", err)
 	}
 
+	// The saved file is already in effect
+	if a.configWatcher != nil {
+		a.configWatcher.Sync()
+	}
+
 	a.logger.Info("Configuration saved successfully")
 	return nil
 }
 
 // GetConfigPath returns the current config file path
 func (a *App) GetConfigPath() string {
-	return a.configPath
+	return a.layers.Path
 }
 
 // UpdateWindowSize updates the window size immediately
@@ -430,7 +922,13 @@ func (a *App) UpdateWindowSize(width, height int) {
 func (a *App) UpdateFontSize(fontSize int) {
 	a.logger.Info("Updating font size", "size", fontSize)
 	if fontSize > 0 {
-		a.fyneApp.Settings().SetTheme(NewCustomTheme(fontSize))
+		a.fyneApp.Settings().SetTheme(NewCustomTheme(fontSize, a.config.UI.Theme))
 		a.logger.Info("Applied new font size theme", "size", fontSize)
 	}
 }
+
+// applyTheme applies the configured font size and theme variant
+func (a *App) applyTheme() {
+	a.fyneApp.Settings().SetTheme(NewCustomTheme(a.config.UI.FontSize, a.config.UI.Theme))
+	a.logger.Info("Applied theme", "font_size", a.config.UI.FontSize, "theme", a.config.UI.Theme)
+}
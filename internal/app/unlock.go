@@ -0,0 +1,63 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ashprao/ollamachat/internal/storage"
+)
+
+// promptPassphrase asks for the storage passphrase until it unlocks the store, then
+// starts the chat UI. Choosing Quit closes the application.
+func (a *App) promptPassphrase() {
+	entry := widget.NewPasswordEntry()
+	entry.SetPlaceHolder("Storage passphrase")
+
+	form := dialog.NewForm("Unlock Chat History", "Unlock", "Quit",
+		[]*widget.FormItem{widget.NewFormItem("Passphrase", entry)},
+		func(confirmed bool) {
+			if !confirmed {
+				a.logger.Info("Passphrase entry cancelled, quitting")
+				a.fyneApp.Quit()
+				return
+			}
+			a.unlockStorage(entry.Text)
+		}, a.window)
+	form.Resize(fyne.NewSize(400, 160))
+	entry.OnSubmitted = func(string) { form.Submit() }
+	form.Show()
+	a.window.Canvas().Focus(entry)
+}
+
+// unlockStorage opens the encrypted store with passphrase and starts the chat UI,
+// asking again if the passphrase is wrong
+func (a *App) unlockStorage(passphrase string) {
+	cipher, err := storage.UnlockKey(a.storagePath, passphrase)
+	if err != nil {
+		a.logger.Warn("Failed to unlock storage", "path", a.storagePath, "error", err)
+		if errors.Is(err, storage.ErrWrongPassphrase) {
+			err = fmt.Errorf("the passphrase is not correct")
+		}
+		errDialog := dialog.NewError(err, a.window)
+		errDialog.SetOnClosed(a.promptPassphrase)
+		errDialog.Show()
+		return
+	}
+
+	a.logger.Info("Storage unlocked", "path", a.storagePath)
+	if cipher.Legacy() {
+		a.logger.Warn("Encrypted values are not bound to their place in each session; run 'ollamachat encrypt-storage' to upgrade the store", "path", a.storagePath)
+	}
+	a.openStorage(cipher)
+	if err := a.startChatUI(); err != nil {
+		errDialog := dialog.NewError(err, a.window)
+		errDialog.SetOnClosed(a.fyneApp.Quit)
+		errDialog.Show()
+		return
+	}
+	a.showQuarantineNotice()
+}
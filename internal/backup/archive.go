@@ -0,0 +1,405 @@
+// Package backup writes and restores archives of everything the application stores:
+// chat sessions, personas, prompt templates, preferences, MCP servers and agent
+// settings. An archive is a zip file with a manifest listing every file with its
+// SHA-256 checksum and the schema version of each document kind.
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/storage"
+)
+
+// FormatName identifies backup archives in their manifest
+const FormatName = "ollamachat-backup"
+
+// FormatVersion is the archive layout version this build writes
+const FormatVersion = 1
+
+// ManifestFile is the archive entry describing the archive
+const ManifestFile = "manifest.json"
+
+// keyFileEntry holds the passphrase-protected key of an encrypted archive
+const keyFileEntry = storage.KeyFile
+
+// maxEntrySize bounds the size of a single archive entry when reading
+const maxEntrySize = 1 << 30
+
+// Archive entries holding single documents
+const (
+	preferencesEntry = "preferences.json"
+	mcpServersEntry  = "mcp_servers.json"
+	agentConfigEntry = "agent_config.json"
+)
+
+// ErrChecksumMismatch is returned when an archive entry does not match its manifest
+var ErrChecksumMismatch = errors.New("archive entry does not match its checksum")
+
+// Manifest describes the content of a backup archive
+type Manifest struct {
+	Format         string                       `json:"format"`
+	FormatVersion  int                          `json:"format_version"`
+	CreatedAt      time.Time                    `json:"created_at"`
+	AppVersion     string                       `json:"app_version,omitempty"`
+	Encrypted      bool                         `json:"encrypted"` // Entries are sealed with the key in encryption.json
+	SchemaVersions map[storage.DocumentKind]int `json:"schema_versions"`
+	Counts         Counts                       `json:"counts"`
+	Files          []FileEntry                  `json:"files"`
+}
+
+// Counts records how many items an archive holds
+type Counts struct {
+	Sessions  int `json:"sessions"`
+	Messages  int `json:"messages"`
+	Personas  int `json:"personas"`
+	Templates int `json:"templates"`
+}
+
+// FileEntry is a file in the archive with its checksum
+type FileEntry struct {
+	Path   string               `json:"path"`
+	Kind   storage.DocumentKind `json:"kind"`
+	Size   int64                `json:"size"`
+	SHA256 string               `json:"sha256"`
+}
+
+// WriteOptions controls how an archive is written
+type WriteOptions struct {
+	AppVersion string
+
+	// Cipher and KeyFile encrypt the archive with the data key of an encrypted store.
+	// KeyFile is the store's key file, kept in the archive so the passphrase unlocks it.
+	Cipher  *storage.Cipher
+	KeyFile []byte
+}
+
+// Archive is a backup archive read into memory and checked against its manifest
+type Archive struct {
+	Manifest Manifest
+	files    map[string][]byte
+	keyFile  []byte
+	cipher   *storage.Cipher
+}
+
+// Write writes an archive of everything in src to w
+func Write(ctx context.Context, w io.Writer, src storage.Storage, opts WriteOptions) (Manifest, error) {
+	manifest := Manifest{
+		Format:         FormatName,
+		FormatVersion:  FormatVersion,
+		CreatedAt:      time.Now().UTC(),
+		AppVersion:     opts.AppVersion,
+		Encrypted:      opts.Cipher != nil,
+		SchemaVersions: storage.CurrentSchemaVersions,
+	}
+	if manifest.Encrypted && len(opts.KeyFile) == 0 {
+		return manifest, fmt.Errorf("encrypted archives need the key file")
+	}
+
+	zw := zip.NewWriter(w)
+	add := func(path string, kind storage.DocumentKind, v interface{}) error {
+		data, err := storage.EncodeDocument(kind, v)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", path, err)
+		}
+		if opts.Cipher != nil {
+			sealed, err := opts.Cipher.Seal(string(data), path)
+			if err != nil {
+				return err
+			}
+			data = []byte(sealed)
+		}
+		return addEntry(zw, &manifest, path, kind, data)
+	}
+
+	sessions, err := src.ListChatSessions(ctx)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	for _, session := range sessions {
+		if err := add("sessions/"+session.ID+".json", storage.KindSession, session); err != nil {
+			return manifest, err
+		}
+		manifest.Counts.Sessions++
+		manifest.Counts.Messages += len(session.Messages)
+	}
+
+	personas, err := src.ListPersonas(ctx)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to list personas: %w", err)
+	}
+	for _, persona := range personas {
+		if err := add("personas/"+persona.ID+".json", storage.KindPersona, persona); err != nil {
+			return manifest, err
+		}
+		manifest.Counts.Personas++
+	}
+
+	templates, err := src.ListPromptTemplates(ctx)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to list prompt templates: %w", err)
+	}
+	for _, template := range templates {
+		if err := add("templates/"+template.ID+".json", storage.KindTemplate, template); err != nil {
+			return manifest, err
+		}
+		manifest.Counts.Templates++
+	}
+
+	prefs, err := src.LoadAppPreferences(ctx)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to load preferences: %w", err)
+	}
+	if err := add(preferencesEntry, storage.KindPreferences, prefs); err != nil {
+		return manifest, err
+	}
+
+	servers, err := src.LoadMCPServers(ctx)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to load MCP servers: %w", err)
+	}
+	if err := add(mcpServersEntry, storage.KindMCPServers, servers); err != nil {
+		return manifest, err
+	}
+
+	agentConfig, err := src.LoadAgentConfig(ctx)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to load agent config: %w", err)
+	}
+	if err := add(agentConfigEntry, storage.KindAgentConfig, agentConfig); err != nil {
+		return manifest, err
+	}
+
+	if manifest.Encrypted {
+		if err := addEntry(zw, &manifest, keyFileEntry, "", opts.KeyFile); err != nil {
+			return manifest, err
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	mw, err := createEntry(zw, ManifestFile, manifest.CreatedAt)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if _, err := mw.Write(data); err != nil {
+		return manifest, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return manifest, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return manifest, nil
+}
+
+// WriteFile writes an archive of everything in src to path, replacing it only once
+// the archive is complete
+func WriteFile(ctx context.Context, path string, src storage.Storage, opts WriteOptions) (Manifest, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return Manifest{}, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".backup-*.tmp")
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	manifest, err := Write(ctx, tmp, src, opts)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return manifest, err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return manifest, fmt.Errorf("failed to save backup file: %w", err)
+	}
+	return manifest, nil
+}
+
+// addEntry stores one file in the archive and records it in the manifest
+func addEntry(zw *zip.Writer, manifest *Manifest, path string, kind storage.DocumentKind, data []byte) error {
+	w, err := createEntry(zw, path, manifest.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", path, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", path, err)
+	}
+
+	sum := sha256.Sum256(data)
+	manifest.Files = append(manifest.Files, FileEntry{
+		Path:   path,
+		Kind:   kind,
+		Size:   int64(len(data)),
+		SHA256: hex.EncodeToString(sum[:]),
+	})
+	return nil
+}
+
+// createEntry starts a compressed archive entry dated modified
+func createEntry(zw *zip.Writer, name string, modified time.Time) (io.Writer, error) {
+	return zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+}
+
+// OpenFile reads the archive at path and checks every file against the manifest
+func OpenFile(path string) (*Archive, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup archive: %w", err)
+	}
+	defer r.Close()
+	return read(&r.Reader)
+}
+
+// Open reads an archive from r and checks every file against the manifest
+func Open(r io.ReaderAt, size int64) (*Archive, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup archive: %w", err)
+	}
+	return read(zr)
+}
+
+// read loads the manifest and the files it lists, verifying their checksums
+func read(zr *zip.Reader) (*Archive, error) {
+	entries := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		entries[f.Name] = f
+	}
+
+	manifestEntry, ok := entries[ManifestFile]
+	if !ok {
+		return nil, fmt.Errorf("not a backup archive: %s is missing", ManifestFile)
+	}
+	data, err := readEntry(manifestEntry)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if manifest.Format != FormatName {
+		return nil, fmt.Errorf("not a backup archive: unknown format %q", manifest.Format)
+	}
+	if manifest.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("backup archive version %d is newer than supported version %d; please upgrade the application", manifest.FormatVersion, FormatVersion)
+	}
+	for kind, version := range manifest.SchemaVersions {
+		if current, known := storage.CurrentSchemaVersions[kind]; known && version > current {
+			return nil, &storage.SchemaVersionError{Kind: kind, Version: version, Current: current}
+		}
+	}
+
+	archive := &Archive{Manifest: manifest, files: make(map[string][]byte, len(manifest.Files))}
+	for _, file := range manifest.Files {
+		entry, ok := entries[file.Path]
+		if !ok {
+			return nil, fmt.Errorf("backup archive is incomplete: %s is missing", file.Path)
+		}
+		data, err := readEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		if int64(len(data)) != file.Size || hex.EncodeToString(sum[:]) != file.SHA256 {
+			return nil, fmt.Errorf("%s: %w", file.Path, ErrChecksumMismatch)
+		}
+
+		if file.Path == keyFileEntry {
+			archive.keyFile = data
+			continue
+		}
+		archive.files[file.Path] = data
+	}
+	if manifest.Encrypted && archive.keyFile == nil {
+		return nil, fmt.Errorf("encrypted backup archive has no %s", keyFileEntry)
+	}
+	return archive, nil
+}
+
+// readEntry reads one archive entry, refusing oversized ones
+func readEntry(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(rc, maxEntrySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	if n > maxEntrySize {
+		return nil, fmt.Errorf("archive entry %s is too large", f.Name)
+	}
+	return buf.Bytes(), nil
+}
+
+// Encrypted reports whether the archive must be unlocked before it is restored
+func (a *Archive) Encrypted() bool {
+	return a.Manifest.Encrypted
+}
+
+// Unlock opens an encrypted archive with the passphrase of the store it was taken from
+func (a *Archive) Unlock(passphrase string) error {
+	if !a.Encrypted() {
+		return nil
+	}
+	cipher, err := storage.UnlockKeyData(a.keyFile, passphrase)
+	if err != nil {
+		return err
+	}
+	a.cipher = cipher
+	return nil
+}
+
+// decode decodes the document stored at path into v, upgrading older schema versions
+func (a *Archive) decode(path string, kind storage.DocumentKind, v interface{}) error {
+	data := a.files[path]
+	if a.Encrypted() {
+		if a.cipher == nil {
+			return fmt.Errorf("backup archive is encrypted; unlock it first")
+		}
+		plaintext, err := a.cipher.Open(string(data), path)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", path, err)
+		}
+		data = []byte(plaintext)
+	}
+	if err := storage.DecodeDocument(kind, data, v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// paths returns the archived files of one kind, in a stable order
+func (a *Archive) paths(kind storage.DocumentKind) []string {
+	var paths []string
+	for _, file := range a.Manifest.Files {
+		if file.Kind == kind {
+			paths = append(paths, file.Path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
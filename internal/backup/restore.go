@@ -0,0 +1,409 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/storage"
+)
+
+// Mode selects how a restore treats what is already stored
+type Mode string
+
+const (
+	// ModeMerge adds the archive to what is stored. Items whose ID is taken by a
+	// different item are restored under a new ID; local settings are kept.
+	ModeMerge Mode = "merge"
+
+	// ModeReplace deletes every stored session, persona and template, including the
+	// trash, and restores the archive with its settings in their place. A backup of
+	// what is stored is written first.
+	ModeReplace Mode = "replace"
+)
+
+// safetyPrefix starts the file names of the backups taken before a replace. They are
+// not scheduled backups, so rotation leaves them alone.
+const safetyPrefix = "ollamachat-pre-restore-"
+
+// SafetyBackupName returns the file name of the backup taken before a replace at t
+func SafetyBackupName(t time.Time) string {
+	return safetyPrefix + t.UTC().Format(archiveTimeLayout) + ".zip"
+}
+
+// RestoreOptions controls what Restore does besides restoring the archive
+type RestoreOptions struct {
+	// SafetyDir receives a backup of the stored data before a replace deletes it.
+	// It is required for ModeReplace.
+	SafetyDir string
+
+	// Write is used for the safety backup, so that of an encrypted store stays encrypted
+	Write WriteOptions
+}
+
+// RestoreReport summarizes what Restore changed
+type RestoreReport struct {
+	Sessions   int
+	Personas   int
+	Templates  int
+	MCPServers int
+	Settings   bool     // Preferences and agent settings were replaced
+	Unchanged  int      // Items already stored with the same content
+	Renamed    []string // Items restored under a new ID, as "kind old-id -> new-id"
+	Removed    int      // Items deleted before a replace
+	Skipped    []string // Items that could not be restored, with the reason
+
+	SafetyBackup string // Backup of the data stored before a replace
+}
+
+// contents is the decoded content of an archive
+type contents struct {
+	sessions    []models.ChatSession
+	personas    []models.Persona
+	templates   []models.PromptTemplate
+	prefs       storage.AppPreferences
+	servers     []models.MCPServer
+	agentConfig models.AgentConfig
+}
+
+// Restore writes the content of archive into dst. Every document is decoded before
+// dst is changed, so a damaged or locked archive leaves dst untouched. A replace
+// first backs up dst into opts.SafetyDir, and names that backup in its errors.
+func Restore(ctx context.Context, dst storage.Storage, archive *Archive, mode Mode, opts RestoreOptions) (report RestoreReport, err error) {
+	if mode != ModeMerge && mode != ModeReplace {
+		return report, fmt.Errorf("unknown restore mode %q (use %q or %q)", mode, ModeMerge, ModeReplace)
+	}
+	if mode == ModeReplace && opts.SafetyDir == "" {
+		return report, fmt.Errorf("a replace requires a directory for the backup of the stored data")
+	}
+
+	c, err := archive.decodeAll()
+	if err != nil {
+		return report, err
+	}
+
+	if mode == ModeReplace {
+		path := filepath.Join(opts.SafetyDir, SafetyBackupName(time.Now()))
+		if _, err := WriteFile(ctx, path, dst, opts.Write); err != nil {
+			return report, fmt.Errorf("failed to back up the stored data before replacing it: %w", err)
+		}
+		report.SafetyBackup = path
+		defer func() {
+			if err != nil {
+				err = fmt.Errorf("%w (the data stored before the restore is in %s)", err, path)
+			}
+		}()
+
+		if report.Removed, err = clearStorage(ctx, dst); err != nil {
+			return report, err
+		}
+	}
+
+	personaIDs, err := restorePersonas(ctx, dst, c.personas, &report)
+	if err != nil {
+		return report, err
+	}
+	if err := restoreTemplates(ctx, dst, c.templates, &report); err != nil {
+		return report, err
+	}
+	if err := restoreSessions(ctx, dst, c.sessions, personaIDs, &report); err != nil {
+		return report, err
+	}
+
+	if mode == ModeReplace {
+		if err := dst.SaveAppPreferences(ctx, c.prefs); err != nil {
+			return report, fmt.Errorf("failed to restore preferences: %w", err)
+		}
+		if err := dst.SaveAgentConfig(ctx, c.agentConfig); err != nil {
+			return report, fmt.Errorf("failed to restore agent config: %w", err)
+		}
+		if err := dst.SaveMCPServers(ctx, c.servers); err != nil {
+			return report, fmt.Errorf("failed to restore MCP servers: %w", err)
+		}
+		report.Settings = true
+		report.MCPServers = len(c.servers)
+		return report, nil
+	}
+
+	// Merging keeps local settings and adds MCP servers not configured here
+	if report.MCPServers, err = mergeMCPServers(ctx, dst, c.servers); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// decodeAll decodes every document in the archive. IDs become file names in some
+// storages, so an archive holding an ID that models.NewID could not have made is refused.
+func (a *Archive) decodeAll() (contents, error) {
+	var c contents
+	for _, path := range a.paths(storage.KindSession) {
+		var session models.ChatSession
+		if err := a.decode(path, storage.KindSession, &session); err != nil {
+			return c, err
+		}
+		if !models.IsValidID(session.ID) {
+			return c, fmt.Errorf("%s holds an invalid session ID: %q", path, session.ID)
+		}
+		c.sessions = append(c.sessions, session)
+	}
+	for _, path := range a.paths(storage.KindPersona) {
+		var persona models.Persona
+		if err := a.decode(path, storage.KindPersona, &persona); err != nil {
+			return c, err
+		}
+		if !models.IsValidID(persona.ID) {
+			return c, fmt.Errorf("%s holds an invalid persona ID: %q", path, persona.ID)
+		}
+		c.personas = append(c.personas, persona)
+	}
+	for _, path := range a.paths(storage.KindTemplate) {
+		var template models.PromptTemplate
+		if err := a.decode(path, storage.KindTemplate, &template); err != nil {
+			return c, err
+		}
+		if !models.IsValidID(template.ID) {
+			return c, fmt.Errorf("%s holds an invalid template ID: %q", path, template.ID)
+		}
+		c.templates = append(c.templates, template)
+	}
+
+	c.prefs = storage.NewDefaultAppPreferences()
+	if _, ok := a.files[preferencesEntry]; ok {
+		if err := a.decode(preferencesEntry, storage.KindPreferences, &c.prefs); err != nil {
+			return c, err
+		}
+	}
+	if _, ok := a.files[mcpServersEntry]; ok {
+		if err := a.decode(mcpServersEntry, storage.KindMCPServers, &c.servers); err != nil {
+			return c, err
+		}
+	}
+	c.agentConfig = storage.NewDefaultAgentConfig()
+	if _, ok := a.files[agentConfigEntry]; ok {
+		if err := a.decode(agentConfigEntry, storage.KindAgentConfig, &c.agentConfig); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+// clearStorage deletes every session, trashed session, persona and template in s
+func clearStorage(ctx context.Context, s storage.Storage) (int, error) {
+	removed := 0
+
+	page, err := s.ListSessionSummaries(ctx, storage.SessionListOptions{})
+	if err != nil {
+		return removed, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	for _, summary := range page.Sessions {
+		if err := s.DeleteChatSession(ctx, summary.ID); err != nil {
+			return removed, fmt.Errorf("failed to delete session %s: %w", summary.ID, err)
+		}
+		removed++
+	}
+
+	purged, err := s.PurgeTrash(ctx, time.Now().Add(time.Minute))
+	removed += purged
+	if err != nil {
+		return removed, fmt.Errorf("failed to empty trash: %w", err)
+	}
+
+	personas, err := s.ListPersonas(ctx)
+	if err != nil {
+		return removed, fmt.Errorf("failed to list personas: %w", err)
+	}
+	for _, persona := range personas {
+		if err := s.DeletePersona(ctx, persona.ID); err != nil {
+			return removed, fmt.Errorf("failed to delete persona %s: %w", persona.ID, err)
+		}
+		removed++
+	}
+
+	templates, err := s.ListPromptTemplates(ctx)
+	if err != nil {
+		return removed, fmt.Errorf("failed to list prompt templates: %w", err)
+	}
+	for _, template := range templates {
+		if err := s.DeletePromptTemplate(ctx, template.ID); err != nil {
+			return removed, fmt.Errorf("failed to delete template %s: %w", template.ID, err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// restorePersonas saves archived personas and returns the new IDs of those restored
+// under a different ID
+func restorePersonas(ctx context.Context, dst storage.Storage, personas []models.Persona, report *RestoreReport) (map[string]string, error) {
+	stored, err := dst.ListPersonas(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list personas: %w", err)
+	}
+	existing := make(map[string]models.Persona, len(stored))
+	for _, persona := range stored {
+		existing[persona.ID] = persona
+	}
+
+	newIDs := make(map[string]string)
+	for _, persona := range personas {
+		if current, taken := existing[persona.ID]; taken {
+			if samePersona(current, persona) {
+				report.Unchanged++
+				continue
+			}
+			newID := models.NewIDAt(persona.CreatedAt)
+			report.Renamed = append(report.Renamed, fmt.Sprintf("persona %s -> %s", persona.ID, newID))
+			newIDs[persona.ID] = newID
+			persona.ID = newID
+		}
+		if err := dst.SavePersona(ctx, persona); err != nil {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("persona %s: %v", persona.ID, err))
+			continue
+		}
+		report.Personas++
+	}
+	return newIDs, nil
+}
+
+// restoreTemplates saves archived prompt templates
+func restoreTemplates(ctx context.Context, dst storage.Storage, templates []models.PromptTemplate, report *RestoreReport) error {
+	stored, err := dst.ListPromptTemplates(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list prompt templates: %w", err)
+	}
+	existing := make(map[string]models.PromptTemplate, len(stored))
+	for _, template := range stored {
+		existing[template.ID] = template
+	}
+
+	for _, template := range templates {
+		if current, taken := existing[template.ID]; taken {
+			if sameTemplate(current, template) {
+				report.Unchanged++
+				continue
+			}
+			newID := models.NewIDAt(template.CreatedAt)
+			report.Renamed = append(report.Renamed, fmt.Sprintf("template %s -> %s", template.ID, newID))
+			template.ID = newID
+		}
+		if err := dst.SavePromptTemplate(ctx, template); err != nil {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("template %s: %v", template.ID, err))
+			continue
+		}
+		report.Templates++
+	}
+	return nil
+}
+
+// restoreSessions stores archived sessions, keeping their timestamps where dst allows
+// and pointing them at the new IDs of renamed personas
+func restoreSessions(ctx context.Context, dst storage.Storage, sessions []models.ChatSession, personaIDs map[string]string, report *RestoreReport) error {
+	page, err := dst.ListSessionSummaries(ctx, storage.SessionListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+	live := make(map[string]bool, len(page.Sessions))
+	for _, summary := range page.Sessions {
+		live[summary.ID] = true
+	}
+	// A trashed session still holds its ID
+	trashed, err := dst.ListTrashedSessions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list trashed sessions: %w", err)
+	}
+	inTrash := make(map[string]bool, len(trashed))
+	for _, item := range trashed {
+		inTrash[item.ID] = true
+	}
+
+	importer, preservesTimestamps := dst.(storage.SessionImporter)
+	for _, session := range sessions {
+		if newID, ok := personaIDs[session.PersonaID]; ok {
+			session.PersonaID = newID
+		}
+
+		if live[session.ID] || inTrash[session.ID] {
+			if live[session.ID] {
+				current, err := dst.LoadChatSession(ctx, session.ID)
+				if err == nil && sameSession(current, session) {
+					report.Unchanged++
+					continue
+				}
+			}
+			newID := models.NewIDAt(session.CreatedAt)
+			report.Renamed = append(report.Renamed, fmt.Sprintf("session %s -> %s", session.ID, newID))
+			session.ID = newID
+		}
+
+		if preservesTimestamps {
+			err = importer.ImportChatSession(ctx, session)
+		} else {
+			err = dst.SaveChatSession(ctx, session)
+		}
+		if err != nil {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("session %s: %v", session.ID, err))
+			continue
+		}
+		report.Sessions++
+	}
+	return nil
+}
+
+// mergeMCPServers adds archived MCP servers whose names are not configured in dst
+func mergeMCPServers(ctx context.Context, dst storage.Storage, servers []models.MCPServer) (int, error) {
+	stored, err := dst.LoadMCPServers(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load MCP servers: %w", err)
+	}
+	names := make(map[string]bool, len(stored))
+	for _, server := range stored {
+		names[strings.ToLower(server.Name)] = true
+	}
+
+	added := 0
+	for _, server := range servers {
+		if names[strings.ToLower(server.Name)] {
+			continue
+		}
+		stored = append(stored, server)
+		names[strings.ToLower(server.Name)] = true
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	if err := dst.SaveMCPServers(ctx, stored); err != nil {
+		return 0, fmt.Errorf("failed to save MCP servers: %w", err)
+	}
+	return added, nil
+}
+
+// sameSession reports whether two sessions hold the same conversation
+func sameSession(a, b models.ChatSession) bool {
+	if a.Name != b.Name || a.SystemPrompt != b.SystemPrompt || len(a.Messages) != len(b.Messages) {
+		return false
+	}
+	for i := range a.Messages {
+		if a.Messages[i].Sender != b.Messages[i].Sender || a.Messages[i].Content != b.Messages[i].Content {
+			return false
+		}
+	}
+	return true
+}
+
+// samePersona reports whether two personas have the same content, ignoring timestamps
+func samePersona(a, b models.Persona) bool {
+	a.CreatedAt, a.UpdatedAt = time.Time{}, time.Time{}
+	b.CreatedAt, b.UpdatedAt = time.Time{}, time.Time{}
+	return a == b
+}
+
+// sameTemplate reports whether two templates have the same content, ignoring timestamps
+func sameTemplate(a, b models.PromptTemplate) bool {
+	a.CreatedAt, a.UpdatedAt = time.Time{}, time.Time{}
+	b.CreatedAt, b.UpdatedAt = time.Time{}, time.Time{}
+	return a == b
+}
@@ -0,0 +1,119 @@
+package backup
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/storage"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+// newStore returns a memory storage holding one session with the given name
+func newStore(t *testing.T, name string) (storage.Storage, models.ChatSession) {
+	t.Helper()
+	s := storage.NewMemoryStorage(logger.NewLogger(slog.LevelError))
+	session := models.NewChatSession(name, "llama3.2:latest")
+	session.Messages = []models.ChatMessage{models.NewChatMessage("user", "hello from "+name)}
+	if err := s.SaveChatSession(context.Background(), session); err != nil {
+		t.Fatalf("SaveChatSession: %v", err)
+	}
+	return s, session
+}
+
+// archiveOf writes an archive of s and opens it again
+func archiveOf(t *testing.T, s storage.Storage) *Archive {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	if _, err := WriteFile(context.Background(), path, s, WriteOptions{}); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	archive, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	return archive
+}
+
+func TestReplaceBacksUpStoredData(t *testing.T) {
+	ctx := context.Background()
+	src, restored := newStore(t, "From the archive")
+	dst, previous := newStore(t, "Stored before")
+	safetyDir := t.TempDir()
+
+	report, err := Restore(ctx, dst, archiveOf(t, src), ModeReplace, RestoreOptions{SafetyDir: safetyDir})
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if report.SafetyBackup == "" || filepath.Dir(report.SafetyBackup) != safetyDir {
+		t.Fatalf("safety backup %q not written to %s", report.SafetyBackup, safetyDir)
+	}
+	if _, err := dst.LoadChatSession(ctx, restored.ID); err != nil {
+		t.Errorf("restored session missing: %v", err)
+	}
+	if _, err := dst.LoadChatSession(ctx, previous.ID); err == nil {
+		t.Error("replace kept the session stored before")
+	}
+
+	// The safety backup brings the previous data back
+	safety, err := OpenFile(report.SafetyBackup)
+	if err != nil {
+		t.Fatalf("OpenFile(safety backup): %v", err)
+	}
+	if _, err := Restore(ctx, dst, safety, ModeReplace, RestoreOptions{SafetyDir: safetyDir}); err != nil {
+		t.Fatalf("Restore(safety backup): %v", err)
+	}
+	loaded, err := dst.LoadChatSession(ctx, previous.ID)
+	if err != nil {
+		t.Fatalf("previous session not recovered: %v", err)
+	}
+	if len(loaded.Messages) != 1 || loaded.Messages[0].Content != "hello from Stored before" {
+		t.Errorf("previous session recovered with %+v", loaded.Messages)
+	}
+}
+
+func TestReplaceWithoutSafetyBackupChangesNothing(t *testing.T) {
+	ctx := context.Background()
+	src, _ := newStore(t, "From the archive")
+	dst, previous := newStore(t, "Stored before")
+
+	if _, err := Restore(ctx, dst, archiveOf(t, src), ModeReplace, RestoreOptions{}); err == nil {
+		t.Fatal("expected a replace without a safety directory to fail")
+	}
+
+	// A safety directory that cannot be created stops the replace too
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Restore(ctx, dst, archiveOf(t, src), ModeReplace, RestoreOptions{SafetyDir: filepath.Join(blocker, "backups")}); err == nil {
+		t.Fatal("expected a replace to fail when the safety backup cannot be written")
+	}
+
+	if _, err := dst.LoadChatSession(ctx, previous.ID); err != nil {
+		t.Errorf("stored session deleted by a failed replace: %v", err)
+	}
+}
+
+func TestRestoreRefusesInvalidSessionIDs(t *testing.T) {
+	ctx := context.Background()
+	src := storage.NewMemoryStorage(logger.NewLogger(slog.LevelError))
+	escaping := models.NewChatSession("Escaping", "llama3.2:latest")
+	escaping.ID = "../../escaped"
+	if err := src.SaveChatSession(ctx, escaping); err != nil {
+		t.Fatalf("SaveChatSession: %v", err)
+	}
+	dst, previous := newStore(t, "Stored before")
+
+	for _, mode := range []Mode{ModeMerge, ModeReplace} {
+		if _, err := Restore(ctx, dst, archiveOf(t, src), mode, RestoreOptions{SafetyDir: t.TempDir()}); err == nil {
+			t.Errorf("%s: restored a session with an escaping ID", mode)
+		}
+	}
+	if _, err := dst.LoadChatSession(ctx, previous.ID); err != nil {
+		t.Errorf("stored session deleted by a refused restore: %v", err)
+	}
+}
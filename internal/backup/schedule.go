@@ -0,0 +1,184 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/storage"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+// archivePrefix starts the file names of scheduled backups
+const archivePrefix = "ollamachat-backup-"
+
+// archiveTimeLayout is the UTC time in scheduled backup names; it sorts by age
+const archiveTimeLayout = "20060102-150405"
+
+// firstBackupDelay keeps the first scheduled backup from slowing down startup
+const firstBackupDelay = time.Minute
+
+// retryDelay is the wait before retrying a failed scheduled backup
+const retryDelay = 15 * time.Minute
+
+// ArchiveName returns the file name of a scheduled backup taken at t
+func ArchiveName(t time.Time) string {
+	return archivePrefix + t.UTC().Format(archiveTimeLayout) + ".zip"
+}
+
+// ListArchives returns the paths of the scheduled backups in dir, oldest first
+func ListArchives(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if _, ok := archiveTime(entry.Name()); ok && !entry.IsDir() {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Rotate deletes all but the newest keep scheduled backups in dir and returns the
+// paths it deleted
+func Rotate(dir string, keep int) ([]string, error) {
+	paths, err := ListArchives(dir)
+	if err != nil || keep <= 0 || len(paths) <= keep {
+		return nil, err
+	}
+
+	var removed []string
+	for _, path := range paths[:len(paths)-keep] {
+		if err := os.Remove(path); err != nil {
+			return removed, fmt.Errorf("failed to remove old backup: %w", err)
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// archiveTime returns when a scheduled backup was taken, from its file name
+func archiveTime(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, archivePrefix) || !strings.HasSuffix(name, ".zip") {
+		return time.Time{}, false
+	}
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, archivePrefix), ".zip")
+	t, err := time.Parse(archiveTimeLayout, stamp)
+	return t, err == nil
+}
+
+// Scheduler backs up a storage into a folder whenever the newest backup there is
+// older than the interval, keeping a fixed number of backups
+type Scheduler struct {
+	store    storage.Storage
+	dir      string
+	interval time.Duration
+	keep     int
+	opts     WriteOptions
+	logger   *logger.Logger
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewScheduler creates a scheduler writing backups of store into dir
+func NewScheduler(store storage.Storage, dir string, interval time.Duration, keep int, opts WriteOptions, logger *logger.Logger) *Scheduler {
+	return &Scheduler{
+		store:    store,
+		dir:      dir,
+		interval: interval,
+		keep:     keep,
+		opts:     opts,
+		logger:   logger.WithComponent("backup"),
+	}
+}
+
+// Start runs scheduled backups in the background until Stop is called
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.run(ctx)
+}
+
+// Stop ends scheduled backups, waiting for one in progress to finish
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+	s.cancel = nil
+}
+
+// BackupNow writes a backup and removes the oldest ones beyond the kept count
+func (s *Scheduler) BackupNow(ctx context.Context) (string, error) {
+	path := filepath.Join(s.dir, ArchiveName(time.Now()))
+	manifest, err := WriteFile(ctx, path, s.store, s.opts)
+	if err != nil {
+		return "", err
+	}
+	s.logger.Info("Backup written", "path", path, "sessions", manifest.Counts.Sessions, "encrypted", manifest.Encrypted)
+
+	removed, err := Rotate(s.dir, s.keep)
+	if err != nil {
+		s.logger.Warn("Failed to remove old backups", "dir", s.dir, "error", err)
+	}
+	if len(removed) > 0 {
+		s.logger.Info("Removed old backups", "count", len(removed), "keep", s.keep)
+	}
+	return path, nil
+}
+
+// run waits until a backup is due, takes it and repeats
+func (s *Scheduler) run(ctx context.Context) {
+	defer close(s.done)
+
+	timer := time.NewTimer(s.untilDue())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		next := s.interval
+		if _, err := s.BackupNow(ctx); err != nil {
+			s.logger.Error("Scheduled backup failed", "dir", s.dir, "error", err)
+			if retryDelay < next {
+				next = retryDelay
+			}
+		}
+		timer.Reset(next)
+	}
+}
+
+// untilDue returns how long to wait for the next backup, based on the newest one
+func (s *Scheduler) untilDue() time.Duration {
+	paths, err := ListArchives(s.dir)
+	if err != nil {
+		s.logger.Warn("Failed to list backups", "dir", s.dir, "error", err)
+	}
+	if len(paths) == 0 {
+		return firstBackupDelay
+	}
+
+	last, _ := archiveTime(filepath.Base(paths[len(paths)-1]))
+	wait := s.interval - time.Since(last)
+	if wait < firstBackupDelay {
+		wait = firstBackupDelay
+	}
+	return wait
+}
@@ -5,24 +5,33 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/ashprao/ollamachat/internal/constants"
-	"github.com/ashprao/ollamachat/internal/validation"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	App   AppConfig   `yaml:"app"`
-	LLM   LLMConfig   `yaml:"llm"`
-	UI    UIConfig    `yaml:"ui"`
-	MCP   MCPConfig   `yaml:"mcp"`
-	Agent AgentConfig `yaml:"agent"`
+	App      AppConfig                `yaml:"app"`
+	LLM      LLMConfig                `yaml:"llm"`
+	UI       UIConfig                 `yaml:"ui"`
+	MCP      MCPConfig                `yaml:"mcp"`
+	Agent    AgentConfig              `yaml:"agent"`
+	Storage  StorageConfig            `yaml:"storage"`
+	Profiles map[string]ProfileConfig `yaml:"profiles,omitempty"` // Named setups, such as "home" or "demo"
 }
 
 type AppConfig struct {
 	Name     string `yaml:"name"`
 	Version  string `yaml:"version"`
 	LogLevel string `yaml:"log_level"`
+	Profile  string `yaml:"profile"` // Profile applied over the rest of the file (empty for none)
+}
+
+// ProfileConfig is a named set of settings applied over the rest of the config file
+type ProfileConfig struct {
+	SeparateSessions bool                   `yaml:"separate_sessions,omitempty"` // Keep this profile's sessions in their own storage directory
+	Settings         map[string]interface{} `yaml:",inline"`                     // Settings in the layout of the config file
 }
 
 type LLMConfig struct {
@@ -31,9 +40,21 @@ type LLMConfig struct {
 	Ollama             OllamaConfig           `yaml:"ollama"`
 	OpenAI             OpenAIConfig           `yaml:"openai"`
 	Eino               EinoConfig             `yaml:"eino"`
+	AutoTitle          AutoTitleConfig        `yaml:"auto_title"`
+	Embeddings         EmbeddingsConfig       `yaml:"embeddings"`
 	Settings           map[string]interface{} `yaml:"settings"`
 }
 
+type AutoTitleConfig struct {
+	Enabled bool   `yaml:"enabled"` // Generate session titles after the first exchange
+	Model   string `yaml:"model"`   // Small model used for titles (empty uses the session model)
+}
+
+type EmbeddingsConfig struct {
+	Enabled bool   `yaml:"enabled"` // Embed chat history with Ollama for semantic search
+	Model   string `yaml:"model"`   // Ollama embedding model; changing it rebuilds the index
+}
+
 type OllamaConfig struct {
 	BaseURL      string `yaml:"base_url"`
 	DefaultModel string `yaml:"default_model"`
@@ -60,6 +81,48 @@ type UIConfig struct {
 	SidebarWidth   int    `yaml:"sidebar_width"` // Session sidebar width
 }
 
+type StorageConfig struct {
+	Type      string          `yaml:"type"` // "file", "sqlite", "memory", "sync", "s3"
+	Path      string          `yaml:"path"` // SQLite database file (empty uses ollamachat.db in the storage directory)
+	Trash     TrashConfig     `yaml:"trash"`
+	Retention RetentionConfig `yaml:"retention"`
+	Backup    BackupConfig    `yaml:"backup"`
+	Sync      SyncConfig      `yaml:"sync"`
+	S3        S3Config        `yaml:"s3"`
+}
+
+type SyncConfig struct {
+	Device string `yaml:"device"` // Name of this device's operation log (empty uses the hostname plus a random suffix)
+}
+
+// S3Config locates the bucket of the S3 storage; the storage directory holds its local cache
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"`   // URL of the object store, such as "http://localhost:9000"
+	Bucket    string `yaml:"bucket"`     // Must exist already
+	Prefix    string `yaml:"prefix"`     // Key prefix, such as "alice/", so several users can share a bucket
+	Region    string `yaml:"region"`     // Defaults to "us-east-1"
+	AccessKey string `yaml:"access_key"` // Set via AWS_ACCESS_KEY_ID environment variable
+	SecretKey string `yaml:"secret_key"` // Set via AWS_SECRET_ACCESS_KEY environment variable
+}
+
+type TrashConfig struct {
+	RetentionDays int `yaml:"retention_days"` // Days before deleted sessions are purged (0 uses the default, -1 keeps them until emptied)
+}
+
+// RetentionConfig limits the sessions kept; pinned sessions are never removed
+type RetentionConfig struct {
+	MaxSessions int `yaml:"max_sessions"` // Sessions kept, pinned ones included
+	MaxAgeDays  int `yaml:"max_age_days"` // Days since a session was last updated
+	MaxSizeMB   int `yaml:"max_size_mb"`  // Total size of the sessions and the trash (0 disables each limit)
+}
+
+type BackupConfig struct {
+	Enabled       bool   `yaml:"enabled"`        // Write backup archives on a schedule while the app runs
+	Dir           string `yaml:"dir"`            // Folder receiving the archives
+	IntervalHours int    `yaml:"interval_hours"` // Hours between backups (0 uses the default)
+	Keep          int    `yaml:"keep"`           // Number of archives kept (0 uses the default)
+}
+
 type MCPConfig struct {
 	Enabled bool              `yaml:"enabled"`
 	Servers []MCPServerConfig `yaml:"servers"`
@@ -80,47 +143,33 @@ type AgentConfig struct {
 	Settings     map[string]interface{} `yaml:"settings"`
 }
 
-// LoadConfig loads configuration from the specified file path
+// LoadConfig loads configuration from the specified file path over the defaults, with
+// environment variables applied on top
 func LoadConfig(configPath string) (*Config, error) {
 	if configPath == "" {
-		configPath = "configs/config.yaml"
+		configPath = DefaultConfigPath
 	}
-
-	// Create default config if file doesn't exist
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		if err := createDefaultConfig(configPath); err != nil {
-			return nil, fmt.Errorf("failed to create default config: %w", err)
-		}
-	}
-
-	data, err := os.ReadFile(configPath)
+	layered, err := Load(LoadOptions{Path: configPath})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil, err
 	}
-
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
-	}
-
-	// Override with environment variables
-	if apiKey := os.Getenv("OPENAI_API_KEY"); apiKey != "" {
-		config.LLM.OpenAI.APIKey = apiKey
+	if layered.FileError != nil {
+		return nil, layered.FileError
 	}
-
-	return &config, nil
+	return layered.Config, nil
 }
 
-// createDefaultConfig creates a default configuration file
-func createDefaultConfig(configPath string) error {
-	defaultConfig := &Config{
+// DefaultConfig returns the configuration used for values the config file leaves out
+func DefaultConfig() *Config {
+	return &Config{
 		App: AppConfig{
 			Name:     "OllamaChat",
 			Version:  "1.0.0",
 			LogLevel: "info",
 		},
 		LLM: LLMConfig{
-			Provider: constants.DefaultProvider,
+			Provider:           constants.DefaultProvider,
+			AvailableProviders: []string{constants.DefaultProvider},
 			Ollama: OllamaConfig{
 				BaseURL:      "http://localhost:11434",
 				DefaultModel: constants.DefaultModelName,
@@ -133,6 +182,14 @@ func createDefaultConfig(configPath string) error {
 				DefaultModel: constants.DefaultModelName,
 				Settings:     map[string]string{},
 			},
+			AutoTitle: AutoTitleConfig{
+				Enabled: true,
+				Model:   "",
+			},
+			Embeddings: EmbeddingsConfig{
+				Enabled: false,
+				Model:   constants.DefaultEmbeddingModel,
+			},
 			Settings: map[string]interface{}{
 				"timeout_seconds": constants.DefaultTimeoutSeconds,
 				"max_tokens":      constants.DefaultMaxTokens,
@@ -160,14 +217,28 @@ func createDefaultConfig(configPath string) error {
 				"timeout":        "30s",
 			},
 		},
+		Storage: StorageConfig{
+			Type: "file",
+			Trash: TrashConfig{
+				RetentionDays: constants.DefaultTrashRetentionDays,
+			},
+			Backup: BackupConfig{
+				Enabled:       false,
+				IntervalHours: constants.DefaultBackupIntervalHours,
+				Keep:          constants.DefaultBackupKeep,
+			},
+		},
 	}
+}
 
+// createDefaultConfig creates a default configuration file
+func createDefaultConfig(configPath string) error {
 	// Create directory if it doesn't exist
 	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
 		return err
 	}
 
-	data, err := yaml.Marshal(defaultConfig)
+	data, err := yaml.Marshal(DefaultConfig())
 	if err != nil {
 		return err
 	}
@@ -191,102 +262,34 @@ func (c *Config) GetLogLevel() slog.Level {
 	}
 }
 
-// ValidateConfig validates the entire configuration structure
-func (c *Config) ValidateConfig() error {
-	// Validate App config
-	if c.App.Name == "" {
-		return fmt.Errorf("app.name cannot be empty")
-	}
-	if c.App.Version == "" {
-		return fmt.Errorf("app.version cannot be empty")
-	}
-
-	// Validate LLM config
-	if err := c.ValidateLLMConfig(); err != nil {
-		return fmt.Errorf("LLM config validation failed: %w", err)
-	}
-
-	// Validate UI config
-	if err := c.ValidateUIConfig(); err != nil {
-		return fmt.Errorf("UI config validation failed: %w", err)
+// TrashRetention returns how long deleted sessions stay in the trash, or 0 if they
+// are kept until the trash is emptied
+func (c *Config) TrashRetention() time.Duration {
+	days := c.Storage.Trash.RetentionDays
+	switch {
+	case days < 0:
+		return 0
+	case days == 0:
+		days = constants.DefaultTrashRetentionDays
 	}
-
-	return nil
+	return time.Duration(days) * 24 * time.Hour
 }
 
-// ValidateLLMConfig validates LLM provider configurations
-func (c *Config) ValidateLLMConfig() error {
-	if c.LLM.Provider == "" {
-		return fmt.Errorf("llm.provider cannot be empty")
-	}
-
-	// Validate that the current provider is in available providers
-	found := false
-	for _, provider := range c.LLM.AvailableProviders {
-		if provider == c.LLM.Provider {
-			found = true
-			break
-		}
-	}
-	if !found {
-		return fmt.Errorf("current provider '%s' not found in available_providers", c.LLM.Provider)
-	}
-
-	// Validate provider-specific configurations
-	switch c.LLM.Provider {
-	case "ollama":
-		if c.LLM.Ollama.BaseURL == "" {
-			return fmt.Errorf("ollama.base_url cannot be empty")
-		}
-		if c.LLM.Ollama.DefaultModel == "" {
-			return fmt.Errorf("ollama.default_model cannot be empty")
-		}
-	case "openai":
-		if c.LLM.OpenAI.BaseURL == "" {
-			return fmt.Errorf("openai.base_url cannot be empty")
-		}
-		if c.LLM.OpenAI.DefaultModel == "" {
-			return fmt.Errorf("openai.default_model cannot be empty")
-		}
-		// API key can be from environment variable, so we don't validate it here
-	case "eino":
-		if c.LLM.Eino.DefaultModel == "" {
-			return fmt.Errorf("eino.default_model cannot be empty")
-		}
-	default:
-		return fmt.Errorf("unsupported provider: %s", c.LLM.Provider)
+// BackupInterval returns the time between scheduled backups
+func (c *Config) BackupInterval() time.Duration {
+	hours := c.Storage.Backup.IntervalHours
+	if hours <= 0 {
+		hours = constants.DefaultBackupIntervalHours
 	}
-
-	return nil
+	return time.Duration(hours) * time.Hour
 }
 
-// ValidateUIConfig validates UI configuration values
-func (c *Config) ValidateUIConfig() error {
-	// Validate numeric values
-	if err := validation.ValidateUIValues(
-		c.UI.WindowWidth,
-		c.UI.WindowHeight,
-		c.UI.MaxMessages,
-		c.UI.FontSize,
-		c.UI.SidebarWidth,
-	); err != nil {
-		return err
-	}
-
-	// Validate theme
-	validThemes := []string{"light", "dark", "auto"}
-	themeValid := false
-	for _, theme := range validThemes {
-		if c.UI.Theme == theme {
-			themeValid = true
-			break
-		}
-	}
-	if !themeValid {
-		return fmt.Errorf("ui.theme must be one of: %v", validThemes)
+// BackupKeep returns the number of scheduled backups kept
+func (c *Config) BackupKeep() int {
+	if c.Storage.Backup.Keep <= 0 {
+		return constants.DefaultBackupKeep
 	}
-
-	return nil
+	return c.Storage.Backup.Keep
 }
 
 // ReloadConfig reloads configuration from file without restart
@@ -297,7 +300,7 @@ func ReloadConfig(configPath string) (*Config, error) {
 // SaveConfig saves the current configuration to file
 func (c *Config) SaveConfig(configPath string) error {
 	if configPath == "" {
-		configPath = "configs/config.yaml"
+		configPath = DefaultConfigPath
 	}
 
 	// Create directory if it doesn't exist
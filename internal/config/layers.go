@@ -0,0 +1,442 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the names of the environment variables that set configuration values
+const EnvPrefix = "OLLAMACHAT_"
+
+// EnvConfigPath names the environment variable holding the config file path
+const EnvConfigPath = EnvPrefix + "CONFIG"
+
+// DefaultConfigPath is the config file read when no other is given
+const DefaultConfigPath = "configs/config.yaml"
+
+// Source is the layer a configuration value came from. Later layers override earlier ones.
+type Source int
+
+// Configuration layers, in order of precedence
+const (
+	SourceDefault Source = iota
+	SourceFile
+	SourceProfile
+	SourceEnv
+	SourceFlag
+)
+
+// Origin records where a configuration value came from
+type Origin struct {
+	Source Source
+	Name   string // Config file path, profile, environment variable or flag
+}
+
+// String describes the origin, such as "env OLLAMACHAT_APP_LOG_LEVEL"
+func (o Origin) String() string {
+	switch o.Source {
+	case SourceFile:
+		return "file " + o.Name
+	case SourceProfile:
+		return "profile " + o.Name
+	case SourceEnv:
+		return "env " + o.Name
+	case SourceFlag:
+		return "flag " + o.Name
+	default:
+		return "default"
+	}
+}
+
+// Override sets one configuration value from a command-line flag
+type Override struct {
+	Flag  string // Flag name, such as "-log-level"
+	Path  string // Configuration path, such as "app.log_level"
+	Value string
+}
+
+// LoadOptions selects the layers merged by Load
+type LoadOptions struct {
+	Path    string     // Config file; empty uses $OLLAMACHAT_CONFIG, else DefaultConfigPath
+	Environ []string   // "NAME=value" pairs; nil reads the process environment
+	Flags   []Override // Applied last, in order
+}
+
+// Layered is a configuration merged from defaults, the config file, the selected
+// profile, environment variables and flags, in that order of precedence
+type Layered struct {
+	*Config
+	Path       string            // Config file read
+	FileError  error             // Why the config file was skipped, if it was
+	Sources    map[string]Origin // Where each value came from, by path
+	UnknownEnv []string          // OLLAMACHAT_ variables matching no configuration value
+
+	fileConfig *Config                // Defaults and the config file only
+	root       *yaml.Node             // Parsed config file, for locating values in it
+	overrides  map[string]interface{} // Values set by the profile, environment variables and flags, by path
+}
+
+// field is a configuration value addressed by its path, such as "llm.ollama.base_url"
+type field struct {
+	path  string
+	index []int
+}
+
+// fields lists every configuration value in declaration order
+var fields = collectFields(reflect.TypeOf(Config{}), "", nil)
+
+// collectFields returns the values of a configuration struct, descending into nested structs
+func collectFields(t reflect.Type, prefix string, index []int) []field {
+	var result []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		path := prefix + name
+		idx := append(append([]int(nil), index...), i)
+		if sf.Type.Kind() == reflect.Struct {
+			result = append(result, collectFields(sf.Type, path+".", idx)...)
+			continue
+		}
+		result = append(result, field{path: path, index: idx})
+	}
+	return result
+}
+
+// Paths returns the path of every configuration value
+func Paths() []string {
+	paths := make([]string, len(fields))
+	for i, f := range fields {
+		paths[i] = f.path
+	}
+	return paths
+}
+
+// EnvName returns the environment variable setting the value at path, such as
+// OLLAMACHAT_LLM_OLLAMA_BASE_URL for "llm.ollama.base_url"
+func EnvName(path string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// lookupField returns the configuration value at path
+func lookupField(path string) (field, bool) {
+	for _, f := range fields {
+		if f.path == path {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// isSecret reports whether the value at path is a credential, hidden when printed
+func isSecret(path string) bool {
+	return strings.HasSuffix(path, "api_key") || strings.HasSuffix(path, "access_key") || strings.HasSuffix(path, "secret_key")
+}
+
+// value returns the configuration value of f in c
+func (c *Config) value(f field) reflect.Value {
+	return reflect.ValueOf(c).Elem().FieldByIndex(f.index)
+}
+
+// Set parses raw and stores it at path. Lists are comma-separated; maps and lists of
+// structs are written in YAML flow style, such as "{timeout_seconds: 60}".
+func (c *Config) Set(path, raw string) error {
+	f, ok := lookupField(path)
+	if !ok {
+		return fmt.Errorf("unknown configuration value %q", path)
+	}
+	v := c.value(f)
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%s must be a whole number, got %q", path, raw)
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%s must be true or false, got %q", path, raw)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String {
+			items := []string{}
+			for _, item := range strings.Split(raw, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			v.Set(reflect.ValueOf(items))
+			return nil
+		}
+		fallthrough
+	default:
+		parsed := reflect.New(v.Type())
+		if err := yaml.Unmarshal([]byte(raw), parsed.Interface()); err != nil {
+			return fmt.Errorf("%s must be YAML: %w", path, err)
+		}
+		v.Set(parsed.Elem())
+	}
+	return nil
+}
+
+// Load merges the configuration layers. A config file that does not exist is created
+// with the defaults; one that cannot be read or parsed is skipped and reported in
+// FileError. Invalid environment variables and flags, and an unknown profile, are errors.
+func Load(opts LoadOptions) (*Layered, error) {
+	environ := opts.Environ
+	if environ == nil {
+		environ = os.Environ()
+	}
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			env[name] = value
+		}
+	}
+
+	path := opts.Path
+	if path == "" {
+		path = env[EnvConfigPath]
+	}
+	if path == "" {
+		path = DefaultConfigPath
+	}
+
+	l := &Layered{
+		Config:     DefaultConfig(),
+		Path:       path,
+		Sources:    make(map[string]Origin, len(fields)),
+		fileConfig: DefaultConfig(),
+		overrides:  make(map[string]interface{}),
+	}
+
+	// File layer
+	data, err := readConfigFile(path)
+	if err == nil {
+		err = l.applyFile(data)
+	}
+	if err != nil {
+		l.FileError = err
+		l.Config, l.fileConfig, l.root = DefaultConfig(), DefaultConfig(), nil
+		l.Sources = make(map[string]Origin, len(fields))
+	}
+
+	// Environment layer. The provider-specific variables are read first, so the
+	// OLLAMACHAT_ ones win over them.
+	legacy := []struct{ name, path string }{
+		{"OPENAI_API_KEY", "llm.openai.api_key"},
+		{"AWS_ACCESS_KEY_ID", "storage.s3.access_key"},
+		{"AWS_SECRET_ACCESS_KEY", "storage.s3.secret_key"},
+	}
+	for _, v := range legacy {
+		if value := env[v.name]; value != "" {
+			if err := l.override(v.path, value, Origin{SourceEnv, v.name}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	byEnv := make(map[string]string, len(fields))
+	for _, f := range fields {
+		byEnv[EnvName(f.path)] = f.path
+	}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		if strings.HasPrefix(name, EnvPrefix) && name != EnvConfigPath {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fieldPath, ok := byEnv[name]
+		if !ok {
+			l.UnknownEnv = append(l.UnknownEnv, name)
+			continue
+		}
+		if err := l.override(fieldPath, env[name], Origin{SourceEnv, name}); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+
+	// Flag layer
+	for _, flag := range opts.Flags {
+		if err := l.Override(flag); err != nil {
+			return nil, err
+		}
+	}
+
+	// Profile layer, once the environment or a flag may have selected the profile
+	if err := l.applyProfile(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// readConfigFile reads the config file, creating it with the defaults if it does not exist
+func readConfigFile(path string) ([]byte, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := createDefaultConfig(path); err != nil {
+			return nil, fmt.Errorf("failed to create default config: %w", err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return data, nil
+}
+
+// applyFile decodes the config file over the defaults and records the values it sets
+func (l *Layered) applyFile(data []byte) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if err := doc.Decode(l.Config); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if err := doc.Decode(l.fileConfig); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	if len(doc.Content) > 0 {
+		l.root = doc.Content[0]
+	}
+	for _, f := range fields {
+		if yamlHasPath(l.root, strings.Split(f.path, ".")) {
+			l.Sources[f.path] = Origin{SourceFile, l.Path}
+		}
+	}
+	return nil
+}
+
+// yamlHasPath reports whether a YAML mapping sets the value at the given keys
+func yamlHasPath(node *yaml.Node, keys []string) bool {
+	for _, key := range keys {
+		if _, node = mappingEntry(node, key); node == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Override applies a flag over the loaded layers
+func (l *Layered) Override(flag Override) error {
+	if err := l.override(flag.Path, flag.Value, Origin{SourceFlag, flag.Flag}); err != nil {
+		return fmt.Errorf("invalid %s: %w", flag.Flag, err)
+	}
+	return nil
+}
+
+// override sets the value at path and records where it came from
+func (l *Layered) override(path, raw string, origin Origin) error {
+	if err := l.Config.Set(path, raw); err != nil {
+		return err
+	}
+	f, _ := lookupField(path)
+	l.overrides[path] = l.Config.value(f).Interface()
+	l.Sources[path] = origin
+	return nil
+}
+
+// Origin returns where the value at path came from
+func (l *Layered) Origin(path string) Origin {
+	return l.Sources[path]
+}
+
+// Save writes the configuration to the config file. Values set by the profile,
+// environment variables and flags are written as the file had them, unless they
+// were changed since, so running once with a flag does not make its value permanent.
+func (l *Layered) Save() error {
+	type restore struct {
+		v   reflect.Value
+		old reflect.Value
+	}
+	var restores []restore
+	for path, applied := range l.overrides {
+		f, _ := lookupField(path)
+		v := l.Config.value(f)
+		if !reflect.DeepEqual(v.Interface(), applied) {
+			continue
+		}
+		old := reflect.New(v.Type()).Elem()
+		old.Set(v)
+		v.Set(l.fileConfig.value(f))
+		restores = append(restores, restore{v, old})
+	}
+
+	err := l.Config.SaveConfig(l.Path)
+	for _, r := range restores {
+		r.v.Set(r.old)
+	}
+	return err
+}
+
+// Write prints every configuration value with where it came from. Credentials are hidden.
+func (l *Layered) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "# Config file: %s\n", l.Path)
+	if l.FileError != nil {
+		fmt.Fprintf(tw, "# Skipped: %v\n", l.FileError)
+	}
+	if name := l.ActiveProfile(); name != "" {
+		fmt.Fprintf(tw, "# Profile: %s\n", name)
+	}
+	for _, f := range fields {
+		fmt.Fprintf(tw, "%s\t%s\t(%s)\n", f.path, l.formatValue(f), l.Sources[f.path])
+	}
+	for _, name := range l.UnknownEnv {
+		fmt.Fprintf(tw, "# Ignored unknown variable %s\n", name)
+	}
+	return tw.Flush()
+}
+
+// formatValue renders a value for Write
+func (l *Layered) formatValue(f field) string {
+	v := l.Config.value(f)
+	if isSecret(f.path) && v.String() != "" {
+		return `"********"`
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Int, reflect.Bool:
+		return fmt.Sprint(v.Interface())
+	}
+	data, err := yaml.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Sprint(v.Interface())
+	}
+	// Flow style keeps lists and maps on one line
+	var node yaml.Node
+	if yaml.Unmarshal(data, &node) == nil {
+		setFlowStyle(&node)
+		if flow, err := yaml.Marshal(&node); err == nil {
+			data = flow
+		}
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// setFlowStyle marks every collection in a YAML tree for flow style
+func setFlowStyle(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+		node.Style = yaml.FlowStyle
+	}
+	for _, child := range node.Content {
+		setFlowStyle(child)
+	}
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfigFile writes a config file in a fresh directory and returns its path
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadLayerPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `
+app:
+  log_level: warn
+ui:
+  theme: light
+  font_size: 16
+  window_width: 1000
+`)
+	l, err := Load(LoadOptions{
+		Path: path,
+		Environ: []string{
+			"OLLAMACHAT_UI_FONT_SIZE=18",
+			"OLLAMACHAT_UI_WINDOW_WIDTH=1100",
+		},
+		Flags: []Override{{Flag: "-set", Path: "ui.window_width", Value: "1200"}},
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		want   interface{}
+		source Source
+	}{
+		{"app.name", "OllamaChat", SourceDefault},
+		{"app.log_level", "warn", SourceFile},
+		{"ui.theme", "light", SourceFile},
+		{"ui.font_size", 18, SourceEnv},
+		{"ui.window_width", 1200, SourceFlag},
+	}
+	for _, tt := range tests {
+		f, _ := lookupField(tt.path)
+		if got := l.Config.value(f).Interface(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.path, got, tt.want)
+		}
+		if got := l.Origin(tt.path).Source; got != tt.source {
+			t.Errorf("%s came from %s, want source %d", tt.path, l.Origin(tt.path), tt.source)
+		}
+	}
+	if got := l.Origin("ui.font_size").String(); got != "env OLLAMACHAT_UI_FONT_SIZE" {
+		t.Errorf("origin of ui.font_size = %q", got)
+	}
+}
+
+func TestLoadEnvironment(t *testing.T) {
+	path := writeConfigFile(t, "llm:\n  openai:\n    api_key: from-file\n")
+	l, err := Load(LoadOptions{
+		Path: path,
+		Environ: []string{
+			"OLLAMACHAT_LLM_AVAILABLE_PROVIDERS= ollama, openai ,,",
+			"OLLAMACHAT_UI_SHOW_TIMESTAMPS=true",
+			"OLLAMACHAT_MCP_SERVERS=[{name: fs, command: mcp-fs, enabled: true}]",
+			"OLLAMACHAT_LLM_SETTINGS={timeout_seconds: 60}",
+			"OPENAI_API_KEY=from-legacy",
+			"OLLAMACHAT_LLM_OPENAI_API_KEY=from-prefixed",
+			"OLLAMACHAT_UI_COLOUR=red",
+			"OLLAMACHAT_CONFIG=/ignored/because/path/is/set.yaml",
+			"UNRELATED=1",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if got := l.LLM.AvailableProviders; !reflect.DeepEqual(got, []string{"ollama", "openai"}) {
+		t.Errorf("available_providers = %q", got)
+	}
+	if !l.UI.ShowTimestamps {
+		t.Error("show_timestamps not set")
+	}
+	if len(l.MCP.Servers) != 1 || l.MCP.Servers[0].Command != "mcp-fs" || !l.MCP.Servers[0].Enabled {
+		t.Errorf("mcp.servers = %+v", l.MCP.Servers)
+	}
+	if got := l.LLM.Settings["timeout_seconds"]; got != 60 {
+		t.Errorf("llm.settings = %v", l.LLM.Settings)
+	}
+	// The OLLAMACHAT_ variable wins over the provider's own
+	if l.LLM.OpenAI.APIKey != "from-prefixed" {
+		t.Errorf("api_key = %q, want the OLLAMACHAT_ value", l.LLM.OpenAI.APIKey)
+	}
+	if !reflect.DeepEqual(l.UnknownEnv, []string{"OLLAMACHAT_UI_COLOUR"}) {
+		t.Errorf("unknown variables = %q", l.UnknownEnv)
+	}
+	if l.Path != path {
+		t.Errorf("read %s, want %s", l.Path, path)
+	}
+
+	// The credential is hidden when the configuration is printed
+	var out strings.Builder
+	if err := l.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "from-prefixed") {
+		t.Error("printed configuration shows the API key")
+	}
+}
+
+func TestLoadRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		environ []string
+		flags   []Override
+		want    string
+	}{
+		{"whole number", []string{"OLLAMACHAT_UI_FONT_SIZE=large"}, nil, "OLLAMACHAT_UI_FONT_SIZE"},
+		{"boolean", []string{"OLLAMACHAT_MCP_ENABLED=maybe"}, nil, "must be true or false"},
+		{"YAML", []string{"OLLAMACHAT_LLM_SETTINGS={unclosed"}, nil, "must be YAML"},
+		{"unknown flag path", nil, []Override{{Flag: "-set", Path: "ui.colour", Value: "red"}}, "unknown configuration value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(LoadOptions{Path: writeConfigFile(t, ""), Environ: tt.environ, Flags: tt.flags})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load error = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	// A missing file is created with the defaults, from $OLLAMACHAT_CONFIG
+	path := filepath.Join(t.TempDir(), "sub", "config.yaml")
+	l, err := Load(LoadOptions{Environ: []string{EnvConfigPath + "=" + path}})
+	if err != nil || l.FileError != nil {
+		t.Fatalf("Load = %v, file error %v", err, l.FileError)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("default config file not created: %v", err)
+	}
+
+	// An unparsable file is skipped and reported, leaving the defaults
+	l, err = Load(LoadOptions{Path: writeConfigFile(t, "ui: [not a mapping"), Environ: []string{}})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if l.FileError == nil || l.UI.Theme != "auto" {
+		t.Errorf("file error %v, theme %q; want the file skipped", l.FileError, l.UI.Theme)
+	}
+}
+
+func TestSaveKeepsOverridesOutOfTheFile(t *testing.T) {
+	path := writeConfigFile(t, "ui:\n  theme: light\n")
+	l, err := Load(LoadOptions{
+		Path:    path,
+		Environ: []string{"OLLAMACHAT_UI_THEME=dark"},
+		Flags:   []Override{{Flag: "-log-level", Path: "app.log_level", Value: "debug"}},
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// A value changed since loading is saved; the overridden ones are not
+	l.UI.FontSize = 20
+	if err := l.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	saved, err := Load(LoadOptions{Path: path, Environ: []string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.UI.Theme != "light" || saved.App.LogLevel != "info" || saved.UI.FontSize != 20 {
+		t.Errorf("saved theme %q, log level %q, font size %d", saved.UI.Theme, saved.App.LogLevel, saved.UI.FontSize)
+	}
+	if l.UI.Theme != "dark" || l.App.LogLevel != "debug" {
+		t.Error("saving changed the values in use")
+	}
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NoProfile selects no profile, even when the config file sets app.profile
+const NoProfile = "none"
+
+// profileNamePattern matches profile names, which also name storage directories
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9 _-]{0,63}$`)
+
+// ProfileNames returns the names of the configured profiles, sorted
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ActiveProfile returns the name of the profile in use, or "" for none
+func (c *Config) ActiveProfile() string {
+	if c.App.Profile == NoProfile {
+		return ""
+	}
+	return c.App.Profile
+}
+
+// ProfileStoragePath returns the storage directory for the profile in use: base
+// itself, or a directory of its own under base for a profile with separate sessions
+func (c *Config) ProfileStoragePath(base string) string {
+	name := c.ActiveProfile()
+	if profile, ok := c.Profiles[name]; ok && profile.SeparateSessions {
+		return filepath.Join(base, "profiles", name)
+	}
+	return base
+}
+
+// applyProfile applies the selected profile over the config file. Values set by
+// environment variables and flags keep precedence over it.
+func (l *Layered) applyProfile() error {
+	name := l.ActiveProfile()
+	if name == "" {
+		return nil
+	}
+	// The name becomes a storage directory, so one that validation rejects is not used
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use 1-64 letters, digits, spaces, '-' or '_'", name)
+	}
+	profile, ok := l.Profiles[name]
+	if !ok {
+		if len(l.Profiles) == 0 {
+			return fmt.Errorf("unknown profile %q: the config file defines no profiles", name)
+		}
+		return fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(l.ProfileNames(), ", "))
+	}
+
+	// A profile cannot select another profile or define its own
+	settings := make(map[string]interface{}, len(profile.Settings))
+	for key, value := range profile.Settings {
+		if key != "profiles" {
+			settings[key] = value
+		}
+	}
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to read profile %q: %w", name, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to read profile %q: %w", name, err)
+	}
+	var root *yaml.Node
+	if len(doc.Content) > 0 {
+		root = doc.Content[0]
+	}
+
+	// Keep the environment and flag values to apply them again on top
+	kept := make(map[string]reflect.Value)
+	for path := range l.overrides {
+		f, _ := lookupField(path)
+		kept[path] = copyValue(l.Config.value(f))
+	}
+
+	if err := doc.Decode(l.Config); err != nil {
+		return fmt.Errorf("failed to apply profile %q: %w", name, err)
+	}
+	l.App.Profile = name
+
+	for _, f := range fields {
+		if f.path == "app.profile" || !yamlHasPath(root, strings.Split(f.path, ".")) {
+			continue
+		}
+		if v, ok := kept[f.path]; ok {
+			l.Config.value(f).Set(v)
+			l.overrides[f.path] = v.Interface()
+			continue
+		}
+		l.Sources[f.path] = Origin{SourceProfile, name}
+		l.overrides[f.path] = l.Config.value(f).Interface()
+	}
+	return nil
+}
+
+// copyValue returns a copy of v that later changes to v, such as a profile merged
+// into a map, do not affect
+func copyValue(v reflect.Value) reflect.Value {
+	c := reflect.New(v.Type()).Elem()
+	switch {
+	case v.Kind() == reflect.Map && !v.IsNil():
+		c.Set(reflect.MakeMapWithSize(v.Type(), v.Len()))
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), iter.Value())
+		}
+	case v.Kind() == reflect.Slice && !v.IsNil():
+		c.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+		reflect.Copy(c, v)
+	default:
+		c.Set(v)
+	}
+	return c
+}
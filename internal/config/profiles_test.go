@@ -0,0 +1,167 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const profilesFile = `app:
+  profile: work
+ui:
+  theme: light
+  font_size: 14
+  window_width: 1000
+profiles:
+  work:
+    ui:
+      theme: dark
+      font_size: 16
+      window_width: 1100
+  demo:
+    separate_sessions: true
+    ui:
+      theme: auto
+`
+
+func TestProfileLayerPrecedence(t *testing.T) {
+	l, err := Load(LoadOptions{
+		Path:    writeConfigFile(t, profilesFile),
+		Environ: []string{"OLLAMACHAT_UI_FONT_SIZE=18"},
+		Flags:   []Override{{Flag: "-set", Path: "ui.window_width", Value: "1200"}},
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// The profile sits over the file and under the environment and flags
+	if l.UI.Theme != "dark" || l.Origin("ui.theme") != (Origin{SourceProfile, "work"}) {
+		t.Errorf("theme %q from %s, want dark from the profile", l.UI.Theme, l.Origin("ui.theme"))
+	}
+	if l.UI.FontSize != 18 || l.Origin("ui.font_size").Source != SourceEnv {
+		t.Errorf("font size %d from %s, want 18 from the environment", l.UI.FontSize, l.Origin("ui.font_size"))
+	}
+	if l.UI.WindowWidth != 1200 || l.Origin("ui.window_width").Source != SourceFlag {
+		t.Errorf("window width %d from %s, want 1200 from the flag", l.UI.WindowWidth, l.Origin("ui.window_width"))
+	}
+	if got := l.ProfileNames(); !reflect.DeepEqual(got, []string{"demo", "work"}) {
+		t.Errorf("profile names = %q", got)
+	}
+}
+
+func TestProfileSelection(t *testing.T) {
+	path := writeConfigFile(t, profilesFile)
+	tests := []struct {
+		name    string
+		environ []string
+		flags   []Override
+		active  string
+		theme   string
+	}{
+		{"from the file", nil, nil, "work", "dark"},
+		{"by environment", []string{"OLLAMACHAT_APP_PROFILE=demo"}, nil, "demo", "auto"},
+		{"by flag over environment", []string{"OLLAMACHAT_APP_PROFILE=demo"}, []Override{{Flag: "-profile", Path: "app.profile", Value: "work"}}, "work", "dark"},
+		{"none", nil, []Override{{Flag: "-profile", Path: "app.profile", Value: NoProfile}}, "", "light"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := Load(LoadOptions{Path: path, Environ: append([]string{}, tt.environ...), Flags: tt.flags})
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if l.ActiveProfile() != tt.active || l.UI.Theme != tt.theme {
+				t.Errorf("profile %q with theme %q, want %q with %q", l.ActiveProfile(), l.UI.Theme, tt.active, tt.theme)
+			}
+		})
+	}
+}
+
+func TestProfileSelectionErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		profile string
+		want    string
+	}{
+		{"unknown", profilesFile, "home", `unknown profile "home" (available: demo, work)`},
+		{"no profiles", "ui:\n  theme: light\n", "home", "defines no profiles"},
+		{"escapes the storage directory", "profiles:\n  ../x:\n    separate_sessions: true\n", "../x", "invalid profile name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(LoadOptions{
+				Path:    writeConfigFile(t, tt.content),
+				Environ: []string{},
+				Flags:   []Override{{Flag: "-profile", Path: "app.profile", Value: tt.profile}},
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsProfiles(t *testing.T) {
+	path := writeConfigFile(t, `profiles:
+  ../x:
+    ui:
+      theme: dark
+  none:
+    ui:
+      theme: dark
+  "":
+    ui:
+      theme: dark
+  ok:
+    ui:
+      colour: red
+    profiles:
+      nested: {}
+`)
+	l, err := Load(LoadOptions{Path: path, Environ: []string{}})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	want := []string{
+		"profiles.../x (line 2): must be",
+		"profiles.none (line 5): must be",
+		"profiles. (line 8): must be",
+		"profiles.ok.ui.colour (line 13): is not a known setting",
+		"profiles.ok.profiles (line 14): is not a known setting",
+	}
+	errs := validationErrors(t, l.Validate())
+	if len(errs) != len(want) {
+		t.Fatalf("got %d problems, want %d: %v", len(errs), len(want), errs)
+	}
+	for i, fe := range errs {
+		if !strings.HasPrefix(fe.Error(), want[i]) {
+			t.Errorf("problem %q, want one starting %q", fe.Error(), want[i])
+		}
+	}
+}
+
+func TestProfileStoragePath(t *testing.T) {
+	l, err := Load(LoadOptions{Path: writeConfigFile(t, profilesFile), Environ: []string{}})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	base := filepath.Join("data", "sessions")
+
+	tests := []struct {
+		profile string
+		want    string
+	}{
+		{"work", base},
+		{"demo", filepath.Join(base, "profiles", "demo")},
+		{NoProfile, base},
+		{"", base},
+	}
+	for _, tt := range tests {
+		l.App.Profile = tt.profile
+		if got := l.ProfileStoragePath(base); got != tt.want {
+			t.Errorf("storage path for profile %q = %s, want %s", tt.profile, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// schemaEnums lists the allowed values of enumerated settings, by path. List items
+// are addressed with "[]", such as "llm.available_providers[]".
+var schemaEnums = map[string][]string{
+	"app.log_level":             logLevels,
+	"llm.provider":              providerTypes,
+	"llm.available_providers[]": providerTypes,
+	"ui.theme":                  themes,
+	"agent.framework":           agentFramework,
+	"storage.type":              storageTypes,
+}
+
+// schemaMinimums holds the smallest value of numeric settings, by path
+var schemaMinimums = map[string]int{
+	"ui.window_width":                1,
+	"ui.window_height":               1,
+	"ui.max_messages":                0,
+	"ui.font_size":                   1,
+	"ui.sidebar_width":               1,
+	"storage.trash.retention_days":   -1,
+	"storage.retention.max_sessions": 0,
+	"storage.retention.max_age_days": 0,
+	"storage.retention.max_size_mb":  0,
+	"storage.backup.interval_hours":  0,
+	"storage.backup.keep":            0,
+}
+
+// schemaURLs lists the settings holding URLs
+var schemaURLs = map[string]bool{
+	"llm.ollama.base_url": true,
+	"llm.openai.base_url": true,
+}
+
+// Schema returns a JSON Schema describing the config file, generated from Config.
+// Editors use it to complete and check config.yaml.
+func Schema() map[string]interface{} {
+	schema := schemaFor(reflect.TypeOf(Config{}), "")
+
+	// A profile holds any of the settings of the file, except profiles of its own
+	profile := schemaFor(reflect.TypeOf(Config{}), "")
+	properties := profile["properties"].(map[string]interface{})
+	delete(properties, "profiles")
+	properties["separate_sessions"] = map[string]interface{}{"type": "boolean"}
+	schema["properties"].(map[string]interface{})["profiles"] = map[string]interface{}{
+		"type":                 "object",
+		"propertyNames":        map[string]interface{}{"pattern": profileNamePattern.String()},
+		"additionalProperties": profile,
+	}
+
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "OllamaChat configuration"
+	return schema
+}
+
+// WriteSchema writes the JSON Schema of the config file as indented JSON
+func WriteSchema(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Schema())
+}
+
+// schemaFor returns the schema of a value of type t at path
+func schemaFor(t reflect.Type, path string) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			properties[name] = schemaFor(t.Field(i).Type, strings.TrimPrefix(path+"."+name, "."))
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+	case reflect.Slice:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": schemaFor(t.Elem(), path+"[]"),
+		}
+	case reflect.Map:
+		schema := map[string]interface{}{"type": "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			schema["additionalProperties"] = schemaFor(t.Elem(), path+"[]")
+		}
+		return schema
+	case reflect.String:
+		schema := map[string]interface{}{"type": "string"}
+		if values, ok := schemaEnums[path]; ok {
+			schema["enum"] = values
+		}
+		if schemaURLs[path] {
+			schema["format"] = "uri"
+			schema["pattern"] = "^https?://"
+		}
+		return schema
+	case reflect.Int:
+		schema := map[string]interface{}{"type": "integer"}
+		if min, ok := schemaMinimums[path]; ok {
+			schema["minimum"] = min
+		}
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	default:
+		return map[string]interface{}{}
+	}
+}
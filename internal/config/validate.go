@@ -0,0 +1,469 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// syncDevicePattern matches the device names the sync storage accepts
+var syncDevicePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// bucketPattern matches S3 bucket names
+var bucketPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// Allowed values of the enumerated settings, also listed in the JSON Schema
+var (
+	logLevels      = []string{"debug", "info", "warn", "error"}
+	providerTypes  = []string{"ollama", "openai", "eino"}
+	themes         = []string{"light", "dark", "auto"}
+	storageTypes   = []string{"file", "sqlite", "memory", "sync", "s3"}
+	agentFramework = []string{"eino", "custom"}
+)
+
+// FieldError is a problem with one configuration value
+type FieldError struct {
+	Path    string // Such as "mcp.servers[0].command"
+	Line    int    // Line of the value in the config file, or 0 if it is not set there
+	Origin  string // Where the value came from when not the config file, such as "env OLLAMACHAT_UI_THEME"
+	Message string
+}
+
+// Error describes the problem with where the value was set
+func (e FieldError) Error() string {
+	switch {
+	case e.Line > 0:
+		return fmt.Sprintf("%s (line %d): %s", e.Path, e.Line, e.Message)
+	case e.Origin != "":
+		return fmt.Sprintf("%s (%s): %s", e.Path, e.Origin, e.Message)
+	default:
+		return fmt.Sprintf("%s: %s", e.Path, e.Message)
+	}
+}
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// Error lists the problems, one per line
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	lines := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		lines[i] = "  " + fe.Error()
+	}
+	return fmt.Sprintf("%d configuration problems:\n%s", len(e.Errors), strings.Join(lines, "\n"))
+}
+
+// validator collects the problems found in a configuration
+type validator struct {
+	errors []FieldError
+}
+
+// addf records a problem with the value at path
+func (v *validator) addf(path, format string, args ...interface{}) {
+	v.errors = append(v.errors, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// required records a problem if value is empty
+func (v *validator) required(path, value, when string) {
+	if strings.TrimSpace(value) == "" {
+		v.addf(path, "is required%s", when)
+	}
+}
+
+// oneOf records a problem if value is not one of allowed
+func (v *validator) oneOf(path, value string, allowed []string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.addf(path, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+}
+
+// url records a problem if value is not an http or https URL with a valid port.
+// A URL without a scheme is accepted when defaultScheme is set.
+func (v *validator) url(path, value string, defaultScheme bool) {
+	if value == "" {
+		return
+	}
+	raw := value
+	if defaultScheme && !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	switch {
+	case err != nil:
+		v.addf(path, "is not a valid URL: %q", value)
+	case u.Scheme != "http" && u.Scheme != "https":
+		v.addf(path, "must be an http:// or https:// URL, got %q", value)
+	case u.Hostname() == "":
+		v.addf(path, "must include a host name, got %q", value)
+	case u.Port() != "":
+		if port, err := strconv.Atoi(u.Port()); err != nil || port < 1 || port > 65535 {
+			v.addf(path, "has an invalid port %q (must be 1-65535)", u.Port())
+		}
+	}
+}
+
+// positiveNumber records a problem if a free-form setting is present and not a
+// positive number
+func (v *validator) positiveNumber(path string, settings map[string]interface{}, key string) {
+	value, ok := settings[key]
+	if !ok {
+		return
+	}
+	switch n := value.(type) {
+	case int:
+		if n > 0 {
+			return
+		}
+	case float64:
+		if n > 0 {
+			return
+		}
+	}
+	v.addf(path+"."+key, "must be a positive number, got %v", value)
+}
+
+// err returns the problems found, or nil
+func (v *validator) err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errors}
+}
+
+// ValidateConfig validates the entire configuration structure. The returned error
+// is a *ValidationError listing every problem.
+func (c *Config) ValidateConfig() error {
+	v := &validator{}
+	c.validate(v)
+	return v.err()
+}
+
+// validate checks every section of the configuration
+func (c *Config) validate(v *validator) {
+	v.required("app.name", c.App.Name, "")
+	v.required("app.version", c.App.Version, "")
+	if c.App.LogLevel != "" {
+		v.oneOf("app.log_level", c.App.LogLevel, logLevels)
+	}
+	c.validateLLM(v)
+	c.validateUI(v)
+	c.validateMCP(v)
+	c.validateAgent(v)
+	c.validateStorage(v)
+}
+
+// ValidateLLMConfig validates LLM provider configurations
+func (c *Config) ValidateLLMConfig() error {
+	v := &validator{}
+	c.validateLLM(v)
+	return v.err()
+}
+
+// validateLLM checks the llm section
+func (c *Config) validateLLM(v *validator) {
+	llm := c.LLM
+	if llm.Provider == "" {
+		v.addf("llm.provider", "is required")
+	} else {
+		v.oneOf("llm.provider", llm.Provider, providerTypes)
+		found := false
+		for _, provider := range llm.AvailableProviders {
+			found = found || provider == llm.Provider
+		}
+		if !found {
+			v.addf("llm.available_providers", "must include the current provider %q", llm.Provider)
+		}
+	}
+	for i, provider := range llm.AvailableProviders {
+		v.oneOf(fmt.Sprintf("llm.available_providers[%d]", i), provider, providerTypes)
+	}
+
+	// Provider-specific settings are required for the provider in use; the OpenAI
+	// API key can come from the environment, so it is not checked
+	v.url("llm.ollama.base_url", llm.Ollama.BaseURL, false)
+	v.url("llm.openai.base_url", llm.OpenAI.BaseURL, false)
+	switch llm.Provider {
+	case "ollama":
+		v.required("llm.ollama.base_url", llm.Ollama.BaseURL, " for the ollama provider")
+		v.required("llm.ollama.default_model", llm.Ollama.DefaultModel, " for the ollama provider")
+	case "openai":
+		v.required("llm.openai.base_url", llm.OpenAI.BaseURL, " for the openai provider")
+		v.required("llm.openai.default_model", llm.OpenAI.DefaultModel, " for the openai provider")
+	case "eino":
+		v.required("llm.eino.default_model", llm.Eino.DefaultModel, " for the eino provider")
+	}
+
+	// Embeddings always come from Ollama, whichever provider is chatting
+	if llm.Embeddings.Enabled {
+		v.required("llm.embeddings.model", llm.Embeddings.Model, " when embeddings are enabled")
+		if llm.Provider != "ollama" {
+			v.required("llm.ollama.base_url", llm.Ollama.BaseURL, " when embeddings are enabled")
+		}
+	}
+
+	v.positiveNumber("llm.settings", llm.Settings, "timeout_seconds")
+	v.positiveNumber("llm.settings", llm.Settings, "max_tokens")
+}
+
+// ValidateUIConfig validates UI configuration values
+func (c *Config) ValidateUIConfig() error {
+	v := &validator{}
+	c.validateUI(v)
+	return v.err()
+}
+
+// validateUI checks the ui section
+func (c *Config) validateUI(v *validator) {
+	positive := []struct {
+		path  string
+		value int
+	}{
+		{"ui.window_width", c.UI.WindowWidth},
+		{"ui.window_height", c.UI.WindowHeight},
+		{"ui.font_size", c.UI.FontSize},
+		{"ui.sidebar_width", c.UI.SidebarWidth},
+	}
+	for _, p := range positive {
+		if p.value <= 0 {
+			v.addf(p.path, "must be positive, got %d", p.value)
+		}
+	}
+	if c.UI.MaxMessages < 0 {
+		v.addf("ui.max_messages", "must be 0 or more (0 disables context), got %d", c.UI.MaxMessages)
+	}
+	v.oneOf("ui.theme", c.UI.Theme, themes)
+}
+
+// validateMCP checks the mcp section. Servers are checked when enabled, whether or
+// not MCP itself is.
+func (c *Config) validateMCP(v *validator) {
+	names := make(map[string]int)
+	for i, server := range c.MCP.Servers {
+		path := fmt.Sprintf("mcp.servers[%d]", i)
+		if first, ok := names[server.Name]; ok && server.Name != "" {
+			v.addf(path+".name", "duplicates the name of mcp.servers[%d]", first)
+		} else {
+			names[server.Name] = i
+		}
+		if !server.Enabled {
+			continue
+		}
+		v.required(path+".name", server.Name, " for an enabled server")
+		v.required(path+".command", server.Command, " for an enabled server")
+		for name := range server.Env {
+			if name == "" || strings.ContainsAny(name, "= ") {
+				v.addf(path+".env", "has an invalid variable name %q", name)
+			}
+		}
+	}
+}
+
+// validateAgent checks the agent section when agents are enabled
+func (c *Config) validateAgent(v *validator) {
+	if !c.Agent.Enabled {
+		return
+	}
+	v.oneOf("agent.framework", c.Agent.Framework, agentFramework)
+	v.required("agent.default_agent", c.Agent.DefaultAgent, " when agents are enabled")
+	v.positiveNumber("agent.settings", c.Agent.Settings, "max_iterations")
+	if timeout, ok := c.Agent.Settings["timeout"]; ok {
+		s, _ := timeout.(string)
+		if d, err := time.ParseDuration(s); err != nil || d <= 0 {
+			v.addf("agent.settings.timeout", "must be a duration such as \"30s\", got %v", timeout)
+		}
+	}
+}
+
+// validateStorage checks the storage section
+func (c *Config) validateStorage(v *validator) {
+	s := c.Storage
+	if s.Type != "" {
+		v.oneOf("storage.type", s.Type, storageTypes)
+	}
+	if s.Type == "s3" {
+		v.required("storage.s3.endpoint", s.S3.Endpoint, " when storage.type is \"s3\"")
+		v.required("storage.s3.bucket", s.S3.Bucket, " when storage.type is \"s3\"")
+	}
+	v.url("storage.s3.endpoint", s.S3.Endpoint, true)
+	if s.S3.Bucket != "" && !bucketPattern.MatchString(s.S3.Bucket) {
+		v.addf("storage.s3.bucket", "must be 3-63 lowercase letters, digits, '.' or '-', got %q", s.S3.Bucket)
+	}
+	if device := s.Sync.Device; device != "" && !syncDevicePattern.MatchString(device) {
+		v.addf("storage.sync.device", "must be 1-64 letters, digits, '-' or '_', got %q", device)
+	}
+	if s.Trash.RetentionDays < -1 {
+		v.addf("storage.trash.retention_days", "must be -1 or more, got %d", s.Trash.RetentionDays)
+	}
+	limits := []struct {
+		path  string
+		value int
+	}{
+		{"storage.retention.max_sessions", s.Retention.MaxSessions},
+		{"storage.retention.max_age_days", s.Retention.MaxAgeDays},
+		{"storage.retention.max_size_mb", s.Retention.MaxSizeMB},
+		{"storage.backup.interval_hours", s.Backup.IntervalHours},
+		{"storage.backup.keep", s.Backup.Keep},
+	}
+	for _, l := range limits {
+		if l.value < 0 {
+			v.addf(l.path, "cannot be negative, got %d", l.value)
+		}
+	}
+	if s.Backup.Enabled {
+		v.required("storage.backup.dir", s.Backup.Dir, " when storage.backup.enabled is set")
+	}
+}
+
+// Validate checks the merged configuration and the config file. Each problem is
+// reported with the line of the config file setting the value, or the environment
+// variable or flag that set it; settings the file has that the app does not know
+// are reported too. The returned error is a *ValidationError.
+func (l *Layered) Validate() error {
+	v := &validator{}
+	if l.root != nil {
+		unknownKeys(v, l.root, reflect.TypeOf(Config{}), "")
+		profileKeys(v, l.root)
+	}
+	l.Config.validate(v)
+
+	for i := range v.errors {
+		fe := &v.errors[i]
+		if fe.Line > 0 {
+			continue
+		}
+		fieldPath, _, _ := strings.Cut(fe.Path, "[")
+		origin, ok := l.Sources[fieldPath]
+		if ok && (origin.Source == SourceEnv || origin.Source == SourceFlag) {
+			fe.Origin = origin.String()
+			continue
+		}
+		if ok && origin.Source == SourceProfile {
+			if line := yamlLine(l.root, "profiles."+origin.Name+"."+fe.Path); line > 0 {
+				fe.Line = line
+				continue
+			}
+		}
+		fe.Line = yamlLine(l.root, fe.Path)
+	}
+	return v.err()
+}
+
+// unknownKeys records the keys of a YAML mapping that match no field of the struct t
+func unknownKeys(v *validator, node *yaml.Node, t reflect.Type, prefix string) {
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			sf, ok := structField(t, key.Value)
+			if !ok {
+				v.errors = append(v.errors, FieldError{Path: prefix + key.Value, Line: key.Line, Message: "is not a known setting"})
+				continue
+			}
+			unknownKeys(v, value, sf.Type, prefix+key.Value+".")
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		base := strings.TrimSuffix(prefix, ".")
+		for i, item := range node.Content {
+			unknownKeys(v, item, t.Elem(), fmt.Sprintf("%s[%d].", base, i))
+		}
+	}
+}
+
+// profileKeys records invalid profile names and settings in profiles that the app
+// does not know. The Config type does not describe a profile's settings, so
+// unknownKeys skips them.
+func profileKeys(v *validator, root *yaml.Node) {
+	_, profiles := mappingEntry(root, "profiles")
+	if profiles == nil || profiles.Kind != yaml.MappingNode {
+		return
+	}
+	configType := reflect.TypeOf(Config{})
+	for i := 0; i+1 < len(profiles.Content); i += 2 {
+		name, profile := profiles.Content[i], profiles.Content[i+1]
+		path := "profiles." + name.Value
+		if !profileNamePattern.MatchString(name.Value) || name.Value == NoProfile {
+			v.errors = append(v.errors, FieldError{Path: path, Line: name.Line,
+				Message: "must be 1-64 letters, digits, spaces, '-' or '_', and not \"" + NoProfile + "\""})
+		}
+		if profile.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 0; j+1 < len(profile.Content); j += 2 {
+			key, value := profile.Content[j], profile.Content[j+1]
+			if key.Value == "separate_sessions" {
+				continue
+			}
+			sf, ok := structField(configType, key.Value)
+			if !ok || key.Value == "profiles" {
+				v.errors = append(v.errors, FieldError{Path: path + "." + key.Value, Line: key.Line, Message: "is not a known setting"})
+				continue
+			}
+			unknownKeys(v, value, sf.Type, path+"."+key.Value+".")
+		}
+	}
+}
+
+// structField returns the field of struct t with the given YAML name
+func structField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if tag, _, _ := strings.Cut(sf.Tag.Get("yaml"), ","); tag == name {
+			return sf, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// yamlLine returns the line setting the value at path, such as
+// "mcp.servers[0].command", or of its closest parent set in the file
+func yamlLine(root *yaml.Node, path string) int {
+	line := 0
+	node := root
+	for _, part := range strings.Split(path, ".") {
+		name, rest, _ := strings.Cut(part, "[")
+		key, value := mappingEntry(node, name)
+		if key == nil {
+			return line
+		}
+		node, line = value, key.Line
+		for rest != "" {
+			var index string
+			index, rest, _ = strings.Cut(rest, "]")
+			rest = strings.TrimPrefix(rest, "[")
+			i, err := strconv.Atoi(index)
+			if err != nil || node.Kind != yaml.SequenceNode || i >= len(node.Content) {
+				return line
+			}
+			node = node.Content[i]
+			line = node.Line
+		}
+	}
+	return line
+}
+
+// mappingEntry returns the key and value nodes of name in a YAML mapping, or nils
+func mappingEntry(node *yaml.Node, name string) (key, value *yaml.Node) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			key, value = node.Content[i], node.Content[i+1]
+		}
+	}
+	return key, value
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// validationErrors returns the problems in err, failing the test if it is not a
+// *ValidationError
+func validationErrors(t *testing.T, err error) []FieldError {
+	t.Helper()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("error %v is not a *ValidationError", err)
+	}
+	return verr.Errors
+}
+
+func TestDefaultConfigIsValid(t *testing.T) {
+	if err := DefaultConfig().ValidateConfig(); err != nil {
+		t.Errorf("default configuration is not valid: %v", err)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		path   string
+		want   string
+	}{
+		{"log level", func(c *Config) { c.App.LogLevel = "verbose" }, "app.log_level", "must be one of"},
+		{"provider", func(c *Config) { c.LLM.Provider = "" }, "llm.provider", "is required"},
+		{"available provider", func(c *Config) { c.LLM.AvailableProviders = []string{"ollama", "gemini"} }, "llm.available_providers[1]", "must be one of"},
+		{"provider not available", func(c *Config) { c.LLM.AvailableProviders = []string{"openai"} }, "llm.available_providers", "current provider"},
+		{"URL scheme", func(c *Config) { c.LLM.Ollama.BaseURL = "ftp://localhost:11434" }, "llm.ollama.base_url", "http:// or https://"},
+		{"URL port", func(c *Config) { c.LLM.Ollama.BaseURL = "http://localhost:99999" }, "llm.ollama.base_url", "invalid port"},
+		{"timeout", func(c *Config) { c.LLM.Settings = map[string]interface{}{"timeout_seconds": -5} }, "llm.settings.timeout_seconds", "positive number"},
+		{"font size", func(c *Config) { c.UI.FontSize = 0 }, "ui.font_size", "must be positive"},
+		{"theme", func(c *Config) { c.UI.Theme = "solarized" }, "ui.theme", "must be one of"},
+		{"MCP command", func(c *Config) { c.MCP.Servers = []MCPServerConfig{{Name: "fs", Enabled: true}} }, "mcp.servers[0].command", "is required"},
+		{"S3 bucket", func(c *Config) { c.Storage.S3.Bucket = "My_Bucket" }, "storage.s3.bucket", "lowercase"},
+		{"S3 endpoint", func(c *Config) { c.Storage.Type = "s3"; c.Storage.S3.Bucket = "chats" }, "storage.s3.endpoint", "is required"},
+		{"sync device", func(c *Config) { c.Storage.Sync.Device = "my/laptop" }, "storage.sync.device", "letters, digits"},
+		{"retention limit", func(c *Config) { c.Storage.Retention.MaxSessions = -1 }, "storage.retention.max_sessions", "cannot be negative"},
+		{"backup dir", func(c *Config) { c.Storage.Backup.Enabled = true; c.Storage.Backup.Dir = "" }, "storage.backup.dir", "is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := DefaultConfig()
+			tt.modify(c)
+			errs := validationErrors(t, c.ValidateConfig())
+			if len(errs) != 1 || errs[0].Path != tt.path || !strings.Contains(errs[0].Message, tt.want) {
+				t.Errorf("errors %v, want one for %s about %q", errs, tt.path, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateLocatesProblems(t *testing.T) {
+	path := writeConfigFile(t, `app:
+  log_level: loud
+ui:
+  theme: dark
+  colour: red
+mcp:
+  servers:
+    - name: fs
+      enabled: true
+      comand: mcp-fs
+`)
+	l, err := Load(LoadOptions{
+		Path:    path,
+		Environ: []string{"OLLAMACHAT_UI_FONT_SIZE=-1"},
+		Flags:   []Override{{Flag: "-set", Path: "ui.theme", Value: "neon"}},
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	err = l.Validate()
+	errs := validationErrors(t, err)
+
+	// Every problem is reported, each where its value was set
+	want := map[string]string{
+		"ui.colour":              "ui.colour (line 5): is not a known setting",
+		"mcp.servers[0].comand":  "mcp.servers[0].comand (line 10): is not a known setting",
+		"app.log_level":          "app.log_level (line 2): must be one of",
+		"ui.font_size":           "ui.font_size (env OLLAMACHAT_UI_FONT_SIZE): must be positive",
+		"ui.theme":               "ui.theme (flag -set): must be one of",
+		"mcp.servers[0].command": "mcp.servers[0].command (line 8): is required",
+	}
+	if len(errs) != len(want) {
+		t.Errorf("got %d problems, want %d:\n%v", len(errs), len(want), err)
+	}
+	for _, fe := range errs {
+		if prefix, ok := want[fe.Path]; !ok || !strings.HasPrefix(fe.Error(), prefix) {
+			t.Errorf("problem %q, want one starting %q", fe.Error(), prefix)
+		}
+	}
+	if !strings.HasPrefix(err.Error(), "6 configuration problems:\n") {
+		t.Errorf("error does not count the problems:\n%v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long the config file must be quiet before a change is
+// reported. Editors often write a file several times when saving it.
+const watchDebounce = 300 * time.Millisecond
+
+// Watcher reports changes made to a config file from outside the app, such as
+// edits in a text editor
+type Watcher struct {
+	path     string
+	onChange func()
+	watcher  *fsnotify.Watcher
+	done     chan struct{}
+
+	mu  sync.Mutex
+	sum [sha256.Size]byte // Content of the file last reported or synced
+}
+
+// NewWatcher starts watching the config file at path and calls onChange, on a
+// background goroutine, each time its content changes. Close it when done.
+func NewWatcher(path string, onChange func()) (*Watcher, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config path: %w", err)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	// Watch the directory rather than the file, which editors replace when saving
+	if err := watcher.Add(filepath.Dir(abs)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch %s: %w", filepath.Dir(abs), err)
+	}
+
+	w := &Watcher{
+		path:     abs,
+		onChange: onChange,
+		watcher:  watcher,
+		done:     make(chan struct{}),
+	}
+	w.Sync()
+	go w.loop()
+	return w, nil
+}
+
+// Sync records the current content of the file as seen, so that a write made by the
+// app itself is not reported as a change
+func (w *Watcher) Sync() {
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return
+	}
+	w.mu.Lock()
+	w.sum = sha256.Sum256(data)
+	w.mu.Unlock()
+}
+
+// Close stops watching and waits for a change being reported to finish
+func (w *Watcher) Close() error {
+	err := w.watcher.Close()
+	<-w.done
+	return err
+}
+
+// loop collects events for the file and checks it once it is quiet
+func (w *Watcher) loop() {
+	defer close(w.done)
+
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Name == w.path {
+				timer.Reset(watchDebounce)
+			}
+
+		case _, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+
+		case <-timer.C:
+			if w.changed() {
+				w.onChange()
+			}
+		}
+	}
+}
+
+// changed reports whether the file's content differs from what was last seen. A
+// missing file is not a change: it is usually about to be replaced.
+func (w *Watcher) changed() bool {
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return false
+	}
+	sum := sha256.Sum256(data)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if sum == w.sum {
+		return false
+	}
+	w.sum = sum
+	return true
+}
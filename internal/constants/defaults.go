@@ -8,6 +8,9 @@ const (
 	// Default provider name
 	DefaultProvider = "ollama"
 
+	// Default system prompt used when a session has none
+	DefaultSystemPrompt = "You are a helpful assistant."
+
 	// Default temperature for LLM operations
 	DefaultTemperature = 0.7
 
@@ -20,6 +23,21 @@ const (
 	// Default timeout for LLM requests (in seconds)
 	DefaultTimeoutSeconds = 30
 
+	// Default embedding model for semantic search
+	DefaultEmbeddingModel = "nomic-embed-text"
+
+	// Timeout for embedding requests (in seconds); the first one also loads the model
+	EmbeddingTimeoutSeconds = 120
+
+	// Default number of days deleted sessions stay in the trash
+	DefaultTrashRetentionDays = 30
+
+	// Default hours between scheduled backups
+	DefaultBackupIntervalHours = 24
+
+	// Default number of scheduled backups kept
+	DefaultBackupKeep = 7
+
 	// UI dimension defaults
 	DefaultWindowWidth  = 800
 	DefaultWindowHeight = 700
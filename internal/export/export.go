@@ -0,0 +1,136 @@
+// Package export writes chat sessions to files in formats meant for reading or for
+// other tools: Markdown, self-contained HTML, lossless JSON and OpenAI-style chat JSONL.
+// Formats register themselves, so new ones only need an Exporter implementation.
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/storage"
+)
+
+// timeLayout formats timestamps in human-readable exports
+const timeLayout = "2006-01-02 15:04"
+
+// Exporter writes sessions in one file format
+type Exporter interface {
+	Name() string      // Identifier used by the export command, e.g. "markdown"
+	Title() string     // Name shown in the UI
+	Extension() string // File extension including the dot
+	Export(w io.Writer, sessions []models.ChatSession) error
+}
+
+// registry holds the known formats in registration order
+var registry []Exporter
+
+// The built-in formats, in the order the UI offers them
+func init() {
+	Register(markdownExporter{})
+	Register(htmlExporter{})
+	Register(jsonExporter{})
+	Register(jsonlExporter{})
+}
+
+// Register adds a format; registering a name twice panics
+func Register(e Exporter) {
+	if _, exists := Get(e.Name()); exists {
+		panic(fmt.Sprintf("export format %q registered twice", e.Name()))
+	}
+	registry = append(registry, e)
+}
+
+// Get returns the format registered under name
+func Get(name string) (Exporter, bool) {
+	for _, e := range registry {
+		if strings.EqualFold(e.Name(), name) {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// Formats returns every registered format in registration order
+func Formats() []Exporter {
+	return append([]Exporter(nil), registry...)
+}
+
+// Names returns the names of every registered format
+func Names() []string {
+	names := make([]string, len(registry))
+	for i, e := range registry {
+		names[i] = e.Name()
+	}
+	return names
+}
+
+// LoadSessions loads the sessions with the given IDs in that order, or every session,
+// most recently updated first, when ids is empty
+func LoadSessions(ctx context.Context, store storage.Storage, ids []string) ([]models.ChatSession, error) {
+	if len(ids) == 0 {
+		sessions, err := store.ListChatSessions(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list sessions: %w", err)
+		}
+		return sessions, nil
+	}
+
+	sessions := make([]models.ChatSession, 0, len(ids))
+	for _, id := range ids {
+		session, err := store.LoadChatSession(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load session %s: %w", id, err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// FileName suggests a file name for exporting sessions with e
+func FileName(e Exporter, sessions []models.ChatSession) string {
+	base := "ollamachat-export"
+	if len(sessions) == 1 {
+		base = sanitizeFileName(sessions[0].Name)
+	}
+	return base + e.Extension()
+}
+
+// sanitizeFileName turns a session name into a safe file name
+func sanitizeFileName(name string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		switch {
+		case strings.ContainsRune(`/\:*?"<>|`, r), r < ' ':
+			return '-'
+		default:
+			return r
+		}
+	}, strings.TrimSpace(name))
+	cleaned = strings.Trim(cleaned, ". -")
+	if cleaned == "" {
+		return "session"
+	}
+	if runes := []rune(cleaned); len(runes) > 80 {
+		cleaned = strings.TrimSpace(string(runes[:80]))
+	}
+	return cleaned
+}
+
+// senderLabel names the author of a message in human-readable exports
+func senderLabel(sender string) string {
+	if sender == "user" {
+		return "You"
+	}
+	return "Assistant"
+}
+
+// formatTime formats a timestamp for human-readable exports, or "" for a zero time
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format(timeLayout)
+}
@@ -0,0 +1,115 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// testSession returns a session with a system prompt, Markdown and HTML in its
+// messages, and an answer cut off inside a code block
+func testSession() models.ChatSession {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	session := models.NewChatSession("Go <generics>", "llama3.2:latest")
+	session.SystemPrompt = "Answer briefly."
+	session.CreatedAt, session.UpdatedAt = at, at.Add(time.Minute)
+	session.Messages = []models.ChatMessage{
+		{Sender: "user", Content: "Show me `any` <script>alert(1)</script>", Timestamp: at},
+		{Sender: "llm", Content: "**Sure:**\n\n```go\nfunc f[T any]() {}", Timestamp: at.Add(time.Minute)},
+		{Sender: "llm", Content: "", Timestamp: at.Add(time.Minute)},
+	}
+	return session
+}
+
+func TestExportFormats(t *testing.T) {
+	tests := []struct {
+		format  string
+		want    []string
+		notWant []string
+	}{
+		{"markdown",
+			[]string{"# Go <generics>", "> Answer briefly.", "- **Model:** llama3.2:latest", "func f[T any]() {}\n```\n"},
+			nil},
+		{"html",
+			[]string{"<title>Go &lt;generics&gt;</title>", "<code>any</code>", "&lt;script&gt;", "<strong>Sure:</strong>", "func f[T any]() {}"},
+			[]string{"<script>alert"}},
+		{"jsonl",
+			[]string{`{"messages":[{"role":"system","content":"Answer briefly."},{"role":"user","content":"Show me ` + "`any`" + ` <script>alert(1)</script>"},{"role":"assistant",`},
+			[]string{`"content":""`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			exporter, ok := Get(tt.format)
+			if !ok {
+				t.Fatalf("format %q not registered", tt.format)
+			}
+			var buf bytes.Buffer
+			if err := exporter.Export(&buf, []models.ChatSession{testSession()}); err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+			out := buf.String()
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output lacks %q:\n%s", want, out)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(out, notWant) {
+					t.Errorf("output holds %q:\n%s", notWant, out)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONExportIsLossless(t *testing.T) {
+	session := testSession()
+	session.Pinned = true
+	session.ManuallyNamed = true
+
+	exporter, _ := Get("json")
+	var buf bytes.Buffer
+	if err := exporter.Export(&buf, []models.ChatSession{session}); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("decode export: %v", err)
+	}
+	if doc.Format != JSONFormat || doc.Version != JSONVersion || len(doc.Sessions) != 1 {
+		t.Fatalf("export header %q v%d with %d sessions", doc.Format, doc.Version, len(doc.Sessions))
+	}
+	want, _ := json.Marshal(session)
+	got, _ := json.Marshal(doc.Sessions[0])
+	if !bytes.Equal(got, want) {
+		t.Errorf("session changed by the export:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestFileName(t *testing.T) {
+	markdown, _ := Get("markdown")
+	tests := []struct {
+		names []string
+		want  string
+	}{
+		{[]string{"Plan: Q3/Q4?"}, "Plan- Q3-Q4.md"},
+		{[]string{"  ..hidden  "}, "hidden.md"},
+		{[]string{"/\\:"}, "session.md"},
+		{[]string{strings.Repeat("é", 100)}, strings.Repeat("é", 80) + ".md"},
+		{[]string{"One", "Two"}, "ollamachat-export.md"},
+	}
+	for _, tt := range tests {
+		var sessions []models.ChatSession
+		for _, name := range tt.names {
+			sessions = append(sessions, models.ChatSession{Name: name})
+		}
+		if got := FileName(markdown, sessions); got != tt.want {
+			t.Errorf("FileName(%q) = %q, want %q", tt.names, got, tt.want)
+		}
+	}
+}
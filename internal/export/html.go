@@ -0,0 +1,162 @@
+package export
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// htmlExporter writes sessions as a single HTML page with inline styles, viewable
+// offline in any browser
+type htmlExporter struct{}
+
+func (htmlExporter) Name() string      { return "html" }
+func (htmlExporter) Title() string     { return "HTML" }
+func (htmlExporter) Extension() string { return ".html" }
+
+// htmlSession is a session prepared for the page template
+type htmlSession struct {
+	Name         string
+	Fields       []sessionField
+	SystemPrompt string
+	Messages     []htmlMessage
+}
+
+// htmlMessage is a message prepared for the page template
+type htmlMessage struct {
+	User   bool
+	Sender string
+	Time   string
+	Body   template.HTML
+}
+
+// Export renders the sessions into one page
+func (htmlExporter) Export(w io.Writer, sessions []models.ChatSession) error {
+	page := struct {
+		Title    string
+		Sessions []htmlSession
+	}{Title: "OllamaChat export"}
+	if len(sessions) == 1 {
+		page.Title = sessions[0].Name
+	}
+
+	for _, session := range sessions {
+		hs := htmlSession{Name: session.Name, Fields: sessionFields(session), SystemPrompt: session.SystemPrompt}
+		for _, msg := range session.Messages {
+			hs.Messages = append(hs.Messages, htmlMessage{
+				User:   msg.Sender == "user",
+				Sender: senderLabel(msg.Sender),
+				Time:   formatTime(msg.Timestamp),
+				Body:   renderMarkdown(msg.Content),
+			})
+		}
+		page.Sessions = append(page.Sessions, hs)
+	}
+
+	if err := htmlPage.Execute(w, page); err != nil {
+		return fmt.Errorf("failed to write HTML: %w", err)
+	}
+	return nil
+}
+
+var htmlPage = template.Must(template.New("page").Funcs(template.FuncMap{
+	"label": func(f sessionField) string { return f.label },
+	"value": func(f sessionField) string { return f.value },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; line-height: 1.5; color: #1f2328; background: #f6f8fa; margin: 0; padding: 2rem 1rem; }
+main { max-width: 52rem; margin: 0 auto; }
+section.session { background: #fff; border: 1px solid #d0d7de; border-radius: 8px; padding: 1.5rem; margin-bottom: 2rem; }
+h1 { font-size: 1.5rem; margin: 0 0 .75rem; }
+dl.meta { display: grid; grid-template-columns: max-content 1fr; gap: .15rem 1rem; font-size: .875rem; color: #57606a; margin: 0 0 1rem; }
+dl.meta dt { font-weight: 600; }
+dl.meta dd { margin: 0; }
+.system { border-left: 3px solid #d0d7de; padding: .25rem .75rem; color: #57606a; white-space: pre-wrap; margin-bottom: 1rem; }
+.message { border-radius: 8px; padding: .75rem 1rem; margin: .75rem 0; }
+.message.user { background: #ddf4ff; margin-left: 3rem; }
+.message.assistant { background: #f6f8fa; border: 1px solid #d0d7de; margin-right: 3rem; }
+.message header { font-size: .8rem; font-weight: 600; color: #57606a; margin-bottom: .35rem; }
+.message header time { font-weight: normal; margin-left: .5rem; }
+.message p { margin: .4rem 0; }
+pre { background: #161b22; color: #e6edf3; padding: .75rem 1rem; border-radius: 6px; overflow-x: auto; }
+code { font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; font-size: .875em; }
+:not(pre) > code { background: rgba(175, 184, 193, .3); padding: .1em .3em; border-radius: 4px; }
+</style>
+</head>
+<body>
+<main>
+{{range .Sessions}}<section class="session">
+<h1>{{.Name}}</h1>
+<dl class="meta">{{range .Fields}}<dt>{{label .}}</dt><dd>{{value .}}</dd>{{end}}</dl>
+{{if .SystemPrompt}}<div class="system">{{.SystemPrompt}}</div>
+{{end}}{{range .Messages}}<article class="message {{if .User}}user{{else}}assistant{{end}}">
+<header>{{.Sender}}{{if .Time}}<time>{{.Time}}</time>{{end}}</header>
+{{.Body}}
+</article>
+{{end}}</section>
+{{end}}</main>
+</body>
+</html>
+`))
+
+var (
+	inlineCode = regexp.MustCompile("`([^`\n]+)`")
+	boldText   = regexp.MustCompile(`\*\*([^*\n]+)\*\*`)
+)
+
+// renderMarkdown converts the Markdown most answers use (fenced code blocks,
+// paragraphs, inline code and bold) to HTML, escaping everything else
+func renderMarkdown(content string) template.HTML {
+	var out strings.Builder
+	var paragraph []string
+
+	flush := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		text := html.EscapeString(strings.Join(paragraph, "\n"))
+		text = inlineCode.ReplaceAllString(text, "<code>$1</code>")
+		text = boldText.ReplaceAllString(text, "<strong>$1</strong>")
+		out.WriteString("<p>" + strings.ReplaceAll(text, "\n", "<br>\n") + "</p>\n")
+		paragraph = nil
+	}
+
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		fence := strings.TrimSpace(line)
+		if !strings.HasPrefix(fence, "```") {
+			if strings.TrimSpace(line) == "" {
+				flush()
+			} else {
+				paragraph = append(paragraph, line)
+			}
+			continue
+		}
+
+		flush()
+		language := strings.TrimSpace(strings.TrimPrefix(fence, "```"))
+		var code []string
+		for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+			code = append(code, lines[i])
+		}
+		class := ""
+		if language != "" {
+			class = fmt.Sprintf(` class="language-%s"`, html.EscapeString(language))
+		}
+		fmt.Fprintf(&out, "<pre><code%s>%s</code></pre>\n", class, html.EscapeString(strings.Join(code, "\n")))
+	}
+	flush()
+
+	return template.HTML(out.String())
+}
@@ -0,0 +1,106 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// JSONFormat identifies lossless JSON exports
+const JSONFormat = "ollamachat-export"
+
+// JSONVersion is the layout version of lossless JSON exports
+const JSONVersion = 1
+
+// Document is the content of a lossless JSON export: every session exactly as stored
+type Document struct {
+	Format     string               `json:"format"`
+	Version    int                  `json:"version"`
+	ExportedAt time.Time            `json:"exported_at"`
+	Sessions   []models.ChatSession `json:"sessions"`
+}
+
+// jsonExporter writes sessions with every field, so they can be read back unchanged
+type jsonExporter struct{}
+
+func (jsonExporter) Name() string      { return "json" }
+func (jsonExporter) Title() string     { return "JSON (lossless)" }
+func (jsonExporter) Extension() string { return ".json" }
+
+// Export writes a Document holding the sessions
+func (jsonExporter) Export(w io.Writer, sessions []models.ChatSession) error {
+	if sessions == nil {
+		sessions = []models.ChatSession{}
+	}
+	doc := Document{
+		Format:     JSONFormat,
+		Version:    JSONVersion,
+		ExportedAt: time.Now().UTC(),
+		Sessions:   sessions,
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write JSON: %w", err)
+	}
+	return nil
+}
+
+// chatLine is one conversation in OpenAI chat format
+type chatLine struct {
+	Messages []chatMessage `json:"messages"`
+}
+
+// chatMessage is a message in OpenAI chat format
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// jsonlExporter writes one OpenAI-style chat conversation per line, as used for
+// fine-tuning and evaluation datasets
+type jsonlExporter struct{}
+
+func (jsonlExporter) Name() string      { return "jsonl" }
+func (jsonlExporter) Title() string     { return "OpenAI chat JSONL" }
+func (jsonlExporter) Extension() string { return ".jsonl" }
+
+// Export writes each session with messages as a line of role/content messages,
+// starting with its system prompt
+func (jsonlExporter) Export(w io.Writer, sessions []models.ChatSession) error {
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	encoder.SetEscapeHTML(false)
+	for _, session := range sessions {
+		line := chatLine{}
+		if session.SystemPrompt != "" {
+			line.Messages = append(line.Messages, chatMessage{Role: "system", Content: session.SystemPrompt})
+		}
+		for _, msg := range session.Messages {
+			if msg.Content == "" {
+				continue
+			}
+			role := "assistant"
+			if msg.Sender == "user" {
+				role = "user"
+			}
+			line.Messages = append(line.Messages, chatMessage{Role: role, Content: msg.Content})
+		}
+		if len(line.Messages) == 0 {
+			continue
+		}
+		if err := encoder.Encode(line); err != nil {
+			return fmt.Errorf("failed to write JSONL: %w", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write JSONL: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// markdownExporter writes sessions as Markdown. Messages are already Markdown, so
+// they are copied as written, code blocks included.
+type markdownExporter struct{}
+
+func (markdownExporter) Name() string      { return "markdown" }
+func (markdownExporter) Title() string     { return "Markdown" }
+func (markdownExporter) Extension() string { return ".md" }
+
+// Export writes each session as a section with its settings and messages
+func (markdownExporter) Export(w io.Writer, sessions []models.ChatSession) error {
+	bw := bufio.NewWriter(w)
+	for i, session := range sessions {
+		if i > 0 {
+			bw.WriteString("\n---\n\n")
+		}
+		writeMarkdownSession(bw, session)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write Markdown: %w", err)
+	}
+	return nil
+}
+
+// writeMarkdownSession writes one session
+func writeMarkdownSession(w *bufio.Writer, session models.ChatSession) {
+	fmt.Fprintf(w, "# %s\n\n", session.Name)
+
+	for _, field := range sessionFields(session) {
+		fmt.Fprintf(w, "- **%s:** %s\n", field.label, field.value)
+	}
+	if session.SystemPrompt != "" {
+		w.WriteString("\n**System prompt:**\n\n")
+		for _, line := range strings.Split(session.SystemPrompt, "\n") {
+			fmt.Fprintf(w, "> %s\n", line)
+		}
+	}
+
+	for _, msg := range session.Messages {
+		heading := senderLabel(msg.Sender)
+		if stamp := formatTime(msg.Timestamp); stamp != "" {
+			heading += " · " + stamp
+		}
+		fmt.Fprintf(w, "\n## %s\n\n%s\n", heading, closeCodeFences(strings.TrimRight(msg.Content, "\n")))
+	}
+}
+
+// closeCodeFences closes a code block left open, as in an answer cut off while
+// streaming, so it does not swallow the rest of the document
+func closeCodeFences(content string) string {
+	open := false
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			open = !open
+		}
+	}
+	if open {
+		return content + "\n```"
+	}
+	return content
+}
+
+// sessionField is a labelled session setting shown in human-readable exports
+type sessionField struct {
+	label string
+	value string
+}
+
+// sessionFields lists the settings of a session worth showing, skipping empty ones
+func sessionFields(session models.ChatSession) []sessionField {
+	var fields []sessionField
+	add := func(label, value string) {
+		if value != "" {
+			fields = append(fields, sessionField{label, value})
+		}
+	}
+	add("Created", formatTime(session.CreatedAt))
+	add("Updated", formatTime(session.UpdatedAt))
+	add("Model", session.Model)
+	add("Provider", session.Provider)
+	add("Temperature", fmt.Sprintf("%g", session.Temperature))
+	if session.MaxMessages > 0 {
+		add("Context messages", fmt.Sprintf("%d", session.MaxMessages))
+	}
+	add("Messages", fmt.Sprintf("%d", len(session.Messages)))
+	return fields
+}
@@ -0,0 +1,190 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// chatGPTConversation is a conversation in a ChatGPT data export (conversations.json).
+// Messages form a tree, since edited prompts and regenerated answers branch off;
+// current_node is the last message of the branch shown in ChatGPT.
+type chatGPTConversation struct {
+	Title            string                 `json:"title"`
+	CreateTime       float64                `json:"create_time"`
+	UpdateTime       float64                `json:"update_time"`
+	Mapping          map[string]chatGPTNode `json:"mapping"`
+	CurrentNode      string                 `json:"current_node"`
+	DefaultModelSlug string                 `json:"default_model_slug"`
+}
+
+// chatGPTNode is a node of the message tree
+type chatGPTNode struct {
+	Message  *chatGPTMessage `json:"message"`
+	Parent   string          `json:"parent"`
+	Children []string        `json:"children"`
+}
+
+// chatGPTMessage is a message of a ChatGPT conversation
+type chatGPTMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+		Text        string            `json:"text"`
+	} `json:"content"`
+	Recipient string `json:"recipient"`
+	Metadata  struct {
+		ModelSlug string `json:"model_slug"`
+		Hidden    bool   `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+// chatGPTImporter reads the conversations.json file of a ChatGPT data export
+type chatGPTImporter struct{}
+
+func (chatGPTImporter) Name() string  { return "chatgpt" }
+func (chatGPTImporter) Title() string { return "ChatGPT export" }
+
+// Detect looks for conversations with a message mapping
+func (chatGPTImporter) Detect(data []byte) bool {
+	conversations, err := decodeChatGPT(data)
+	if err != nil || len(conversations) == 0 {
+		return false
+	}
+	return conversations[0].Mapping != nil
+}
+
+// Parse converts each conversation, following the branch ChatGPT shows
+func (chatGPTImporter) Parse(data []byte) ([]models.ChatSession, error) {
+	conversations, err := decodeChatGPT(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]models.ChatSession, 0, len(conversations))
+	for _, conv := range conversations {
+		session := models.ChatSession{
+			Name:      conv.Title,
+			CreatedAt: unixTime(conv.CreateTime),
+			UpdatedAt: unixTime(conv.UpdateTime),
+			Model:     conv.DefaultModelSlug,
+		}
+		for _, msg := range conv.branch() {
+			if msg.Metadata.Hidden {
+				continue
+			}
+			text := msg.text()
+			switch msg.Author.Role {
+			case "system":
+				// Custom instructions
+				if session.SystemPrompt == "" {
+					session.SystemPrompt = text
+				}
+				continue
+			case "user":
+			case "assistant":
+				// Messages to tools (browsing, code interpreter) are not part of the answer
+				if msg.Recipient != "" && msg.Recipient != "all" {
+					continue
+				}
+				if msg.Metadata.ModelSlug != "" {
+					session.Model = msg.Metadata.ModelSlug
+				}
+			default:
+				continue
+			}
+
+			sender := "llm"
+			if msg.Author.Role == "user" {
+				sender = "user"
+			}
+			session.Messages = append(session.Messages, models.ChatMessage{
+				Sender:    sender,
+				Content:   text,
+				Timestamp: unixTime(msg.CreateTime),
+			})
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// decodeChatGPT reads a list of conversations or a single one
+func decodeChatGPT(data []byte) ([]chatGPTConversation, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var conv chatGPTConversation
+		if err := json.Unmarshal(data, &conv); err != nil {
+			return nil, fmt.Errorf("failed to parse conversation: %w", err)
+		}
+		return []chatGPTConversation{conv}, nil
+	}
+
+	var conversations []chatGPTConversation
+	if err := json.Unmarshal(data, &conversations); err != nil {
+		return nil, fmt.Errorf("failed to parse conversations: %w", err)
+	}
+	return conversations, nil
+}
+
+// branch returns the messages from the root to the current node. Without a current
+// node it follows the latest child from the root.
+func (conv chatGPTConversation) branch() []*chatGPTMessage {
+	var path []*chatGPTMessage
+	if _, ok := conv.Mapping[conv.CurrentNode]; ok {
+		seen := make(map[string]bool)
+		for id := conv.CurrentNode; id != "" && !seen[id]; id = conv.Mapping[id].Parent {
+			seen[id] = true
+			if node := conv.Mapping[id]; node.Message != nil {
+				path = append(path, node.Message)
+			}
+		}
+		for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+			path[i], path[j] = path[j], path[i]
+		}
+		return path
+	}
+
+	root := ""
+	for id, node := range conv.Mapping {
+		if node.Parent == "" {
+			root = id
+			break
+		}
+	}
+	seen := make(map[string]bool)
+	for id := root; id != "" && !seen[id]; {
+		seen[id] = true
+		node := conv.Mapping[id]
+		if node.Message != nil {
+			path = append(path, node.Message)
+		}
+		id = ""
+		if len(node.Children) > 0 {
+			id = node.Children[len(node.Children)-1]
+		}
+	}
+	return path
+}
+
+// text joins the text parts of a message, skipping images and other attachments
+func (msg *chatGPTMessage) text() string {
+	if msg.Content.Text != "" {
+		return msg.Content.Text
+	}
+	var parts []string
+	for _, raw := range msg.Content.Parts {
+		var part string
+		if json.Unmarshal(raw, &part) == nil && part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,209 @@
+// Package importer reads conversations exported from other chat tools (ChatGPT,
+// Open WebUI, Ollama CLI transcripts and OllamaChat's own JSON export) and turns
+// them into chat sessions. Importing is done in two steps: Preview parses a file and
+// marks conversations that are already stored, then Save writes the chosen ones.
+// Formats register themselves, so new ones only need an Importer implementation.
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/constants"
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// Importer reads conversations in one file format
+type Importer interface {
+	Name() string  // Identifier used by the import command, e.g. "chatgpt"
+	Title() string // Name shown in the UI
+	// Detect reports whether data looks like this format
+	Detect(data []byte) bool
+	// Parse converts data into sessions. Sessions need no ID or defaults; Normalize
+	// fills them in.
+	Parse(data []byte) ([]models.ChatSession, error)
+}
+
+// registry holds the known formats in registration order, which is also the order
+// Detect tries them in
+var registry []Importer
+
+// The built-in formats. The plain-text Ollama transcript accepts almost anything,
+// so it is tried last.
+func init() {
+	Register(nativeImporter{})
+	Register(chatGPTImporter{})
+	Register(openWebUIImporter{})
+	Register(ollamaCLIImporter{})
+}
+
+// Register adds a format; registering a name twice panics
+func Register(i Importer) {
+	if _, exists := Get(i.Name()); exists {
+		panic(fmt.Sprintf("import format %q registered twice", i.Name()))
+	}
+	registry = append(registry, i)
+}
+
+// Get returns the format registered under name
+func Get(name string) (Importer, bool) {
+	for _, i := range registry {
+		if strings.EqualFold(i.Name(), name) {
+			return i, true
+		}
+	}
+	return nil, false
+}
+
+// Formats returns every registered format in registration order
+func Formats() []Importer {
+	return append([]Importer(nil), registry...)
+}
+
+// Names returns the names of every registered format
+func Names() []string {
+	names := make([]string, len(registry))
+	for i, imp := range registry {
+		names[i] = imp.Name()
+	}
+	return names
+}
+
+// Detect returns the first format that recognizes data
+func Detect(data []byte) (Importer, error) {
+	for _, i := range registry {
+		if i.Detect(data) {
+			return i, nil
+		}
+	}
+	return nil, fmt.Errorf("unrecognized import format (supported: %s)", strings.Join(Names(), ", "))
+}
+
+// Parse reads data with format, or with the detected format when format is empty
+// or "auto", and returns normalized sessions ready to be saved
+func Parse(format string, data []byte) (Importer, []models.ChatSession, error) {
+	var imp Importer
+	if format == "" || strings.EqualFold(format, "auto") {
+		detected, err := Detect(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		imp = detected
+	} else {
+		found, ok := Get(format)
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown import format %q (use %s)", format, strings.Join(Names(), ", "))
+		}
+		imp = found
+	}
+
+	parsed, err := imp.Parse(data)
+	if err != nil {
+		return imp, nil, fmt.Errorf("failed to read %s export: %w", imp.Title(), err)
+	}
+
+	sessions := make([]models.ChatSession, 0, len(parsed))
+	for _, session := range parsed {
+		if session, ok := Normalize(session); ok {
+			sessions = append(sessions, session)
+		}
+	}
+	return imp, sessions, nil
+}
+
+// Normalize gives an imported session a new ID, a name, timestamps and the default
+// session settings it lacks, and drops empty messages. It reports false for a
+// session without messages.
+func Normalize(session models.ChatSession) (models.ChatSession, bool) {
+	messages := make([]models.ChatMessage, 0, len(session.Messages))
+	for _, msg := range session.Messages {
+		if strings.TrimSpace(msg.Content) == "" {
+			continue
+		}
+		if msg.Sender != "user" {
+			msg.Sender = "llm"
+		}
+		messages = append(messages, msg)
+	}
+	if len(messages) == 0 {
+		return session, false
+	}
+	session.Messages = messages
+
+	// Fill in missing timestamps from the neighbouring ones
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = firstTimestamp(messages)
+	}
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+	last := session.CreatedAt
+	for i := range session.Messages {
+		if session.Messages[i].Timestamp.IsZero() {
+			session.Messages[i].Timestamp = last
+		}
+		last = session.Messages[i].Timestamp
+	}
+	if session.UpdatedAt.Before(last) {
+		session.UpdatedAt = last
+	}
+
+	session.ID = models.NewIDAt(session.CreatedAt)
+	session.Name = strings.TrimSpace(session.Name)
+	if session.Name == "" {
+		session.Name = untitledName(session.Messages)
+	}
+	if session.Provider == "" {
+		session.Provider = constants.DefaultProvider
+	}
+	if session.MaxMessages <= 0 {
+		session.MaxMessages = constants.DefaultMaxMessages
+	}
+	if session.Temperature == 0 {
+		session.Temperature = constants.DefaultTemperature
+	}
+	// Personas are local to a store and never match an imported ID
+	session.PersonaID = ""
+	return session, true
+}
+
+// firstTimestamp returns the earliest known message time, or the zero time
+func firstTimestamp(messages []models.ChatMessage) time.Time {
+	var first time.Time
+	for _, msg := range messages {
+		if !msg.Timestamp.IsZero() && (first.IsZero() || msg.Timestamp.Before(first)) {
+			first = msg.Timestamp
+		}
+	}
+	return first
+}
+
+// untitledName names a session after its first user message
+func untitledName(messages []models.ChatMessage) string {
+	for _, msg := range messages {
+		if msg.Sender != "user" {
+			continue
+		}
+		words := strings.Fields(msg.Content)
+		if len(words) > 8 {
+			words = append(words[:8], "…")
+		}
+		return strings.Join(words, " ")
+	}
+	return "Imported chat"
+}
+
+// unixTime converts seconds since the epoch, possibly fractional, to a time; zero
+// and negative values give the zero time
+func unixTime(seconds float64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	// Some exports use milliseconds
+	if seconds > 1e12 {
+		seconds /= 1000
+	}
+	sec := int64(seconds)
+	return time.Unix(sec, int64((seconds-float64(sec))*1e9))
+}
@@ -0,0 +1,243 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/ashprao/ollamachat/internal/export"
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/storage"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+// chatGPTExport returns a conversation whose first answer was regenerated. The
+// branch ChatGPT shows ends at currentNode; the regenerated answer is the latest child.
+func chatGPTExport(currentNode string) string {
+	return `[{
+	"title": "Go question",
+	"create_time": 1709294400.5,
+	"update_time": 1709294500,
+	"current_node": "` + currentNode + `",
+	"default_model_slug": "gpt-4",
+	"mapping": {
+		"root": {"message": null, "parent": null, "children": ["sys"]},
+		"sys": {"parent": "root", "children": ["u1"], "message": {"author": {"role": "system"},
+			"content": {"content_type": "text", "parts": [""]}, "metadata": {"is_visually_hidden_from_conversation": true}}},
+		"u1": {"parent": "sys", "children": ["a1", "a2"], "message": {"author": {"role": "user"}, "create_time": 1709294401,
+			"content": {"content_type": "text", "parts": ["What is Go?", {"asset_pointer": "file-service://image"}]}}},
+		"a1": {"parent": "u1", "children": ["tool"], "message": {"author": {"role": "assistant"}, "create_time": 1709294402, "recipient": "all",
+			"content": {"content_type": "text", "parts": ["A programming language."]}, "metadata": {"model_slug": "gpt-4o"}}},
+		"tool": {"parent": "a1", "children": ["u2"], "message": {"author": {"role": "assistant"}, "recipient": "python",
+			"content": {"content_type": "code", "text": "print(1)"}}},
+		"u2": {"parent": "tool", "children": [], "message": {"author": {"role": "user"}, "create_time": 1709294403,
+			"content": {"content_type": "text", "parts": ["Thanks"]}}},
+		"a2": {"parent": "u1", "children": [], "message": {"author": {"role": "assistant"}, "create_time": 1709294404,
+			"content": {"content_type": "text", "parts": ["Regenerated answer."]}}}
+	}
+}]`
+}
+
+const openWebUIChats = `[{
+	"title": "Web chat",
+	"created_at": 1709294400,
+	"chat": {
+		"models": ["llama3.2:latest"],
+		"params": {"system": "Be brief.", "temperature": 0.2},
+		"history": {
+			"currentId": "m3",
+			"messages": {
+				"m1": {"id": "m1", "parentId": null, "role": "user", "content": "Hi", "timestamp": 1709294401},
+				"m2": {"id": "m2", "parentId": "m1", "role": "assistant", "content": "Old answer", "model": "llama3.2:latest"},
+				"m3": {"id": "m3", "parentId": "m1", "role": "assistant", "content": "New answer", "model": "qwen2.5:7b"}
+			}
+		}
+	}
+}]`
+
+const ollamaTranscript = `$ ollama run llama3.2
+>>> /set parameter temperature 0.5
+Set parameter 'temperature' to '0.5'
+>>> """Write a
+... haiku"""
+Leaves fall slowly down
+
+>>> Send a message (/? for help)
+`
+
+func TestParse(t *testing.T) {
+	type message struct{ sender, content string }
+	tests := []struct {
+		name         string
+		data         string
+		wantFormat   string
+		wantName     string
+		wantModel    string
+		wantPrompt   string
+		wantMessages []message
+	}{
+		{"chatgpt follows current_node", chatGPTExport("u2"), "chatgpt", "Go question", "gpt-4o", "",
+			[]message{{"user", "What is Go?"}, {"llm", "A programming language."}, {"user", "Thanks"}}},
+		{"chatgpt without current_node follows the latest child", chatGPTExport(""), "chatgpt", "Go question", "gpt-4", "",
+			[]message{{"user", "What is Go?"}, {"llm", "Regenerated answer."}}},
+		{"open webui follows currentId", openWebUIChats, "openwebui", "Web chat", "qwen2.5:7b", "Be brief.",
+			[]message{{"user", "Hi"}, {"llm", "New answer"}}},
+		{"ollama transcript", ollamaTranscript, "ollama", "Write a haiku", "llama3.2", "",
+			[]message{{"user", "Write a\nhaiku"}, {"llm", "Leaves fall slowly down"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imp, sessions, err := Parse("auto", []byte(tt.data))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if imp.Name() != tt.wantFormat {
+				t.Errorf("detected %q, want %q", imp.Name(), tt.wantFormat)
+			}
+			if len(sessions) != 1 {
+				t.Fatalf("got %d sessions, want 1", len(sessions))
+			}
+			session := sessions[0]
+			if session.Name != tt.wantName || session.Model != tt.wantModel || session.SystemPrompt != tt.wantPrompt {
+				t.Errorf("session %q, model %q, prompt %q; want %q, %q, %q",
+					session.Name, session.Model, session.SystemPrompt, tt.wantName, tt.wantModel, tt.wantPrompt)
+			}
+			var got []message
+			for _, msg := range session.Messages {
+				got = append(got, message{msg.Sender, msg.Content})
+				if msg.Timestamp.IsZero() {
+					t.Errorf("message %q has no timestamp", msg.Content)
+				}
+			}
+			if len(got) != len(tt.wantMessages) {
+				t.Fatalf("messages %q, want %q", got, tt.wantMessages)
+			}
+			for i := range got {
+				if got[i] != tt.wantMessages[i] {
+					t.Errorf("message %d = %q, want %q", i, got[i], tt.wantMessages[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseAssignsNewIDs(t *testing.T) {
+	source := models.NewChatSession("Exported", "llama3.2:latest")
+	source.PersonaID = models.NewID()
+	source.Messages = []models.ChatMessage{models.NewChatMessage("user", "hello"), models.NewChatMessage("llm", "")}
+	empty := models.NewChatSession("No messages", "llama3.2:latest")
+
+	var buf bytes.Buffer
+	exporter, _ := export.Get("json")
+	if err := exporter.Export(&buf, []models.ChatSession{source, source, empty}); err != nil {
+		t.Fatal(err)
+	}
+
+	imp, sessions, err := Parse("", buf.Bytes())
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if imp.Name() != "ollamachat" {
+		t.Errorf("detected %q, want ollamachat", imp.Name())
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("got %d sessions, want the 2 with messages", len(sessions))
+	}
+	ids := make(map[string]bool)
+	for _, session := range sessions {
+		if session.ID == source.ID || !models.IsValidID(session.ID) || ids[session.ID] {
+			t.Errorf("imported under ID %q, want a new unique ID", session.ID)
+		}
+		ids[session.ID] = true
+		if session.PersonaID != "" {
+			t.Errorf("imported session kept persona %q", session.PersonaID)
+		}
+		if len(session.Messages) != 1 {
+			t.Errorf("empty message kept: %+v", session.Messages)
+		}
+	}
+}
+
+func TestParseRejectsMalformedInput(t *testing.T) {
+	tests := []struct {
+		name, format, data string
+	}{
+		{"empty", "auto", ""},
+		{"unrecognized", "auto", "just some notes\nwithout prompts"},
+		{"unknown format", "slack", "[]"},
+		{"truncated chatgpt", "chatgpt", `[{"title": "Cut off", "mapping": {`},
+		{"chatgpt of the wrong shape", "chatgpt", `{"mapping": []}`},
+		{"truncated open webui", "openwebui", `[{"chat": {"history": `},
+		{"newer native export", "ollamachat", `{"format": "ollamachat-export", "version": 99, "sessions": []}`},
+		{"native export without header", "ollamachat", `{"sessions": []}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, sessions, err := Parse(tt.format, []byte(tt.data)); err == nil {
+				t.Errorf("Parse succeeded with %d sessions", len(sessions))
+			}
+		})
+	}
+}
+
+func TestPreviewFindsDuplicates(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStorage(logger.NewLogger(slog.LevelError))
+
+	stored := models.NewChatSession("Stored", "llama3.2:latest")
+	stored.Messages = []models.ChatMessage{models.NewChatMessage("user", "hello"), models.NewChatMessage("llm", "hi")}
+	if err := store.SaveChatSession(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same messages with other whitespace and names, then a new conversation twice
+	same := stored
+	same.Name = "Renamed"
+	same.Messages = []models.ChatMessage{models.NewChatMessage("user", "hello\n"), models.NewChatMessage("assistant", " hi")}
+	other := models.NewChatSession("Other", "llama3.2:latest")
+	other.Messages = []models.ChatMessage{models.NewChatMessage("user", "something else")}
+
+	candidates, err := Preview(ctx, store, []models.ChatSession{same, other, other})
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	want := []Candidate{
+		{Duplicate: true, Existing: "Stored"},
+		{Duplicate: false},
+		{Duplicate: true},
+	}
+	for i, c := range candidates {
+		if c.Duplicate != want[i].Duplicate || c.Existing != want[i].Existing {
+			t.Errorf("candidate %d: duplicate %v of %q, want %v of %q", i, c.Duplicate, c.Existing, want[i].Duplicate, want[i].Existing)
+		}
+	}
+
+	report, err := Save(ctx, store, []models.ChatSession{other})
+	if err != nil || report.Sessions != 1 || report.Messages != 1 {
+		t.Errorf("Save = %+v, %v", report, err)
+	}
+	if _, err := store.LoadChatSession(ctx, other.ID); err != nil {
+		t.Errorf("saved session not stored: %v", err)
+	}
+}
+
+func TestNormalizeNamesUntitledSessions(t *testing.T) {
+	session, ok := Normalize(models.ChatSession{Messages: []models.ChatMessage{
+		{Sender: "llm", Content: "Hello"},
+		{Sender: "user", Content: strings.Repeat("word ", 12)},
+	}})
+	if !ok {
+		t.Fatal("Normalize dropped a session with messages")
+	}
+	if want := strings.Repeat("word ", 8) + "…"; session.Name != want {
+		t.Errorf("name %q, want %q", session.Name, want)
+	}
+	if session.CreatedAt.IsZero() || session.Messages[0].Timestamp.IsZero() {
+		t.Error("timestamps not filled in")
+	}
+	if _, ok := Normalize(models.ChatSession{Messages: []models.ChatMessage{{Sender: "user", Content: "  "}}}); ok {
+		t.Error("Normalize kept a session without messages")
+	}
+}
@@ -0,0 +1,39 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ashprao/ollamachat/internal/export"
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// nativeImporter reads OllamaChat's own lossless JSON export, so sessions can be
+// moved between installations
+type nativeImporter struct{}
+
+func (nativeImporter) Name() string  { return "ollamachat" }
+func (nativeImporter) Title() string { return "OllamaChat JSON export" }
+
+// Detect looks for the export document header
+func (nativeImporter) Detect(data []byte) bool {
+	var header struct {
+		Format string `json:"format"`
+	}
+	return json.Unmarshal(data, &header) == nil && header.Format == export.JSONFormat
+}
+
+// Parse returns the sessions of the document as exported
+func (nativeImporter) Parse(data []byte) ([]models.ChatSession, error) {
+	var doc export.Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse export: %w", err)
+	}
+	if doc.Format != export.JSONFormat {
+		return nil, fmt.Errorf("not an OllamaChat export")
+	}
+	if doc.Version > export.JSONVersion {
+		return nil, fmt.Errorf("export version %d is newer than supported version %d", doc.Version, export.JSONVersion)
+	}
+	return doc.Sessions, nil
+}
@@ -0,0 +1,118 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// Prompts printed by the ollama CLI
+const (
+	ollamaPrompt        = ">>> "
+	ollamaContinuation  = "... "
+	ollamaPlaceholder   = "Send a message (/? for help)"
+	ollamaMultilineMark = `"""`
+)
+
+// ollamaRunCommand matches the shell command starting a CLI session, with or without
+// a shell prompt before it, and captures the model
+var ollamaRunCommand = regexp.MustCompile(`^\s*(?:\S*[$%#>]\s+)?ollama\s+run\s+(\S+)`)
+
+// ollamaCLIImporter reads transcripts of `ollama run` sessions copied or logged from
+// a terminal. Each `ollama run <model>` line starts a new session; lines after
+// ">>> " are prompts and the text up to the next prompt is the answer. Transcripts
+// carry no timestamps, so imported messages get the import time.
+type ollamaCLIImporter struct{}
+
+func (ollamaCLIImporter) Name() string  { return "ollama" }
+func (ollamaCLIImporter) Title() string { return "Ollama CLI transcript" }
+
+// Detect looks for a prompt line
+func (ollamaCLIImporter) Detect(data []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), ollamaPrompt) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse splits the transcript into sessions of prompts and answers
+func (ollamaCLIImporter) Parse(data []byte) ([]models.ChatSession, error) {
+	var sessions []models.ChatSession
+	var session *models.ChatSession
+	var prompt, answer []string
+	inPrompt := false // Reading the continuation lines of a prompt
+	skipping := false // Reading the output of a /command
+
+	flush := func() {
+		if session == nil {
+			return
+		}
+		if len(prompt) > 0 {
+			content := strings.TrimSpace(strings.Join(prompt, "\n"))
+			content = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(content, ollamaMultilineMark), ollamaMultilineMark))
+			session.Messages = append(session.Messages, models.ChatMessage{Sender: "user", Content: content})
+		}
+		if content := strings.TrimSpace(strings.Join(answer, "\n")); content != "" {
+			session.Messages = append(session.Messages, models.ChatMessage{Sender: "llm", Content: content})
+		}
+		prompt, answer = nil, nil
+	}
+	startSession := func(model string) {
+		flush()
+		sessions = append(sessions, models.ChatSession{Model: model})
+		session = &sessions[len(sessions)-1]
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if match := ollamaRunCommand.FindStringSubmatch(line); match != nil && !strings.HasPrefix(line, ollamaPrompt) {
+			startSession(match[1])
+			inPrompt, skipping = false, false
+			continue
+		}
+
+		if strings.HasPrefix(line, ollamaPrompt) || line == strings.TrimSpace(ollamaPrompt) {
+			text := strings.TrimPrefix(strings.TrimPrefix(line, ollamaPrompt), strings.TrimSpace(ollamaPrompt))
+			if session == nil {
+				startSession("")
+			}
+			flush()
+			trimmed := strings.TrimSpace(text)
+			skipping = strings.HasPrefix(trimmed, "/")
+			inPrompt = false
+			if skipping || trimmed == "" || trimmed == ollamaPlaceholder {
+				continue
+			}
+			prompt = []string{text}
+			inPrompt = true
+			continue
+		}
+
+		switch {
+		case skipping || session == nil:
+			// /command output or text before the first prompt
+		case inPrompt && strings.HasPrefix(line, ollamaContinuation):
+			prompt = append(prompt, strings.TrimPrefix(line, ollamaContinuation))
+		default:
+			inPrompt = false
+			answer = append(answer, line)
+		}
+	}
+	flush()
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	}
+
+	return sessions, nil
+}
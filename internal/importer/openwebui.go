@@ -0,0 +1,160 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// openWebUIExport is an entry of an Open WebUI chat export
+type openWebUIExport struct {
+	Title     string        `json:"title"`
+	CreatedAt float64       `json:"created_at"`
+	UpdatedAt float64       `json:"updated_at"`
+	Chat      openWebUIChat `json:"chat"`
+}
+
+// openWebUIChat is the conversation of an Open WebUI chat. history holds every
+// branch of the conversation; messages is the flat list older versions wrote.
+type openWebUIChat struct {
+	Title  string   `json:"title"`
+	Models []string `json:"models"`
+	Params struct {
+		System      string   `json:"system"`
+		Temperature *float64 `json:"temperature"`
+	} `json:"params"`
+	History struct {
+		Messages  map[string]openWebUIMessage `json:"messages"`
+		CurrentID string                      `json:"currentId"`
+	} `json:"history"`
+	Messages  []openWebUIMessage `json:"messages"`
+	Timestamp float64            `json:"timestamp"`
+}
+
+// openWebUIMessage is a message of an Open WebUI chat
+type openWebUIMessage struct {
+	ID        string  `json:"id"`
+	ParentID  string  `json:"parentId"`
+	Role      string  `json:"role"`
+	Content   string  `json:"content"`
+	Timestamp float64 `json:"timestamp"`
+	Model     string  `json:"model"`
+}
+
+// openWebUIImporter reads chats exported from Open WebUI ("Export All Chats" or a
+// single chat downloaded as JSON)
+type openWebUIImporter struct{}
+
+func (openWebUIImporter) Name() string  { return "openwebui" }
+func (openWebUIImporter) Title() string { return "Open WebUI export" }
+
+// Detect looks for chats with a message history or list
+func (openWebUIImporter) Detect(data []byte) bool {
+	chats, err := decodeOpenWebUI(data)
+	if err != nil || len(chats) == 0 {
+		return false
+	}
+	chat := chats[0].Chat
+	return len(chat.History.Messages) > 0 || len(chat.Messages) > 0
+}
+
+// Parse converts each chat, following the branch Open WebUI shows
+func (openWebUIImporter) Parse(data []byte) ([]models.ChatSession, error) {
+	chats, err := decodeOpenWebUI(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]models.ChatSession, 0, len(chats))
+	for _, entry := range chats {
+		chat := entry.Chat
+		session := models.ChatSession{
+			Name:         entry.Title,
+			CreatedAt:    unixTime(entry.CreatedAt),
+			UpdatedAt:    unixTime(entry.UpdatedAt),
+			SystemPrompt: chat.Params.System,
+		}
+		if session.Name == "" {
+			session.Name = chat.Title
+		}
+		if session.CreatedAt.IsZero() {
+			session.CreatedAt = unixTime(chat.Timestamp)
+		}
+		if len(chat.Models) > 0 {
+			session.Model = chat.Models[0]
+		}
+		if chat.Params.Temperature != nil {
+			session.Temperature = *chat.Params.Temperature
+		}
+
+		for _, msg := range chat.branch() {
+			sender := "llm"
+			switch msg.Role {
+			case "user":
+				sender = "user"
+			case "assistant":
+				if msg.Model != "" {
+					session.Model = msg.Model
+				}
+			default:
+				continue
+			}
+			session.Messages = append(session.Messages, models.ChatMessage{
+				Sender:    sender,
+				Content:   msg.Content,
+				Timestamp: unixTime(msg.Timestamp),
+			})
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// decodeOpenWebUI reads a list of exported chats, a single one, or a bare chat
+func decodeOpenWebUI(data []byte) ([]openWebUIExport, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var entry openWebUIExport
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse chat: %w", err)
+		}
+		if len(entry.Chat.History.Messages) == 0 && len(entry.Chat.Messages) == 0 {
+			if err := json.Unmarshal(data, &entry.Chat); err != nil {
+				return nil, fmt.Errorf("failed to parse chat: %w", err)
+			}
+		}
+		return []openWebUIExport{entry}, nil
+	}
+
+	var chats []openWebUIExport
+	if err := json.Unmarshal(data, &chats); err != nil {
+		return nil, fmt.Errorf("failed to parse chats: %w", err)
+	}
+	return chats, nil
+}
+
+// branch returns the messages from the first to the current one, or the flat
+// message list when the chat has no history
+func (chat openWebUIChat) branch() []openWebUIMessage {
+	if _, ok := chat.History.Messages[chat.History.CurrentID]; !ok {
+		return chat.Messages
+	}
+
+	var path []openWebUIMessage
+	seen := make(map[string]bool)
+	for id := chat.History.CurrentID; id != "" && !seen[id]; {
+		seen[id] = true
+		msg, ok := chat.History.Messages[id]
+		if !ok {
+			break
+		}
+		path = append(path, msg)
+		id = msg.ParentID
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
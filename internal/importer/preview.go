@@ -0,0 +1,100 @@
+package importer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/storage"
+)
+
+// Candidate is a parsed session awaiting confirmation
+type Candidate struct {
+	Session   models.ChatSession
+	Duplicate bool   // The same conversation is stored already or appears earlier in the file
+	Existing  string // Name of the stored session it duplicates, if any
+}
+
+// Report summarizes what Save wrote
+type Report struct {
+	Sessions int
+	Messages int
+	Skipped  []string // Sessions that could not be saved, with the reason
+}
+
+// Preview compares parsed sessions with the stored ones. A session is a duplicate
+// when a stored session, or an earlier session of the same import, has the same
+// messages, whatever its name or settings.
+func Preview(ctx context.Context, store storage.Storage, sessions []models.ChatSession) ([]Candidate, error) {
+	stored, err := store.ListChatSessions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	known := make(map[string]string, len(stored))
+	for _, session := range stored {
+		known[Fingerprint(session)] = session.Name
+	}
+
+	candidates := make([]Candidate, 0, len(sessions))
+	seen := make(map[string]bool, len(sessions))
+	for _, session := range sessions {
+		candidate := Candidate{Session: session}
+		fingerprint := Fingerprint(session)
+		if name, ok := known[fingerprint]; ok {
+			candidate.Duplicate = true
+			candidate.Existing = name
+		} else if seen[fingerprint] {
+			candidate.Duplicate = true
+		}
+		seen[fingerprint] = true
+		candidates = append(candidates, candidate)
+	}
+	return candidates, nil
+}
+
+// Fingerprint identifies a conversation by its messages, ignoring timestamps and
+// surrounding whitespace, which exports do not all keep
+func Fingerprint(session models.ChatSession) string {
+	hash := sha256.New()
+	for _, msg := range session.Messages {
+		content := strings.TrimSpace(msg.Content)
+		if content == "" {
+			continue
+		}
+		sender := "llm"
+		if msg.Sender == "user" {
+			sender = "user"
+		}
+		fmt.Fprintf(hash, "%s\x00%s\x01", sender, content)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Save stores sessions, keeping their timestamps when the storage allows it.
+// Sessions that fail to save are recorded in the report and do not stop the import.
+func Save(ctx context.Context, store storage.Storage, sessions []models.ChatSession) (Report, error) {
+	var report Report
+	importer, preservesTimestamps := store.(storage.SessionImporter)
+	for _, session := range sessions {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		var err error
+		if preservesTimestamps {
+			err = importer.ImportChatSession(ctx, session)
+		} else {
+			err = store.SaveChatSession(ctx, session)
+		}
+		if err != nil {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("%s: %v", session.Name, err))
+			continue
+		}
+		report.Sessions++
+		report.Messages += len(session.Messages)
+	}
+	return report, nil
+}
@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Embedder turns text into embedding vectors
+type Embedder interface {
+	// Embed returns one vector per input, in order
+	Embed(ctx context.Context, model string, inputs []string) ([][]float32, error)
+}
+
+// Embed returns embeddings for the inputs from an Ollama embedding model
+func (o *OllamaProvider) Embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	o.logger.Debug("Requesting embeddings", "model", model, "inputs", len(inputs))
+
+	jsonBody, err := json.Marshal(map[string]interface{}{
+		"model": model,
+		"input": inputs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/api/embed", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		o.logger.Error("Failed to send embedding request to Ollama", "error", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		o.logger.Error("Unexpected status code from Ollama", "status_code", resp.StatusCode, "model", model)
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(result.Embeddings) != len(inputs) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(result.Embeddings))
+	}
+	return result.Embeddings, nil
+}
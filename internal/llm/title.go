@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// maxTitleLength caps generated session titles (in runes)
+const maxTitleLength = 60
+
+// titleSourceChars limits how much of each message is sent to the title model (in runes)
+const titleSourceChars = 1000
+
+// GenerateTitle asks the provider for a short title summarizing the given messages
+func GenerateTitle(ctx context.Context, provider Provider, model string, messages []models.ChatMessage) (string, error) {
+	if len(messages) == 0 {
+		return "", fmt.Errorf("no messages to generate a title from")
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString("Write a short title (at most 6 words) for the following conversation. ")
+	prompt.WriteString("Reply with the title only, without quotes or punctuation at the end.\n\n")
+	for _, msg := range messages {
+		content := msg.Content
+		if runes := []rune(content); len(runes) > titleSourceChars {
+			content = string(runes[:titleSourceChars])
+		}
+		if msg.Sender == "user" {
+			prompt.WriteString("user: " + content + "\n")
+		} else {
+			prompt.WriteString("llm: " + content + "\n")
+		}
+	}
+	prompt.WriteString("\ntitle:")
+
+	var response strings.Builder
+	err := provider.SendQueryWithOptions(ctx, model, prompt.String(), QueryOptions{
+		Temperature: 0.2,
+		MaxTokens:   24,
+	}, func(chunk string, isNewStream bool) {
+		response.WriteString(chunk)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate title: %w", err)
+	}
+
+	title := CleanTitle(response.String())
+	if title == "" {
+		return "", fmt.Errorf("model returned an empty title")
+	}
+	return title, nil
+}
+
+// CleanTitle normalizes a model-generated title to a single short line
+func CleanTitle(raw string) string {
+	title := strings.TrimSpace(raw)
+
+	// Keep only the first non-empty line
+	for _, line := range strings.Split(title, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			title = line
+			break
+		}
+	}
+
+	title = strings.TrimPrefix(title, "Title:")
+	title = strings.TrimPrefix(title, "title:")
+	title = strings.Trim(title, " \t\"'`*#.")
+	title = strings.Join(strings.Fields(title), " ")
+
+	runes := []rune(title)
+	if len(runes) > maxTitleLength {
+		title = strings.TrimSpace(string(runes[:maxTitleLength])) + "…"
+	}
+	return title
+}
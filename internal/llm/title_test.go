@@ -0,0 +1,45 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// promptRecorder is a provider that records the prompt it is sent and replies with a title
+type promptRecorder struct {
+	Provider
+	prompt string
+}
+
+func (p *promptRecorder) SendQueryWithOptions(ctx context.Context, model, query string, options QueryOptions, onUpdate StreamCallback) error {
+	p.prompt = query
+	onUpdate("A title", true)
+	return nil
+}
+
+func TestGenerateTitleTruncatesByRune(t *testing.T) {
+	// Each rune takes several bytes, so a cut by bytes would split one
+	content := strings.Repeat("é日🙂", titleSourceChars)
+	provider := &promptRecorder{}
+
+	title, err := GenerateTitle(context.Background(), provider, "model", []models.ChatMessage{
+		models.NewChatMessage("user", content),
+	})
+	if err != nil {
+		t.Fatalf("GenerateTitle: %v", err)
+	}
+	if title != "A title" {
+		t.Errorf("title = %q, want %q", title, "A title")
+	}
+	if !utf8.ValidString(provider.prompt) {
+		t.Fatal("prompt holds a split rune")
+	}
+	want := "user: " + string([]rune(content)[:titleSourceChars]) + "\n"
+	if !strings.Contains(provider.prompt, want) {
+		t.Errorf("prompt does not hold the first %d runes of the message", titleSourceChars)
+	}
+}
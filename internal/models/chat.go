@@ -32,6 +32,34 @@ type ChatSession struct {
 	Provider    string  `json:"provider"`     // Selected provider for this session
 	MaxMessages int     `json:"max_messages"` // Max context messages for this session
 	Temperature float64 `json:"temperature"`  // Model temperature setting
+
+	// Persona settings
+	SystemPrompt string `json:"system_prompt,omitempty"` // System prompt for this session (empty uses default)
+	PersonaID    string `json:"persona_id,omitempty"`    // Persona the session was created from, if any
+
+	// Naming state
+	ManuallyNamed bool `json:"manually_named,omitempty"` // Name was set by the user and must not be replaced
+	AutoTitled    bool `json:"auto_titled,omitempty"`    // Name was generated from the conversation
+
+	// Pinned sessions are never removed by retention rules
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// SessionSummary is the listing form of a chat session: everything the session
+// sidebar shows, without the messages
+type SessionSummary struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Model         string    `json:"model,omitempty"`
+	Provider      string    `json:"provider,omitempty"`
+	PersonaID     string    `json:"persona_id,omitempty"`
+	MessageCount  int       `json:"message_count"`
+	ManuallyNamed bool      `json:"manually_named,omitempty"`
+	AutoTitled    bool      `json:"auto_titled,omitempty"`
+	Pinned        bool      `json:"pinned,omitempty"`
+	Size          int64     `json:"size,omitempty"` // Approximate bytes the session takes in storage
 }
 
 // NewChatMessage creates a new chat message with current timestamp
@@ -47,7 +75,7 @@ func NewChatMessage(sender, content string) ChatMessage {
 func NewChatSession(name, model string) ChatSession {
 	now := time.Now()
 	return ChatSession{
-		ID:          generateSessionID(),
+		ID:          NewID(),
 		Name:        name,
 		Messages:    []ChatMessage{},
 		CreatedAt:   now,
@@ -63,7 +91,7 @@ func NewChatSession(name, model string) ChatSession {
 func NewChatSessionWithConfig(name, model string, maxMessages int, temperature float64) ChatSession {
 	now := time.Now()
 	return ChatSession{
-		ID:          generateSessionID(),
+		ID:          NewID(),
 		Name:        name,
 		Messages:    []ChatMessage{},
 		CreatedAt:   now,
@@ -90,6 +118,66 @@ func (cs *ChatSession) UpdateSessionSettings(model, provider string, maxMessages
 	cs.UpdatedAt = time.Now()
 }
 
+// GetSystemPrompt returns the session system prompt, falling back to the default
+func (cs *ChatSession) GetSystemPrompt() string {
+	if cs.SystemPrompt == "" {
+		return constants.DefaultSystemPrompt
+	}
+	return cs.SystemPrompt
+}
+
+// Rename sets a user-chosen session name, which automatic titling never overwrites
+func (cs *ChatSession) Rename(name string) {
+	cs.Name = name
+	cs.ManuallyNamed = true
+	cs.UpdatedAt = time.Now()
+}
+
+// SetAutoTitle applies a generated title unless the user has named the session
+func (cs *ChatSession) SetAutoTitle(title string) bool {
+	if cs.ManuallyNamed || title == "" {
+		return false
+	}
+	cs.Name = title
+	cs.AutoTitled = true
+	return true
+}
+
+// NeedsAutoTitle reports whether the session should get a generated title
+func (cs *ChatSession) NeedsAutoTitle() bool {
+	if cs.ManuallyNamed || cs.AutoTitled {
+		return false
+	}
+
+	// Wait for the first complete exchange
+	hasUser, hasLLM := false, false
+	for _, msg := range cs.Messages {
+		if msg.Sender == "user" {
+			hasUser = true
+		} else {
+			hasLLM = true
+		}
+	}
+	return hasUser && hasLLM
+}
+
+// Summary returns the session's listing summary
+func (cs *ChatSession) Summary() SessionSummary {
+	return SessionSummary{
+		ID:            cs.ID,
+		Name:          cs.Name,
+		CreatedAt:     cs.CreatedAt,
+		UpdatedAt:     cs.UpdatedAt,
+		Model:         cs.Model,
+		Provider:      cs.Provider,
+		PersonaID:     cs.PersonaID,
+		MessageCount:  len(cs.Messages),
+		ManuallyNamed: cs.ManuallyNamed,
+		AutoTitled:    cs.AutoTitled,
+		Pinned:        cs.Pinned,
+	}
+}
+
 // GetContextMessages returns the last N messages for context, based on session settings
 func (cs *ChatSession) GetContextMessages() []ChatMessage {
 	if len(cs.Messages) <= cs.MaxMessages {
@@ -97,8 +185,3 @@ func (cs *ChatSession) GetContextMessages() []ChatMessage {
 	}
 	return cs.Messages[len(cs.Messages)-cs.MaxMessages:]
 }
-
-// generateSessionID generates a simple session ID (placeholder implementation)
-func generateSessionID() string {
-	return time.Now().Format("20060102-150405")
-}
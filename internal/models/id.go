@@ -0,0 +1,121 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// IDLength is the length of identifiers produced by NewID
+const IDLength = 26
+
+// crockford is the Crockford base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// idGenerator produces monotonic ULIDs: IDs created within the same millisecond
+// increment the random part instead of drawing a new one, so they still sort in
+// creation order and never collide.
+type idGenerator struct {
+	mu       sync.Mutex
+	lastTime uint64
+	lastHi   uint16 // upper 16 bits of the 80-bit random part
+	lastLo   uint64 // lower 64 bits of the 80-bit random part
+}
+
+var defaultIDGenerator = &idGenerator{}
+
+// NewID returns a new lexicographically sortable unique ID (a ULID)
+func NewID() string {
+	return defaultIDGenerator.next(time.Now())
+}
+
+// NewIDAt returns a new unique ID whose time component is t.
+// It is used when migrating existing records so their IDs sort by creation time.
+func NewIDAt(t time.Time) string {
+	return defaultIDGenerator.next(t)
+}
+
+// IsValidID reports whether id has the shape of an ID produced by NewID
+func IsValidID(id string) bool {
+	if len(id) != IDLength {
+		return false
+	}
+	// The first character only carries 3 bits of the timestamp
+	if id[0] > '7' {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if !isCrockford(id[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// next generates the next ID for the given time
+func (g *idGenerator) next(t time.Time) string {
+	ms := uint64(t.UnixMilli())
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if ms == g.lastTime {
+		g.lastLo++
+		if g.lastLo == 0 {
+			g.lastHi++
+		}
+	} else {
+		var buf [10]byte
+		if _, err := rand.Read(buf[:]); err != nil {
+			// crypto/rand never fails on supported platforms; fall back to the clock
+			binary.BigEndian.PutUint64(buf[2:], uint64(time.Now().UnixNano()))
+		}
+		g.lastTime = ms
+		g.lastHi = binary.BigEndian.Uint16(buf[:2])
+		g.lastLo = binary.BigEndian.Uint64(buf[2:])
+	}
+
+	return encodeID(ms, g.lastHi, g.lastLo)
+}
+
+// encodeID encodes a 48-bit timestamp and 80 bits of randomness as 26 base32 characters
+func encodeID(ms uint64, hi uint16, lo uint64) string {
+	var raw [16]byte
+	raw[0] = byte(ms >> 40)
+	raw[1] = byte(ms >> 32)
+	raw[2] = byte(ms >> 24)
+	raw[3] = byte(ms >> 16)
+	raw[4] = byte(ms >> 8)
+	raw[5] = byte(ms)
+	binary.BigEndian.PutUint16(raw[6:8], hi)
+	binary.BigEndian.PutUint64(raw[8:], lo)
+
+	// 128 bits are emitted 5 bits at a time, with 2 leading zero bits
+	var out [IDLength]byte
+	var acc uint32
+	bits := 2
+	pos := 0
+	for _, b := range raw {
+		acc = acc<<8 | uint32(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[pos] = crockford[(acc>>uint(bits))&0x1F]
+			pos++
+		}
+	}
+	return string(out[:])
+}
+
+// isCrockford reports whether c is an upper-case Crockford base32 character
+func isCrockford(c byte) bool {
+	switch {
+	case c >= '0' && c <= '9':
+		return true
+	case c >= 'A' && c <= 'Z':
+		return c != 'I' && c != 'L' && c != 'O' && c != 'U'
+	default:
+		return false
+	}
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Persona represents a named system prompt with default sampling options
+type Persona struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description,omitempty"`
+	SystemPrompt string    `json:"system_prompt"`
+	Model        string    `json:"model,omitempty"`        // Optional model preference
+	Temperature  float64   `json:"temperature"`            // Default temperature for new sessions
+	MaxMessages  int       `json:"max_messages,omitempty"` // Default max context messages (0 keeps config default)
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// PersonaBundle is the file format used to share personas between installations
+type PersonaBundle struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Personas   []Persona `json:"personas"`
+}
+
+// PersonaBundleVersion is the current version of the persona export format
+const PersonaBundleVersion = 1
+
+// NewPersona creates a new persona with a unique ID
+func NewPersona(name, systemPrompt string, temperature float64) Persona {
+	now := time.Now()
+	return Persona{
+		ID:           NewID(),
+		Name:         name,
+		SystemPrompt: systemPrompt,
+		Temperature:  temperature,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
+// NewPersonaBundle wraps personas for export
+func NewPersonaBundle(personas []Persona) PersonaBundle {
+	return PersonaBundle{
+		Version:    PersonaBundleVersion,
+		ExportedAt: time.Now(),
+		Personas:   personas,
+	}
+}
+
+// Validate checks that the persona has the fields required to be stored
+func (p Persona) Validate() error {
+	if p.ID == "" {
+		return fmt.Errorf("persona id cannot be empty")
+	}
+	if !IsValidID(p.ID) {
+		return fmt.Errorf("invalid persona id: %q", p.ID)
+	}
+	if p.Name == "" {
+		return fmt.Errorf("persona name cannot be empty")
+	}
+	if p.Temperature < 0 || p.Temperature > 2 {
+		return fmt.Errorf("persona temperature must be between 0 and 2")
+	}
+	if p.MaxMessages < 0 {
+		return fmt.Errorf("persona max messages must be non-negative")
+	}
+	return nil
+}
+
+// ApplyToSession copies the persona's prompt and sampling defaults onto a session
+func (p Persona) ApplyToSession(session *ChatSession) {
+	session.PersonaID = p.ID
+	session.SystemPrompt = p.SystemPrompt
+	session.Temperature = p.Temperature
+	if p.MaxMessages > 0 {
+		session.MaxMessages = p.MaxMessages
+	}
+	if p.Model != "" {
+		session.Model = p.Model
+	}
+	session.UpdatedAt = time.Now()
+}
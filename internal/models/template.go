@@ -0,0 +1,45 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// PromptTemplate represents a reusable prompt written with Go text/template syntax
+type PromptTemplate struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Body        string    `json:"body"` // text/template source, e.g. "Summarize {{.Topic}} as of {{.Date}}"
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// NewPromptTemplate creates a new prompt template with a unique ID
+func NewPromptTemplate(name, body string) PromptTemplate {
+	now := time.Now()
+	return PromptTemplate{
+		ID:        NewID(),
+		Name:      name,
+		Body:      body,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Validate checks that the template has the fields required to be stored
+func (t PromptTemplate) Validate() error {
+	if t.ID == "" {
+		return fmt.Errorf("template id cannot be empty")
+	}
+	if !IsValidID(t.ID) {
+		return fmt.Errorf("invalid template id: %q", t.ID)
+	}
+	if t.Name == "" {
+		return fmt.Errorf("template name cannot be empty")
+	}
+	if t.Body == "" {
+		return fmt.Errorf("template body cannot be empty")
+	}
+	return nil
+}
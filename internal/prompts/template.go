@@ -0,0 +1,208 @@
+// Package prompts renders prompt templates written with Go's text/template syntax.
+//
+// Templates reference variables as fields, e.g. {{.Language}}. A handful of
+// built-in variables are filled in automatically; every other field becomes a
+// user variable that the UI asks for before rendering.
+package prompts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// Built-in variable names available to every template
+const (
+	VarDate      = "Date"      // Current date, e.g. 2024-05-01
+	VarTime      = "Time"      // Current time, e.g. 14:05
+	VarClipboard = "Clipboard" // Current clipboard content
+	VarFile      = "File"      // Contents of a file selected by the user
+	VarFileName  = "FileName"  // Base name of the selected file
+)
+
+// builtinVars lists the variables that are never asked for in the form
+var builtinVars = map[string]bool{
+	VarDate:      true,
+	VarTime:      true,
+	VarClipboard: true,
+	VarFile:      true,
+	VarFileName:  true,
+}
+
+// Variables describes the variables a template references
+type Variables struct {
+	User     []string // Variables that must be filled in by the user, in order of first use
+	Builtins []string // Built-in variables referenced by the template, sorted
+}
+
+// NeedsFile reports whether the template references the selected file
+func (v Variables) NeedsFile() bool {
+	for _, name := range v.Builtins {
+		if name == VarFile || name == VarFileName {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsClipboard reports whether the template references the clipboard
+func (v Variables) NeedsClipboard() bool {
+	for _, name := range v.Builtins {
+		if name == VarClipboard {
+			return true
+		}
+	}
+	return false
+}
+
+// Context carries the values for built-in variables
+type Context struct {
+	Now       time.Time
+	Clipboard string
+	File      string
+	FileName  string
+}
+
+// Parse parses a template body and returns the variables it references
+func Parse(body string) (Variables, error) {
+	tmpl, err := newTemplate(body)
+	if err != nil {
+		return Variables{}, err
+	}
+
+	seen := make(map[string]bool)
+	var vars Variables
+	var builtins []string
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		walk(t.Tree.Root, func(name string) {
+			if seen[name] {
+				return
+			}
+			seen[name] = true
+			if builtinVars[name] {
+				builtins = append(builtins, name)
+			} else {
+				vars.User = append(vars.User, name)
+			}
+		})
+	}
+
+	sort.Strings(builtins)
+	vars.Builtins = builtins
+	return vars, nil
+}
+
+// Render executes a prompt template with the given user values and built-in context
+func Render(promptTemplate models.PromptTemplate, values map[string]string, ctx Context) (string, error) {
+	tmpl, err := newTemplate(promptTemplate.Body)
+	if err != nil {
+		return "", err
+	}
+
+	now := ctx.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	data := make(map[string]string, len(values)+len(builtinVars))
+	for name, value := range values {
+		data[name] = value
+	}
+	data[VarDate] = now.Format("2006-01-02")
+	data[VarTime] = now.Format("15:04")
+	data[VarClipboard] = ctx.Clipboard
+	data[VarFile] = ctx.File
+	data[VarFileName] = ctx.FileName
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render template %q: %w", promptTemplate.Name, err)
+	}
+	return out.String(), nil
+}
+
+// ExampleTemplates returns a starter set of templates for an empty library
+func ExampleTemplates() []models.PromptTemplate {
+	review := models.NewPromptTemplate("Review diff",
+		"Review the following diff for bugs, readability and missing tests. Focus on {{.Focus}}.\n\n```diff\n{{.Clipboard}}\n```")
+	review.Description = "Code review of the diff on the clipboard"
+
+	standup := models.NewPromptTemplate("Standup summary",
+		"Summarize the following notes as a standup update for {{.Date}} with Yesterday, Today and Blockers sections:\n\n{{.Notes}}")
+	standup.Description = "Turn raw notes into a standup update"
+
+	translate := models.NewPromptTemplate("Translate",
+		"Translate the following text into {{.Language}}. Keep formatting intact.\n\n{{.Text}}")
+	translate.Description = "Translate text into another language"
+
+	explain := models.NewPromptTemplate("Explain file",
+		"Explain what the file {{.FileName}} does, then list any problems you notice.\n\n```\n{{.File}}\n```")
+	explain.Description = "Explain the contents of a selected file"
+
+	return []models.PromptTemplate{review, standup, translate, explain}
+}
+
+// newTemplate parses a template body with missing keys reported as errors
+func newTemplate(body string) (*template.Template, error) {
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// walk visits every top-level field referenced in a template parse tree
+func walk(node parse.Node, visit func(name string)) {
+	if node == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walk(child, visit)
+		}
+	case *parse.ActionNode:
+		walk(n.Pipe, visit)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walk(cmd, visit)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walk(arg, visit)
+		}
+	case *parse.FieldNode:
+		if len(n.Ident) > 0 {
+			visit(n.Ident[0])
+		}
+	case *parse.IfNode:
+		walk(n.Pipe, visit)
+		walk(n.List, visit)
+		if n.ElseList != nil {
+			walk(n.ElseList, visit)
+		}
+	case *parse.RangeNode:
+		// Fields inside range/with are relative to the new dot, so only the pipeline counts
+		walk(n.Pipe, visit)
+	case *parse.WithNode:
+		walk(n.Pipe, visit)
+	case *parse.TemplateNode:
+		walk(n.Pipe, visit)
+	}
+}
@@ -0,0 +1,256 @@
+// Package retention removes chat sessions beyond configured limits on their number,
+// age and size
+package retention
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/storage"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+// checkInterval is the time between scheduled retention runs
+const checkInterval = time.Hour
+
+// Policy limits the sessions kept. Pinned sessions are never removed but count toward
+// MaxSessions and MaxBytes. Zero limits are not enforced.
+type Policy struct {
+	MaxSessions    int
+	MaxAge         time.Duration // Since the session was last updated
+	MaxBytes       int64         // Total size of the sessions and the trash
+	TrashRetention time.Duration // How long removed sessions stay in the trash; zero keeps them
+}
+
+// Limited reports whether the policy limits the sessions kept
+func (p Policy) Limited() bool {
+	return p.MaxSessions > 0 || p.MaxAge > 0 || p.MaxBytes > 0
+}
+
+// Removal is a session a policy removes and the rule that removes it
+type Removal struct {
+	models.SessionSummary
+	Reason string
+}
+
+// Result describes one retention run
+type Result struct {
+	Removed []Removal // Moved to the trash
+	Purged  int       // Permanently deleted from the trash
+}
+
+// Select returns the sessions the policy removes at now, oldest first
+func Select(sessions []models.SessionSummary, policy Policy, now time.Time) []Removal {
+	ordered := make([]models.SessionSummary, len(sessions))
+	copy(ordered, sessions)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].UpdatedAt.After(ordered[j].UpdatedAt)
+	})
+
+	kept := 0
+	var total int64
+	for _, s := range ordered {
+		if s.Pinned {
+			kept++
+			total += s.Size
+		}
+	}
+
+	// Newest first, so the count limit keeps the most recent sessions
+	var removed []Removal
+	var keptUnpinned []models.SessionSummary
+	for _, s := range ordered {
+		switch {
+		case s.Pinned:
+		case policy.MaxAge > 0 && now.Sub(s.UpdatedAt) > policy.MaxAge:
+			removed = append(removed, Removal{s, fmt.Sprintf("not updated for %d days", int(policy.MaxAge.Hours()/24))})
+		case policy.MaxSessions > 0 && kept >= policy.MaxSessions:
+			removed = append(removed, Removal{s, fmt.Sprintf("more than %d sessions", policy.MaxSessions)})
+		default:
+			kept++
+			total += s.Size
+			keptUnpinned = append(keptUnpinned, s)
+		}
+	}
+
+	// Oldest first until the rest fits
+	for i := len(keptUnpinned) - 1; i >= 0 && policy.MaxBytes > 0 && total > policy.MaxBytes; i-- {
+		s := keptUnpinned[i]
+		removed = append(removed, Removal{s, fmt.Sprintf("sessions larger than %s", storage.FormatBytes(policy.MaxBytes))})
+		total -= s.Size
+	}
+
+	sort.SliceStable(removed, func(i, j int) bool {
+		return removed[i].UpdatedAt.Before(removed[j].UpdatedAt)
+	})
+	return removed
+}
+
+// Apply purges expired sessions from the trash and moves the sessions the policy
+// removes into it
+func Apply(ctx context.Context, store storage.Storage, policy Policy, now time.Time) (Result, error) {
+	var result Result
+	if policy.TrashRetention > 0 {
+		purged, err := store.PurgeTrash(ctx, now.Add(-policy.TrashRetention))
+		if err != nil {
+			return result, fmt.Errorf("failed to purge trash: %w", err)
+		}
+		result.Purged = purged
+	}
+	if !policy.Limited() {
+		return result, nil
+	}
+
+	page, err := store.ListSessionSummaries(ctx, storage.SessionListOptions{})
+	if err != nil {
+		return result, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	var failed int
+	var firstErr error
+	kept := make(map[string]bool, len(page.Sessions))
+	for _, s := range page.Sessions {
+		kept[s.ID] = true
+	}
+	for _, removal := range Select(page.Sessions, policy, now) {
+		if err := store.TrashChatSession(ctx, removal.ID); err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		delete(kept, removal.ID)
+		result.Removed = append(result.Removed, removal)
+	}
+	if firstErr != nil {
+		return result, fmt.Errorf("failed to remove %d sessions: %w", failed, firstErr)
+	}
+
+	// Sessions moved to the trash still take space until they are purged
+	if policy.MaxBytes > 0 {
+		var keptBytes int64
+		for _, s := range page.Sessions {
+			if kept[s.ID] {
+				keptBytes += s.Size
+			}
+		}
+		purged, err := purgeOverSize(ctx, store, policy.MaxBytes-keptBytes)
+		result.Purged += purged
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// purgeOverSize permanently deletes trashed sessions, those deleted first before the
+// others, until the trash takes at most maxBytes. It returns how many it deleted.
+func purgeOverSize(ctx context.Context, store storage.Storage, maxBytes int64) (int, error) {
+	trashed, err := store.ListTrashedSessions(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list trash: %w", err)
+	}
+	var total int64
+	for _, t := range trashed {
+		total += t.Size
+	}
+	sort.SliceStable(trashed, func(i, j int) bool {
+		return trashed[i].DeletedAt.Before(trashed[j].DeletedAt)
+	})
+
+	purged := 0
+	for _, t := range trashed {
+		if total <= maxBytes {
+			break
+		}
+		if err := store.PurgeTrashedSession(ctx, t.ID); err != nil {
+			return purged, fmt.Errorf("failed to purge trashed session %s: %w", t.ID, err)
+		}
+		total -= t.Size
+		purged++
+	}
+	return purged, nil
+}
+
+// Scheduler applies a retention policy to a storage in the background
+type Scheduler struct {
+	store   storage.Storage
+	policy  Policy
+	onApply func(Result)
+	logger  *logger.Logger
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewScheduler creates a scheduler applying policy to store
+func NewScheduler(store storage.Storage, policy Policy, logger *logger.Logger) *Scheduler {
+	return &Scheduler{
+		store:  store,
+		policy: policy,
+		logger: logger.WithComponent("retention"),
+	}
+}
+
+// OnApply sets a function called from the background after a run removed or purged
+// sessions. It must be set before Start.
+func (s *Scheduler) OnApply(fn func(Result)) {
+	s.onApply = fn
+}
+
+// Start applies the policy every hour in the background until Stop is called
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.run(ctx)
+}
+
+// Stop ends scheduled runs, waiting for one in progress to finish
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+	s.cancel = nil
+}
+
+// RunNow applies the policy once and logs what it removed
+func (s *Scheduler) RunNow(ctx context.Context) (Result, error) {
+	result, err := Apply(ctx, s.store, s.policy, time.Now())
+	if result.Purged > 0 {
+		s.logger.Info("Purged sessions from trash", "count", result.Purged, "retention", s.policy.TrashRetention, "max_bytes", s.policy.MaxBytes)
+	}
+	for _, removal := range result.Removed {
+		s.logger.Info("Moved session to trash by retention rule", "session_id", removal.ID, "reason", removal.Reason)
+	}
+	return result, err
+}
+
+// run applies the policy on every tick
+func (s *Scheduler) run(ctx context.Context) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		result, err := s.RunNow(ctx)
+		if err != nil {
+			s.logger.Error("Retention run failed", "error", err)
+		}
+		if s.onApply != nil && (len(result.Removed) > 0 || result.Purged > 0) {
+			s.onApply(result)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/storage"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+func TestApplyCountsTrashTowardMaxBytes(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStorage(logger.NewLogger(slog.LevelError))
+
+	var ids []string
+	for i := 0; i < 4; i++ {
+		session := models.NewChatSession("Session", "llama3.2:latest")
+		session.Messages = []models.ChatMessage{models.NewChatMessage("user", string(make([]byte, 1000)))}
+		if err := store.SaveChatSession(ctx, session); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, session.ID)
+	}
+	page, err := store.ListSessionSummaries(ctx, storage.SessionListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := page.Sessions[0].Size
+
+	// Two sessions were deleted, the first one earlier than the second
+	for _, id := range ids[:2] {
+		if err := store.TrashChatSession(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Room for the two stored sessions and one trashed one
+	result, err := Apply(ctx, store, Policy{MaxBytes: 3*size + size/2}, time.Now())
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if len(result.Removed) != 0 || result.Purged != 1 {
+		t.Fatalf("got %d removed and %d purged, want 0 and 1", len(result.Removed), result.Purged)
+	}
+	trashed, err := store.ListTrashedSessions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trashed) != 1 || trashed[0].ID != ids[1] {
+		t.Errorf("trash holds %+v, want only the session deleted last", trashed)
+	}
+}
@@ -0,0 +1,387 @@
+// Package search implements a local full-text index over chat messages with English
+// stemming, phrase queries and prefix queries.
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// indexVersion is the layout version of the serialized index; other versions are rebuilt
+const indexVersion = 1
+
+// Span is a byte range of a text
+type Span struct {
+	Start int
+	End   int
+}
+
+// Result is a session that matches a query
+type Result struct {
+	SessionID    string
+	SessionName  string
+	MessageIndex int    // Best matching message
+	Matches      int    // Number of matching messages in the session
+	Snippet      string // Text around the first match in the best message
+	Highlights   []Span // Matched words in Snippet
+	Score        float64
+}
+
+// docKey identifies one message
+type docKey struct {
+	session string
+	message int
+}
+
+// indexedSession is the indexed content of one session
+type indexedSession struct {
+	name      string
+	indexedAt time.Time
+	messages  []string
+	words     [][]string // Distinct words of each message, used to remove its postings
+}
+
+// Index is an in-memory inverted index of message text. It is safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	sessions map[string]*indexedSession
+	postings map[string]map[docKey][]int    // Word to the positions it occurs at in each message
+	stems    map[string]map[string]struct{} // Stem to the indexed words sharing it
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{
+		sessions: make(map[string]*indexedSession),
+		postings: make(map[string]map[docKey][]int),
+		stems:    make(map[string]map[string]struct{}),
+	}
+}
+
+// IndexSession indexes a session, replacing anything indexed for it before
+func (ix *Index) IndexSession(session models.ChatSession) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.removeSession(session.ID)
+	ix.addSession(session.ID, session.Name, time.Now(), contents(session.Messages))
+}
+
+// UpdateMessages re-indexes a session's messages from index from onward, dropping any
+// indexed after them. It returns false if the session is not indexed.
+func (ix *Index) UpdateMessages(sessionID string, from int, messages []models.ChatMessage) bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	s, ok := ix.sessions[sessionID]
+	if !ok || from > len(s.messages) {
+		return false
+	}
+
+	for i := len(s.messages) - 1; i >= from; i-- {
+		ix.removeMessage(sessionID, i, s.words[i])
+	}
+	s.messages = s.messages[:from]
+	s.words = s.words[:from]
+	for _, msg := range messages {
+		ix.addMessage(sessionID, s, msg.Content)
+	}
+	s.indexedAt = time.Now()
+	return true
+}
+
+// RemoveSession drops a session from the index
+func (ix *Index) RemoveSession(sessionID string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.removeSession(sessionID)
+}
+
+// NeedsUpdate reports whether a stored session changed since it was indexed
+func (ix *Index) NeedsUpdate(summary models.SessionSummary) bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	s, ok := ix.sessions[summary.ID]
+	return !ok || summary.UpdatedAt.After(s.indexedAt) || len(s.messages) != summary.MessageCount || s.name != summary.Name
+}
+
+// SessionIDs returns the IDs of all indexed sessions
+func (ix *Index) SessionIDs() []string {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	ids := make([]string, 0, len(ix.sessions))
+	for id := range ix.sessions {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Search returns the sessions whose messages match query, best first. A message
+// matches when it contains every word, phrase and prefix of the query.
+func (ix *Index) Search(query string, limit int) []Result {
+	clauses := parseQuery(query)
+	if len(clauses) == 0 {
+		return nil
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	total := 0
+	for _, s := range ix.sessions {
+		total += len(s.messages)
+	}
+
+	// Score each message that matches every clause
+	var scores map[docKey]float64
+	for _, c := range clauses {
+		counts := ix.matchClause(c)
+		idf := math.Log(1 + float64(total)/float64(len(counts)+1))
+
+		next := make(map[docKey]float64)
+		for key, count := range counts {
+			if scores != nil {
+				if _, ok := scores[key]; !ok {
+					continue
+				}
+			}
+			next[key] = scores[key] + (1+math.Log(float64(count)))*idf
+		}
+		scores = next
+		if len(scores) == 0 {
+			return nil
+		}
+	}
+
+	// Keep the best message of each session
+	bySession := make(map[string]*Result)
+	for key, score := range scores {
+		r, ok := bySession[key.session]
+		if !ok {
+			r = &Result{SessionID: key.session, SessionName: ix.sessions[key.session].name, MessageIndex: key.message, Score: score}
+			bySession[key.session] = r
+		} else if score > r.Score || (score == r.Score && key.message > r.MessageIndex) {
+			r.MessageIndex, r.Score = key.message, score
+		}
+		r.Matches++
+	}
+
+	results := make([]Result, 0, len(bySession))
+	for _, r := range bySession {
+		results = append(results, *r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		// Session IDs sort by creation time, so newer sessions come first
+		return results[i].SessionID > results[j].SessionID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	for i := range results {
+		text := ix.sessions[results[i].SessionID].messages[results[i].MessageIndex]
+		results[i].Snippet, results[i].Highlights = snippet(text, clauses)
+	}
+	return results
+}
+
+// matchClause returns the number of matches of a clause in each matching message
+func (ix *Index) matchClause(c clause) map[docKey]int {
+	counts := make(map[docKey]int)
+
+	if c.kind != clausePhrase {
+		for _, word := range ix.variants(c) {
+			for key, positions := range ix.postings[word] {
+				counts[key] += len(positions)
+			}
+		}
+		return counts
+	}
+
+	// Positions of each phrase word, per message
+	sets := make([]map[docKey]map[int]bool, len(c.words))
+	for i, w := range c.words {
+		sets[i] = make(map[docKey]map[int]bool)
+		for _, word := range ix.variants(clause{kind: clauseWord, words: []string{w}}) {
+			for key, positions := range ix.postings[word] {
+				if sets[i][key] == nil {
+					sets[i][key] = make(map[int]bool)
+				}
+				for _, p := range positions {
+					sets[i][key][p] = true
+				}
+			}
+		}
+	}
+
+	for key, starts := range sets[0] {
+		for p := range starts {
+			matched := true
+			for i := 1; i < len(sets); i++ {
+				if !sets[i][key][p+i] {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				counts[key]++
+			}
+		}
+	}
+	return counts
+}
+
+// variants returns the indexed words matched by a single-word clause
+func (ix *Index) variants(c clause) []string {
+	var words []string
+	if c.kind == clausePrefix {
+		for word := range ix.postings {
+			if strings.HasPrefix(word, c.words[0]) {
+				words = append(words, word)
+			}
+		}
+		return words
+	}
+
+	for word := range ix.stems[Stem(c.words[0])] {
+		words = append(words, word)
+	}
+	return words
+}
+
+// addSession indexes a session's message texts. The caller must hold mu.
+func (ix *Index) addSession(sessionID, name string, indexedAt time.Time, messages []string) {
+	s := &indexedSession{name: name, indexedAt: indexedAt}
+	ix.sessions[sessionID] = s
+	for _, text := range messages {
+		ix.addMessage(sessionID, s, text)
+	}
+}
+
+// addMessage indexes one message appended to a session. The caller must hold mu.
+func (ix *Index) addMessage(sessionID string, s *indexedSession, text string) {
+	key := docKey{session: sessionID, message: len(s.messages)}
+
+	var distinct []string
+	for _, token := range Tokenize(text) {
+		postings, ok := ix.postings[token.Word]
+		if !ok {
+			postings = make(map[docKey][]int)
+			ix.postings[token.Word] = postings
+
+			stem := Stem(token.Word)
+			if ix.stems[stem] == nil {
+				ix.stems[stem] = make(map[string]struct{})
+			}
+			ix.stems[stem][token.Word] = struct{}{}
+		}
+		if _, seen := postings[key]; !seen {
+			distinct = append(distinct, token.Word)
+		}
+		postings[key] = append(postings[key], token.Pos)
+	}
+
+	s.messages = append(s.messages, text)
+	s.words = append(s.words, distinct)
+}
+
+// removeSession drops every posting of a session. The caller must hold mu.
+func (ix *Index) removeSession(sessionID string) {
+	s, ok := ix.sessions[sessionID]
+	if !ok {
+		return
+	}
+	for i, words := range s.words {
+		ix.removeMessage(sessionID, i, words)
+	}
+	delete(ix.sessions, sessionID)
+}
+
+// removeMessage drops the postings of one message. The caller must hold mu.
+func (ix *Index) removeMessage(sessionID string, message int, words []string) {
+	key := docKey{session: sessionID, message: message}
+	for _, word := range words {
+		postings := ix.postings[word]
+		delete(postings, key)
+		if len(postings) > 0 {
+			continue
+		}
+
+		delete(ix.postings, word)
+		stem := Stem(word)
+		delete(ix.stems[stem], word)
+		if len(ix.stems[stem]) == 0 {
+			delete(ix.stems, stem)
+		}
+	}
+}
+
+// storedIndex is the serialized form of an index; postings are rebuilt when loading
+type storedIndex struct {
+	Version  int             `json:"version"`
+	Sessions []storedSession `json:"sessions"`
+}
+
+// storedSession is the serialized form of an indexed session
+type storedSession struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	IndexedAt time.Time `json:"indexed_at"`
+	Messages  []string  `json:"messages"`
+}
+
+// MarshalJSON serializes the indexed sessions
+func (ix *Index) MarshalJSON() ([]byte, error) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	stored := storedIndex{Version: indexVersion, Sessions: make([]storedSession, 0, len(ix.sessions))}
+	for id, s := range ix.sessions {
+		stored.Sessions = append(stored.Sessions, storedSession{ID: id, Name: s.name, IndexedAt: s.indexedAt, Messages: s.messages})
+	}
+	return json.Marshal(stored)
+}
+
+// UnmarshalJSON replaces the index contents with serialized sessions
+func (ix *Index) UnmarshalJSON(data []byte) error {
+	var stored storedIndex
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+	if stored.Version != indexVersion {
+		return fmt.Errorf("unsupported search index version %d", stored.Version)
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.sessions = make(map[string]*indexedSession, len(stored.Sessions))
+	ix.postings = make(map[string]map[docKey][]int)
+	ix.stems = make(map[string]map[string]struct{})
+	for _, s := range stored.Sessions {
+		ix.addSession(s.ID, s.Name, s.IndexedAt, s.Messages)
+	}
+	return nil
+}
+
+// contents returns the text of each message
+func contents(messages []models.ChatMessage) []string {
+	texts := make([]string, len(messages))
+	for i, msg := range messages {
+		texts[i] = msg.Content
+	}
+	return texts
+}
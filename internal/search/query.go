@@ -0,0 +1,112 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// clauseKind is the kind of a query clause
+type clauseKind int
+
+const (
+	clauseWord   clauseKind = iota // Matches any word with the same stem
+	clausePrefix                   // Matches words starting with the text, written word*
+	clausePhrase                   // Matches the words in order, written "two words"
+)
+
+// clause is one part of a query; a message must match every clause
+type clause struct {
+	kind  clauseKind
+	words []string
+}
+
+// parseQuery splits a query into clauses. Quoted text is a phrase, a trailing * makes
+// a prefix query, and everything else is matched by stem.
+func parseQuery(query string) []clause {
+	var clauses []clause
+	rest := strings.TrimSpace(query)
+
+	for rest != "" {
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			var text string
+			if end < 0 {
+				text, rest = rest[1:], ""
+			} else {
+				text, rest = rest[1:end+1], rest[end+2:]
+			}
+			if words := tokenWords(text); len(words) == 1 {
+				clauses = append(clauses, clause{kind: clauseWord, words: words})
+			} else if len(words) > 1 {
+				clauses = append(clauses, clause{kind: clausePhrase, words: words})
+			}
+		} else {
+			end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+			var text string
+			if end < 0 {
+				text, rest = rest, ""
+			} else {
+				text, rest = rest[:end], rest[end:]
+			}
+
+			words := tokenWords(text)
+			switch {
+			case len(words) == 1 && strings.HasSuffix(text, "*"):
+				clauses = append(clauses, clause{kind: clausePrefix, words: words})
+			case len(words) == 1:
+				clauses = append(clauses, clause{kind: clauseWord, words: words})
+			case len(words) > 1:
+				// Punctuated terms such as "e-mail" or "v1.2" match as phrases
+				clauses = append(clauses, clause{kind: clausePhrase, words: words})
+			}
+		}
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+	}
+	return clauses
+}
+
+// tokenWords returns the words of text
+func tokenWords(text string) []string {
+	tokens := Tokenize(text)
+	words := make([]string, len(tokens))
+	for i, token := range tokens {
+		words[i] = token.Word
+	}
+	return words
+}
+
+// matchesWord reports whether an indexed word matches a single-word clause
+func (c clause) matchesWord(word string) bool {
+	if c.kind == clausePrefix {
+		return strings.HasPrefix(word, c.words[0])
+	}
+	return Stem(word) == Stem(c.words[0])
+}
+
+// highlight returns the tokens matched by the clause
+func (c clause) highlight(tokens []Token) []Span {
+	var spans []Span
+	if c.kind != clausePhrase {
+		for _, token := range tokens {
+			if c.matchesWord(token.Word) {
+				spans = append(spans, Span{Start: token.Start, End: token.End})
+			}
+		}
+		return spans
+	}
+
+	n := len(c.words)
+	for i := 0; i+n <= len(tokens); i++ {
+		matched := true
+		for j, word := range c.words {
+			if Stem(tokens[i+j].Word) != Stem(word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			spans = append(spans, Span{Start: tokens[i].Start, End: tokens[i+n-1].End})
+		}
+	}
+	return spans
+}
@@ -0,0 +1,103 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// snippetContext is how much text is kept before the first match
+const snippetContext = 60
+
+// snippetLength is the length of a snippet, not counting ellipses
+const snippetLength = 200
+
+// ellipsis marks text cut from either end of a snippet
+const ellipsis = "…"
+
+// snippet returns the text around the first match of the clauses, with the matched
+// words as spans of the returned snippet
+func snippet(text string, clauses []clause) (string, []Span) {
+	tokens := Tokenize(text)
+	var spans []Span
+	for _, c := range clauses {
+		spans = append(spans, c.highlight(tokens)...)
+	}
+	spans = mergeSpans(spans)
+
+	start := 0
+	if len(spans) > 0 && spans[0].Start > snippetContext {
+		start = wordStart(text, spans[0].Start-snippetContext)
+	}
+	end := len(text)
+	if end-start > snippetLength {
+		end = wordEnd(text, start+snippetLength)
+	}
+
+	var out strings.Builder
+	if start > 0 {
+		out.WriteString(ellipsis)
+	}
+	offset := out.Len() - start
+	// Line breaks would make the snippet taller than a list row; spaces keep offsets intact
+	out.WriteString(strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return ' '
+		}
+		return r
+	}, text[start:end]))
+	if end < len(text) {
+		out.WriteString(ellipsis)
+	}
+
+	var highlights []Span
+	for _, span := range spans {
+		if span.Start >= start && span.End <= end {
+			highlights = append(highlights, Span{Start: span.Start + offset, End: span.End + offset})
+		}
+	}
+	return out.String(), highlights
+}
+
+// mergeSpans sorts spans and joins overlapping ones
+func mergeSpans(spans []Span) []Span {
+	if len(spans) == 0 {
+		return nil
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+
+	merged := []Span{spans[0]}
+	for _, span := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if span.Start <= last.End {
+			if span.End > last.End {
+				last.End = span.End
+			}
+			continue
+		}
+		merged = append(merged, span)
+	}
+	return merged
+}
+
+// wordStart moves i forward to the start of the next word, keeping it on a rune boundary
+func wordStart(text string, i int) int {
+	for i < len(text) && !utf8.RuneStart(text[i]) {
+		i++
+	}
+	if j := strings.IndexAny(text[i:], " \n\t"); j >= 0 && j < snippetContext/2 {
+		return i + j + 1
+	}
+	return i
+}
+
+// wordEnd moves i back to the end of the previous word, keeping it on a rune boundary
+func wordEnd(text string, i int) int {
+	for i > 0 && !utf8.RuneStart(text[i]) {
+		i--
+	}
+	if j := strings.LastIndexAny(text[:i], " \n\t"); j >= 0 && i-j < snippetContext/2 {
+		return j
+	}
+	return i
+}
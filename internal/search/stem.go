@@ -0,0 +1,238 @@
+package search
+
+// Stem reduces an English word to its stem with the Porter algorithm, so that
+// "connection", "connected" and "connecting" all index as "connect". The word must
+// be lowercase; words of two letters or fewer are returned unchanged.
+func Stem(word string) string {
+	if len(word) <= 2 || !isASCIIWord(word) {
+		return word
+	}
+
+	s := &stemmer{b: []byte(word)}
+	s.step1a()
+	s.step1b()
+	s.step1c()
+	s.step2()
+	s.step3()
+	s.step4()
+	s.step5()
+	return string(s.b)
+}
+
+// isASCIIWord reports whether word consists of lowercase ASCII letters only;
+// other words (numbers, non-English text) are indexed as they are
+func isASCIIWord(word string) bool {
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// stemmer holds the word being stemmed
+type stemmer struct {
+	b []byte
+}
+
+// isConsonant reports whether the letter at i is a consonant in Porter's sense
+func (s *stemmer) isConsonant(i int) bool {
+	switch s.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !s.isConsonant(i-1)
+	}
+	return true
+}
+
+// measure counts the vowel-consonant sequences in the first n letters
+func (s *stemmer) measure(n int) int {
+	m, i := 0, 0
+	for i < n && s.isConsonant(i) {
+		i++
+	}
+	for i < n {
+		for i < n && !s.isConsonant(i) {
+			i++
+		}
+		if i >= n {
+			break
+		}
+		for i < n && s.isConsonant(i) {
+			i++
+		}
+		m++
+	}
+	return m
+}
+
+// hasVowel reports whether the first n letters contain a vowel
+func (s *stemmer) hasVowel(n int) bool {
+	for i := 0; i < n; i++ {
+		if !s.isConsonant(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// endsDoubleConsonant reports whether the first n letters end in a double consonant
+func (s *stemmer) endsDoubleConsonant(n int) bool {
+	return n >= 2 && s.b[n-1] == s.b[n-2] && s.isConsonant(n-1)
+}
+
+// endsCVC reports whether the first n letters end consonant-vowel-consonant,
+// where the final consonant is not w, x or y
+func (s *stemmer) endsCVC(n int) bool {
+	if n < 3 || !s.isConsonant(n-1) || s.isConsonant(n-2) || !s.isConsonant(n-3) {
+		return false
+	}
+	switch s.b[n-1] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+// hasSuffix reports whether the word ends with suffix
+func (s *stemmer) hasSuffix(suffix string) bool {
+	return len(s.b) >= len(suffix) && string(s.b[len(s.b)-len(suffix):]) == suffix
+}
+
+// replace swaps suffix for repl when the remaining stem has a measure above min
+func (s *stemmer) replace(suffix, repl string, min int) bool {
+	if !s.hasSuffix(suffix) {
+		return false
+	}
+	stem := len(s.b) - len(suffix)
+	if s.measure(stem) > min {
+		s.b = append(s.b[:stem], repl...)
+	}
+	return true
+}
+
+// step1a removes plurals
+func (s *stemmer) step1a() {
+	switch {
+	case s.hasSuffix("sses"), s.hasSuffix("ies"):
+		s.b = s.b[:len(s.b)-2]
+	case s.hasSuffix("ss"):
+	case s.hasSuffix("s"):
+		s.b = s.b[:len(s.b)-1]
+	}
+}
+
+// step1b removes -ed and -ing
+func (s *stemmer) step1b() {
+	if s.hasSuffix("eed") {
+		if s.measure(len(s.b)-3) > 0 {
+			s.b = s.b[:len(s.b)-1]
+		}
+		return
+	}
+
+	var stem int
+	switch {
+	case s.hasSuffix("ed") && s.hasVowel(len(s.b)-2):
+		stem = len(s.b) - 2
+	case s.hasSuffix("ing") && s.hasVowel(len(s.b)-3):
+		stem = len(s.b) - 3
+	default:
+		return
+	}
+	s.b = s.b[:stem]
+
+	switch {
+	case s.hasSuffix("at"), s.hasSuffix("bl"), s.hasSuffix("iz"):
+		s.b = append(s.b, 'e')
+	case s.endsDoubleConsonant(len(s.b)):
+		switch s.b[len(s.b)-1] {
+		case 'l', 's', 'z':
+		default:
+			s.b = s.b[:len(s.b)-1]
+		}
+	case s.measure(len(s.b)) == 1 && s.endsCVC(len(s.b)):
+		s.b = append(s.b, 'e')
+	}
+}
+
+// step1c turns a terminal y into i when there is another vowel
+func (s *stemmer) step1c() {
+	if s.hasSuffix("y") && s.hasVowel(len(s.b)-1) {
+		s.b[len(s.b)-1] = 'i'
+	}
+}
+
+// step2Suffixes maps double suffixes to single ones
+var step2Suffixes = [][2]string{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"},
+	{"izer", "ize"}, {"abli", "able"}, {"alli", "al"}, {"entli", "ent"},
+	{"eli", "e"}, {"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"},
+	{"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+	{"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+}
+
+// step3Suffixes maps -ic-, -full, -ness and similar suffixes
+var step3Suffixes = [][2]string{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+	{"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+// step4Suffixes are removed when the stem is long enough
+var step4Suffixes = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment",
+	"ent", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+func (s *stemmer) step2() {
+	for _, rule := range step2Suffixes {
+		if s.replace(rule[0], rule[1], 0) {
+			return
+		}
+	}
+}
+
+func (s *stemmer) step3() {
+	for _, rule := range step3Suffixes {
+		if s.replace(rule[0], rule[1], 0) {
+			return
+		}
+	}
+}
+
+func (s *stemmer) step4() {
+	// -ion is removed only after s or t
+	if s.hasSuffix("ion") {
+		stem := len(s.b) - 3
+		if stem > 0 && (s.b[stem-1] == 's' || s.b[stem-1] == 't') && s.measure(stem) > 1 {
+			s.b = s.b[:stem]
+		}
+		return
+	}
+
+	// The longest matching suffix wins ("ement" over "ment" over "ent")
+	longest := ""
+	for _, suffix := range step4Suffixes {
+		if len(suffix) > len(longest) && s.hasSuffix(suffix) {
+			longest = suffix
+		}
+	}
+	if longest != "" && s.measure(len(s.b)-len(longest)) > 1 {
+		s.b = s.b[:len(s.b)-len(longest)]
+	}
+}
+
+// step5 removes a final -e and reduces -ll
+func (s *stemmer) step5() {
+	if s.hasSuffix("e") {
+		stem := len(s.b) - 1
+		m := s.measure(stem)
+		if m > 1 || (m == 1 && !s.endsCVC(stem)) {
+			s.b = s.b[:stem]
+		}
+	}
+	if s.hasSuffix("ll") && s.measure(len(s.b)) > 1 {
+		s.b = s.b[:len(s.b)-1]
+	}
+}
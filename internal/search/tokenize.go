@@ -0,0 +1,56 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token is one word of a text
+type Token struct {
+	Word  string // Lowercased word
+	Start int    // Byte offset of the word in the text
+	End   int    // Byte offset just past the word
+	Pos   int    // Word position, used for phrase matching
+}
+
+// Tokenize splits text into lowercase words made of letters and digits. Apostrophes
+// inside a word are dropped, so "don't" indexes as "dont".
+func Tokenize(text string) []Token {
+	var tokens []Token
+	var word strings.Builder
+	start := -1
+
+	flush := func(end int) {
+		if start >= 0 && word.Len() > 0 {
+			tokens = append(tokens, Token{Word: word.String(), Start: start, End: end, Pos: len(tokens)})
+		}
+		word.Reset()
+		start = -1
+	}
+
+	for i, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if start < 0 {
+				start = i
+			}
+			word.WriteRune(unicode.ToLower(r))
+		case (r == '\'' || r == '’') && start >= 0 && nextIsLetter(text, i+utf8.RuneLen(r)):
+			// Inner apostrophe: keep the word going
+		default:
+			flush(i)
+		}
+	}
+	flush(len(text))
+	return tokens
+}
+
+// nextIsLetter reports whether the rune at offset i is a letter
+func nextIsLetter(text string, i int) bool {
+	if i >= len(text) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	return unicode.IsLetter(r)
+}
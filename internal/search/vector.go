@@ -0,0 +1,288 @@
+package search
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// vectorIndexVersion is the layout version of the serialized vector index
+const vectorIndexVersion = 1
+
+// maxExchangeChars caps the text of an exchange sent to the embedding model
+const maxExchangeChars = 2000
+
+// maxPreviewChars caps the stored preview of an exchange
+const maxPreviewChars = 200
+
+// Exchange is a user message with the reply that follows it, the unit that is embedded
+type Exchange struct {
+	MessageIndex int    // Index of the first message of the exchange
+	Text         string // Text sent to the embedding model
+	Preview      string // Opening of the first message, for listing
+	Hash         uint64 // Hash of Text, used to skip unchanged exchanges
+}
+
+// Exchanges splits messages into exchanges: each user message with the replies after
+// it. Replies before the first user message form an exchange of their own.
+func Exchanges(messages []models.ChatMessage) []Exchange {
+	var exchanges []Exchange
+	var text strings.Builder
+	start := -1
+
+	flush := func() {
+		if start < 0 {
+			return
+		}
+		body := truncateRunes(text.String(), maxExchangeChars)
+		if body == "" {
+			start = -1
+			return
+		}
+		h := fnv.New64a()
+		h.Write([]byte(body))
+		exchanges = append(exchanges, Exchange{
+			MessageIndex: start,
+			Text:         body,
+			Preview:      truncateRunes(strings.Join(strings.Fields(messages[start].Content), " "), maxPreviewChars),
+			Hash:         h.Sum64(),
+		})
+		text.Reset()
+		start = -1
+	}
+
+	for i, msg := range messages {
+		if msg.Sender == "user" || start < 0 {
+			flush()
+			start = i
+		}
+		if strings.TrimSpace(msg.Content) == "" {
+			continue
+		}
+		text.WriteString(msg.Sender + ": " + msg.Content + "\n")
+	}
+	flush()
+	return exchanges
+}
+
+// truncateRunes shortens s to at most n runes
+func truncateRunes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// VectorChunk is an embedded exchange
+type VectorChunk struct {
+	MessageIndex int
+	Preview      string
+	Hash         uint64
+	Vector       []float32 // Unit length, so similarity is a dot product
+}
+
+// VectorMatch is an exchange similar to a query
+type VectorMatch struct {
+	SessionID    string
+	SessionName  string
+	MessageIndex int
+	Preview      string
+	Score        float32 // Cosine similarity, from -1 to 1
+}
+
+// vectorSession holds the embedded exchanges of one session
+type vectorSession struct {
+	Name      string
+	IndexedAt time.Time
+	Chunks    []VectorChunk
+}
+
+// VectorIndex holds embedded exchanges for similarity search. Vectors from different
+// models are not comparable, so an index belongs to a single embedding model.
+type VectorIndex struct {
+	mu       sync.RWMutex
+	model    string
+	sessions map[string]*vectorSession
+}
+
+// NewVectorIndex creates an empty index for an embedding model
+func NewVectorIndex(model string) *VectorIndex {
+	return &VectorIndex{model: model, sessions: make(map[string]*vectorSession)}
+}
+
+// Model returns the embedding model of the index
+func (vx *VectorIndex) Model() string {
+	return vx.model
+}
+
+// Len returns the number of embedded exchanges
+func (vx *VectorIndex) Len() int {
+	vx.mu.RLock()
+	defer vx.mu.RUnlock()
+
+	n := 0
+	for _, s := range vx.sessions {
+		n += len(s.Chunks)
+	}
+	return n
+}
+
+// NeedsUpdate reports whether a stored session changed since it was embedded
+func (vx *VectorIndex) NeedsUpdate(summary models.SessionSummary) bool {
+	vx.mu.RLock()
+	defer vx.mu.RUnlock()
+
+	s, ok := vx.sessions[summary.ID]
+	return !ok || summary.UpdatedAt.After(s.IndexedAt) || s.Name != summary.Name
+}
+
+// Vectors returns the embedded vectors of a session by exchange hash, so unchanged
+// exchanges can be reused instead of embedded again
+func (vx *VectorIndex) Vectors(sessionID string) map[uint64][]float32 {
+	vx.mu.RLock()
+	defer vx.mu.RUnlock()
+
+	vectors := make(map[uint64][]float32)
+	if s, ok := vx.sessions[sessionID]; ok {
+		for _, chunk := range s.Chunks {
+			vectors[chunk.Hash] = chunk.Vector
+		}
+	}
+	return vectors
+}
+
+// SetSession replaces the embedded exchanges of a session. Vectors are normalized.
+func (vx *VectorIndex) SetSession(sessionID, name string, chunks []VectorChunk) {
+	for i := range chunks {
+		chunks[i].Vector = normalize(chunks[i].Vector)
+	}
+
+	vx.mu.Lock()
+	defer vx.mu.Unlock()
+	vx.sessions[sessionID] = &vectorSession{Name: name, IndexedAt: time.Now(), Chunks: chunks}
+}
+
+// RemoveSession drops a session from the index
+func (vx *VectorIndex) RemoveSession(sessionID string) {
+	vx.mu.Lock()
+	defer vx.mu.Unlock()
+	delete(vx.sessions, sessionID)
+}
+
+// SessionIDs returns the IDs of all embedded sessions
+func (vx *VectorIndex) SessionIDs() []string {
+	vx.mu.RLock()
+	defer vx.mu.RUnlock()
+
+	ids := make([]string, 0, len(vx.sessions))
+	for id := range vx.sessions {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Nearest returns the exchanges most similar to vector, best first
+func (vx *VectorIndex) Nearest(vector []float32, limit int) []VectorMatch {
+	query := normalize(vector)
+
+	vx.mu.RLock()
+	defer vx.mu.RUnlock()
+
+	var matches []VectorMatch
+	for id, s := range vx.sessions {
+		for _, chunk := range s.Chunks {
+			if len(chunk.Vector) != len(query) {
+				continue
+			}
+			matches = append(matches, VectorMatch{
+				SessionID:    id,
+				SessionName:  s.Name,
+				MessageIndex: chunk.MessageIndex,
+				Preview:      chunk.Preview,
+				Score:        dot(query, chunk.Vector),
+			})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// storedVectorIndex is the serialized form of a vector index
+type storedVectorIndex struct {
+	Version  int
+	Model    string
+	Sessions map[string]*vectorSession
+}
+
+// MarshalBinary serializes the index with gob
+func (vx *VectorIndex) MarshalBinary() ([]byte, error) {
+	vx.mu.RLock()
+	defer vx.mu.RUnlock()
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(storedVectorIndex{Version: vectorIndexVersion, Model: vx.model, Sessions: vx.sessions})
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary replaces the index with a serialized one
+func (vx *VectorIndex) UnmarshalBinary(data []byte) error {
+	var stored storedVectorIndex
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&stored); err != nil {
+		return err
+	}
+	if stored.Version != vectorIndexVersion {
+		return fmt.Errorf("unsupported vector index version %d", stored.Version)
+	}
+	if stored.Sessions == nil {
+		stored.Sessions = make(map[string]*vectorSession)
+	}
+
+	vx.mu.Lock()
+	defer vx.mu.Unlock()
+	vx.model = stored.Model
+	vx.sessions = stored.Sessions
+	return nil
+}
+
+// normalize scales v to unit length
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+
+	norm := float32(1 / math.Sqrt(sum))
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x * norm
+	}
+	return out
+}
+
+// dot returns the dot product of two vectors of equal length
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
@@ -0,0 +1,332 @@
+// Package semantic embeds chat exchanges with a local embedding model and finds past
+// exchanges similar to a query.
+package semantic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ashprao/ollamachat/internal/llm"
+	"github.com/ashprao/ollamachat/internal/search"
+	"github.com/ashprao/ollamachat/internal/storage"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+// IndexFile holds the embedded exchanges inside the storage directory
+const IndexFile = "vector_index.gob"
+
+// embedBatchSize is the number of exchanges sent to the embedding model per request
+const embedBatchSize = 16
+
+// Indexer keeps a vector index of every session's exchanges up to date in the
+// background and answers similarity queries against it
+type Indexer struct {
+	storage  storage.Storage
+	embedder llm.Embedder
+	path     string // Index file; empty keeps the index in memory only
+	logger   *logger.Logger
+
+	mu       sync.Mutex // Guards index, pending and fullSync
+	index    *search.VectorIndex
+	pending  map[string]bool
+	fullSync bool
+
+	wake   chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewIndexer loads the vector index from dir (in memory only if dir is empty) and
+// starts embedding sessions that changed since it was saved. An index built with a
+// different model is discarded and rebuilt.
+func NewIndexer(store storage.Storage, embedder llm.Embedder, model, dir string, logger *logger.Logger) *Indexer {
+	ix := &Indexer{
+		storage:  store,
+		embedder: embedder,
+		logger:   logger.WithComponent("semantic-index"),
+		index:    search.NewVectorIndex(model),
+		pending:  make(map[string]bool),
+		fullSync: true,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	if dir != "" {
+		ix.path = filepath.Join(dir, IndexFile)
+		ix.load(model)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ix.cancel = cancel
+	go ix.run(ctx)
+	ix.signal()
+	return ix
+}
+
+// load reads the saved index, keeping it only if it was built with model
+func (ix *Indexer) load(model string) {
+	data, err := os.ReadFile(ix.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			ix.logger.Warn("Failed to read vector index", "error", err)
+		}
+		return
+	}
+
+	stored := search.NewVectorIndex("")
+	if err := stored.UnmarshalBinary(data); err != nil {
+		ix.logger.Warn("Rebuilding unreadable vector index", "error", err)
+		return
+	}
+	if stored.Model() != model {
+		ix.logger.Info("Embedding model changed, rebuilding vector index", "old_model", stored.Model(), "new_model", model)
+		return
+	}
+	ix.index = stored
+}
+
+// Model returns the embedding model in use
+func (ix *Indexer) Model() string {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.index.Model()
+}
+
+// SetModel switches to another embedding model and rebuilds the index
+func (ix *Indexer) SetModel(model string) {
+	ix.mu.Lock()
+	if ix.index.Model() == model {
+		ix.mu.Unlock()
+		return
+	}
+	ix.logger.Info("Embedding model changed, rebuilding vector index", "old_model", ix.index.Model(), "new_model", model)
+	ix.index = search.NewVectorIndex(model)
+	ix.fullSync = true
+	ix.mu.Unlock()
+
+	ix.signal()
+}
+
+// Notify schedules a session for embedding after it changed or was deleted
+func (ix *Indexer) Notify(sessionID string) {
+	ix.mu.Lock()
+	ix.pending[sessionID] = true
+	ix.mu.Unlock()
+
+	ix.signal()
+}
+
+// signal wakes the background worker without blocking
+func (ix *Indexer) signal() {
+	select {
+	case ix.wake <- struct{}{}:
+	default:
+	}
+}
+
+// FindSimilar embeds text and returns the most similar past exchanges, best first
+func (ix *Indexer) FindSimilar(ctx context.Context, text string, limit int) ([]search.VectorMatch, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+
+	ix.mu.Lock()
+	index := ix.index
+	ix.mu.Unlock()
+
+	vectors, err := ix.embedder.Embed(ctx, index.Model(), []string{"user: " + text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	return index.Nearest(vectors[0], limit), nil
+}
+
+// Close stops the background worker and saves the index
+func (ix *Indexer) Close() error {
+	ix.cancel()
+	<-ix.done
+	return ix.save()
+}
+
+// run embeds scheduled sessions until the indexer is closed
+func (ix *Indexer) run(ctx context.Context) {
+	defer close(ix.done)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ix.wake:
+		}
+
+		ix.mu.Lock()
+		fullSync, pending := ix.fullSync, ix.pending
+		ix.fullSync, ix.pending = false, make(map[string]bool)
+		ix.mu.Unlock()
+
+		var err error
+		if fullSync {
+			err = ix.syncAll(ctx)
+		} else {
+			err = ix.syncSessions(ctx, pending)
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				// Typically the model is not pulled or Ollama is not running; try again on the next change
+				ix.logger.Warn("Embedding sessions failed", "error", err)
+			}
+			ix.mu.Lock()
+			ix.fullSync = ix.fullSync || fullSync
+			for id := range pending {
+				ix.pending[id] = true
+			}
+			ix.mu.Unlock()
+			continue
+		}
+
+		if err := ix.save(); err != nil {
+			ix.logger.Warn("Failed to save vector index", "error", err)
+		}
+	}
+}
+
+// syncAll embeds every session that changed since it was last embedded
+func (ix *Indexer) syncAll(ctx context.Context) error {
+	page, err := ix.storage.ListSessionSummaries(ctx, storage.SessionListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	ix.mu.Lock()
+	index := ix.index
+	ix.mu.Unlock()
+
+	stored := make(map[string]bool, len(page.Sessions))
+	for _, summary := range page.Sessions {
+		stored[summary.ID] = true
+	}
+	for _, id := range index.SessionIDs() {
+		if !stored[id] {
+			index.RemoveSession(id)
+		}
+	}
+
+	embedded := 0
+	for _, summary := range page.Sessions {
+		if !index.NeedsUpdate(summary) {
+			continue
+		}
+		if err := ix.embedSession(ctx, index, summary.ID); err != nil {
+			return err
+		}
+		embedded++
+	}
+
+	if embedded > 0 {
+		ix.logger.Info("Embedded changed sessions", "count", embedded, "exchanges", index.Len(), "model", index.Model())
+	}
+	return nil
+}
+
+// syncSessions embeds the given sessions, dropping those that no longer exist
+func (ix *Indexer) syncSessions(ctx context.Context, sessionIDs map[string]bool) error {
+	ix.mu.Lock()
+	index := ix.index
+	ix.mu.Unlock()
+
+	for id := range sessionIDs {
+		if err := ix.embedSession(ctx, index, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// embedSession embeds the exchanges of a session that are not embedded yet
+func (ix *Indexer) embedSession(ctx context.Context, index *search.VectorIndex, sessionID string) error {
+	session, err := ix.storage.LoadChatSession(ctx, sessionID)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Deleted since it was scheduled
+		index.RemoveSession(sessionID)
+		return nil
+	}
+
+	exchanges := search.Exchanges(session.Messages)
+	known := index.Vectors(sessionID)
+
+	var missing []int
+	for i, exchange := range exchanges {
+		if _, ok := known[exchange.Hash]; !ok {
+			missing = append(missing, i)
+		}
+	}
+
+	for start := 0; start < len(missing); start += embedBatchSize {
+		end := start + embedBatchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+
+		inputs := make([]string, 0, end-start)
+		for _, i := range missing[start:end] {
+			inputs = append(inputs, exchanges[i].Text)
+		}
+
+		vectors, err := ix.embedder.Embed(ctx, index.Model(), inputs)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			return fmt.Errorf("failed to embed session %s: %w", sessionID, err)
+		}
+		for j, i := range missing[start:end] {
+			known[exchanges[i].Hash] = vectors[j]
+		}
+	}
+
+	chunks := make([]search.VectorChunk, len(exchanges))
+	for i, exchange := range exchanges {
+		chunks[i] = search.VectorChunk{
+			MessageIndex: exchange.MessageIndex,
+			Preview:      exchange.Preview,
+			Hash:         exchange.Hash,
+			Vector:       known[exchange.Hash],
+		}
+	}
+	index.SetSession(sessionID, session.Name, chunks)
+	return nil
+}
+
+// save writes the index file
+func (ix *Indexer) save() error {
+	if ix.path == "" {
+		return nil
+	}
+
+	ix.mu.Lock()
+	index := ix.index
+	ix.mu.Unlock()
+
+	data, err := index.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to encode vector index: %w", err)
+	}
+
+	// Write then rename, so a crash never leaves a torn index
+	tmp := ix.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write vector index: %w", err)
+	}
+	if err := os.Rename(tmp, ix.path); err != nil {
+		return fmt.Errorf("failed to replace vector index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package storage_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/ashprao/ollamachat/internal/storage"
+	"github.com/ashprao/ollamachat/internal/storage/storagebench"
+)
+
+// benchWorkload is a shorter answer than the default, so each iteration stays quick
+var benchWorkload = storagebench.StreamingWorkload{
+	HistoryMessages: 200,
+	MessageSize:     500,
+	Chunks:          200,
+	ChunkSize:       20,
+	ChunksPerFlush:  25,
+}
+
+// benchmarkStreaming runs strategy against a fresh storage per iteration and reports
+// the writes it makes
+func benchmarkStreaming(b *testing.B, newStorage func(dir string) (storage.Storage, error),
+	strategy func(context.Context, storage.Storage, storagebench.StreamingWorkload) (storagebench.StreamingResult, error)) {
+	ctx := context.Background()
+	var writes, written int64
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		s, err := newStorage(b.TempDir())
+		if err != nil {
+			b.Fatalf("open storage: %v", err)
+		}
+		b.StartTimer()
+
+		result, err := strategy(ctx, s, benchWorkload)
+		if err != nil {
+			b.Fatal(err)
+		}
+		writes += result.Writes
+		written += result.BytesWritten
+
+		b.StopTimer()
+		s.Close()
+		b.StartTimer()
+	}
+	// Only some storages count their writes
+	if writes > 0 {
+		b.ReportMetric(float64(writes)/float64(b.N), "writes/op")
+		b.ReportMetric(float64(written)/float64(b.N), "written-B/op")
+	}
+}
+
+// openFile opens a FileStorage for a benchmark
+func openFile(dir string) (storage.Storage, error) {
+	return storage.NewFileStorage(dir, nil, testLogger())
+}
+
+// openSQLite opens a SQLiteStorage for a benchmark
+func openSQLite(dir string) (storage.Storage, error) {
+	return storage.NewSQLiteStorage(filepath.Join(dir, storage.DefaultSQLiteFile), testLogger())
+}
+
+func BenchmarkFileStreamingRewritePerChunk(b *testing.B) {
+	benchmarkStreaming(b, openFile, storagebench.MeasureRewritePerChunk)
+}
+
+func BenchmarkFileStreamingBatchedAppend(b *testing.B) {
+	benchmarkStreaming(b, openFile, storagebench.MeasureBatchedAppend)
+}
+
+func BenchmarkSQLiteStreamingRewritePerChunk(b *testing.B) {
+	benchmarkStreaming(b, openSQLite, storagebench.MeasureRewritePerChunk)
+}
+
+func BenchmarkSQLiteStreamingBatchedAppend(b *testing.B) {
+	benchmarkStreaming(b, openSQLite, storagebench.MeasureBatchedAppend)
+}
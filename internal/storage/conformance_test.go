@@ -0,0 +1,79 @@
+package storage_test
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/ashprao/ollamachat/internal/storage"
+	"github.com/ashprao/ollamachat/internal/storage/storagetest"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+// testLogger returns a logger that keeps test output quiet
+func testLogger() *logger.Logger {
+	return logger.NewLogger(slog.LevelError)
+}
+
+// newFileStorage opens a FileStorage in a fresh directory
+func newFileStorage(t *testing.T) *storage.FileStorage {
+	t.Helper()
+	fs, err := storage.NewFileStorage(t.TempDir(), nil, testLogger())
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	return fs
+}
+
+func TestMemoryStorageConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return storage.NewMemoryStorage(testLogger())
+	})
+}
+
+func TestFileStorageConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return newFileStorage(t)
+	})
+}
+
+func TestEncryptedStorageConformance(t *testing.T) {
+	// Deriving the key is slow on purpose, so one key serves every test
+	cipher, err := storage.CreateKey(t.TempDir(), "correct horse battery staple")
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+
+	t.Run("Memory", func(t *testing.T) {
+		storagetest.Run(t, func(t *testing.T) storage.Storage {
+			return storage.NewEncryptedStorage(storage.NewMemoryStorage(testLogger()), cipher, testLogger())
+		})
+	})
+	t.Run("File", func(t *testing.T) {
+		storagetest.Run(t, func(t *testing.T) storage.Storage {
+			return storage.NewEncryptedStorage(newFileStorage(t), cipher, testLogger())
+		})
+	})
+}
+
+func TestIndexedStorageConformance(t *testing.T) {
+	t.Run("Memory", func(t *testing.T) {
+		storagetest.Run(t, func(t *testing.T) storage.Storage {
+			return storage.NewIndexedStorage(storage.NewMemoryStorage(testLogger()), t.TempDir(), testLogger())
+		})
+	})
+	t.Run("File", func(t *testing.T) {
+		storagetest.Run(t, func(t *testing.T) storage.Storage {
+			return storage.NewIndexedStorage(newFileStorage(t), t.TempDir(), testLogger())
+		})
+	})
+}
+
+func TestSyncStorageConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		ss, err := storage.NewSyncStorage(t.TempDir(), "laptop", testLogger())
+		if err != nil {
+			t.Fatalf("NewSyncStorage: %v", err)
+		}
+		return ss
+	})
+}
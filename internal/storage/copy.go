@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// SessionImporter is implemented by storages that can store a session without
+// touching its timestamps, so copied sessions keep their original ordering
+type SessionImporter interface {
+	ImportChatSession(ctx context.Context, session models.ChatSession) error
+}
+
+// CopyReport summarizes what CopyStorage transferred
+type CopyReport struct {
+	Sessions  int
+	Messages  int
+	Personas  int
+	Templates int
+	Skipped   []string // Items that could not be copied, with the reason
+}
+
+// CopyStorage copies every session, persona, template and setting from src into dst.
+// Existing items in dst with the same IDs are overwritten. Items that fail to copy
+// are recorded in the report and do not stop the copy.
+func CopyStorage(ctx context.Context, dst, src Storage) (CopyReport, error) {
+	var report CopyReport
+
+	sessions, err := src.ListChatSessions(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to list source sessions: %w", err)
+	}
+
+	importer, preservesTimestamps := dst.(SessionImporter)
+	for _, session := range sessions {
+		if preservesTimestamps {
+			err = importer.ImportChatSession(ctx, session)
+		} else {
+			err = dst.SaveChatSession(ctx, session)
+		}
+		if err != nil {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("session %s: %v", session.ID, err))
+			continue
+		}
+		report.Sessions++
+		report.Messages += len(session.Messages)
+	}
+
+	personas, err := src.ListPersonas(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to list source personas: %w", err)
+	}
+	for _, persona := range personas {
+		if err := dst.SavePersona(ctx, persona); err != nil {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("persona %s: %v", persona.ID, err))
+			continue
+		}
+		report.Personas++
+	}
+
+	templates, err := src.ListPromptTemplates(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to list source prompt templates: %w", err)
+	}
+	for _, template := range templates {
+		if err := dst.SavePromptTemplate(ctx, template); err != nil {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("template %s: %v", template.ID, err))
+			continue
+		}
+		report.Templates++
+	}
+
+	prefs, err := src.LoadAppPreferences(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to load source preferences: %w", err)
+	}
+	if err := dst.SaveAppPreferences(ctx, prefs); err != nil {
+		return report, fmt.Errorf("failed to copy preferences: %w", err)
+	}
+
+	servers, err := src.LoadMCPServers(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to load source MCP servers: %w", err)
+	}
+	if err := dst.SaveMCPServers(ctx, servers); err != nil {
+		return report, fmt.Errorf("failed to copy MCP servers: %w", err)
+	}
+
+	agentConfig, err := src.LoadAgentConfig(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to load source agent config: %w", err)
+	}
+	if err := dst.SaveAgentConfig(ctx, agentConfig); err != nil {
+		return report, fmt.Errorf("failed to copy agent config: %w", err)
+	}
+
+	return report, nil
+}
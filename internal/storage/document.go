@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// schemaBackupDir holds original copies of documents upgraded by schema migrations
+const schemaBackupDir = "backups/schema"
+
+// decodeStoredDocument upgrades document bytes read from path to the current schema
+// and decodes them into v. When a migration runs, the original file is backed up and
+// the upgraded document is written back in its place.
+func (fs *FileStorage) decodeStoredDocument(path string, kind DocumentKind, data []byte, v interface{}) error {
+	result, err := UpgradeDocument(kind, data)
+	if err != nil {
+		return err
+	}
+
+	if result.Upgraded {
+		backupPath, err := fs.backupDocument(path, result.FromVersion, data)
+		if err != nil {
+			return fmt.Errorf("failed to back up %s before migration: %w", kind, err)
+		}
+
+		if err := writeFileAtomic(path, result.Data, filePerm); err != nil {
+			return fmt.Errorf("failed to write migrated %s: %w", kind, err)
+		}
+
+		fs.logger.Info("Migrated stored document",
+			"kind", kind,
+			"path", path,
+			"from_version", result.FromVersion,
+			"to_version", CurrentSchemaVersions[kind],
+			"backup", backupPath)
+	}
+
+	return DecodeDocument(kind, result.Data, v)
+}
+
+// writeDocumentFile writes an encoded document, refusing to replace a file written
+// by a newer version of the application so its data is never silently lost.
+func (fs *FileStorage) writeDocumentFile(path string, kind DocumentKind, data []byte) error {
+	if existing, err := os.ReadFile(path); err == nil {
+		version, err := DocumentVersion(kind, existing)
+		if err == nil && version > CurrentSchemaVersions[kind] {
+			return &SchemaVersionError{Kind: kind, Version: version, Current: CurrentSchemaVersions[kind]}
+		}
+	}
+
+	fs.recordWrite(len(data))
+	return writeFileAtomic(path, data, filePerm)
+}
+
+// backupDocument copies the original bytes of a document into the schema backup directory
+func (fs *FileStorage) backupDocument(path string, version int, data []byte) (string, error) {
+	backupDir := filepath.Join(fs.basePath, schemaBackupDir)
+	if err := os.MkdirAll(backupDir, dirPerm); err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(fs.basePath, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+	name := strings.TrimSuffix(strings.ReplaceAll(filepath.ToSlash(rel), "/", "_"), ".json")
+	backupPath := filepath.Join(backupDir, fmt.Sprintf("%s.v%d-%s.json", name, version, time.Now().Format("20060102-150405")))
+
+	if err := writeFileAtomic(backupPath, data, filePerm); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
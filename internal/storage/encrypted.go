@@ -0,0 +1,323 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+// EncryptedStorage wraps a Storage and encrypts the private parts of every session
+// (its name, system prompt and message text) before they reach the wrapped backend.
+// IDs, timestamps and settings stay readable so the backend can list and sort sessions.
+// Each value is bound to its session, field and message index, so values cannot be
+// swapped or reordered undetected.
+type EncryptedStorage struct {
+	Storage
+	cipher *Cipher
+	logger *logger.Logger
+}
+
+// NewEncryptedStorage wraps inner so session content is stored encrypted with cipher
+func NewEncryptedStorage(inner Storage, cipher *Cipher, logger *logger.Logger) *EncryptedStorage {
+	return &EncryptedStorage{
+		Storage: inner,
+		cipher:  cipher,
+		logger:  logger.WithComponent("encrypted-storage"),
+	}
+}
+
+// SaveChatSession encrypts and saves a session
+func (es *EncryptedStorage) SaveChatSession(ctx context.Context, session models.ChatSession) error {
+	sealed, err := es.sealSession(session)
+	if err != nil {
+		return err
+	}
+	return es.Storage.SaveChatSession(ctx, sealed)
+}
+
+// ImportChatSession encrypts and stores a session, keeping its timestamps when the
+// wrapped storage can
+func (es *EncryptedStorage) ImportChatSession(ctx context.Context, session models.ChatSession) error {
+	sealed, err := es.sealSession(session)
+	if err != nil {
+		return err
+	}
+	if importer, ok := es.Storage.(SessionImporter); ok {
+		return importer.ImportChatSession(ctx, sealed)
+	}
+	return es.Storage.SaveChatSession(ctx, sealed)
+}
+
+// LoadChatSession loads and decrypts a session
+func (es *EncryptedStorage) LoadChatSession(ctx context.Context, sessionID string) (models.ChatSession, error) {
+	session, err := es.Storage.LoadChatSession(ctx, sessionID)
+	if err != nil {
+		return session, err
+	}
+	return es.openSession(session, false)
+}
+
+// ListChatSessions loads and decrypts every session
+func (es *EncryptedStorage) ListChatSessions(ctx context.Context) ([]models.ChatSession, error) {
+	sessions, err := es.Storage.ListChatSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		if sessions[i], err = es.openSession(sessions[i], false); err != nil {
+			return nil, err
+		}
+	}
+	return sessions, nil
+}
+
+// ListSessionSummaries lists sessions with decrypted names. Encrypted names cannot be
+// sorted by the wrapped storage, so sorting by name is done here over all sessions.
+func (es *EncryptedStorage) ListSessionSummaries(ctx context.Context, opts SessionListOptions) (SessionPage, error) {
+	if err := opts.validate(); err != nil {
+		return SessionPage{}, err
+	}
+
+	innerOpts := opts
+	if opts.SortBy == SortByName {
+		innerOpts = SessionListOptions{}
+	}
+
+	page, err := es.Storage.ListSessionSummaries(ctx, innerOpts)
+	if err != nil {
+		return page, err
+	}
+	for i := range page.Sessions {
+		summary := &page.Sessions[i]
+		if summary.Name, err = es.open(summary.Name, nameContext(summary.ID), summary.ID, false); err != nil {
+			return SessionPage{}, fmt.Errorf("failed to decrypt session %s: %w", summary.ID, err)
+		}
+	}
+
+	if opts.SortBy == SortByName {
+		return pageSummaries(page.Sessions, opts), nil
+	}
+	return page, nil
+}
+
+// ListTrashedSessions lists trashed sessions with decrypted names. Sessions trashed
+// before the store was encrypted are listed without their unencrypted name.
+func (es *EncryptedStorage) ListTrashedSessions(ctx context.Context) ([]TrashedSession, error) {
+	trashed, err := es.Storage.ListTrashedSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range trashed {
+		item := &trashed[i]
+		name, err := es.open(item.Name, nameContext(item.ID), item.ID, false)
+		if errors.Is(err, ErrNotSealed) {
+			es.logger.Warn("Hiding the unencrypted name of a trashed session", "session_id", item.ID)
+			name, err = "", nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt session %s: %w", item.ID, err)
+		}
+		item.Name = name
+	}
+	return trashed, nil
+}
+
+// AppendMessages encrypts and stores messages
+func (es *EncryptedStorage) AppendMessages(ctx context.Context, sessionID string, from int, messages []models.ChatMessage) error {
+	sealed := make([]models.ChatMessage, len(messages))
+	for i, msg := range messages {
+		content, err := es.cipher.Seal(msg.Content, messageContext(sessionID, from+i))
+		if err != nil {
+			return err
+		}
+		msg.Content = content
+		sealed[i] = msg
+	}
+	return es.Storage.AppendMessages(ctx, sessionID, from, sealed)
+}
+
+// WatchChanges publishes changes to the wrapped storage with sessions decrypted
+func (es *EncryptedStorage) WatchChanges(fn func(Change)) (stop func()) {
+	watcher, ok := es.Storage.(ChangeWatcher)
+	if !ok {
+		return func() {}
+	}
+	return watcher.WatchChanges(func(change Change) {
+		if change.Kind == SessionChanged {
+			session, err := es.openSession(change.Session, false)
+			if err != nil {
+				es.logger.Warn("Failed to decrypt session changed elsewhere", "session_id", change.SessionID, "error", err)
+				return
+			}
+			change.Session = session
+		}
+		fn(change)
+	})
+}
+
+// Unwrap returns the wrapped storage
+func (es *EncryptedStorage) Unwrap() Storage {
+	return es.Storage
+}
+
+// EncryptExisting encrypts sessions stored in plaintext before encryption was turned
+// on, keeping their timestamps where the wrapped storage allows. With a legacy key, it
+// also seals every session again bound to the position of each value, after which the
+// key can be upgraded with UpgradeKey. Trashed sessions are sealed too; they are
+// restored for the rewrite and trashed again, so their time in the trash starts over.
+// It returns the number of sessions rewritten. This is the only way unencrypted values
+// are read from an encrypted store.
+func (es *EncryptedStorage) EncryptExisting(ctx context.Context) (int, error) {
+	page, err := es.Storage.ListSessionSummaries(ctx, SessionListOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	trashed, err := es.Storage.ListTrashedSessions(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list the trash: %w", err)
+	}
+
+	encrypted := 0
+	for _, summary := range page.Sessions {
+		sealed, err := es.sealStored(ctx, summary.ID)
+		if err != nil {
+			return encrypted, err
+		}
+		if sealed {
+			encrypted++
+		}
+	}
+
+	for _, item := range trashed {
+		// A sealed name means the session was written through this storage
+		if !es.cipher.Legacy() && item.Name != "" && IsSealed(item.Name) {
+			continue
+		}
+		if err := es.Storage.RestoreChatSession(ctx, item.ID); err != nil {
+			return encrypted, fmt.Errorf("failed to restore trashed session %s: %w", item.ID, err)
+		}
+		sealed, err := es.sealStored(ctx, item.ID)
+		// Trash the session again even if sealing failed, so it is not left in the session list
+		if trashErr := es.Storage.TrashChatSession(ctx, item.ID); trashErr != nil && err == nil {
+			err = fmt.Errorf("failed to trash session %s again: %w", item.ID, trashErr)
+		}
+		if err != nil {
+			return encrypted, err
+		}
+		if sealed {
+			encrypted++
+		}
+	}
+
+	es.logger.Info("Encrypted plaintext sessions", "count", encrypted, "total", len(page.Sessions), "trashed", len(trashed))
+	return encrypted, nil
+}
+
+// sealStored seals a stored session that holds plaintext, or any session with a legacy
+// key. It reports whether the session was rewritten.
+func (es *EncryptedStorage) sealStored(ctx context.Context, sessionID string) (bool, error) {
+	session, err := es.Storage.LoadChatSession(ctx, sessionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load session %s: %w", sessionID, err)
+	}
+	if !es.cipher.Legacy() && !hasPlaintext(session) {
+		return false, nil
+	}
+
+	if session, err = es.openSession(session, true); err != nil {
+		return false, err
+	}
+	if err := es.ImportChatSession(ctx, session); err != nil {
+		return false, fmt.Errorf("failed to encrypt session %s: %w", sessionID, err)
+	}
+	return true, nil
+}
+
+// hasPlaintext reports whether any private field of a stored session is unencrypted
+func hasPlaintext(session models.ChatSession) bool {
+	if (session.Name != "" && !IsSealed(session.Name)) || (session.SystemPrompt != "" && !IsSealed(session.SystemPrompt)) {
+		return true
+	}
+	for _, msg := range session.Messages {
+		if msg.Content != "" && !IsSealed(msg.Content) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameContext binds an encrypted session name to its session
+func nameContext(sessionID string) string {
+	return sessionID + "/name"
+}
+
+// promptContext binds an encrypted system prompt to its session
+func promptContext(sessionID string) string {
+	return sessionID + "/system_prompt"
+}
+
+// messageContext binds encrypted message text to its session and position
+func messageContext(sessionID string, index int) string {
+	return fmt.Sprintf("%s/messages/%d", sessionID, index)
+}
+
+// sealSession returns a copy of session with its private fields encrypted
+func (es *EncryptedStorage) sealSession(session models.ChatSession) (models.ChatSession, error) {
+	var err error
+	if session.Name, err = es.cipher.Seal(session.Name, nameContext(session.ID)); err != nil {
+		return session, err
+	}
+	if session.SystemPrompt, err = es.cipher.Seal(session.SystemPrompt, promptContext(session.ID)); err != nil {
+		return session, err
+	}
+
+	messages := make([]models.ChatMessage, len(session.Messages))
+	for i, msg := range session.Messages {
+		if msg.Content, err = es.cipher.Seal(msg.Content, messageContext(session.ID, i)); err != nil {
+			return session, err
+		}
+		messages[i] = msg
+	}
+	session.Messages = messages
+	return session, nil
+}
+
+// openSession decrypts the private fields of a stored session. Unencrypted values are
+// only accepted when migrating.
+func (es *EncryptedStorage) openSession(session models.ChatSession, migrating bool) (models.ChatSession, error) {
+	var err error
+	if session.Name, err = es.open(session.Name, nameContext(session.ID), session.ID, migrating); err != nil {
+		return session, fmt.Errorf("failed to decrypt session %s: %w", session.ID, err)
+	}
+	if session.SystemPrompt, err = es.open(session.SystemPrompt, promptContext(session.ID), session.ID, migrating); err != nil {
+		return session, fmt.Errorf("failed to decrypt session %s: %w", session.ID, err)
+	}
+	for i := range session.Messages {
+		msg := &session.Messages[i]
+		if msg.Content, err = es.open(msg.Content, messageContext(session.ID, i), session.ID, migrating); err != nil {
+			return session, fmt.Errorf("failed to decrypt session %s: %w", session.ID, err)
+		}
+	}
+	return session, nil
+}
+
+// open decrypts one value sealed with context. With a legacy key, a value sealed
+// bound to its session only is accepted too. An unencrypted value is returned as is
+// when migrating and refused otherwise.
+func (es *EncryptedStorage) open(value, context, sessionID string, migrating bool) (string, error) {
+	opened, err := es.cipher.Open(value, context)
+	switch {
+	case err == nil:
+		return opened, nil
+	case errors.Is(err, ErrNotSealed) && migrating:
+		return value, nil
+	case errors.Is(err, ErrNotSealed):
+		return "", fmt.Errorf("%w; run 'ollamachat encrypt-storage' to finish encrypting the store", err)
+	case es.cipher.Legacy():
+		return es.cipher.Open(value, sessionID)
+	}
+	return "", err
+}
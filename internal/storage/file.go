@@ -2,15 +2,17 @@ package storage
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"fyne.io/fyne/v2"
+	"github.com/fsnotify/fsnotify"
 
 	"github.com/ashprao/ollamachat/internal/models"
 	"github.com/ashprao/ollamachat/pkg/logger"
@@ -18,9 +20,56 @@ import (
 
 // FileStorage implements the Storage interface using local file system
 type FileStorage struct {
-	basePath string
-	app      fyne.App
-	logger   *logger.Logger
+	basePath      string
+	app           fyne.App
+	logger        *logger.Logger
+	lock          *dirLock
+	quarantined   []string
+	journalMu     sync.Mutex       // Serializes session journal appends, compaction and snapshots
+	messageCounts map[string]int   // Known message count per session, guarded by journalMu
+	journalSeqs   map[string]int64 // Last journal entry number per session, guarded by journalMu
+	indexMu       sync.RWMutex
+	index         map[string]sessionIndexEntry // Session summaries, guarded by indexMu
+	trash         map[string]TrashedSession    // Trashed sessions, guarded by journalMu
+	trashState    fileState                    // Trash manifest as last written or read, guarded by journalMu
+	observed      map[string]sessionFiles      // Last known state of session files, guarded by journalMu
+	seen          map[string]sessionFiles      // Session files as last read or written by callers, guarded by journalMu
+	indexDirty    bool
+	writes        atomic.Int64
+	bytes         atomic.Int64
+
+	watchMu     sync.Mutex // Guards the watcher state below
+	watcher     *fsnotify.Watcher
+	watchDone   chan struct{}
+	subscribers map[int]func(Change)
+	nextWatchID int
+	prefsState  fileState // Preferences file as last written or read
+}
+
+// checkID refuses an ID that could not have come from models.NewID. IDs name the files
+// records are stored in, so one holding a path separator or ".." would reach outside
+// the data directory.
+func checkID(kind, id string) error {
+	if !models.IsValidID(id) {
+		return fmt.Errorf("invalid %s ID: %q", kind, id)
+	}
+	return nil
+}
+
+// personaPath returns the file of a persona
+func (fs *FileStorage) personaPath(personaID string) (string, error) {
+	if err := checkID("persona", personaID); err != nil {
+		return "", err
+	}
+	return filepath.Join(fs.basePath, "personas", personaID+".json"), nil
+}
+
+// templatePath returns the file of a prompt template
+func (fs *FileStorage) templatePath(templateID string) (string, error) {
+	if err := checkID("prompt template", templateID); err != nil {
+		return "", err
+	}
+	return filepath.Join(fs.basePath, "templates", templateID+".json"), nil
 }
 
 // NewFileStorage creates a new file-based storage implementation
@@ -30,54 +79,183 @@ func NewFileStorage(basePath string, app fyne.App, logger *logger.Logger) (*File
 	}
 
 	// Create base directory if it doesn't exist
-	if err := os.MkdirAll(basePath, 0755); err != nil {
+	if err := os.MkdirAll(basePath, dirPerm); err != nil {
 		return nil, fmt.Errorf("failed to create storage directory: %w", err)
 	}
 
+	// Only one instance may use a data directory at a time
+	lock, err := acquireDirLock(basePath)
+	if err != nil {
+		return nil, err
+	}
+
 	fs := &FileStorage{
 		basePath: basePath,
 		app:      app,
 		logger:   logger.WithComponent("file-storage"),
+		lock:     lock,
+
+		messageCounts: make(map[string]int),
+		journalSeqs:   make(map[string]int64),
+		index:         make(map[string]sessionIndexEntry),
+		trash:         make(map[string]TrashedSession),
+		observed:      make(map[string]sessionFiles),
+		seen:          make(map[string]sessionFiles),
+		subscribers:   make(map[int]func(Change)),
 	}
 
+	// Files written by older versions were readable by other users
+	if changed, err := TightenPermissions(basePath); err != nil {
+		fs.logger.Warn("Failed to restrict data directory permissions", "error", err)
+	} else if changed > 0 {
+		fs.logger.Info("Restricted data directory permissions to the owner", "entries", changed)
+	}
+
+	// Move files damaged by a crash out of the way before anything reads them
+	quarantined, err := fs.quarantineCorruptFiles()
+	if err != nil {
+		fs.logger.Error("Failed to check stored files for corruption", "error", err)
+	}
+	fs.quarantined = quarantined
+	if len(quarantined) > 0 {
+		fs.logger.Warn("Moved corrupt files to quarantine", "count", len(quarantined), "dir", filepath.Join(basePath, quarantineDir))
+	}
+
+	// One-time upgrade of legacy timestamp-based session IDs
+	if idMap, err := fs.MigrateSessionIDs(context.Background()); err != nil {
+		fs.logger.Error("Failed to migrate legacy session IDs", "error", err)
+	} else if len(idMap) > 0 {
+		fs.logger.Info("Migrated legacy session IDs", "count", len(idMap))
+	}
+
+	// Fold journals left over from the previous run into their sessions
+	fs.compactAllJournals()
+
+	// Summaries for the session list, reading only sessions changed since the last run
+	if err := fs.loadSessionIndex(); err != nil {
+		fs.logger.Error("Failed to load session index", "error", err)
+	}
+
+	// When each session in the trash was deleted, for retention purging
+	fs.loadTrash()
+
 	fs.logger.Info("Initialized file storage", "base_path", basePath)
 	return fs, nil
 }
 
-// SaveChatSession saves a chat session to file
+// SaveChatSession saves a chat session to file, replacing any journaled messages
 func (fs *FileStorage) SaveChatSession(ctx context.Context, session models.ChatSession) error {
 	fs.logger.Info("Saving chat session", "session_id", session.ID, "message_count", len(session.Messages))
 
-	// Save to session-based structure
-	sessionPath := filepath.Join(fs.basePath, "sessions", fmt.Sprintf("%s.json", session.ID))
-	if err := os.MkdirAll(filepath.Dir(sessionPath), 0755); err != nil {
-		fs.logger.Error("Failed to create sessions directory", "error", err)
-		return fmt.Errorf("failed to create sessions directory: %w", err)
-	}
-
 	// Update the session timestamp
 	session.UpdatedAt = time.Now()
 
-	data, err := json.MarshalIndent(session, "", "  ")
+	if err := fs.storeSession(session, true); err != nil {
+		fs.logger.Error("Failed to save session", "session_id", session.ID, "error", err)
+		return err
+	}
+
+	fs.logger.Info("Successfully saved chat session", "session_id", session.ID)
+	return nil
+}
+
+// ImportChatSession stores a session exactly as given, keeping its timestamps
+func (fs *FileStorage) ImportChatSession(ctx context.Context, session models.ChatSession) error {
+	if err := fs.storeSession(session, false); err != nil {
+		fs.logger.Error("Failed to import session", "session_id", session.ID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// storeSession writes a session snapshot and drops its journal. A trashed copy of
+// the session is discarded, as saving it again brings it back. With checkConflict set,
+// a session changed by another process since the caller last loaded it is not overwritten.
+func (fs *FileStorage) storeSession(session models.ChatSession, checkConflict bool) error {
+	journalPath, err := fs.journalPath(session.ID)
+	if err != nil {
+		return err
+	}
+
+	fs.journalMu.Lock()
+	defer fs.journalMu.Unlock()
+
+	if checkConflict {
+		if err := fs.checkSession(session.ID); err != nil {
+			return err
+		}
+	}
+	fs.discardTrashed(session.ID)
+
+	// Number the snapshot past every journal entry, so a journal that outlives it
+	// after a crash is not replayed over it
+	seq, known := fs.journalSeqs[session.ID]
+	if !known {
+		seq = fs.lastJournalSeq(session.ID)
+	}
+	if err := fs.writeSessionSnapshot(session, seq); err != nil {
+		return err
+	}
+	fs.messageCounts[session.ID] = len(session.Messages)
+	fs.journalSeqs[session.ID] = seq
+
+	// The snapshot now holds every message, so the journal is obsolete
+	if err := os.Remove(journalPath); err != nil && !os.IsNotExist(err) {
+		fs.logger.Warn("Failed to remove session journal", "session_id", session.ID, "error", err)
+	}
+	fs.noteSession(session.ID, true)
+	return nil
+}
+
+// writeSessionSnapshot writes the full session file, holding journal entries up to seq
+func (fs *FileStorage) writeSessionSnapshot(session models.ChatSession, seq int64) error {
+	sessionPath, err := fs.sessionPath(session.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(sessionPath), dirPerm); err != nil {
+		return fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+
+	data, err := EncodeDocument(KindSession, sessionSnapshot{ChatSession: session, JournalSeq: seq})
 	if err != nil {
-		fs.logger.Error("Failed to marshal session", "session_id", session.ID, "error", err)
 		return fmt.Errorf("failed to marshal session: %w", err)
 	}
 
-	if err := os.WriteFile(sessionPath, data, 0644); err != nil {
-		fs.logger.Error("Failed to write session file", "session_id", session.ID, "error", err)
+	if err := fs.writeDocumentFile(sessionPath, KindSession, data); err != nil {
 		return fmt.Errorf("failed to write session file: %w", err)
 	}
-
-	fs.logger.Info("Successfully saved chat session", "session_id", session.ID)
+	fs.indexSnapshot(session, sessionPath)
 	return nil
 }
 
-// LoadChatSession loads a chat session from file
+// LoadChatSession loads a chat session from file, including journaled messages
 func (fs *FileStorage) LoadChatSession(ctx context.Context, sessionID string) (models.ChatSession, error) {
-	fs.logger.Info("Loading chat session", "session_id", sessionID)
+	fs.journalMu.Lock()
+	defer fs.journalMu.Unlock()
 
-	sessionPath := filepath.Join(fs.basePath, "sessions", fmt.Sprintf("%s.json", sessionID))
+	session, err := fs.loadChatSession(ctx, sessionID)
+	if err == nil {
+		fs.noteSession(session.ID, true)
+	}
+	return session, err
+}
+
+// loadChatSession loads a session and replays its journal. The caller must hold journalMu.
+func (fs *FileStorage) loadChatSession(ctx context.Context, sessionID string) (models.ChatSession, error) {
+	fs.logger.Debug("Loading chat session", "session_id", sessionID)
+
+	if !models.IsValidID(sessionID) {
+		// Follow references to sessions renamed by the ID migration
+		if newID := fs.ResolveSessionID(sessionID); newID != sessionID {
+			fs.logger.Info("Resolved legacy session ID", "old_id", sessionID, "new_id", newID)
+			return fs.loadChatSession(ctx, newID)
+		}
+	}
+	sessionPath, err := fs.sessionPath(sessionID)
+	if err != nil {
+		return models.ChatSession{}, err
+	}
 	data, err := os.ReadFile(sessionPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -88,13 +266,22 @@ func (fs *FileStorage) LoadChatSession(ctx context.Context, sessionID string) (m
 		return models.ChatSession{}, fmt.Errorf("failed to read session file: %w", err)
 	}
 
-	var session models.ChatSession
-	if err := json.Unmarshal(data, &session); err != nil {
-		fs.logger.Error("Failed to unmarshal session", "session_id", sessionID, "error", err)
-		return models.ChatSession{}, fmt.Errorf("failed to unmarshal session: %w", err)
+	var snapshot sessionSnapshot
+	if err := fs.decodeStoredDocument(sessionPath, KindSession, data, &snapshot); err != nil {
+		fs.logger.Error("Failed to decode session", "session_id", sessionID, "error", err)
+		return models.ChatSession{}, fmt.Errorf("failed to decode session: %w", err)
 	}
 
-	fs.logger.Info("Successfully loaded chat session", "session_id", sessionID, "message_count", len(session.Messages))
+	session := snapshot.ChatSession
+	seq, err := fs.replayJournal(&session, snapshot.JournalSeq)
+	if err != nil {
+		fs.logger.Error("Failed to replay session journal", "session_id", sessionID, "error", err)
+		return models.ChatSession{}, err
+	}
+	fs.messageCounts[session.ID] = len(session.Messages)
+	fs.journalSeqs[session.ID] = seq
+
+	fs.logger.Debug("Successfully loaded chat session", "session_id", sessionID, "message_count", len(session.Messages))
 	return session, nil
 }
 
@@ -124,8 +311,12 @@ func (fs *FileStorage) ListChatSessions(ctx context.Context) ([]models.ChatSessi
 			continue
 		}
 
+		// A bulk read does not count as loading each session for editing, so it
+		// leaves conflict detection alone
 		sessionID := strings.TrimSuffix(entry.Name(), ".json")
-		session, err := fs.LoadChatSession(ctx, sessionID)
+		fs.journalMu.Lock()
+		session, err := fs.loadChatSession(ctx, sessionID)
+		fs.journalMu.Unlock()
 		if err != nil {
 			fs.logger.Warn("Failed to load session", "session_id", sessionID, "error", err)
 			continue
@@ -147,7 +338,14 @@ func (fs *FileStorage) ListChatSessions(ctx context.Context) ([]models.ChatSessi
 func (fs *FileStorage) DeleteChatSession(ctx context.Context, sessionID string) error {
 	fs.logger.Info("Deleting chat session", "session_id", sessionID)
 
-	sessionPath := filepath.Join(fs.basePath, "sessions", fmt.Sprintf("%s.json", sessionID))
+	sessionPath, err := fs.sessionPath(sessionID)
+	if err != nil {
+		return err
+	}
+	journalPath, err := fs.journalPath(sessionID)
+	if err != nil {
+		return err
+	}
 	if err := os.Remove(sessionPath); err != nil {
 		if os.IsNotExist(err) {
 			fs.logger.Warn("Session not found for deletion", "session_id", sessionID)
@@ -156,26 +354,270 @@ func (fs *FileStorage) DeleteChatSession(ctx context.Context, sessionID string)
 		fs.logger.Error("Failed to delete session file", "session_id", sessionID, "error", err)
 		return fmt.Errorf("failed to delete session file: %w", err)
 	}
+	fs.journalMu.Lock()
+	if err := os.Remove(journalPath); err != nil && !os.IsNotExist(err) {
+		fs.logger.Warn("Failed to remove session journal", "session_id", sessionID, "error", err)
+	}
+	delete(fs.messageCounts, sessionID)
+	delete(fs.journalSeqs, sessionID)
+	fs.forgetSession(sessionID)
+	fs.journalMu.Unlock()
+	fs.unindexSession(sessionID)
 
 	fs.logger.Info("Successfully deleted chat session", "session_id", sessionID)
 	return nil
 }
 
+// SavePersona saves a persona to file
+func (fs *FileStorage) SavePersona(ctx context.Context, persona models.Persona) error {
+	fs.logger.Info("Saving persona", "persona_id", persona.ID, "name", persona.Name)
+
+	if err := persona.Validate(); err != nil {
+		return fmt.Errorf("invalid persona: %w", err)
+	}
+
+	personaPath, err := fs.personaPath(persona.ID)
+	if err != nil {
+		return fmt.Errorf("invalid persona: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(personaPath), dirPerm); err != nil {
+		fs.logger.Error("Failed to create personas directory", "error", err)
+		return fmt.Errorf("failed to create personas directory: %w", err)
+	}
+
+	persona.UpdatedAt = time.Now()
+
+	data, err := EncodeDocument(KindPersona, persona)
+	if err != nil {
+		fs.logger.Error("Failed to marshal persona", "persona_id", persona.ID, "error", err)
+		return fmt.Errorf("failed to marshal persona: %w", err)
+	}
+
+	if err := fs.writeDocumentFile(personaPath, KindPersona, data); err != nil {
+		fs.logger.Error("Failed to write persona file", "persona_id", persona.ID, "error", err)
+		return fmt.Errorf("failed to write persona file: %w", err)
+	}
+
+	fs.logger.Info("Successfully saved persona", "persona_id", persona.ID)
+	return nil
+}
+
+// LoadPersona loads a persona from file
+func (fs *FileStorage) LoadPersona(ctx context.Context, personaID string) (models.Persona, error) {
+	personaPath, err := fs.personaPath(personaID)
+	if err != nil {
+		return models.Persona{}, err
+	}
+	data, err := os.ReadFile(personaPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fs.logger.Warn("Persona not found", "persona_id", personaID)
+			return models.Persona{}, fmt.Errorf("persona not found: %s", personaID)
+		}
+		fs.logger.Error("Failed to read persona file", "persona_id", personaID, "error", err)
+		return models.Persona{}, fmt.Errorf("failed to read persona file: %w", err)
+	}
+
+	var persona models.Persona
+	if err := fs.decodeStoredDocument(personaPath, KindPersona, data, &persona); err != nil {
+		fs.logger.Error("Failed to decode persona", "persona_id", personaID, "error", err)
+		return models.Persona{}, fmt.Errorf("failed to decode persona: %w", err)
+	}
+
+	return persona, nil
+}
+
+// ListPersonas lists all stored personas sorted by name
+func (fs *FileStorage) ListPersonas(ctx context.Context) ([]models.Persona, error) {
+	personas := []models.Persona{}
+
+	personasDir := filepath.Join(fs.basePath, "personas")
+	entries, err := os.ReadDir(personasDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return personas, nil
+		}
+		fs.logger.Error("Failed to read personas directory", "error", err)
+		return personas, fmt.Errorf("failed to read personas directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		personaID := strings.TrimSuffix(entry.Name(), ".json")
+		persona, err := fs.LoadPersona(ctx, personaID)
+		if err != nil {
+			fs.logger.Warn("Failed to load persona", "persona_id", personaID, "error", err)
+			continue
+		}
+
+		personas = append(personas, persona)
+	}
+
+	sort.Slice(personas, func(i, j int) bool {
+		return strings.ToLower(personas[i].Name) < strings.ToLower(personas[j].Name)
+	})
+
+	fs.logger.Info("Successfully listed personas", "count", len(personas))
+	return personas, nil
+}
+
+// DeletePersona deletes a persona
+func (fs *FileStorage) DeletePersona(ctx context.Context, personaID string) error {
+	fs.logger.Info("Deleting persona", "persona_id", personaID)
+
+	personaPath, err := fs.personaPath(personaID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(personaPath); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("persona not found: %s", personaID)
+		}
+		fs.logger.Error("Failed to delete persona file", "persona_id", personaID, "error", err)
+		return fmt.Errorf("failed to delete persona file: %w", err)
+	}
+
+	fs.logger.Info("Successfully deleted persona", "persona_id", personaID)
+	return nil
+}
+
+// SavePromptTemplate saves a prompt template to file
+func (fs *FileStorage) SavePromptTemplate(ctx context.Context, template models.PromptTemplate) error {
+	fs.logger.Info("Saving prompt template", "template_id", template.ID, "name", template.Name)
+
+	if err := template.Validate(); err != nil {
+		return fmt.Errorf("invalid prompt template: %w", err)
+	}
+
+	templatePath, err := fs.templatePath(template.ID)
+	if err != nil {
+		return fmt.Errorf("invalid prompt template: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(templatePath), dirPerm); err != nil {
+		fs.logger.Error("Failed to create templates directory", "error", err)
+		return fmt.Errorf("failed to create templates directory: %w", err)
+	}
+
+	template.UpdatedAt = time.Now()
+
+	data, err := EncodeDocument(KindTemplate, template)
+	if err != nil {
+		fs.logger.Error("Failed to marshal prompt template", "template_id", template.ID, "error", err)
+		return fmt.Errorf("failed to marshal prompt template: %w", err)
+	}
+
+	if err := fs.writeDocumentFile(templatePath, KindTemplate, data); err != nil {
+		fs.logger.Error("Failed to write prompt template file", "template_id", template.ID, "error", err)
+		return fmt.Errorf("failed to write prompt template file: %w", err)
+	}
+
+	fs.logger.Info("Successfully saved prompt template", "template_id", template.ID)
+	return nil
+}
+
+// LoadPromptTemplate loads a prompt template from file
+func (fs *FileStorage) LoadPromptTemplate(ctx context.Context, templateID string) (models.PromptTemplate, error) {
+	templatePath, err := fs.templatePath(templateID)
+	if err != nil {
+		return models.PromptTemplate{}, err
+	}
+	data, err := os.ReadFile(templatePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fs.logger.Warn("Prompt template not found", "template_id", templateID)
+			return models.PromptTemplate{}, fmt.Errorf("prompt template not found: %s", templateID)
+		}
+		fs.logger.Error("Failed to read prompt template file", "template_id", templateID, "error", err)
+		return models.PromptTemplate{}, fmt.Errorf("failed to read prompt template file: %w", err)
+	}
+
+	var template models.PromptTemplate
+	if err := fs.decodeStoredDocument(templatePath, KindTemplate, data, &template); err != nil {
+		fs.logger.Error("Failed to decode prompt template", "template_id", templateID, "error", err)
+		return models.PromptTemplate{}, fmt.Errorf("failed to decode prompt template: %w", err)
+	}
+
+	return template, nil
+}
+
+// ListPromptTemplates lists all stored prompt templates sorted by name
+func (fs *FileStorage) ListPromptTemplates(ctx context.Context) ([]models.PromptTemplate, error) {
+	templates := []models.PromptTemplate{}
+
+	templatesDir := filepath.Join(fs.basePath, "templates")
+	entries, err := os.ReadDir(templatesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return templates, nil
+		}
+		fs.logger.Error("Failed to read templates directory", "error", err)
+		return templates, fmt.Errorf("failed to read templates directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		templateID := strings.TrimSuffix(entry.Name(), ".json")
+		template, err := fs.LoadPromptTemplate(ctx, templateID)
+		if err != nil {
+			fs.logger.Warn("Failed to load prompt template", "template_id", templateID, "error", err)
+			continue
+		}
+
+		templates = append(templates, template)
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return strings.ToLower(templates[i].Name) < strings.ToLower(templates[j].Name)
+	})
+
+	fs.logger.Info("Successfully listed prompt templates", "count", len(templates))
+	return templates, nil
+}
+
+// DeletePromptTemplate deletes a prompt template
+func (fs *FileStorage) DeletePromptTemplate(ctx context.Context, templateID string) error {
+	fs.logger.Info("Deleting prompt template", "template_id", templateID)
+
+	templatePath, err := fs.templatePath(templateID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(templatePath); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("prompt template not found: %s", templateID)
+		}
+		fs.logger.Error("Failed to delete prompt template file", "template_id", templateID, "error", err)
+		return fmt.Errorf("failed to delete prompt template file: %w", err)
+	}
+
+	fs.logger.Info("Successfully deleted prompt template", "template_id", templateID)
+	return nil
+}
+
 // SaveAppPreferences saves application preferences
 func (fs *FileStorage) SaveAppPreferences(ctx context.Context, prefs AppPreferences) error {
 	fs.logger.Info("Saving application preferences")
 
 	prefsPath := filepath.Join(fs.basePath, "preferences.json")
-	data, err := json.MarshalIndent(prefs, "", "  ")
+	data, err := EncodeDocument(KindPreferences, prefs)
 	if err != nil {
 		fs.logger.Error("Failed to marshal preferences", "error", err)
 		return fmt.Errorf("failed to marshal preferences: %w", err)
 	}
 
-	if err := os.WriteFile(prefsPath, data, 0644); err != nil {
+	if err := fs.writeDocumentFile(prefsPath, KindPreferences, data); err != nil {
 		fs.logger.Error("Failed to write preferences file", "error", err)
 		return fmt.Errorf("failed to write preferences file: %w", err)
 	}
+	fs.watchMu.Lock()
+	fs.prefsState = statFile(prefsPath)
+	fs.watchMu.Unlock()
 
 	fs.logger.Info("Successfully saved application preferences")
 	return nil
@@ -197,9 +639,9 @@ func (fs *FileStorage) LoadAppPreferences(ctx context.Context) (AppPreferences,
 	}
 
 	var prefs AppPreferences
-	if err := json.Unmarshal(data, &prefs); err != nil {
-		fs.logger.Error("Failed to unmarshal preferences", "error", err)
-		return AppPreferences{}, fmt.Errorf("failed to unmarshal preferences: %w", err)
+	if err := fs.decodeStoredDocument(prefsPath, KindPreferences, data, &prefs); err != nil {
+		fs.logger.Error("Failed to decode preferences", "error", err)
+		return AppPreferences{}, fmt.Errorf("failed to decode preferences: %w", err)
 	}
 
 	fs.logger.Info("Successfully loaded application preferences")
@@ -211,13 +653,13 @@ func (fs *FileStorage) SaveMCPServers(ctx context.Context, servers []models.MCPS
 	fs.logger.Info("Saving MCP servers", "count", len(servers))
 
 	mcpPath := filepath.Join(fs.basePath, "mcp_servers.json")
-	data, err := json.MarshalIndent(servers, "", "  ")
+	data, err := EncodeDocument(KindMCPServers, servers)
 	if err != nil {
 		fs.logger.Error("Failed to marshal MCP servers", "error", err)
 		return fmt.Errorf("failed to marshal MCP servers: %w", err)
 	}
 
-	if err := os.WriteFile(mcpPath, data, 0644); err != nil {
+	if err := fs.writeDocumentFile(mcpPath, KindMCPServers, data); err != nil {
 		fs.logger.Error("Failed to write MCP servers file", "error", err)
 		return fmt.Errorf("failed to write MCP servers file: %w", err)
 	}
@@ -242,9 +684,9 @@ func (fs *FileStorage) LoadMCPServers(ctx context.Context) ([]models.MCPServer,
 	}
 
 	var servers []models.MCPServer
-	if err := json.Unmarshal(data, &servers); err != nil {
-		fs.logger.Error("Failed to unmarshal MCP servers", "error", err)
-		return nil, fmt.Errorf("failed to unmarshal MCP servers: %w", err)
+	if err := fs.decodeStoredDocument(mcpPath, KindMCPServers, data, &servers); err != nil {
+		fs.logger.Error("Failed to decode MCP servers", "error", err)
+		return nil, fmt.Errorf("failed to decode MCP servers: %w", err)
 	}
 
 	fs.logger.Info("Successfully loaded MCP servers", "count", len(servers))
@@ -256,13 +698,13 @@ func (fs *FileStorage) SaveAgentConfig(ctx context.Context, config models.AgentC
 	fs.logger.Info("Saving agent configuration")
 
 	agentPath := filepath.Join(fs.basePath, "agent_config.json")
-	data, err := json.MarshalIndent(config, "", "  ")
+	data, err := EncodeDocument(KindAgentConfig, config)
 	if err != nil {
 		fs.logger.Error("Failed to marshal agent config", "error", err)
 		return fmt.Errorf("failed to marshal agent config: %w", err)
 	}
 
-	if err := os.WriteFile(agentPath, data, 0644); err != nil {
+	if err := fs.writeDocumentFile(agentPath, KindAgentConfig, data); err != nil {
 		fs.logger.Error("Failed to write agent config file", "error", err)
 		return fmt.Errorf("failed to write agent config file: %w", err)
 	}
@@ -280,38 +722,52 @@ func (fs *FileStorage) LoadAgentConfig(ctx context.Context) (models.AgentConfig,
 	if err != nil {
 		if os.IsNotExist(err) {
 			fs.logger.Info("Agent config file not found, returning defaults")
-			return models.AgentConfig{
-				Enabled:            false,
-				DefaultFramework:   "eino",
-				MaxConcurrent:      1,
-				Timeout:            30,
-				EnableMCPTools:     false,
-				EnableBuiltinTools: true,
-				LogLevel:           "info",
-				EnableTracing:      false,
-				UpdatedAt:          time.Now(),
-			}, nil
+			return NewDefaultAgentConfig(), nil
 		}
 		fs.logger.Error("Failed to read agent config file", "error", err)
 		return models.AgentConfig{}, fmt.Errorf("failed to read agent config file: %w", err)
 	}
 
 	var config models.AgentConfig
-	if err := json.Unmarshal(data, &config); err != nil {
-		fs.logger.Error("Failed to unmarshal agent config", "error", err)
-		return models.AgentConfig{}, fmt.Errorf("failed to unmarshal agent config: %w", err)
+	if err := fs.decodeStoredDocument(agentPath, KindAgentConfig, data, &config); err != nil {
+		fs.logger.Error("Failed to decode agent config", "error", err)
+		return models.AgentConfig{}, fmt.Errorf("failed to decode agent config: %w", err)
 	}
 
 	fs.logger.Info("Successfully loaded agent configuration")
 	return config, nil
 }
 
-// Close closes the storage (no-op for file storage)
+// Close stops watching for changes and releases the data directory lock
 func (fs *FileStorage) Close() error {
 	fs.logger.Info("Closing file storage")
+	fs.stopWatching()
+	if err := fs.saveSessionIndex(); err != nil {
+		// The index is rebuilt from the session files on the next start
+		fs.logger.Warn("Failed to save session index", "error", err)
+	}
+	if err := fs.lock.release(); err != nil {
+		return fmt.Errorf("failed to release data directory lock: %w", err)
+	}
 	return nil
 }
 
+// IOStats returns the number of writes and bytes written to session and settings files
+func (fs *FileStorage) IOStats() IOStats {
+	return IOStats{Writes: fs.writes.Load(), BytesWritten: fs.bytes.Load()}
+}
+
+// recordWrite adds one write of n bytes to the I/O counters
+func (fs *FileStorage) recordWrite(n int) {
+	fs.writes.Add(1)
+	fs.bytes.Add(int64(n))
+}
+
+// QuarantinedFiles returns the files moved to quarantine at startup, relative to the data directory
+func (fs *FileStorage) QuarantinedFiles() []string {
+	return fs.quarantined
+}
+
 // Ping checks if the storage is accessible
 func (fs *FileStorage) Ping(ctx context.Context) error {
 	// Check if base directory is accessible
@@ -322,7 +778,7 @@ func (fs *FileStorage) Ping(ctx context.Context) error {
 	return nil
 }
 
-// DefaultFileStorageFactory creates file storage instances
+// DefaultFileStorageFactory creates file, SQLite, in-memory, sync and S3 storage instances
 type DefaultFileStorageFactory struct {
 	app    fyne.App
 	logger *logger.Logger
@@ -336,21 +792,45 @@ func NewDefaultFileStorageFactory(app fyne.App, logger *logger.Logger) *DefaultF
 	}
 }
 
-// CreateStorage creates a file storage instance from configuration
+// CreateStorage creates a storage instance from configuration
 func (f *DefaultFileStorageFactory) CreateStorage(config StorageConfig) (Storage, error) {
-	if config.Type != "file" {
-		return nil, fmt.Errorf("unsupported storage type: %s", config.Type)
-	}
-
 	basePath := config.BasePath
 	if basePath == "" {
 		basePath = "data"
 	}
 
-	return NewFileStorage(basePath, f.app, f.logger)
+	switch config.Type {
+	case "file":
+		return NewFileStorage(basePath, f.app, f.logger)
+	case "sqlite":
+		dbPath, _ := config.Settings["path"].(string)
+		if dbPath == "" {
+			dbPath = filepath.Join(basePath, DefaultSQLiteFile)
+		}
+		return NewSQLiteStorage(dbPath, f.logger)
+	case "memory":
+		return NewMemoryStorage(f.logger), nil
+	case "sync":
+		device, _ := config.Settings["device"].(string)
+		return NewSyncStorage(basePath, device, f.logger)
+	case "s3":
+		settings := S3Settings{CacheDir: basePath}
+		settings.Endpoint, _ = config.Settings["endpoint"].(string)
+		settings.Bucket, _ = config.Settings["bucket"].(string)
+		settings.Prefix, _ = config.Settings["prefix"].(string)
+		settings.Region, _ = config.Settings["region"].(string)
+		settings.AccessKey, _ = config.Settings["access_key"].(string)
+		settings.SecretKey, _ = config.Settings["secret_key"].(string)
+		if cacheDir, _ := config.Settings["cache_dir"].(string); cacheDir != "" {
+			settings.CacheDir = cacheDir
+		}
+		return NewS3Storage(settings, f.logger)
+	default:
+		return nil, fmt.Errorf("unsupported storage type: %s", config.Type)
+	}
 }
 
 // SupportedTypes returns the list of supported storage types
 func (f *DefaultFileStorageFactory) SupportedTypes() []string {
-	return []string{"file"}
+	return []string{"file", "sqlite", "memory", "sync", "s3"}
 }
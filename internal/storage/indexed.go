@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/search"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+// SearchIndexFile holds the full-text index of message content
+const SearchIndexFile = "search_index.json"
+
+// Searcher is implemented by storages that can search message content
+type Searcher interface {
+	SearchMessages(ctx context.Context, query string, limit int) ([]search.Result, error)
+}
+
+// IndexedStorage wraps a Storage and keeps a full-text index of its messages up to
+// date as sessions are saved, appended to and deleted
+type IndexedStorage struct {
+	Storage
+	index  *search.Index
+	path   string // Index file; empty keeps the index in memory only
+	logger *logger.Logger
+
+	mu     sync.Mutex // Orders index updates from writes against the startup sync
+	cancel context.CancelFunc
+	synced chan struct{}
+}
+
+// NewIndexedStorage wraps inner with a search index stored in dir (in memory only if
+// dir is empty). Sessions changed since the index was saved are indexed in the background.
+func NewIndexedStorage(inner Storage, dir string, logger *logger.Logger) *IndexedStorage {
+	is := &IndexedStorage{
+		Storage: inner,
+		index:   search.NewIndex(),
+		logger:  logger.WithComponent("search-index"),
+		synced:  make(chan struct{}),
+	}
+	if dir != "" {
+		is.path = filepath.Join(dir, SearchIndexFile)
+	}
+
+	if is.path != "" {
+		if data, err := os.ReadFile(is.path); err == nil {
+			if err := json.Unmarshal(data, is.index); err != nil {
+				is.logger.Warn("Rebuilding unreadable search index", "error", err)
+				is.index = search.NewIndex()
+			}
+		} else if !os.IsNotExist(err) {
+			is.logger.Warn("Failed to read search index", "error", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	is.cancel = cancel
+	go is.sync(ctx)
+	return is
+}
+
+// sync brings the index up to date with the stored sessions
+func (is *IndexedStorage) sync(ctx context.Context) {
+	defer close(is.synced)
+
+	page, err := is.Storage.ListSessionSummaries(ctx, SessionListOptions{})
+	if err != nil {
+		is.logger.Error("Failed to list sessions for indexing", "error", err)
+		return
+	}
+
+	stored := make(map[string]bool, len(page.Sessions))
+	for _, summary := range page.Sessions {
+		stored[summary.ID] = true
+	}
+	for _, id := range is.index.SessionIDs() {
+		if !stored[id] {
+			is.index.RemoveSession(id)
+		}
+	}
+
+	indexed := 0
+	for _, summary := range page.Sessions {
+		if ctx.Err() != nil {
+			return
+		}
+		if !is.index.NeedsUpdate(summary) {
+			continue
+		}
+
+		session, err := is.Storage.LoadChatSession(ctx, summary.ID)
+		if err != nil {
+			is.logger.Warn("Failed to load session for indexing", "session_id", summary.ID, "error", err)
+			continue
+		}
+
+		// A write during the load has already indexed a newer version
+		is.mu.Lock()
+		if is.index.NeedsUpdate(summary) {
+			is.index.IndexSession(session)
+			indexed++
+		}
+		is.mu.Unlock()
+	}
+
+	if indexed > 0 {
+		is.logger.Info("Indexed changed sessions", "count", indexed, "total", len(page.Sessions))
+		if err := is.save(); err != nil {
+			is.logger.Warn("Failed to save search index", "error", err)
+		}
+	}
+}
+
+// SearchMessages returns the sessions whose messages match query, best first
+func (is *IndexedStorage) SearchMessages(ctx context.Context, query string, limit int) ([]search.Result, error) {
+	return is.index.Search(query, limit), nil
+}
+
+// SaveChatSession saves a session and re-indexes it
+func (is *IndexedStorage) SaveChatSession(ctx context.Context, session models.ChatSession) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	if err := is.Storage.SaveChatSession(ctx, session); err != nil {
+		return err
+	}
+	is.index.IndexSession(session)
+	return nil
+}
+
+// ImportChatSession stores a session, keeping its timestamps when the wrapped storage
+// can, and indexes it
+func (is *IndexedStorage) ImportChatSession(ctx context.Context, session models.ChatSession) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	var err error
+	if importer, ok := is.Storage.(SessionImporter); ok {
+		err = importer.ImportChatSession(ctx, session)
+	} else {
+		err = is.Storage.SaveChatSession(ctx, session)
+	}
+	if err != nil {
+		return err
+	}
+	is.index.IndexSession(session)
+	return nil
+}
+
+// AppendMessages stores messages and re-indexes them
+func (is *IndexedStorage) AppendMessages(ctx context.Context, sessionID string, from int, messages []models.ChatMessage) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	if err := is.Storage.AppendMessages(ctx, sessionID, from, messages); err != nil {
+		return err
+	}
+	if !is.index.UpdateMessages(sessionID, from, messages) {
+		// Not indexed yet; index the whole session
+		session, err := is.Storage.LoadChatSession(ctx, sessionID)
+		if err != nil {
+			is.logger.Warn("Failed to load session for indexing", "session_id", sessionID, "error", err)
+			return nil
+		}
+		is.index.IndexSession(session)
+	}
+	return nil
+}
+
+// DeleteChatSession deletes a session and drops it from the index
+func (is *IndexedStorage) DeleteChatSession(ctx context.Context, sessionID string) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	if err := is.Storage.DeleteChatSession(ctx, sessionID); err != nil {
+		return err
+	}
+	is.index.RemoveSession(sessionID)
+	return nil
+}
+
+// TrashChatSession moves a session to the trash and drops it from the index
+func (is *IndexedStorage) TrashChatSession(ctx context.Context, sessionID string) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	if err := is.Storage.TrashChatSession(ctx, sessionID); err != nil {
+		return err
+	}
+	is.index.RemoveSession(sessionID)
+	return nil
+}
+
+// RestoreChatSession brings a session back from the trash and re-indexes it
+func (is *IndexedStorage) RestoreChatSession(ctx context.Context, sessionID string) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	if err := is.Storage.RestoreChatSession(ctx, sessionID); err != nil {
+		return err
+	}
+	session, err := is.Storage.LoadChatSession(ctx, sessionID)
+	if err != nil {
+		is.logger.Warn("Failed to load restored session for indexing", "session_id", sessionID, "error", err)
+		return nil
+	}
+	is.index.IndexSession(session)
+	return nil
+}
+
+// WatchChanges keeps the index in step with sessions changed elsewhere and passes
+// the changes on
+func (is *IndexedStorage) WatchChanges(fn func(Change)) (stop func()) {
+	watcher, ok := is.Storage.(ChangeWatcher)
+	if !ok {
+		return func() {}
+	}
+	return watcher.WatchChanges(func(change Change) {
+		is.mu.Lock()
+		switch change.Kind {
+		case SessionChanged:
+			is.index.IndexSession(change.Session)
+		case SessionDeleted:
+			is.index.RemoveSession(change.SessionID)
+		}
+		is.mu.Unlock()
+		fn(change)
+	})
+}
+
+// Unwrap returns the wrapped storage
+func (is *IndexedStorage) Unwrap() Storage {
+	return is.Storage
+}
+
+// Close stops background indexing, saves the index and closes the wrapped storage
+func (is *IndexedStorage) Close() error {
+	is.cancel()
+	<-is.synced
+
+	if err := is.save(); err != nil {
+		// The index is rebuilt from the stored sessions on the next start
+		is.logger.Warn("Failed to save search index", "error", err)
+	}
+	return is.Storage.Close()
+}
+
+// save writes the index file
+func (is *IndexedStorage) save() error {
+	if is.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(is.index)
+	if err != nil {
+		return fmt.Errorf("failed to marshal search index: %w", err)
+	}
+	if err := writeFileAtomic(is.path, data, filePerm); err != nil {
+		return fmt.Errorf("failed to write search index: %w", err)
+	}
+	return nil
+}
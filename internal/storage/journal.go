@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// journalExt is the extension of a session's append-only message journal
+const journalExt = ".journal"
+
+// journalCompactMinBytes is the journal size below which compaction is never triggered.
+// Above it, a journal is folded into the session snapshot once it outgrows the snapshot,
+// which keeps the total I/O per message proportional to the message size.
+const journalCompactMinBytes = 256 << 10
+
+// journalEntry is one line of a session journal. Applying an entry truncates the
+// session's messages to From and appends Messages, so replaying is idempotent.
+// Seq numbers the entries of a session in the order they were written.
+type journalEntry struct {
+	Seq      int64                `json:"seq,omitempty"`
+	At       time.Time            `json:"at"`
+	From     int                  `json:"from"`
+	Messages []models.ChatMessage `json:"messages"`
+}
+
+// sessionSnapshot is the stored form of a session. JournalSeq is the sequence number
+// of the last journal entry the snapshot holds; replay starts after it. Timestamps
+// cannot be used for this, as an imported or synced session may be dated in the future.
+type sessionSnapshot struct {
+	models.ChatSession
+	JournalSeq int64 `json:"journal_seq,omitempty"`
+}
+
+// journalPath returns the journal file of a session
+func (fs *FileStorage) journalPath(sessionID string) (string, error) {
+	if err := checkID("session", sessionID); err != nil {
+		return "", err
+	}
+	return filepath.Join(fs.basePath, "sessions", sessionID+journalExt), nil
+}
+
+// AppendMessages records messages from index from onward in the session journal
+// instead of rewriting the whole session file. The journal is compacted into the
+// session file once it grows larger than the session itself.
+func (fs *FileStorage) AppendMessages(ctx context.Context, sessionID string, from int, messages []models.ChatMessage) error {
+	sessionPath, err := fs.sessionPath(sessionID)
+	if err != nil {
+		return err
+	}
+	journalPath, err := fs.journalPath(sessionID)
+	if err != nil {
+		return err
+	}
+
+	fs.journalMu.Lock()
+	defer fs.journalMu.Unlock()
+
+	snapshot, err := os.Stat(sessionPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("session not found: %s", sessionID)
+		}
+		return fmt.Errorf("failed to stat session file: %w", err)
+	}
+
+	if err := fs.checkSession(sessionID); err != nil {
+		return err
+	}
+
+	count, known := fs.messageCounts[sessionID]
+	if !known {
+		session, err := fs.loadChatSession(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+		count = len(session.Messages)
+	}
+	if from < 0 || from > count {
+		return fmt.Errorf("invalid message index %d for session with %d messages", from, count)
+	}
+
+	seq := fs.journalSeqs[sessionID] + 1
+	entry := journalEntry{Seq: seq, At: time.Now(), From: from, Messages: messages}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal entry: %w", err)
+	}
+
+	size, err := fs.appendJournalLine(journalPath, line)
+	if err != nil {
+		fs.logger.Error("Failed to append to session journal", "session_id", sessionID, "error", err)
+		return fmt.Errorf("failed to append to session journal: %w", err)
+	}
+	fs.messageCounts[sessionID] = from + len(messages)
+	fs.journalSeqs[sessionID] = seq
+	fs.indexAppend(sessionID, from+len(messages), entry.At, int64(len(line)+1))
+
+	if size >= journalCompactMinBytes && size > snapshot.Size() {
+		if err := fs.compactJournal(sessionID); err != nil {
+			// The journal is still intact, so the data is safe; compaction is retried later
+			fs.logger.Warn("Failed to compact session journal", "session_id", sessionID, "error", err)
+		}
+	}
+	fs.noteSession(sessionID, true)
+	return nil
+}
+
+// appendJournalLine appends one entry to a journal, syncs it, and returns the new size
+func (fs *FileStorage) appendJournalLine(path string, line []byte) (int64, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, filePerm)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	var buf bytes.Buffer
+	// A crash can leave a torn final line; start on a fresh line so it stays isolated
+	if info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	buf.Write(line)
+	buf.WriteByte('\n')
+
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	fs.recordWrite(buf.Len())
+	if err := file.Sync(); err != nil {
+		return 0, err
+	}
+	return info.Size() + int64(buf.Len()), nil
+}
+
+// replayJournal applies a session's journal entries written after its snapshot and
+// returns the sequence number of the last entry the session now holds
+func (fs *FileStorage) replayJournal(session *models.ChatSession, snapshotSeq int64) (int64, error) {
+	journalPath, err := fs.journalPath(session.ID)
+	if err != nil {
+		return 0, err
+	}
+	data, err := os.ReadFile(journalPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return snapshotSeq, nil
+		}
+		return 0, fmt.Errorf("failed to read session journal: %w", err)
+	}
+
+	seq := snapshotSeq
+	snapshotTime := session.UpdatedAt
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			// Torn writes from a crash are skipped; every entry is self-contained
+			fs.logger.Warn("Skipping unreadable journal entry", "session_id", session.ID, "line", lineNo, "error", err)
+			continue
+		}
+
+		// Skip entries already folded into the snapshot. Journals written before
+		// entries were numbered fall back to comparing times.
+		if entry.Seq > 0 && entry.Seq <= seq {
+			continue
+		}
+		if entry.Seq == 0 && !entry.At.After(snapshotTime) {
+			continue
+		}
+		if entry.From > len(session.Messages) {
+			fs.logger.Warn("Skipping journal entry beyond the end of the session", "session_id", session.ID, "line", lineNo, "from", entry.From)
+			continue
+		}
+
+		session.Messages = append(session.Messages[:entry.From], entry.Messages...)
+		if entry.At.After(session.UpdatedAt) {
+			session.UpdatedAt = entry.At
+		}
+		if entry.Seq > seq {
+			seq = entry.Seq
+		}
+	}
+	return seq, scanner.Err()
+}
+
+// lastJournalSeq returns the number of the last entry in a session's journal, or 0
+func (fs *FileStorage) lastJournalSeq(sessionID string) int64 {
+	journalPath, err := fs.journalPath(sessionID)
+	if err != nil {
+		return 0
+	}
+	data, err := os.ReadFile(journalPath)
+	if err != nil {
+		return 0
+	}
+
+	var last int64
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var entry journalEntry
+		if json.Unmarshal(line, &entry) == nil && entry.Seq > last {
+			last = entry.Seq
+		}
+	}
+	return last
+}
+
+// compactJournal folds a session's journal into its snapshot and removes the journal.
+// The caller must hold journalMu.
+func (fs *FileStorage) compactJournal(sessionID string) error {
+	session, err := fs.loadChatSession(context.Background(), sessionID)
+	if err != nil {
+		return err
+	}
+
+	// The snapshot records the last folded entry, so a journal left behind by a
+	// crash before its removal is not applied twice
+	if err := fs.writeSessionSnapshot(session, fs.journalSeqs[sessionID]); err != nil {
+		return err
+	}
+	journalPath, err := fs.journalPath(sessionID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(journalPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove compacted journal: %w", err)
+	}
+
+	fs.logger.Info("Compacted session journal", "session_id", sessionID, "message_count", len(session.Messages))
+	return nil
+}
+
+// compactAllJournals folds every leftover journal into its session
+func (fs *FileStorage) compactAllJournals() {
+	entries, err := os.ReadDir(filepath.Join(fs.basePath, "sessions"))
+	if err != nil {
+		return
+	}
+
+	fs.journalMu.Lock()
+	defer fs.journalMu.Unlock()
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), journalExt) {
+			continue
+		}
+		sessionID := strings.TrimSuffix(entry.Name(), journalExt)
+		sessionPath, err := fs.sessionPath(sessionID)
+		if err != nil {
+			fs.logger.Warn("Skipping journal with an invalid session ID", "session_id", sessionID)
+			continue
+		}
+
+		// A journal without a session belongs to a session deleted mid-write
+		if _, err := os.Stat(sessionPath); os.IsNotExist(err) {
+			fs.logger.Warn("Removing journal of missing session", "session_id", sessionID)
+			os.Remove(filepath.Join(fs.basePath, "sessions", entry.Name()))
+			continue
+		}
+
+		if err := fs.compactJournal(sessionID); err != nil {
+			fs.logger.Warn("Failed to compact session journal", "session_id", sessionID, "error", err)
+		}
+	}
+}
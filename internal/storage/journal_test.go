@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+// newTestFileStorage opens a FileStorage in dir, closing it when the test ends
+func newTestFileStorage(t *testing.T, dir string) *FileStorage {
+	t.Helper()
+	fs, err := NewFileStorage(dir, nil, logger.NewLogger(slog.LevelError))
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	t.Cleanup(func() { fs.Close() })
+	return fs
+}
+
+// reopen closes fs and opens its directory again, which compacts leftover journals
+func reopen(t *testing.T, fs *FileStorage) *FileStorage {
+	t.Helper()
+	if err := fs.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return newTestFileStorage(t, fs.basePath)
+}
+
+// testJournalPath returns the journal file of a session
+func testJournalPath(t *testing.T, fs *FileStorage, sessionID string) string {
+	t.Helper()
+	path, err := fs.journalPath(sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestJournalReplayAfterFutureDatedImport(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileStorage(t, t.TempDir())
+
+	// A session imported from a device whose clock runs ahead
+	session := models.NewChatSession("Imported", "llama3.2:latest")
+	session.Messages = []models.ChatMessage{models.NewChatMessage("user", "first")}
+	session.UpdatedAt = time.Now().Add(24 * time.Hour)
+	if err := fs.ImportChatSession(ctx, session); err != nil {
+		t.Fatalf("ImportChatSession: %v", err)
+	}
+
+	if err := fs.AppendMessages(ctx, session.ID, 1, []models.ChatMessage{models.NewChatMessage("llm", "second")}); err != nil {
+		t.Fatalf("AppendMessages: %v", err)
+	}
+
+	// Reopening folds the journal into the snapshot and removes it
+	fs = reopen(t, fs)
+	loaded, err := fs.LoadChatSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession: %v", err)
+	}
+	if len(loaded.Messages) != 2 {
+		t.Fatalf("got %d messages after reload, want 2", len(loaded.Messages))
+	}
+	if _, err := os.Stat(testJournalPath(t, fs, session.ID)); !os.IsNotExist(err) {
+		t.Errorf("journal still present after compaction: %v", err)
+	}
+
+	// Appends after compaction continue from the snapshot's entry number
+	if err := fs.AppendMessages(ctx, session.ID, 2, []models.ChatMessage{models.NewChatMessage("user", "third")}); err != nil {
+		t.Fatalf("AppendMessages: %v", err)
+	}
+	fs = reopen(t, fs)
+	loaded, err = fs.LoadChatSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession: %v", err)
+	}
+	if len(loaded.Messages) != 3 || loaded.Messages[2].Content != "third" {
+		t.Fatalf("got %d messages after second reload, want 3 ending in %q", len(loaded.Messages), "third")
+	}
+}
+
+func TestJournalNotReplayedOverNewerSnapshot(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileStorage(t, t.TempDir())
+
+	session := models.NewChatSession("Edited", "llama3.2:latest")
+	session.Messages = []models.ChatMessage{models.NewChatMessage("user", "first")}
+	if err := fs.SaveChatSession(ctx, session); err != nil {
+		t.Fatalf("SaveChatSession: %v", err)
+	}
+	if err := fs.AppendMessages(ctx, session.ID, 1, []models.ChatMessage{models.NewChatMessage("llm", "second")}); err != nil {
+		t.Fatalf("AppendMessages: %v", err)
+	}
+	journal, err := os.ReadFile(testJournalPath(t, fs, session.ID))
+	if err != nil {
+		t.Fatalf("read journal: %v", err)
+	}
+
+	// The user edits the conversation, then the app crashes before the journal
+	// the save made obsolete is removed
+	loaded, err := fs.LoadChatSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession: %v", err)
+	}
+	loaded.Messages = loaded.Messages[:1]
+	loaded.Messages[0].Content = "edited"
+	if err := fs.SaveChatSession(ctx, loaded); err != nil {
+		t.Fatalf("SaveChatSession: %v", err)
+	}
+	if err := os.WriteFile(testJournalPath(t, fs, session.ID), journal, filePerm); err != nil {
+		t.Fatalf("restore journal: %v", err)
+	}
+
+	fs = reopen(t, fs)
+	loaded, err = fs.LoadChatSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession: %v", err)
+	}
+	if len(loaded.Messages) != 1 || loaded.Messages[0].Content != "edited" {
+		t.Fatalf("stale journal replayed over the saved session: %+v", loaded.Messages)
+	}
+}
+
+func TestJournalLegacyEntriesReplayByTime(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileStorage(t, t.TempDir())
+
+	session := models.NewChatSession("Legacy", "llama3.2:latest")
+	session.Messages = []models.ChatMessage{models.NewChatMessage("user", "first")}
+	if err := fs.SaveChatSession(ctx, session); err != nil {
+		t.Fatalf("SaveChatSession: %v", err)
+	}
+
+	// A journal written before entries were numbered
+	line := `{"at":"` + time.Now().Add(time.Minute).UTC().Format(time.RFC3339Nano) + `","from":1,"messages":[{"sender":"llm","content":"second"}]}` + "\n"
+	if err := os.WriteFile(testJournalPath(t, fs, session.ID), []byte(line), filePerm); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+
+	fs = reopen(t, fs)
+	loaded, err := fs.LoadChatSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession: %v", err)
+	}
+	if len(loaded.Messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(loaded.Messages))
+	}
+}
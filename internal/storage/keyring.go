@@ -0,0 +1,311 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// KeyFile holds the passphrase-protected data key of an encrypted store
+const KeyFile = "encryption.json"
+
+// keyFileVersion is the layout version of the key file written for new and upgraded
+// stores. Stores whose key file is at version 1 sealed values bound to their session
+// only; from version 2, values are bound to their field and position in the session.
+const keyFileVersion = 2
+
+// kdfIterations is the PBKDF2-HMAC-SHA256 work factor for new and changed passphrases
+const kdfIterations = 600000
+
+// encryptedPrefix marks an encrypted value
+const encryptedPrefix = "enc:v1:"
+
+var (
+	// ErrWrongPassphrase is returned when a passphrase does not unlock the key file
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+
+	// ErrAlreadyEncrypted is returned when creating a key for a store that has one
+	ErrAlreadyEncrypted = errors.New("storage is already encrypted")
+
+	// ErrNotEncrypted is returned when a store has no key file
+	ErrNotEncrypted = errors.New("storage is not encrypted")
+
+	// ErrNotSealed is returned when opening a value that was stored unencrypted
+	ErrNotSealed = errors.New("value is not encrypted")
+)
+
+// keyEnvelope is the key file: a random data key sealed with a key derived from the
+// passphrase, so changing the passphrase does not re-encrypt any data
+type keyEnvelope struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	WrappedKey []byte `json:"wrapped_key"`
+}
+
+// Cipher encrypts and decrypts stored values with AES-256-GCM
+type Cipher struct {
+	aead   cipher.AEAD
+	legacy bool // The key file is at version 1
+}
+
+// IsEncrypted reports whether the store in dir has a key file
+func IsEncrypted(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, KeyFile))
+	return err == nil
+}
+
+// CreateKey generates a data key for the store in dir, protects it with passphrase
+// and returns a cipher using it
+func CreateKey(dir, passphrase string) (*Cipher, error) {
+	if IsEncrypted(dir) {
+		return nil, ErrAlreadyEncrypted
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase cannot be empty")
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	if err := writeKeyFile(dir, dataKey, passphrase, keyFileVersion); err != nil {
+		return nil, err
+	}
+	return newCipher(dataKey)
+}
+
+// UnlockKey opens the key file in dir with passphrase and returns a cipher using the data key
+func UnlockKey(dir, passphrase string) (*Cipher, error) {
+	dataKey, version, err := readKeyFile(dir, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return newKeyCipher(dataKey, version)
+}
+
+// ChangePassphrase re-protects the data key of the store in dir with a new passphrase
+func ChangePassphrase(dir, oldPassphrase, newPassphrase string) error {
+	if newPassphrase == "" {
+		return fmt.Errorf("passphrase cannot be empty")
+	}
+	dataKey, version, err := readKeyFile(dir, oldPassphrase)
+	if err != nil {
+		return err
+	}
+	return writeKeyFile(dir, dataKey, newPassphrase, version)
+}
+
+// UpgradeKey moves the key file of the store in dir to the current version. Once it
+// has, values bound to their session only are refused, so every session must first be
+// sealed again, as EncryptedStorage.EncryptExisting does.
+func UpgradeKey(dir, passphrase string) error {
+	dataKey, version, err := readKeyFile(dir, passphrase)
+	if err != nil || version == keyFileVersion {
+		return err
+	}
+	return writeKeyFile(dir, dataKey, passphrase, keyFileVersion)
+}
+
+// UnlockKeyData opens the content of a key file, such as one kept in a backup archive,
+// with passphrase and returns a cipher using its data key
+func UnlockKeyData(data []byte, passphrase string) (*Cipher, error) {
+	dataKey, version, err := openKeyEnvelope(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return newKeyCipher(dataKey, version)
+}
+
+// readKeyFile returns the data key sealed in the key file and the file's version
+func readKeyFile(dir, passphrase string) ([]byte, int, error) {
+	data, err := os.ReadFile(filepath.Join(dir, KeyFile))
+	if os.IsNotExist(err) {
+		return nil, 0, ErrNotEncrypted
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read key file: %w", err)
+	}
+	return openKeyEnvelope(data, passphrase)
+}
+
+// openKeyEnvelope returns the data key sealed in the content of a key file and the
+// file's version
+func openKeyEnvelope(data []byte, passphrase string) ([]byte, int, error) {
+	var envelope keyEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse key file: %w", err)
+	}
+	if envelope.Version < 1 || envelope.Version > keyFileVersion || envelope.KDF != "pbkdf2-sha256" {
+		return nil, 0, fmt.Errorf("unsupported key file version %d (%s)", envelope.Version, envelope.KDF)
+	}
+
+	wrap, err := newCipher(pbkdf2SHA256([]byte(passphrase), envelope.Salt, envelope.Iterations, 32))
+	if err != nil {
+		return nil, 0, err
+	}
+	dataKey, err := wrap.aead.Open(nil, envelope.Nonce, envelope.WrappedKey, keyFileContext(envelope.Version))
+	if err != nil {
+		return nil, 0, ErrWrongPassphrase
+	}
+	return dataKey, envelope.Version, nil
+}
+
+// keyFileContext binds the data key to the key file version, so the file cannot be
+// turned back to a version that accepts values bound to their session only
+func keyFileContext(version int) []byte {
+	if version == 1 {
+		return []byte(KeyFile)
+	}
+	return []byte(fmt.Sprintf("%s:v%d", KeyFile, version))
+}
+
+// writeKeyFile seals the data key with passphrase and replaces the key file
+func writeKeyFile(dir string, dataKey []byte, passphrase string, version int) error {
+	envelope := keyEnvelope{
+		Version:    version,
+		KDF:        "pbkdf2-sha256",
+		Iterations: kdfIterations,
+		Salt:       make([]byte, 16),
+	}
+	if _, err := rand.Read(envelope.Salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	wrap, err := newCipher(pbkdf2SHA256([]byte(passphrase), envelope.Salt, envelope.Iterations, 32))
+	if err != nil {
+		return err
+	}
+	envelope.Nonce = make([]byte, wrap.aead.NonceSize())
+	if _, err := rand.Read(envelope.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	envelope.WrappedKey = wrap.aead.Seal(nil, envelope.Nonce, dataKey, keyFileContext(version))
+
+	data, err := json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal key file: %w", err)
+	}
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, KeyFile), data, filePerm); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	return nil
+}
+
+// newCipher creates an AES-256-GCM cipher
+func newCipher(key []byte) (*Cipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// newKeyCipher creates the cipher of a data key read from a key file at version
+func newKeyCipher(dataKey []byte, version int) (*Cipher, error) {
+	c, err := newCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	c.legacy = version < keyFileVersion
+	return c, nil
+}
+
+// Legacy reports whether the key file predates binding values to their position, so
+// values bound to their session only may still be stored. UpgradeKey ends this.
+func (c *Cipher) Legacy() bool {
+	return c.legacy
+}
+
+// Seal encrypts a value, binding it to context (such as the session ID) so it cannot
+// be moved elsewhere undetected. Empty values stay empty.
+func (c *Cipher) Seal(plaintext, context string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), []byte(context))
+	return encryptedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value sealed with the same context. A value stored unencrypted is
+// refused with ErrNotSealed, as anyone able to write the files could have put it there.
+// Empty values stay empty.
+func (c *Cipher) Open(value, context string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if !IsSealed(value) {
+		return "", ErrNotSealed
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, []byte(context))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// IsSealed reports whether a stored value is encrypted
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// pbkdf2SHA256 derives a key from a password with PBKDF2-HMAC-SHA256 (RFC 8018)
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	key := make([]byte, 0, blocks*hashLen)
+	u := make([]byte, hashLen)
+	t := make([]byte, hashLen)
+	var counter [4]byte
+	for block := 1; block <= blocks; block++ {
+		binary.BigEndian.PutUint32(counter[:], uint32(block))
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(counter[:])
+		u = prf.Sum(u[:0])
+		copy(t, u)
+
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
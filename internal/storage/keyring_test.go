@@ -0,0 +1,330 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+func TestPBKDF2SHA256KnownAnswers(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iterations     int
+		want           string
+	}{
+		// RFC 7914, section 11
+		{"passwd", "salt", 1,
+			"55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000,
+			"4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+		// The RFC 6070 inputs with SHA-256, including a key longer than one block
+		// that does not end on a block boundary
+		{"password", "salt", 4096,
+			"c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096,
+			"348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1c635518c7dac47e9"},
+		{"pass\x00word", "sa\x00lt", 4096,
+			"89b69d0516f829893c696226650a8687"},
+	}
+	for _, tt := range tests {
+		want, _ := hex.DecodeString(tt.want)
+		got := pbkdf2SHA256([]byte(tt.password), []byte(tt.salt), tt.iterations, len(want))
+		if hex.EncodeToString(got) != tt.want {
+			t.Errorf("pbkdf2SHA256(%q, %q, %d) = %x, want %s", tt.password, tt.salt, tt.iterations, got, tt.want)
+		}
+	}
+}
+
+// testCipher returns a cipher with a random key, as a key file at version would give
+func testCipher(t *testing.T, version int) *Cipher {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	c, err := newKeyCipher(key, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCipherOpenRefusesUnsealedValues(t *testing.T) {
+	c := testCipher(t, keyFileVersion)
+
+	if _, err := c.Open("written in plaintext", "context"); !errors.Is(err, ErrNotSealed) {
+		t.Errorf("Open(plaintext) error = %v, want ErrNotSealed", err)
+	}
+	if got, err := c.Open("", "context"); err != nil || got != "" {
+		t.Errorf("Open(\"\") = %q, %v, want an empty value", got, err)
+	}
+
+	sealed, err := c.Seal("secret", "context")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Open(sealed, "other context"); err == nil {
+		t.Error("Open succeeded with a different context")
+	}
+	if got, err := c.Open(sealed, "context"); err != nil || got != "secret" {
+		t.Errorf("Open = %q, %v, want %q", got, err, "secret")
+	}
+}
+
+// sealedStore returns an encrypted memory storage holding a session, and the wrapped
+// storage holding its sealed form
+func sealedStore(t *testing.T, c *Cipher) (*EncryptedStorage, Storage, models.ChatSession) {
+	t.Helper()
+	log := logger.NewLogger(slog.LevelError)
+	inner := NewMemoryStorage(log)
+	es := NewEncryptedStorage(inner, c, log)
+
+	session := models.NewChatSession("Private name", "llama3.2:latest")
+	session.SystemPrompt = "Private prompt"
+	session.Messages = []models.ChatMessage{
+		models.NewChatMessage("user", "first"),
+		models.NewChatMessage("llm", "second"),
+	}
+	if err := es.SaveChatSession(context.Background(), session); err != nil {
+		t.Fatalf("SaveChatSession: %v", err)
+	}
+	return es, inner, session
+}
+
+func TestEncryptedStorageDetectsTampering(t *testing.T) {
+	ctx := context.Background()
+
+	tamper := map[string]func(s *models.ChatSession){
+		"messages swapped": func(s *models.ChatSession) {
+			s.Messages[0].Content, s.Messages[1].Content = s.Messages[1].Content, s.Messages[0].Content
+		},
+		"name and prompt swapped": func(s *models.ChatSession) {
+			s.Name, s.SystemPrompt = s.SystemPrompt, s.Name
+		},
+		"message replaced with plaintext": func(s *models.ChatSession) {
+			s.Messages[1].Content = "injected"
+		},
+		"name replaced with plaintext": func(s *models.ChatSession) {
+			s.Name = "injected"
+		},
+	}
+	for name, change := range tamper {
+		t.Run(name, func(t *testing.T) {
+			es, inner, session := sealedStore(t, testCipher(t, keyFileVersion))
+			stored, err := inner.LoadChatSession(ctx, session.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			change(&stored)
+			if err := inner.SaveChatSession(ctx, stored); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := es.LoadChatSession(ctx, session.ID); err == nil {
+				t.Fatal("tampered session loaded without an error")
+			}
+		})
+	}
+}
+
+func TestEncryptedStorageAppendBindsPosition(t *testing.T) {
+	ctx := context.Background()
+	es, inner, session := sealedStore(t, testCipher(t, keyFileVersion))
+
+	reply := models.NewChatMessage("llm", "third")
+	if err := es.AppendMessages(ctx, session.ID, 2, []models.ChatMessage{reply}); err != nil {
+		t.Fatalf("AppendMessages: %v", err)
+	}
+	loaded, err := es.LoadChatSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession: %v", err)
+	}
+	if len(loaded.Messages) != 3 || loaded.Messages[2].Content != "third" {
+		t.Fatalf("appended message not read back: %+v", loaded.Messages)
+	}
+
+	// The appended value cannot be moved to another position
+	stored, _ := inner.LoadChatSession(ctx, session.ID)
+	stored.Messages[1].Content = stored.Messages[2].Content
+	inner.SaveChatSession(ctx, stored)
+	if _, err := es.LoadChatSession(ctx, session.ID); err == nil {
+		t.Fatal("moved message loaded without an error")
+	}
+}
+
+func TestEncryptExistingSealsPlaintext(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewLogger(slog.LevelError)
+	inner := NewMemoryStorage(log)
+
+	session := models.NewChatSession("Written before encryption", "llama3.2:latest")
+	session.Messages = []models.ChatMessage{models.NewChatMessage("user", "plaintext")}
+	if err := inner.SaveChatSession(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+
+	es := NewEncryptedStorage(inner, testCipher(t, keyFileVersion), log)
+	if _, err := es.LoadChatSession(ctx, session.ID); !errors.Is(err, ErrNotSealed) {
+		t.Fatalf("LoadChatSession of a plaintext session error = %v, want ErrNotSealed", err)
+	}
+
+	count, err := es.EncryptExisting(ctx)
+	if err != nil || count != 1 {
+		t.Fatalf("EncryptExisting = %d, %v, want 1 session", count, err)
+	}
+	loaded, err := es.LoadChatSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession after encrypting: %v", err)
+	}
+	if loaded.Name != session.Name || loaded.Messages[0].Content != "plaintext" {
+		t.Errorf("session changed by encrypting: %q %+v", loaded.Name, loaded.Messages)
+	}
+}
+
+func TestEncryptExistingSealsTrash(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewLogger(slog.LevelError)
+	inner := NewMemoryStorage(log)
+
+	session := models.NewChatSession("Trashed before encryption", "llama3.2:latest")
+	session.Messages = []models.ChatMessage{models.NewChatMessage("user", "plaintext")}
+	inner.SaveChatSession(ctx, session)
+	if err := inner.TrashChatSession(ctx, session.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	es := NewEncryptedStorage(inner, testCipher(t, keyFileVersion), log)
+	count, err := es.EncryptExisting(ctx)
+	if err != nil || count != 1 {
+		t.Fatalf("EncryptExisting = %d, %v, want 1 session", count, err)
+	}
+
+	trashed, err := es.ListTrashedSessions(ctx)
+	if err != nil || len(trashed) != 1 || trashed[0].Name != session.Name {
+		t.Fatalf("ListTrashedSessions = %+v, %v, want the sealed session with its name", trashed, err)
+	}
+	if page, _ := es.ListSessionSummaries(ctx, SessionListOptions{}); page.Total != 0 {
+		t.Errorf("sealing left %d trashed sessions in the session list", page.Total)
+	}
+
+	// Sealed trash is left alone when run again
+	if count, err := es.EncryptExisting(ctx); err != nil || count != 0 {
+		t.Errorf("EncryptExisting (second run) = %d, %v, want nothing to do", count, err)
+	}
+
+	if err := es.RestoreChatSession(ctx, session.ID); err != nil {
+		t.Fatalf("RestoreChatSession: %v", err)
+	}
+	loaded, err := es.LoadChatSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession of a restored session: %v", err)
+	}
+	if loaded.Messages[0].Content != "plaintext" {
+		t.Errorf("restored session holds %+v", loaded.Messages)
+	}
+}
+
+func TestLegacyKeyUpgrade(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	log := logger.NewLogger(slog.LevelError)
+	const passphrase = "correct horse battery staple"
+
+	// A store created before values were bound to their position
+	dataKey := make([]byte, 32)
+	rand.Read(dataKey)
+	if err := writeKeyFile(dir, dataKey, passphrase, 1); err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := UnlockKey(dir, passphrase)
+	if err != nil {
+		t.Fatalf("UnlockKey (version 1): %v", err)
+	}
+	if !legacy.Legacy() {
+		t.Fatal("a version 1 key should be legacy")
+	}
+
+	inner := NewMemoryStorage(log)
+	session := models.NewChatSession("Old session", "llama3.2:latest")
+	session.Messages = []models.ChatMessage{models.NewChatMessage("user", "old message")}
+	stored := session
+	stored.Name, _ = legacy.Seal(session.Name, session.ID)
+	stored.Messages = []models.ChatMessage{session.Messages[0]}
+	stored.Messages[0].Content, _ = legacy.Seal(session.Messages[0].Content, session.ID)
+	if err := inner.SaveChatSession(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+
+	// Values bound to their session only are still read with the legacy key
+	es := NewEncryptedStorage(inner, legacy, log)
+	if loaded, err := es.LoadChatSession(ctx, session.ID); err != nil || loaded.Name != session.Name {
+		t.Fatalf("LoadChatSession with a legacy key = %q, %v", loaded.Name, err)
+	}
+
+	if count, err := es.EncryptExisting(ctx); err != nil || count != 1 {
+		t.Fatalf("EncryptExisting = %d, %v, want 1 session sealed again", count, err)
+	}
+	if err := UpgradeKey(dir, passphrase); err != nil {
+		t.Fatalf("UpgradeKey: %v", err)
+	}
+	upgraded, err := UnlockKey(dir, passphrase)
+	if err != nil {
+		t.Fatalf("UnlockKey (upgraded): %v", err)
+	}
+	if upgraded.Legacy() {
+		t.Fatal("an upgraded key should not be legacy")
+	}
+
+	es = NewEncryptedStorage(inner, upgraded, log)
+	if loaded, err := es.LoadChatSession(ctx, session.ID); err != nil || loaded.Messages[0].Content != "old message" {
+		t.Fatalf("LoadChatSession after the upgrade = %+v, %v", loaded.Messages, err)
+	}
+
+	// A value bound to its session only is refused once the key is upgraded
+	inner.SaveChatSession(ctx, stored)
+	if _, err := es.LoadChatSession(ctx, session.ID); err == nil {
+		t.Fatal("a value bound to its session only was accepted after the upgrade")
+	}
+
+	// Turning the key file back to version 1 does not bring the legacy behavior back
+	path := filepath.Join(dir, KeyFile)
+	data, _ := os.ReadFile(path)
+	var envelope map[string]interface{}
+	json.Unmarshal(data, &envelope)
+	envelope["version"] = 1
+	data, _ = json.Marshal(envelope)
+	os.WriteFile(path, data, filePerm)
+	if _, err := UnlockKey(dir, passphrase); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("UnlockKey of a downgraded key file error = %v, want it refused", err)
+	}
+}
+
+func TestTrashedPlaintextNameIsHidden(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewLogger(slog.LevelError)
+	inner := NewMemoryStorage(log)
+
+	session := models.NewChatSession("Trashed before encryption", "llama3.2:latest")
+	inner.SaveChatSession(ctx, session)
+	if err := inner.TrashChatSession(ctx, session.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	es := NewEncryptedStorage(inner, testCipher(t, keyFileVersion), log)
+	trashed, err := es.ListTrashedSessions(ctx)
+	if err != nil {
+		t.Fatalf("ListTrashedSessions: %v", err)
+	}
+	if len(trashed) != 1 || strings.Contains(trashed[0].Name, "Trashed") {
+		t.Errorf("unencrypted trashed name shown: %+v", trashed)
+	}
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// lockFileName is the advisory lock file inside the data directory
+const lockFileName = ".lock"
+
+// ErrStorageLocked is returned when another instance already has the data directory open
+var ErrStorageLocked = errors.New("data directory is already open elsewhere")
+
+// errLockHeld is returned by the platform lock functions when the lock is taken
+var errLockHeld = errors.New("lock is held by another process")
+
+// LockError describes a data directory that is locked by another instance
+type LockError struct {
+	Dir    string
+	Holder string // Contents of the lock file, describing the owning process, if readable
+}
+
+// Error implements the error interface
+func (e *LockError) Error() string {
+	msg := fmt.Sprintf("data directory %s is already open elsewhere", e.Dir)
+	if e.Holder != "" {
+		msg += " (" + e.Holder + ")"
+	}
+	return msg + "; close the other OllamaChat instance or choose a different -storage directory"
+}
+
+// Unwrap allows errors.Is(err, ErrStorageLocked)
+func (e *LockError) Unwrap() error {
+	return ErrStorageLocked
+}
+
+// dirLock is an exclusive advisory lock on a data directory, held for the life of the process
+type dirLock struct {
+	file *os.File
+}
+
+// acquireDirLock takes the lock on dir without blocking
+func acquireDirLock(dir string) (*dirLock, error) {
+	return acquireLockFile(filepath.Join(dir, lockFileName), dir)
+}
+
+// acquireLockFile takes the lock on dir through the lock file at path without blocking
+func acquireLockFile(path, dir string) (*dirLock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, filePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := lockFile(file); err != nil {
+		file.Close()
+		if errors.Is(err, errLockHeld) {
+			return nil, &LockError{Dir: dir, Holder: readLockHolder(path)}
+		}
+		return nil, fmt.Errorf("failed to lock data directory: %w", err)
+	}
+
+	// Record the owner so a second instance can say who holds the lock
+	hostname, _ := os.Hostname()
+	holder := fmt.Sprintf("pid %d on %s since %s", os.Getpid(), hostname, time.Now().Format(time.RFC3339))
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(holder+"\n"), 0)
+		file.Sync()
+	}
+
+	return &dirLock{file: file}, nil
+}
+
+// release drops the lock. The lock file itself is left in place, since removing
+// it could let two processes lock different files with the same name.
+func (l *dirLock) release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	unlockErr := unlockFile(l.file)
+	closeErr := l.file.Close()
+	l.file = nil
+	if unlockErr != nil {
+		return unlockErr
+	}
+	return closeErr
+}
+
+// readLockHolder returns the owner description written by the process holding the lock
+func readLockHolder(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,491 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+// MemoryStorage implements the Storage interface in memory. Nothing is persisted,
+// which makes it suitable for tests and for running the UI without touching disk.
+type MemoryStorage struct {
+	mu          sync.RWMutex
+	sessions    map[string]models.ChatSession
+	trash       map[string]trashedCopy
+	personas    map[string]models.Persona
+	templates   map[string]models.PromptTemplate
+	prefs       *AppPreferences
+	mcpServers  []models.MCPServer
+	agentConfig *models.AgentConfig
+	closed      bool
+	logger      *logger.Logger
+}
+
+// NewMemoryStorage creates a new empty in-memory storage
+func NewMemoryStorage(logger *logger.Logger) *MemoryStorage {
+	ms := &MemoryStorage{
+		sessions:  make(map[string]models.ChatSession),
+		trash:     make(map[string]trashedCopy),
+		personas:  make(map[string]models.Persona),
+		templates: make(map[string]models.PromptTemplate),
+		logger:    logger.WithComponent("memory-storage"),
+	}
+
+	ms.logger.Info("Initialized memory storage")
+	return ms
+}
+
+// SaveChatSession stores a copy of the session
+func (ms *MemoryStorage) SaveChatSession(ctx context.Context, session models.ChatSession) error {
+	// Update the session timestamp
+	session.UpdatedAt = time.Now()
+	return ms.ImportChatSession(ctx, session)
+}
+
+// ImportChatSession stores a copy of the session exactly as given, keeping its timestamps
+func (ms *MemoryStorage) ImportChatSession(ctx context.Context, session models.ChatSession) error {
+	if session.ID == "" {
+		return fmt.Errorf("session id cannot be empty")
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.closed {
+		return errStorageClosed
+	}
+	ms.sessions[session.ID] = copySession(session)
+	delete(ms.trash, session.ID) // Saving a trashed session brings it back
+	return nil
+}
+
+// LoadChatSession returns a copy of the stored session
+func (ms *MemoryStorage) LoadChatSession(ctx context.Context, sessionID string) (models.ChatSession, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	session, ok := ms.sessions[sessionID]
+	if !ok {
+		return models.ChatSession{}, fmt.Errorf("session not found: %s", sessionID)
+	}
+	return copySession(session), nil
+}
+
+// ListChatSessions lists all sessions, most recently updated first
+func (ms *MemoryStorage) ListChatSessions(ctx context.Context) ([]models.ChatSession, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var sessions []models.ChatSession
+	for _, session := range ms.sessions {
+		sessions = append(sessions, copySession(session))
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+// ListSessionSummaries returns a page of session summaries
+func (ms *MemoryStorage) ListSessionSummaries(ctx context.Context, opts SessionListOptions) (SessionPage, error) {
+	if err := opts.validate(); err != nil {
+		return SessionPage{}, err
+	}
+
+	ms.mu.RLock()
+	summaries := make([]models.SessionSummary, 0, len(ms.sessions))
+	for _, session := range ms.sessions {
+		summary := session.Summary()
+		summary.Size = estimateSize(session)
+		summaries = append(summaries, summary)
+	}
+	ms.mu.RUnlock()
+
+	return pageSummaries(summaries, opts), nil
+}
+
+// AppendMessages replaces the session's messages from index from onward
+func (ms *MemoryStorage) AppendMessages(ctx context.Context, sessionID string, from int, messages []models.ChatMessage) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.closed {
+		return errStorageClosed
+	}
+	session, ok := ms.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	if from < 0 || from > len(session.Messages) {
+		return fmt.Errorf("invalid message index %d for session with %d messages", from, len(session.Messages))
+	}
+
+	// Build a new slice so copies handed out earlier are unaffected
+	updated := make([]models.ChatMessage, 0, from+len(messages))
+	updated = append(updated, session.Messages[:from]...)
+	updated = append(updated, messages...)
+	session.Messages = updated
+	session.UpdatedAt = time.Now()
+	ms.sessions[sessionID] = session
+	return nil
+}
+
+// DeleteChatSession removes a session
+func (ms *MemoryStorage) DeleteChatSession(ctx context.Context, sessionID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.sessions[sessionID]; !ok {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	delete(ms.sessions, sessionID)
+	return nil
+}
+
+// trashedCopy is a session in the memory trash
+type trashedCopy struct {
+	session   models.ChatSession
+	deletedAt time.Time
+}
+
+// TrashChatSession moves a session to the trash
+func (ms *MemoryStorage) TrashChatSession(ctx context.Context, sessionID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.closed {
+		return errStorageClosed
+	}
+	session, ok := ms.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	delete(ms.sessions, sessionID)
+	ms.trash[sessionID] = trashedCopy{session: session, deletedAt: time.Now()}
+	return nil
+}
+
+// ListTrashedSessions lists trashed sessions, most recently deleted first
+func (ms *MemoryStorage) ListTrashedSessions(ctx context.Context) ([]TrashedSession, error) {
+	ms.mu.RLock()
+	trashed := make([]TrashedSession, 0, len(ms.trash))
+	for _, item := range ms.trash {
+		summary := item.session.Summary()
+		summary.Size = estimateSize(item.session)
+		trashed = append(trashed, TrashedSession{SessionSummary: summary, DeletedAt: item.deletedAt})
+	}
+	ms.mu.RUnlock()
+
+	sortTrash(trashed)
+	return trashed, nil
+}
+
+// RestoreChatSession moves a session out of the trash
+func (ms *MemoryStorage) RestoreChatSession(ctx context.Context, sessionID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.closed {
+		return errStorageClosed
+	}
+	item, ok := ms.trash[sessionID]
+	if !ok {
+		return fmt.Errorf("session not in trash: %s", sessionID)
+	}
+	if _, exists := ms.sessions[sessionID]; exists {
+		return fmt.Errorf("session already exists: %s", sessionID)
+	}
+	delete(ms.trash, sessionID)
+	ms.sessions[sessionID] = item.session
+	return nil
+}
+
+// PurgeTrashedSession permanently deletes a trashed session
+func (ms *MemoryStorage) PurgeTrashedSession(ctx context.Context, sessionID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.trash[sessionID]; !ok {
+		return fmt.Errorf("session not in trash: %s", sessionID)
+	}
+	delete(ms.trash, sessionID)
+	return nil
+}
+
+// PurgeTrash permanently deletes sessions trashed before deletedBefore
+func (ms *MemoryStorage) PurgeTrash(ctx context.Context, deletedBefore time.Time) (int, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	purged := 0
+	for id, item := range ms.trash {
+		if item.deletedAt.Before(deletedBefore) {
+			delete(ms.trash, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// SavePersona stores a persona
+func (ms *MemoryStorage) SavePersona(ctx context.Context, persona models.Persona) error {
+	if err := persona.Validate(); err != nil {
+		return fmt.Errorf("invalid persona: %w", err)
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.closed {
+		return errStorageClosed
+	}
+	persona.UpdatedAt = time.Now()
+	ms.personas[persona.ID] = persona
+	return nil
+}
+
+// LoadPersona returns a stored persona
+func (ms *MemoryStorage) LoadPersona(ctx context.Context, personaID string) (models.Persona, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	persona, ok := ms.personas[personaID]
+	if !ok {
+		return models.Persona{}, fmt.Errorf("persona not found: %s", personaID)
+	}
+	return persona, nil
+}
+
+// ListPersonas lists all stored personas sorted by name
+func (ms *MemoryStorage) ListPersonas(ctx context.Context) ([]models.Persona, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	personas := make([]models.Persona, 0, len(ms.personas))
+	for _, persona := range ms.personas {
+		personas = append(personas, persona)
+	}
+
+	sort.Slice(personas, func(i, j int) bool {
+		return strings.ToLower(personas[i].Name) < strings.ToLower(personas[j].Name)
+	})
+	return personas, nil
+}
+
+// DeletePersona removes a persona
+func (ms *MemoryStorage) DeletePersona(ctx context.Context, personaID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.personas[personaID]; !ok {
+		return fmt.Errorf("persona not found: %s", personaID)
+	}
+	delete(ms.personas, personaID)
+	return nil
+}
+
+// SavePromptTemplate stores a prompt template
+func (ms *MemoryStorage) SavePromptTemplate(ctx context.Context, template models.PromptTemplate) error {
+	if err := template.Validate(); err != nil {
+		return fmt.Errorf("invalid prompt template: %w", err)
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.closed {
+		return errStorageClosed
+	}
+	template.UpdatedAt = time.Now()
+	ms.templates[template.ID] = template
+	return nil
+}
+
+// LoadPromptTemplate returns a stored prompt template
+func (ms *MemoryStorage) LoadPromptTemplate(ctx context.Context, templateID string) (models.PromptTemplate, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	template, ok := ms.templates[templateID]
+	if !ok {
+		return models.PromptTemplate{}, fmt.Errorf("prompt template not found: %s", templateID)
+	}
+	return template, nil
+}
+
+// ListPromptTemplates lists all stored prompt templates sorted by name
+func (ms *MemoryStorage) ListPromptTemplates(ctx context.Context) ([]models.PromptTemplate, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	templates := make([]models.PromptTemplate, 0, len(ms.templates))
+	for _, template := range ms.templates {
+		templates = append(templates, template)
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return strings.ToLower(templates[i].Name) < strings.ToLower(templates[j].Name)
+	})
+	return templates, nil
+}
+
+// DeletePromptTemplate removes a prompt template
+func (ms *MemoryStorage) DeletePromptTemplate(ctx context.Context, templateID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.templates[templateID]; !ok {
+		return fmt.Errorf("prompt template not found: %s", templateID)
+	}
+	delete(ms.templates, templateID)
+	return nil
+}
+
+// SaveAppPreferences stores application preferences
+func (ms *MemoryStorage) SaveAppPreferences(ctx context.Context, prefs AppPreferences) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.closed {
+		return errStorageClosed
+	}
+	ms.prefs = &prefs
+	return nil
+}
+
+// LoadAppPreferences returns the stored preferences, or defaults if none were saved
+func (ms *MemoryStorage) LoadAppPreferences(ctx context.Context) (AppPreferences, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if ms.prefs == nil {
+		return NewDefaultAppPreferences(), nil
+	}
+	return *ms.prefs, nil
+}
+
+// SaveMCPServers stores MCP server configurations
+func (ms *MemoryStorage) SaveMCPServers(ctx context.Context, servers []models.MCPServer) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.closed {
+		return errStorageClosed
+	}
+	ms.mcpServers = copyMCPServers(servers)
+	return nil
+}
+
+// LoadMCPServers returns the stored MCP server configurations
+func (ms *MemoryStorage) LoadMCPServers(ctx context.Context) ([]models.MCPServer, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if ms.mcpServers == nil {
+		return []models.MCPServer{}, nil
+	}
+	return copyMCPServers(ms.mcpServers), nil
+}
+
+// SaveAgentConfig stores agent configuration
+func (ms *MemoryStorage) SaveAgentConfig(ctx context.Context, config models.AgentConfig) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.closed {
+		return errStorageClosed
+	}
+	copied, err := copyAgentConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to copy agent config: %w", err)
+	}
+	ms.agentConfig = &copied
+	return nil
+}
+
+// LoadAgentConfig returns the stored agent configuration, or defaults if none was saved
+func (ms *MemoryStorage) LoadAgentConfig(ctx context.Context) (models.AgentConfig, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if ms.agentConfig == nil {
+		return NewDefaultAgentConfig(), nil
+	}
+	return copyAgentConfig(*ms.agentConfig)
+}
+
+// Close marks the storage as closed; further writes fail
+func (ms *MemoryStorage) Close() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.closed = true
+	ms.logger.Info("Closing memory storage")
+	return nil
+}
+
+// Ping reports whether the storage is still open
+func (ms *MemoryStorage) Ping(ctx context.Context) error {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if ms.closed {
+		return fmt.Errorf("storage ping failed: %w", errStorageClosed)
+	}
+	return nil
+}
+
+// errStorageClosed is returned by writes to a closed memory storage
+var errStorageClosed = errors.New("storage is closed")
+
+// copySession returns a session that shares no mutable state with the original
+func copySession(session models.ChatSession) models.ChatSession {
+	messages := make([]models.ChatMessage, len(session.Messages))
+	copy(messages, session.Messages)
+	session.Messages = messages
+	return session
+}
+
+// copyMCPServers returns a copy of the server list and each server's args and env
+func copyMCPServers(servers []models.MCPServer) []models.MCPServer {
+	copied := make([]models.MCPServer, len(servers))
+	for i, server := range servers {
+		if server.Args != nil {
+			args := make([]string, len(server.Args))
+			copy(args, server.Args)
+			server.Args = args
+		}
+		if server.Env != nil {
+			env := make(map[string]string, len(server.Env))
+			for k, v := range server.Env {
+				env[k] = v
+			}
+			server.Env = env
+		}
+		copied[i] = server
+	}
+	return copied
+}
+
+// copyAgentConfig deep-copies an agent config, including its framework settings maps
+func copyAgentConfig(config models.AgentConfig) (models.AgentConfig, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return models.AgentConfig{}, err
+	}
+
+	var copied models.AgentConfig
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return models.AgentConfig{}, err
+	}
+	return copied, nil
+}
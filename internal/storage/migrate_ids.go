@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// sessionIDMapFile records legacy-to-new session ID renames performed by the migration
+const sessionIDMapFile = "session_id_map.json"
+
+// legacySession is a session file that still uses a legacy ID
+type legacySession struct {
+	fileID     string
+	session    models.ChatSession
+	journalSeq int64 // last journal entry folded into the file, kept across the rename
+}
+
+// MigrateSessionIDs renames session files that still use legacy timestamp or
+// "default" IDs to sortable unique IDs. New IDs take their time component from
+// the session's creation time, so the original ordering is preserved. The
+// mapping from old to new IDs is written to session_id_map.json and returned.
+// Sessions that already use new-style IDs are left untouched, so running the
+// migration again is a no-op.
+func (fs *FileStorage) MigrateSessionIDs(ctx context.Context) (map[string]string, error) {
+	sessionsDir := filepath.Join(fs.basePath, "sessions")
+	entries, err := os.ReadDir(sessionsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, fmt.Errorf("failed to read sessions directory: %w", err)
+	}
+
+	var legacy []legacySession
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		fileID := strings.TrimSuffix(entry.Name(), ".json")
+		if models.IsValidID(fileID) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(sessionsDir, entry.Name()))
+		if err != nil {
+			fs.logger.Warn("Skipping unreadable legacy session", "session_id", fileID, "error", err)
+			continue
+		}
+
+		var snapshot sessionSnapshot
+		if err := fs.decodeStoredDocument(filepath.Join(sessionsDir, entry.Name()), KindSession, data, &snapshot); err != nil {
+			fs.logger.Warn("Skipping unparsable legacy session", "session_id", fileID, "error", err)
+			continue
+		}
+
+		legacy = append(legacy, legacySession{fileID: fileID, session: snapshot.ChatSession, journalSeq: snapshot.JournalSeq})
+	}
+
+	if len(legacy) == 0 {
+		return map[string]string{}, nil
+	}
+
+	// Assign IDs oldest first so sessions created in the same millisecond keep their order
+	sort.SliceStable(legacy, func(i, j int) bool {
+		return legacy[i].session.CreatedAt.Before(legacy[j].session.CreatedAt)
+	})
+
+	fs.logger.Info("Migrating legacy session IDs", "count", len(legacy))
+
+	idMap := make(map[string]string, len(legacy))
+	for _, item := range legacy {
+		createdAt := item.session.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = item.session.UpdatedAt
+		}
+
+		newID := models.NewIDAt(createdAt)
+		session := item.session
+		session.ID = newID
+
+		data, err := EncodeDocument(KindSession, sessionSnapshot{ChatSession: session, JournalSeq: item.journalSeq})
+		if err != nil {
+			return idMap, fmt.Errorf("failed to marshal migrated session %s: %w", item.fileID, err)
+		}
+
+		// Write the new file before removing the old one so a crash never loses a session
+		newPath := filepath.Join(sessionsDir, newID+".json")
+		if err := writeFileAtomic(newPath, data, filePerm); err != nil {
+			return idMap, fmt.Errorf("failed to write migrated session %s: %w", item.fileID, err)
+		}
+		// Messages still in the journal move with the session
+		oldJournal := filepath.Join(sessionsDir, item.fileID+journalExt)
+		newJournal := filepath.Join(sessionsDir, newID+journalExt)
+		if err := os.Rename(oldJournal, newJournal); err != nil && !os.IsNotExist(err) {
+			return idMap, fmt.Errorf("failed to move journal of legacy session %s: %w", item.fileID, err)
+		}
+		if err := os.Remove(filepath.Join(sessionsDir, item.fileID+".json")); err != nil {
+			return idMap, fmt.Errorf("failed to remove legacy session file %s: %w", item.fileID, err)
+		}
+
+		idMap[item.fileID] = newID
+		if item.session.ID != "" && item.session.ID != item.fileID {
+			idMap[item.session.ID] = newID
+		}
+
+		fs.logger.Info("Migrated session ID", "old_id", item.fileID, "new_id", newID)
+	}
+
+	if err := fs.recordSessionIDMap(idMap); err != nil {
+		return idMap, err
+	}
+
+	return idMap, nil
+}
+
+// recordSessionIDMap merges the given renames into session_id_map.json
+func (fs *FileStorage) recordSessionIDMap(idMap map[string]string) error {
+	mapPath := filepath.Join(fs.basePath, sessionIDMapFile)
+
+	merged := make(map[string]string)
+	if data, err := os.ReadFile(mapPath); err == nil {
+		if err := json.Unmarshal(data, &merged); err != nil {
+			fs.logger.Warn("Ignoring unreadable session ID map", "error", err)
+			merged = make(map[string]string)
+		}
+	}
+	for oldID, newID := range idMap {
+		merged[oldID] = newID
+	}
+
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session ID map: %w", err)
+	}
+	if err := writeFileAtomic(mapPath, data, filePerm); err != nil {
+		return fmt.Errorf("failed to write session ID map: %w", err)
+	}
+	return nil
+}
+
+// ResolveSessionID maps a legacy session ID to its migrated ID, if it was renamed
+func (fs *FileStorage) ResolveSessionID(sessionID string) string {
+	data, err := os.ReadFile(filepath.Join(fs.basePath, sessionIDMapFile))
+	if err != nil {
+		return sessionID
+	}
+
+	var idMap map[string]string
+	if err := json.Unmarshal(data, &idMap); err != nil {
+		return sessionID
+	}
+
+	if newID, ok := idMap[sessionID]; ok {
+		return newID
+	}
+	return sessionID
+}
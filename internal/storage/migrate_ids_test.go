@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// writeLegacySession writes a session file named after a legacy ID, as older
+// versions did
+func writeLegacySession(t *testing.T, dir, fileID, name string, createdAt time.Time) {
+	t.Helper()
+	session := models.ChatSession{
+		ID:        fileID,
+		Name:      name,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt.Add(time.Minute),
+		Messages:  []models.ChatMessage{{Sender: "user", Content: "hello from " + name, Timestamp: createdAt}},
+	}
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		t.Fatalf("marshal legacy session: %v", err)
+	}
+	sessionsDir := filepath.Join(dir, "sessions")
+	if err := os.MkdirAll(sessionsDir, dirPerm); err != nil {
+		t.Fatalf("create sessions directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sessionsDir, fileID+".json"), data, filePerm); err != nil {
+		t.Fatalf("write legacy session: %v", err)
+	}
+}
+
+// sessionFileIDs lists the IDs of the session files in dir
+func sessionFileIDs(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Join(dir, "sessions"))
+	if err != nil {
+		t.Fatalf("read sessions directory: %v", err)
+	}
+	var ids []string
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == ".json" {
+			ids = append(ids, entry.Name()[:len(entry.Name())-len(".json")])
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestMigrateSessionIDs(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	writeLegacySession(t, dir, "default", "Default Session", base)
+	writeLegacySession(t, dir, "session_1709298000", "Second", base.Add(time.Hour))
+	// Created in the same second, which used to give both the same ID
+	writeLegacySession(t, dir, "session_1709301600", "Third", base.Add(2*time.Hour))
+	writeLegacySession(t, dir, "session_1709301600_2", "Fourth", base.Add(2*time.Hour))
+
+	// A journal written before the migration belongs to the renamed session
+	line := `{"seq":1,"at":"` + base.Add(3*time.Hour).Format(time.RFC3339Nano) + `","from":1,"messages":[{"sender":"llm","content":"journaled"}]}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "sessions", "default"+journalExt), []byte(line), filePerm); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+
+	// Opening the storage runs the migration
+	fs := newTestFileStorage(t, dir)
+
+	ids := sessionFileIDs(t, dir)
+	if len(ids) != 4 {
+		t.Fatalf("got %d session files after migration, want 4: %v", len(ids), ids)
+	}
+	for _, id := range ids {
+		if !models.IsValidID(id) {
+			t.Errorf("session file %s was not renamed to a new ID", id)
+		}
+	}
+
+	// Every legacy ID resolves to its new ID, which is stored in the session too
+	names := map[string]string{
+		"default":              "Default Session",
+		"session_1709298000":   "Second",
+		"session_1709301600":   "Third",
+		"session_1709301600_2": "Fourth",
+	}
+	newIDs := make(map[string]string)
+	for oldID, name := range names {
+		newID := fs.ResolveSessionID(oldID)
+		if newID == oldID || !models.IsValidID(newID) {
+			t.Fatalf("ResolveSessionID(%q) = %q, want a new ID", oldID, newID)
+		}
+		session, err := fs.LoadChatSession(ctx, newID)
+		if err != nil {
+			t.Fatalf("LoadChatSession(%s): %v", newID, err)
+		}
+		if session.ID != newID || session.Name != name {
+			t.Errorf("session %s holds ID %s and name %q, want %s and %q", oldID, session.ID, session.Name, newID, name)
+		}
+		newIDs[oldID] = newID
+	}
+
+	// Loading by a legacy ID follows the rename
+	session, err := fs.LoadChatSession(ctx, "default")
+	if err != nil {
+		t.Fatalf("LoadChatSession(default): %v", err)
+	}
+	if session.ID != newIDs["default"] {
+		t.Errorf("LoadChatSession(default) returned %s, want %s", session.ID, newIDs["default"])
+	}
+	if len(session.Messages) != 2 || session.Messages[1].Content != "journaled" {
+		t.Errorf("journaled message lost in the migration: %+v", session.Messages)
+	}
+
+	// The ID map on disk records every rename
+	data, err := os.ReadFile(filepath.Join(dir, sessionIDMapFile))
+	if err != nil {
+		t.Fatalf("read session ID map: %v", err)
+	}
+	var recorded map[string]string
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		t.Fatalf("decode session ID map: %v", err)
+	}
+	for oldID, newID := range newIDs {
+		if recorded[oldID] != newID {
+			t.Errorf("session ID map has %s -> %q, want %q", oldID, recorded[oldID], newID)
+		}
+	}
+
+	// New IDs sort in creation order, including sessions created in the same second
+	order := []string{"default", "session_1709298000", "session_1709301600", "session_1709301600_2"}
+	for i := 1; i < len(order); i++ {
+		if newIDs[order[i-1]] >= newIDs[order[i]] {
+			t.Errorf("ID of %s (%s) does not sort before ID of %s (%s)", order[i-1], newIDs[order[i-1]], order[i], newIDs[order[i]])
+		}
+	}
+
+	// A second run finds nothing to migrate and changes nothing
+	idMap, err := fs.MigrateSessionIDs(ctx)
+	if err != nil {
+		t.Fatalf("MigrateSessionIDs (second run): %v", err)
+	}
+	if len(idMap) != 0 {
+		t.Errorf("second run migrated %d sessions, want none", len(idMap))
+	}
+	if after := sessionFileIDs(t, dir); len(after) != len(ids) {
+		t.Errorf("second run changed the session files: %v -> %v", ids, after)
+	}
+	if again, err := os.ReadFile(filepath.Join(dir, sessionIDMapFile)); err != nil || string(again) != string(data) {
+		t.Errorf("second run changed the session ID map (err %v)", err)
+	}
+}
+
+func TestMigrateSessionIDsKeepsJournalSeq(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// A legacy session that already folded journal entry 1 and was edited since
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	snapshot := sessionSnapshot{
+		ChatSession: models.ChatSession{
+			ID:        "session_1709298000",
+			Name:      "Edited",
+			CreatedAt: createdAt,
+			UpdatedAt: createdAt.Add(time.Hour),
+			Messages: []models.ChatMessage{
+				{Sender: "user", Content: "question", Timestamp: createdAt},
+				{Sender: "llm", Content: "edited answer", Timestamp: createdAt},
+			},
+		},
+		JournalSeq: 1,
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionsDir := filepath.Join(dir, "sessions")
+	os.MkdirAll(sessionsDir, dirPerm)
+	if err := os.WriteFile(filepath.Join(sessionsDir, snapshot.ID+".json"), data, filePerm); err != nil {
+		t.Fatal(err)
+	}
+	line := `{"seq":1,"at":"` + createdAt.Add(time.Minute).Format(time.RFC3339Nano) + `","from":1,"messages":[{"sender":"llm","content":"original answer"}]}` + "\n"
+	if err := os.WriteFile(filepath.Join(sessionsDir, snapshot.ID+journalExt), []byte(line), filePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := newTestFileStorage(t, dir)
+	session, err := fs.LoadChatSession(ctx, snapshot.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession: %v", err)
+	}
+	if len(session.Messages) != 2 || session.Messages[1].Content != "edited answer" {
+		t.Errorf("a folded journal entry was replayed again after the migration: %+v", session.Messages)
+	}
+}
+
+func TestResolveSessionIDWithoutMigration(t *testing.T) {
+	fs := newTestFileStorage(t, t.TempDir())
+
+	id := models.NewID()
+	if got := fs.ResolveSessionID(id); got != id {
+		t.Errorf("ResolveSessionID(%s) = %s, want it unchanged", id, got)
+	}
+	if got := fs.ResolveSessionID("default"); got != "default" {
+		t.Errorf("ResolveSessionID(default) = %s without a migration, want it unchanged", got)
+	}
+}
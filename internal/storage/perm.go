@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Permissions of files and directories written to the data directory. Chats can hold
+// private code and customer details, so only the owner may read them.
+const (
+	filePerm os.FileMode = 0600
+	dirPerm  os.FileMode = 0700
+)
+
+// TightenPermissions restricts every file and directory under dir to its owner. It
+// returns the number of entries whose permissions were changed.
+func TightenPermissions(dir string) (int, error) {
+	changed := 0
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		want := filePerm
+		if entry.IsDir() {
+			want = dirPerm
+		}
+		if info.Mode().Perm()&^want == 0 {
+			return nil
+		}
+		if err := os.Chmod(path, info.Mode().Perm()&want); err != nil {
+			return err
+		}
+		changed++
+		return nil
+	})
+	return changed, err
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// ExportPersonas writes the given personas to w as a shareable persona bundle
+func ExportPersonas(w io.Writer, personas []models.Persona) error {
+	bundle := models.NewPersonaBundle(personas)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(bundle); err != nil {
+		return fmt.Errorf("failed to encode persona bundle: %w", err)
+	}
+	return nil
+}
+
+// ImportPersonas reads a persona bundle from r and saves every persona into the storage.
+// Every persona is imported under a new ID, so that local personas are never overwritten
+// and IDs from the bundle never name a file. It returns the number of personas imported.
+func ImportPersonas(ctx context.Context, s Storage, r io.Reader) (int, error) {
+	var bundle models.PersonaBundle
+	if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+		return 0, fmt.Errorf("failed to decode persona bundle: %w", err)
+	}
+
+	if bundle.Version > models.PersonaBundleVersion {
+		return 0, fmt.Errorf("unsupported persona bundle version: %d", bundle.Version)
+	}
+
+	imported := 0
+	for _, persona := range bundle.Personas {
+		persona.ID = models.NewID()
+		if persona.CreatedAt.IsZero() {
+			persona.CreatedAt = time.Now()
+		}
+
+		if err := s.SavePersona(ctx, persona); err != nil {
+			return imported, fmt.Errorf("failed to import persona %q: %w", persona.Name, err)
+		}
+		imported++
+	}
+
+	return imported, nil
+}
@@ -0,0 +1,88 @@
+package storage_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/storage"
+)
+
+func TestImportPersonasAssignsNewIDs(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	dataDir := filepath.Join(root, "a", "data")
+	fs, err := storage.NewFileStorage(dataDir, nil, testLogger())
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	defer fs.Close()
+
+	local := models.NewPersona("Local", "You are local.", 0.7)
+	if err := fs.SavePersona(ctx, local); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := `{"version": 1, "personas": [
+		{"id": "../../escaped", "name": "Escaping", "system_prompt": "x", "temperature": 0.5},
+		{"id": "` + local.ID + `", "name": "Same ID", "system_prompt": "y", "temperature": 0.5}
+	]}`
+	count, err := storage.ImportPersonas(ctx, fs, strings.NewReader(bundle))
+	if err != nil {
+		t.Fatalf("ImportPersonas: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("imported %d personas, want 2", count)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "escaped.json")); !os.IsNotExist(err) {
+		t.Fatal("a persona was written outside the data directory")
+	}
+	personas, err := fs.ListPersonas(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(personas) != 3 {
+		t.Fatalf("got %d personas, want 3", len(personas))
+	}
+	for _, persona := range personas {
+		if !models.IsValidID(persona.ID) {
+			t.Errorf("persona %q stored under ID %q", persona.Name, persona.ID)
+		}
+		if persona.Name == "Local" && persona.SystemPrompt != "You are local." {
+			t.Error("import overwrote the local persona")
+		}
+	}
+}
+
+func TestFileStorageRefusesInvalidIDs(t *testing.T) {
+	ctx := context.Background()
+	fs := newFileStorage(t)
+	defer fs.Close()
+	const escaping = "../../escaped"
+
+	persona := models.NewPersona("Escaping", "x", 0.5)
+	persona.ID = escaping
+	if err := fs.SavePersona(ctx, persona); err == nil {
+		t.Error("SavePersona accepted an escaping ID")
+	}
+	template := models.NewPromptTemplate("Escaping", "x")
+	template.ID = escaping
+	if err := fs.SavePromptTemplate(ctx, template); err == nil {
+		t.Error("SavePromptTemplate accepted an escaping ID")
+	}
+	session := models.NewChatSession("Escaping", "llama3.2:latest")
+	session.ID = escaping
+	if err := fs.ImportChatSession(ctx, session); err == nil {
+		t.Error("ImportChatSession accepted an escaping ID")
+	}
+	if _, err := fs.LoadChatSession(ctx, escaping); err == nil {
+		t.Error("LoadChatSession accepted an escaping ID")
+	}
+	if err := fs.DeletePersona(ctx, escaping); err == nil {
+		t.Error("DeletePersona accepted an escaping ID")
+	}
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// quarantineDir holds files that could not be parsed at startup
+const quarantineDir = "quarantine"
+
+// storedDocuments lists the single-file documents at the top of the data directory
+var storedDocuments = map[string]DocumentKind{
+	"preferences.json":  KindPreferences,
+	"mcp_servers.json":  KindMCPServers,
+	"agent_config.json": KindAgentConfig,
+}
+
+// documentDirs lists the directories holding one document per file
+var documentDirs = map[string]DocumentKind{
+	"sessions":  KindSession,
+	"personas":  KindPersona,
+	"templates": KindTemplate,
+}
+
+// quarantineCorruptFiles moves stored files that cannot be parsed into the quarantine
+// directory, so that one damaged file neither breaks startup nor gets overwritten,
+// and removes temporary files left behind by interrupted writes. It returns the
+// quarantined paths relative to the data directory.
+func (fs *FileStorage) quarantineCorruptFiles() ([]string, error) {
+	var quarantined []string
+
+	check := func(path string, kind DocumentKind) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		reason := corruptionReason(kind, data)
+		if reason == "" {
+			return nil
+		}
+
+		rel, err := fs.quarantineFile(path)
+		if err != nil {
+			return err
+		}
+		fs.logger.Warn("Quarantined corrupt file", "path", path, "reason", reason, "moved_to", rel)
+		quarantined = append(quarantined, rel)
+		return nil
+	}
+
+	for name, kind := range storedDocuments {
+		if err := check(filepath.Join(fs.basePath, name), kind); err != nil {
+			return quarantined, err
+		}
+	}
+	if err := check(filepath.Join(fs.basePath, sessionIDMapFile), ""); err != nil {
+		return quarantined, err
+	}
+	fs.removeTempFiles(fs.basePath)
+
+	for dirName, kind := range documentDirs {
+		dir := filepath.Join(fs.basePath, dirName)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return quarantined, fmt.Errorf("failed to read %s directory: %w", dirName, err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") || isTempFile(entry.Name()) {
+				continue
+			}
+			if err := check(filepath.Join(dir, entry.Name()), kind); err != nil {
+				return quarantined, err
+			}
+		}
+		fs.removeTempFiles(dir)
+	}
+
+	return quarantined, nil
+}
+
+// corruptionReason returns why a stored file is unusable, or "" if it looks intact.
+// An empty kind only checks that the file is valid JSON.
+func corruptionReason(kind DocumentKind, data []byte) string {
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return "file is empty"
+	}
+	if !json.Valid(data) {
+		return "file is not valid JSON"
+	}
+	if kind == "" {
+		return ""
+	}
+
+	// Newer documents are intact; they are refused later rather than quarantined
+	result, err := UpgradeDocument(kind, data)
+	if errors.Is(err, ErrNewerSchema) {
+		return ""
+	}
+	if err != nil {
+		return err.Error()
+	}
+	if err := DecodeDocument(kind, result.Data, newDocumentValue(kind)); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// newDocumentValue returns a pointer to the Go type stored for a document kind
+func newDocumentValue(kind DocumentKind) interface{} {
+	switch kind {
+	case KindSession:
+		return &models.ChatSession{}
+	case KindPreferences:
+		return &AppPreferences{}
+	case KindMCPServers:
+		return &[]models.MCPServer{}
+	case KindAgentConfig:
+		return &models.AgentConfig{}
+	case KindPersona:
+		return &models.Persona{}
+	case KindTemplate:
+		return &models.PromptTemplate{}
+	default:
+		return &map[string]interface{}{}
+	}
+}
+
+// quarantineFile moves path into the quarantine directory and returns its new relative path
+func (fs *FileStorage) quarantineFile(path string) (string, error) {
+	dir := filepath.Join(fs.basePath, quarantineDir)
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return "", fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+
+	rel, err := filepath.Rel(fs.basePath, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+	name := strings.ReplaceAll(filepath.ToSlash(rel), "/", "_")
+	target := filepath.Join(dir, fmt.Sprintf("%s.%s", name, time.Now().Format("20060102-150405")))
+
+	if err := os.Rename(path, target); err != nil {
+		return "", fmt.Errorf("failed to quarantine %s: %w", rel, err)
+	}
+
+	targetRel, err := filepath.Rel(fs.basePath, target)
+	if err != nil {
+		targetRel = target
+	}
+	return targetRel, nil
+}
+
+// removeTempFiles deletes temporary files from interrupted atomic writes in dir
+func (fs *FileStorage) removeTempFiles(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !isTempFile(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err := os.Remove(path); err != nil {
+			fs.logger.Warn("Failed to remove leftover temporary file", "path", path, "error", err)
+			continue
+		}
+		fs.logger.Info("Removed leftover temporary file from an interrupted write", "path", path)
+	}
+}
@@ -0,0 +1,888 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+// s3StateFile records, in the cache directory, the uploads still to be made and the
+// version of every object last seen in the bucket
+const s3StateFile = "s3_state.json"
+
+// s3StateVersion is the format version of the state file
+const s3StateVersion = 1
+
+// s3RequestTimeout bounds each request to the object store
+const s3RequestTimeout = 30 * time.Second
+
+// s3FlushDelay is how long appended messages wait before they are uploaded, so a
+// streamed reply is uploaded once rather than for every chunk
+const s3FlushDelay = 2 * time.Second
+
+// s3RetryInterval is the time between attempts to upload queued changes while the
+// object store is unreachable
+const s3RetryInterval = 30 * time.Second
+
+// s3DeletedAtMeta is the object metadata holding when a trashed session was deleted
+const s3DeletedAtMeta = "deleted-at"
+
+// s3KeyPattern matches the object keys, relative to the prefix, the storage reads and
+// writes. They are also the paths of the matching files in the cache.
+var s3KeyPattern = regexp.MustCompile(`^((sessions|trash|personas|templates)/[A-Za-z0-9_-]+\.json|preferences\.json|mcp_servers\.json|agent_config\.json)$`)
+
+// S3Settings configures an S3Storage
+type S3Settings struct {
+	Endpoint  string // URL of the object store, such as "http://localhost:9000"
+	Bucket    string
+	Prefix    string // Key prefix everything is stored under, such as "alice/"
+	Region    string // Defaults to "us-east-1"
+	AccessKey string
+	SecretKey string
+	CacheDir  string // Local copy of the stored data
+}
+
+// s3State is the content of the state file
+type s3State struct {
+	Version int               `json:"version"`
+	Pending []string          `json:"pending"`
+	ETags   map[string]string `json:"etags"`
+}
+
+// S3Storage stores everything as objects in an S3-compatible bucket, with a local
+// write-through cache. Reads are served from the cache. Writes go to the cache first
+// and are then uploaded; while the object store is unreachable they are queued and
+// uploaded when it is back. Changes made in the bucket by other machines are brought
+// into the cache when the storage is opened; a change queued here wins over them.
+type S3Storage struct {
+	cache    *FileStorage
+	client   *s3Client
+	prefix   string
+	cacheDir string
+	logger   *logger.Logger
+
+	flushMu sync.Mutex // Serializes uploads and downloads
+	mu      sync.Mutex // Guards everything below
+	pending map[string]uint64
+	etags   map[string]string
+	seq     uint64
+	offline bool
+
+	kick   chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	writes atomic.Int64
+	bytes  atomic.Int64
+}
+
+// NewS3Storage opens the storage in the bucket described by settings. Changes made
+// elsewhere are downloaded and queued changes uploaded before it returns; if the
+// object store cannot be reached, the storage starts offline from its cache.
+func NewS3Storage(settings S3Settings, logger *logger.Logger) (*S3Storage, error) {
+	if settings.Endpoint == "" || settings.Bucket == "" {
+		return nil, fmt.Errorf("S3 storage requires an endpoint and a bucket")
+	}
+	if settings.CacheDir == "" {
+		return nil, fmt.Errorf("S3 storage requires a cache directory")
+	}
+	client, err := newS3Client(settings.Endpoint, settings.Bucket, settings.Region, settings.AccessKey, settings.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+	client.http.Timeout = s3RequestTimeout
+
+	prefix := strings.TrimPrefix(settings.Prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	cache, err := NewFileStorage(settings.CacheDir, nil, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &S3Storage{
+		cache:    cache,
+		client:   client,
+		prefix:   prefix,
+		cacheDir: settings.CacheDir,
+		logger:   logger.WithComponent("s3-storage"),
+		pending:  make(map[string]uint64),
+		etags:    make(map[string]string),
+		kick:     make(chan struct{}, 1),
+	}
+	firstUse, err := s.loadState()
+	if err != nil {
+		cache.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3RequestTimeout)
+	defer cancel()
+	if err := s.pull(ctx, firstUse); err != nil {
+		s.offline = true
+		s.logger.Warn("Object store is unreachable; working offline from the cache", "endpoint", settings.Endpoint, "error", err)
+	} else if err := s.flush(ctx); err != nil {
+		s.logger.Warn("Failed to upload queued changes", "error", err)
+	}
+
+	loopCtx, stop := context.WithCancel(context.Background())
+	s.cancel = stop
+	s.done = make(chan struct{})
+	go s.run(loopCtx)
+
+	s.logger.Info("Initialized S3 storage", "endpoint", settings.Endpoint, "bucket", settings.Bucket,
+		"prefix", prefix, "cache_dir", settings.CacheDir, "pending", s.PendingUploads())
+	return s, nil
+}
+
+// loadState reads the state file and reports whether there was none, meaning the
+// cache has never been used with the bucket
+func (s *S3Storage) loadState() (bool, error) {
+	data, err := os.ReadFile(filepath.Join(s.cacheDir, s3StateFile))
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read S3 state: %w", err)
+	}
+
+	var state s3State
+	if err := json.Unmarshal(data, &state); err != nil || state.Version != s3StateVersion {
+		// Without the state every cached item is compared with the bucket again
+		s.logger.Warn("Rebuilding unreadable S3 state", "error", err, "version", state.Version)
+		return true, nil
+	}
+	for _, key := range state.Pending {
+		s.seq++
+		s.pending[key] = s.seq
+	}
+	for key, etag := range state.ETags {
+		s.etags[key] = etag
+	}
+	return false, nil
+}
+
+// saveState writes the state file. The caller must hold mu.
+func (s *S3Storage) saveState() {
+	state := s3State{Version: s3StateVersion, Pending: make([]string, 0, len(s.pending)), ETags: s.etags}
+	for key := range s.pending {
+		state.Pending = append(state.Pending, key)
+	}
+	sort.Strings(state.Pending)
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(filepath.Join(s.cacheDir, s3StateFile), data, filePerm)
+	}
+	if err != nil {
+		s.logger.Warn("Failed to save S3 state", "error", err)
+	}
+}
+
+// PendingUploads returns the number of changes waiting to be uploaded
+func (s *S3Storage) PendingUploads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// queue records keys as changed in the cache
+func (s *S3Storage) queue(keys ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		s.seq++
+		s.pending[key] = s.seq
+	}
+	s.saveState()
+}
+
+// writeThrough queues keys and uploads them right away unless the object store is
+// known to be unreachable. The change is kept in the cache and queue either way, so
+// a failed upload is only logged.
+func (s *S3Storage) writeThrough(ctx context.Context, keys ...string) {
+	s.queue(keys...)
+
+	s.mu.Lock()
+	offline := s.offline
+	s.mu.Unlock()
+	if offline {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s3RequestTimeout)
+	defer cancel()
+	if err := s.flush(ctx); err != nil {
+		s.setOffline(true)
+		s.logger.Warn("Object store is unreachable; changes are queued for upload", "error", err)
+	}
+}
+
+// writeLater queues keys to be uploaded shortly by the background loop
+func (s *S3Storage) writeLater(keys ...string) {
+	s.queue(keys...)
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// setOffline records whether the object store is reachable
+func (s *S3Storage) setOffline(offline bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offline = offline
+}
+
+// run uploads appended messages shortly after they are queued, and retries queued
+// uploads while the object store is unreachable
+func (s *S3Storage) run(ctx context.Context) {
+	defer close(s.done)
+
+	delay := time.NewTimer(s3FlushDelay)
+	delay.Stop()
+	defer delay.Stop()
+	retry := time.NewTicker(s3RetryInterval)
+	defer retry.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.kick:
+			delay.Reset(s3FlushDelay)
+			continue
+		case <-delay.C:
+		case <-retry.C:
+			if s.PendingUploads() == 0 {
+				continue
+			}
+		}
+
+		s.mu.Lock()
+		wasOffline := s.offline
+		s.mu.Unlock()
+
+		flushCtx, cancel := context.WithTimeout(ctx, s3RequestTimeout)
+		err := s.flush(flushCtx)
+		cancel()
+		switch {
+		case err != nil && !wasOffline:
+			s.setOffline(true)
+			s.logger.Warn("Object store is unreachable; changes are queued for upload", "error", err)
+		case err == nil && wasOffline:
+			s.setOffline(false)
+			s.logger.Info("Object store is reachable again; queued changes uploaded")
+		}
+	}
+}
+
+// flush uploads every queued change, stopping at the first failure
+func (s *S3Storage) flush(ctx context.Context) error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	keys := make([]string, 0, len(s.pending))
+	seqs := make(map[string]uint64, len(s.pending))
+	for key, seq := range s.pending {
+		keys = append(keys, key)
+		seqs[key] = seq
+	}
+	s.mu.Unlock()
+	sort.Strings(keys)
+
+	var err error
+	uploaded := 0
+	for _, key := range keys {
+		var etag string
+		if etag, err = s.upload(ctx, key); err != nil {
+			err = fmt.Errorf("failed to upload %s: %w", key, err)
+			break
+		}
+
+		s.mu.Lock()
+		// A key changed again during the upload stays queued for the next flush
+		if s.pending[key] == seqs[key] {
+			delete(s.pending, key)
+		}
+		if etag == "" {
+			delete(s.etags, key)
+		} else {
+			s.etags[key] = etag
+		}
+		s.mu.Unlock()
+		uploaded++
+	}
+
+	if uploaded > 0 {
+		s.mu.Lock()
+		s.saveState()
+		s.mu.Unlock()
+		s.logger.Debug("Uploaded changes", "count", uploaded)
+	}
+	return err
+}
+
+// upload copies the cached item of key to the bucket, or deletes the object if the
+// item is gone, and returns the new ETag ("" after a delete)
+func (s *S3Storage) upload(ctx context.Context, key string) (string, error) {
+	data, meta, ok, err := s.cachedObject(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", s.client.delete(ctx, s.prefix+key)
+	}
+	etag, err := s.client.put(ctx, s.prefix+key, data, meta)
+	if err != nil {
+		return "", err
+	}
+	s.writes.Add(1)
+	s.bytes.Add(int64(len(data)))
+	return etag, nil
+}
+
+// cachedObject returns the object content of key from the cache, and false if the
+// cache no longer holds it
+func (s *S3Storage) cachedObject(ctx context.Context, key string) ([]byte, map[string]string, bool, error) {
+	dir, name := filepath.Split(key)
+	id := strings.TrimSuffix(name, ".json")
+	switch dir {
+	case "sessions/":
+		// The session file may lack messages that are still in its journal
+		session, ok, err := s.cache.liveSession(ctx, id)
+		if err != nil || !ok {
+			return nil, nil, ok, err
+		}
+		data, err := EncodeDocument(KindSession, session)
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("failed to marshal session: %w", err)
+		}
+		return data, nil, true, nil
+
+	case "trash/":
+		deletedAt, ok := s.cache.trashedAt(id)
+		if !ok {
+			return nil, nil, false, nil
+		}
+		trashPath, err := s.cache.trashPath(id)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		data, err := os.ReadFile(trashPath)
+		if os.IsNotExist(err) {
+			return nil, nil, false, nil
+		}
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("failed to read trashed session: %w", err)
+		}
+		return data, map[string]string{s3DeletedAtMeta: deletedAt.UTC().Format(time.RFC3339Nano)}, true, nil
+
+	default:
+		data, err := os.ReadFile(filepath.Join(s.cacheDir, filepath.FromSlash(key)))
+		if os.IsNotExist(err) {
+			return nil, nil, false, nil
+		}
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("failed to read %s: %w", key, err)
+		}
+		return data, nil, true, nil
+	}
+}
+
+// pull brings objects changed in the bucket since they were last seen into the cache.
+// Keys with queued changes are left alone, since those changes are uploaded over them.
+// On first use, cached items missing from the bucket are queued for upload.
+func (s *S3Storage) pull(ctx context.Context, firstUse bool) error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	objects, err := s.client.list(ctx, s.prefix)
+	if err != nil {
+		return fmt.Errorf("failed to list bucket: %w", err)
+	}
+	remote := make(map[string]string, len(objects))
+	for _, obj := range objects {
+		if key := strings.TrimPrefix(obj.Key, s.prefix); s3KeyPattern.MatchString(key) {
+			remote[key] = obj.ETag
+		}
+	}
+
+	s.mu.Lock()
+	var changed, removed []string
+	for key, etag := range remote {
+		if s.pending[key] == 0 && s.etags[key] != etag {
+			changed = append(changed, key)
+		}
+	}
+	for key := range s.etags {
+		if _, ok := remote[key]; !ok && s.pending[key] == 0 {
+			removed = append(removed, key)
+		}
+	}
+	s.mu.Unlock()
+	// Sessions are applied before the trash, so a session moved to the trash elsewhere
+	// ends up there rather than live
+	sort.Strings(changed)
+	sort.Strings(removed)
+
+	for _, key := range changed {
+		etag, err := s.download(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to download %s: %w", key, err)
+		}
+		s.mu.Lock()
+		s.etags[key] = etag
+		s.mu.Unlock()
+	}
+	for _, key := range removed {
+		if err := s.removeCached(ctx, key); err != nil {
+			return fmt.Errorf("failed to remove %s from cache: %w", key, err)
+		}
+		s.mu.Lock()
+		delete(s.etags, key)
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	if firstUse {
+		for _, key := range s.cachedKeys() {
+			if _, ok := remote[key]; !ok {
+				s.seq++
+				s.pending[key] = s.seq
+			}
+		}
+	}
+	s.saveState()
+	s.mu.Unlock()
+
+	if len(changed) > 0 || len(removed) > 0 {
+		s.logger.Info("Downloaded changes from the object store", "changed", len(changed), "removed", len(removed))
+	}
+	return nil
+}
+
+// download stores the object of key in the cache and returns its ETag
+func (s *S3Storage) download(ctx context.Context, key string) (string, error) {
+	data, meta, etag, err := s.client.get(ctx, s.prefix+key)
+	if errors.Is(err, errObjectNotFound) {
+		// Deleted since the listing; the next pull removes it from the cache
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	dir, name := filepath.Split(key)
+	id := strings.TrimSuffix(name, ".json")
+	switch dir {
+	case "sessions/", "trash/":
+		var session models.ChatSession
+		if err := decodeObject(KindSession, data, &session); err != nil {
+			return "", err
+		}
+		if session.ID != id {
+			return "", fmt.Errorf("object holds session %q", session.ID)
+		}
+		if err := s.cache.ImportChatSession(ctx, session); err != nil {
+			return "", err
+		}
+		if dir == "trash/" {
+			if err := s.cache.TrashChatSession(ctx, id); err != nil {
+				return "", err
+			}
+			if deletedAt, err := time.Parse(time.RFC3339Nano, meta[s3DeletedAtMeta]); err == nil {
+				s.cache.setTrashedAt(id, deletedAt)
+			}
+		}
+
+	default:
+		if err := decodeObject(s3ObjectKind(key), data, nil); err != nil {
+			return "", err
+		}
+		path := filepath.Join(s.cacheDir, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+			return "", fmt.Errorf("failed to create cache directory: %w", err)
+		}
+		if err := writeFileAtomic(path, data, filePerm); err != nil {
+			return "", fmt.Errorf("failed to write cache file: %w", err)
+		}
+	}
+	return etag, nil
+}
+
+// removeCached removes the cached item of a key deleted from the bucket
+func (s *S3Storage) removeCached(ctx context.Context, key string) error {
+	dir, name := filepath.Split(key)
+	id := strings.TrimSuffix(name, ".json")
+	switch dir {
+	case "sessions/":
+		if _, ok, err := s.cache.liveSession(ctx, id); err != nil || !ok {
+			return err
+		}
+		return s.cache.DeleteChatSession(ctx, id)
+	case "trash/":
+		if _, ok := s.cache.trashedAt(id); !ok {
+			return nil
+		}
+		return s.cache.PurgeTrashedSession(ctx, id)
+	default:
+		if err := os.Remove(filepath.Join(s.cacheDir, filepath.FromSlash(key))); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+}
+
+// cachedKeys returns the keys of every item in the cache
+func (s *S3Storage) cachedKeys() []string {
+	var keys []string
+	for _, dir := range []string{"sessions", trashDir, "personas", "templates"} {
+		entries, _ := os.ReadDir(filepath.Join(s.cacheDir, dir))
+		for _, entry := range entries {
+			if key := dir + "/" + entry.Name(); !entry.IsDir() && s3KeyPattern.MatchString(key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	for _, name := range []string{"preferences.json", "mcp_servers.json", "agent_config.json"} {
+		if _, err := os.Stat(filepath.Join(s.cacheDir, name)); err == nil {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+// s3ObjectKind returns the document kind stored under a key other than a session
+func s3ObjectKind(key string) DocumentKind {
+	switch {
+	case strings.HasPrefix(key, "personas/"):
+		return KindPersona
+	case strings.HasPrefix(key, "templates/"):
+		return KindTemplate
+	case key == "preferences.json":
+		return KindPreferences
+	case key == "mcp_servers.json":
+		return KindMCPServers
+	default:
+		return KindAgentConfig
+	}
+}
+
+// decodeObject checks that data is a document of kind this version can read, and
+// decodes it into v unless v is nil
+func decodeObject(kind DocumentKind, data []byte, v interface{}) error {
+	result, err := UpgradeDocument(kind, data)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	return DecodeDocument(kind, result.Data, v)
+}
+
+// sessionKeys returns the keys of a session, live and trashed
+func sessionKeys(sessionID string) []string {
+	return []string{"sessions/" + sessionID + ".json", "trash/" + sessionID + ".json"}
+}
+
+// SaveChatSession saves a session to the cache and uploads it
+func (s *S3Storage) SaveChatSession(ctx context.Context, session models.ChatSession) error {
+	if err := s.cache.SaveChatSession(ctx, session); err != nil {
+		return err
+	}
+	s.writeThrough(ctx, sessionKeys(session.ID)...)
+	return nil
+}
+
+// ImportChatSession stores a session exactly as given, keeping its timestamps
+func (s *S3Storage) ImportChatSession(ctx context.Context, session models.ChatSession) error {
+	if err := s.cache.ImportChatSession(ctx, session); err != nil {
+		return err
+	}
+	s.writeThrough(ctx, sessionKeys(session.ID)...)
+	return nil
+}
+
+// LoadChatSession loads a session from the cache
+func (s *S3Storage) LoadChatSession(ctx context.Context, sessionID string) (models.ChatSession, error) {
+	return s.cache.LoadChatSession(ctx, sessionID)
+}
+
+// ListChatSessions lists the cached sessions
+func (s *S3Storage) ListChatSessions(ctx context.Context) ([]models.ChatSession, error) {
+	return s.cache.ListChatSessions(ctx)
+}
+
+// ListSessionSummaries lists the cached session summaries
+func (s *S3Storage) ListSessionSummaries(ctx context.Context, opts SessionListOptions) (SessionPage, error) {
+	return s.cache.ListSessionSummaries(ctx, opts)
+}
+
+// AppendMessages appends messages in the cache; the session is uploaded shortly after
+func (s *S3Storage) AppendMessages(ctx context.Context, sessionID string, from int, messages []models.ChatMessage) error {
+	if err := s.cache.AppendMessages(ctx, sessionID, from, messages); err != nil {
+		return err
+	}
+	s.writeLater("sessions/" + sessionID + ".json")
+	return nil
+}
+
+// DeleteChatSession deletes a session from the cache and the bucket
+func (s *S3Storage) DeleteChatSession(ctx context.Context, sessionID string) error {
+	if err := s.cache.DeleteChatSession(ctx, sessionID); err != nil {
+		return err
+	}
+	s.writeThrough(ctx, sessionKeys(sessionID)...)
+	return nil
+}
+
+// TrashChatSession moves a session into the trash
+func (s *S3Storage) TrashChatSession(ctx context.Context, sessionID string) error {
+	if err := s.cache.TrashChatSession(ctx, sessionID); err != nil {
+		return err
+	}
+	s.writeThrough(ctx, sessionKeys(sessionID)...)
+	return nil
+}
+
+// ListTrashedSessions lists the cached trash
+func (s *S3Storage) ListTrashedSessions(ctx context.Context) ([]TrashedSession, error) {
+	return s.cache.ListTrashedSessions(ctx)
+}
+
+// RestoreChatSession moves a session out of the trash
+func (s *S3Storage) RestoreChatSession(ctx context.Context, sessionID string) error {
+	if err := s.cache.RestoreChatSession(ctx, sessionID); err != nil {
+		return err
+	}
+	s.writeThrough(ctx, sessionKeys(sessionID)...)
+	return nil
+}
+
+// PurgeTrashedSession permanently deletes a trashed session
+func (s *S3Storage) PurgeTrashedSession(ctx context.Context, sessionID string) error {
+	if err := s.cache.PurgeTrashedSession(ctx, sessionID); err != nil {
+		return err
+	}
+	s.writeThrough(ctx, sessionKeys(sessionID)...)
+	return nil
+}
+
+// PurgeTrash permanently deletes sessions trashed before deletedBefore
+func (s *S3Storage) PurgeTrash(ctx context.Context, deletedBefore time.Time) (int, error) {
+	trashed, err := s.cache.ListTrashedSessions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := s.cache.PurgeTrash(ctx, deletedBefore)
+	if purged > 0 {
+		var keys []string
+		for _, item := range trashed {
+			if item.DeletedAt.Before(deletedBefore) {
+				keys = append(keys, "trash/"+item.ID+".json")
+			}
+		}
+		s.writeThrough(ctx, keys...)
+	}
+	return purged, err
+}
+
+// SavePersona saves a persona and uploads it
+func (s *S3Storage) SavePersona(ctx context.Context, persona models.Persona) error {
+	if err := s.cache.SavePersona(ctx, persona); err != nil {
+		return err
+	}
+	s.writeThrough(ctx, "personas/"+persona.ID+".json")
+	return nil
+}
+
+// LoadPersona loads a persona from the cache
+func (s *S3Storage) LoadPersona(ctx context.Context, personaID string) (models.Persona, error) {
+	return s.cache.LoadPersona(ctx, personaID)
+}
+
+// ListPersonas lists the cached personas
+func (s *S3Storage) ListPersonas(ctx context.Context) ([]models.Persona, error) {
+	return s.cache.ListPersonas(ctx)
+}
+
+// DeletePersona deletes a persona from the cache and the bucket
+func (s *S3Storage) DeletePersona(ctx context.Context, personaID string) error {
+	if err := s.cache.DeletePersona(ctx, personaID); err != nil {
+		return err
+	}
+	s.writeThrough(ctx, "personas/"+personaID+".json")
+	return nil
+}
+
+// SavePromptTemplate saves a prompt template and uploads it
+func (s *S3Storage) SavePromptTemplate(ctx context.Context, template models.PromptTemplate) error {
+	if err := s.cache.SavePromptTemplate(ctx, template); err != nil {
+		return err
+	}
+	s.writeThrough(ctx, "templates/"+template.ID+".json")
+	return nil
+}
+
+// LoadPromptTemplate loads a prompt template from the cache
+func (s *S3Storage) LoadPromptTemplate(ctx context.Context, templateID string) (models.PromptTemplate, error) {
+	return s.cache.LoadPromptTemplate(ctx, templateID)
+}
+
+// ListPromptTemplates lists the cached prompt templates
+func (s *S3Storage) ListPromptTemplates(ctx context.Context) ([]models.PromptTemplate, error) {
+	return s.cache.ListPromptTemplates(ctx)
+}
+
+// DeletePromptTemplate deletes a prompt template from the cache and the bucket
+func (s *S3Storage) DeletePromptTemplate(ctx context.Context, templateID string) error {
+	if err := s.cache.DeletePromptTemplate(ctx, templateID); err != nil {
+		return err
+	}
+	s.writeThrough(ctx, "templates/"+templateID+".json")
+	return nil
+}
+
+// SaveAppPreferences saves the preferences and uploads them
+func (s *S3Storage) SaveAppPreferences(ctx context.Context, prefs AppPreferences) error {
+	if err := s.cache.SaveAppPreferences(ctx, prefs); err != nil {
+		return err
+	}
+	s.writeThrough(ctx, "preferences.json")
+	return nil
+}
+
+// LoadAppPreferences loads the cached preferences
+func (s *S3Storage) LoadAppPreferences(ctx context.Context) (AppPreferences, error) {
+	return s.cache.LoadAppPreferences(ctx)
+}
+
+// SaveMCPServers saves the MCP servers and uploads them
+func (s *S3Storage) SaveMCPServers(ctx context.Context, servers []models.MCPServer) error {
+	if err := s.cache.SaveMCPServers(ctx, servers); err != nil {
+		return err
+	}
+	s.writeThrough(ctx, "mcp_servers.json")
+	return nil
+}
+
+// LoadMCPServers loads the cached MCP servers
+func (s *S3Storage) LoadMCPServers(ctx context.Context) ([]models.MCPServer, error) {
+	return s.cache.LoadMCPServers(ctx)
+}
+
+// SaveAgentConfig saves the agent configuration and uploads it
+func (s *S3Storage) SaveAgentConfig(ctx context.Context, config models.AgentConfig) error {
+	if err := s.cache.SaveAgentConfig(ctx, config); err != nil {
+		return err
+	}
+	s.writeThrough(ctx, "agent_config.json")
+	return nil
+}
+
+// LoadAgentConfig loads the cached agent configuration
+func (s *S3Storage) LoadAgentConfig(ctx context.Context) (models.AgentConfig, error) {
+	return s.cache.LoadAgentConfig(ctx)
+}
+
+// CacheDir returns the directory of the local cache, where the indexes are kept too
+func (s *S3Storage) CacheDir() string {
+	return s.cacheDir
+}
+
+// IOStats returns the number of uploads and bytes uploaded since the storage was opened
+func (s *S3Storage) IOStats() IOStats {
+	return IOStats{Writes: s.writes.Load(), BytesWritten: s.bytes.Load()}
+}
+
+// Close uploads queued changes if it can and releases the cache. Changes that could
+// not be uploaded stay queued for the next time the storage is opened.
+func (s *S3Storage) Close() error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	<-s.done
+	s.cancel = nil
+
+	if s.PendingUploads() > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), s3RequestTimeout)
+		if err := s.flush(ctx); err != nil {
+			s.logger.Warn("Changes left queued for upload", "pending", s.PendingUploads(), "error", err)
+		}
+		cancel()
+	}
+	s.logger.Info("Closing S3 storage")
+	return s.cache.Close()
+}
+
+// Ping checks that the cache is accessible. An unreachable object store is not an
+// error, since changes are queued until it is back.
+func (s *S3Storage) Ping(ctx context.Context) error {
+	return s.cache.Ping(ctx)
+}
+
+// CheckBucket checks that the bucket exists and the credentials may use it
+func (s *S3Storage) CheckBucket(ctx context.Context) error {
+	if err := s.client.headBucket(ctx); err != nil {
+		return fmt.Errorf("failed to reach bucket: %w", err)
+	}
+	return nil
+}
+
+// liveSession loads a session that is not in the trash, reporting false if there is
+// none. Unlike LoadChatSession, it does not count as the caller having seen the session.
+func (fs *FileStorage) liveSession(ctx context.Context, sessionID string) (models.ChatSession, bool, error) {
+	sessionPath, err := fs.sessionPath(sessionID)
+	if err != nil {
+		return models.ChatSession{}, false, err
+	}
+
+	fs.journalMu.Lock()
+	defer fs.journalMu.Unlock()
+
+	if _, err := os.Stat(sessionPath); os.IsNotExist(err) {
+		return models.ChatSession{}, false, nil
+	}
+	session, err := fs.loadChatSession(ctx, sessionID)
+	if err != nil {
+		return models.ChatSession{}, false, err
+	}
+	return session, true, nil
+}
+
+// trashedAt returns when a session was moved to the trash, and false if it is not there
+func (fs *FileStorage) trashedAt(sessionID string) (time.Time, bool) {
+	fs.journalMu.Lock()
+	defer fs.journalMu.Unlock()
+	item, ok := fs.trash[sessionID]
+	return item.DeletedAt, ok
+}
+
+// setTrashedAt sets when a trashed session was deleted
+func (fs *FileStorage) setTrashedAt(sessionID string, deletedAt time.Time) {
+	fs.journalMu.Lock()
+	defer fs.journalMu.Unlock()
+	if item, ok := fs.trash[sessionID]; ok {
+		item.DeletedAt = deletedAt
+		fs.trash[sessionID] = item
+		fs.saveTrashManifest()
+	}
+}
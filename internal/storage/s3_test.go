@@ -0,0 +1,181 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/storage"
+	"github.com/ashprao/ollamachat/internal/storage/s3test"
+	"github.com/ashprao/ollamachat/internal/storage/storagetest"
+)
+
+// testBucket is the bucket the fake server holds
+const testBucket = "ollamachat-test"
+
+// newS3Storage opens an S3Storage on server with its cache in cacheDir
+func newS3Storage(t *testing.T, server *s3test.Server, cacheDir string) *storage.S3Storage {
+	t.Helper()
+	s, err := storage.NewS3Storage(storage.S3Settings{
+		Endpoint:  server.URL,
+		Bucket:    testBucket,
+		Prefix:    "alice/",
+		AccessKey: "test-access-key",
+		SecretKey: "test-secret-key",
+		CacheDir:  cacheDir,
+	}, testLogger())
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	return s
+}
+
+// waitFor polls cond until it holds or the timeout passes
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestS3StorageConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		server := s3test.NewServer(testBucket)
+		t.Cleanup(server.Close)
+		return newS3Storage(t, server, t.TempDir())
+	})
+}
+
+func TestS3StorageQueuesWritesWhileOffline(t *testing.T) {
+	ctx := context.Background()
+	server := s3test.NewServer(testBucket)
+	defer server.Close()
+
+	s := newS3Storage(t, server, t.TempDir())
+	defer s.Close()
+
+	server.SetOffline(true)
+	session := models.NewChatSession("Offline chat", "llama3.2:latest")
+	session.Messages = []models.ChatMessage{models.NewChatMessage("user", "written offline")}
+	if err := s.SaveChatSession(ctx, session); err != nil {
+		t.Fatalf("SaveChatSession while offline: %v", err)
+	}
+	if s.PendingUploads() == 0 {
+		t.Fatal("expected the change to be queued while the server is down")
+	}
+	if _, ok := server.Object(testBucket, "alice/sessions/"+session.ID+".json"); ok {
+		t.Fatal("session reached the bucket while the server was down")
+	}
+
+	// Reads are served from the cache meanwhile
+	loaded, err := s.LoadChatSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession while offline: %v", err)
+	}
+	if len(loaded.Messages) != 1 {
+		t.Fatalf("got %d messages from the cache, want 1", len(loaded.Messages))
+	}
+
+	// Once the server is back, the next upload drains the whole queue
+	server.SetOffline(false)
+	reply := models.NewChatMessage("llm", "written online")
+	if err := s.AppendMessages(ctx, session.ID, 1, []models.ChatMessage{reply}); err != nil {
+		t.Fatalf("AppendMessages: %v", err)
+	}
+	waitFor(t, 10*time.Second, "the upload queue to drain", func() bool {
+		return s.PendingUploads() == 0
+	})
+
+	data, ok := server.Object(testBucket, "alice/sessions/"+session.ID+".json")
+	if !ok {
+		t.Fatal("session missing from the bucket after the queue drained")
+	}
+	var uploaded models.ChatSession
+	if err := storage.DecodeDocument(storage.KindSession, data, &uploaded); err != nil {
+		t.Fatalf("decode uploaded session: %v", err)
+	}
+	if len(uploaded.Messages) != 2 {
+		t.Errorf("uploaded session has %d messages, want 2", len(uploaded.Messages))
+	}
+}
+
+func TestS3StorageUploadsQueueOnReopen(t *testing.T) {
+	ctx := context.Background()
+	server := s3test.NewServer(testBucket)
+	defer server.Close()
+	cacheDir := t.TempDir()
+
+	s := newS3Storage(t, server, cacheDir)
+	server.SetOffline(true)
+	persona := models.NewPersona("Reviewer", "You review code.", 0.2)
+	if err := s.SavePersona(ctx, persona); err != nil {
+		t.Fatalf("SavePersona while offline: %v", err)
+	}
+	s.Close()
+
+	// The queue is kept in the cache and uploaded when the storage is opened again
+	server.SetOffline(false)
+	s = newS3Storage(t, server, cacheDir)
+	defer s.Close()
+	if pending := s.PendingUploads(); pending != 0 {
+		t.Fatalf("%d changes still queued after reopening with the server up", pending)
+	}
+	if _, ok := server.Object(testBucket, "alice/personas/"+persona.ID+".json"); !ok {
+		t.Fatal("persona missing from the bucket after reopening")
+	}
+}
+
+func TestS3StoragePullsRemoteChanges(t *testing.T) {
+	ctx := context.Background()
+	server := s3test.NewServer(testBucket)
+	defer server.Close()
+	cacheDir := t.TempDir()
+
+	s := newS3Storage(t, server, cacheDir)
+	session := models.NewChatSession("Before", "llama3.2:latest")
+	session.Messages = []models.ChatMessage{models.NewChatMessage("user", "hello")}
+	if err := s.SaveChatSession(ctx, session); err != nil {
+		t.Fatalf("SaveChatSession: %v", err)
+	}
+	s.Close()
+
+	// Another machine renames the session and adds a reply
+	session.Name = "After"
+	session.Messages = append(session.Messages, models.NewChatMessage("llm", "hi from elsewhere"))
+	session.UpdatedAt = time.Now()
+	data, err := storage.EncodeDocument(storage.KindSession, session)
+	if err != nil {
+		t.Fatalf("EncodeDocument: %v", err)
+	}
+	server.PutObject(testBucket, "alice/sessions/"+session.ID+".json", data)
+
+	// And creates a session of its own
+	other := models.NewChatSession("From elsewhere", "llama3.2:latest")
+	data, err = storage.EncodeDocument(storage.KindSession, other)
+	if err != nil {
+		t.Fatalf("EncodeDocument: %v", err)
+	}
+	server.PutObject(testBucket, "alice/sessions/"+other.ID+".json", data)
+
+	s = newS3Storage(t, server, cacheDir)
+	defer s.Close()
+
+	loaded, err := s.LoadChatSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession: %v", err)
+	}
+	if loaded.Name != "After" || len(loaded.Messages) != 2 {
+		t.Errorf("remote change not pulled: name %q with %d messages", loaded.Name, len(loaded.Messages))
+	}
+	if _, err := s.LoadChatSession(ctx, other.ID); err != nil {
+		t.Errorf("remote session not pulled: %v", err)
+	}
+	if pending := s.PendingUploads(); pending != 0 {
+		t.Errorf("pulling queued %d uploads, want none", pending)
+	}
+}
@@ -0,0 +1,352 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DocumentKind identifies a type of stored document for schema versioning
+type DocumentKind string
+
+const (
+	KindSession     DocumentKind = "session"
+	KindPreferences DocumentKind = "preferences"
+	KindMCPServers  DocumentKind = "mcp_servers"
+	KindAgentConfig DocumentKind = "agent_config"
+	KindPersona     DocumentKind = "persona"
+	KindTemplate    DocumentKind = "template"
+)
+
+// SchemaVersionField is the top-level JSON field holding a document's schema version
+const SchemaVersionField = "schema_version"
+
+// legacySchemaVersion is assumed for documents written before versioning existed
+const legacySchemaVersion = 1
+
+// CurrentSchemaVersions lists the schema version this build writes for each document kind
+var CurrentSchemaVersions = map[DocumentKind]int{
+	KindSession:     2,
+	KindPreferences: 3,
+	KindMCPServers:  2,
+	KindAgentConfig: 2,
+	KindPersona:     1,
+	KindTemplate:    1,
+}
+
+// envelopeFields names the field that holds the payload of documents whose payload is
+// not a JSON object. Such documents are stored as {"schema_version": N, "<field>": payload}.
+var envelopeFields = map[DocumentKind]string{
+	KindMCPServers: "servers",
+}
+
+// ErrNewerSchema is returned when a document was written by a newer version of the app
+var ErrNewerSchema = errors.New("document was written by a newer version of the application")
+
+// SchemaVersionError describes a document whose schema version cannot be handled
+type SchemaVersionError struct {
+	Kind    DocumentKind
+	Version int
+	Current int
+}
+
+// Error implements the error interface
+func (e *SchemaVersionError) Error() string {
+	return fmt.Sprintf("%s schema version %d is newer than supported version %d; please upgrade the application", e.Kind, e.Version, e.Current)
+}
+
+// Unwrap allows errors.Is(err, ErrNewerSchema)
+func (e *SchemaVersionError) Unwrap() error {
+	return ErrNewerSchema
+}
+
+// Migration upgrades a decoded document of one kind from version From to From+1.
+// The document is the generic JSON value produced by encoding/json (maps, slices, etc.),
+// without the schema version field, which the framework maintains.
+type Migration struct {
+	Kind        DocumentKind
+	From        int
+	Description string
+	Migrate     func(doc interface{}) (interface{}, error)
+}
+
+// migrations holds every registered migration, keyed by document kind
+var migrations = map[DocumentKind][]Migration{}
+
+// RegisterMigration adds a migration to the registry
+func RegisterMigration(m Migration) {
+	migrations[m.Kind] = append(migrations[m.Kind], m)
+	sort.Slice(migrations[m.Kind], func(i, j int) bool {
+		return migrations[m.Kind][i].From < migrations[m.Kind][j].From
+	})
+}
+
+// UpgradeResult describes what UpgradeDocument did
+type UpgradeResult struct {
+	Data        []byte // Current-version document
+	FromVersion int    // Version the document was stored with
+	Upgraded    bool   // Whether any migration ran
+}
+
+// UpgradeDocument migrates raw document bytes of the given kind to the current schema
+// version. It returns a *SchemaVersionError when the document is newer than supported.
+func UpgradeDocument(kind DocumentKind, data []byte) (UpgradeResult, error) {
+	current, ok := CurrentSchemaVersions[kind]
+	if !ok {
+		return UpgradeResult{}, fmt.Errorf("unknown document kind: %s", kind)
+	}
+
+	payload, version, err := splitDocument(kind, data)
+	if err != nil {
+		return UpgradeResult{}, err
+	}
+
+	if version > current {
+		return UpgradeResult{}, &SchemaVersionError{Kind: kind, Version: version, Current: current}
+	}
+	if version == current {
+		return UpgradeResult{Data: data, FromVersion: version}, nil
+	}
+
+	doc := payload
+	for v := version; v < current; v++ {
+		migration, found := findMigration(kind, v)
+		if !found {
+			return UpgradeResult{}, fmt.Errorf("no migration registered for %s from version %d", kind, v)
+		}
+		if doc, err = migration.Migrate(doc); err != nil {
+			return UpgradeResult{}, fmt.Errorf("failed to migrate %s from version %d: %w", kind, v, err)
+		}
+	}
+
+	upgraded, err := joinDocument(kind, doc, current)
+	if err != nil {
+		return UpgradeResult{}, err
+	}
+
+	return UpgradeResult{Data: upgraded, FromVersion: version, Upgraded: true}, nil
+}
+
+// EncodeDocument marshals v as a current-version document of the given kind
+func EncodeDocument(kind DocumentKind, v interface{}) ([]byte, error) {
+	current, ok := CurrentSchemaVersions[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown document kind: %s", kind)
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, enveloped := envelopeFields[kind]; enveloped {
+		var doc interface{}
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, err
+		}
+		return joinDocument(kind, doc, current)
+	}
+
+	// Splice the version in front of the struct's fields to keep their declared order
+	raw = bytes.TrimSpace(raw)
+	if len(raw) < 2 || raw[0] != '{' {
+		return nil, fmt.Errorf("%s document must be a JSON object", kind)
+	}
+
+	var doc bytes.Buffer
+	fmt.Fprintf(&doc, `{"%s":%d`, SchemaVersionField, current)
+	if len(bytes.TrimSpace(raw[1:len(raw)-1])) > 0 {
+		doc.WriteByte(',')
+	}
+	doc.Write(raw[1:])
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, doc.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
+
+// DecodeDocument unmarshals a current-version document of the given kind into v
+func DecodeDocument(kind DocumentKind, data []byte, v interface{}) error {
+	field, enveloped := envelopeFields[kind]
+	if !enveloped {
+		// The schema version field is simply ignored by the model structs
+		return json.Unmarshal(data, v)
+	}
+
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return err
+	}
+	payload, ok := envelope[field]
+	if !ok {
+		return fmt.Errorf("%s document is missing %q", kind, field)
+	}
+	return json.Unmarshal(payload, v)
+}
+
+// DocumentVersion returns the schema version stored in raw document bytes
+func DocumentVersion(kind DocumentKind, data []byte) (int, error) {
+	_, version, err := splitDocument(kind, data)
+	return version, err
+}
+
+// splitDocument separates the payload of a stored document from its schema version
+func splitDocument(kind DocumentKind, data []byte) (interface{}, int, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse %s document: %w", kind, err)
+	}
+
+	obj, isObject := doc.(map[string]interface{})
+	if !isObject {
+		// Unversioned non-object payloads predate schema versioning
+		return doc, legacySchemaVersion, nil
+	}
+
+	rawVersion, hasVersion := obj[SchemaVersionField]
+	if !hasVersion {
+		return doc, legacySchemaVersion, nil
+	}
+
+	number, ok := rawVersion.(float64)
+	if !ok || number < 1 || number != float64(int(number)) {
+		return nil, 0, fmt.Errorf("invalid %s in %s document: %v", SchemaVersionField, kind, rawVersion)
+	}
+	delete(obj, SchemaVersionField)
+
+	if field, enveloped := envelopeFields[kind]; enveloped {
+		return obj[field], int(number), nil
+	}
+	return obj, int(number), nil
+}
+
+// joinDocument attaches a schema version to a payload
+func joinDocument(kind DocumentKind, payload interface{}, version int) ([]byte, error) {
+	if field, enveloped := envelopeFields[kind]; enveloped {
+		return json.MarshalIndent(map[string]interface{}{
+			SchemaVersionField: version,
+			field:              payload,
+		}, "", "  ")
+	}
+
+	obj, ok := payload.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s document must be a JSON object", kind)
+	}
+	obj[SchemaVersionField] = version
+	return json.MarshalIndent(obj, "", "  ")
+}
+
+// findMigration returns the migration for kind starting at version from
+func findMigration(kind DocumentKind, from int) (Migration, bool) {
+	for _, m := range migrations[kind] {
+		if m.From == from {
+			return m, true
+		}
+	}
+	return Migration{}, false
+}
+
+// Built-in migrations, from the unversioned (v1) layout on
+func init() {
+	RegisterMigration(Migration{
+		Kind:        KindSession,
+		From:        1,
+		Description: "normalize legacy string message timestamps",
+		Migrate:     migrateSessionV1,
+	})
+	RegisterMigration(Migration{
+		Kind:        KindPreferences,
+		From:        1,
+		Description: "add schema version",
+		Migrate:     stampOnly,
+	})
+	RegisterMigration(Migration{
+		Kind:        KindPreferences,
+		From:        2,
+		Description: "drop the unused max_history_length; storage.retention limits sessions",
+		Migrate:     migratePreferencesV2,
+	})
+	RegisterMigration(Migration{
+		Kind:        KindMCPServers,
+		From:        1,
+		Description: "wrap server list in a versioned envelope",
+		Migrate:     stampOnly,
+	})
+	RegisterMigration(Migration{
+		Kind:        KindAgentConfig,
+		From:        1,
+		Description: "add schema version",
+		Migrate:     stampOnly,
+	})
+}
+
+// stampOnly is a migration whose only change is the new schema version
+func stampOnly(doc interface{}) (interface{}, error) {
+	return doc, nil
+}
+
+// migratePreferencesV2 removes max_history_length, which never limited anything
+func migratePreferencesV2(doc interface{}) (interface{}, error) {
+	prefs, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("preferences document must be a JSON object")
+	}
+	delete(prefs, "max_history_length")
+	return prefs, nil
+}
+
+// legacyTimestampFormats are message timestamp layouts used before timestamps were time.Time
+var legacyTimestampFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"Jan 2 15:04:05",
+	"Jan 2, 15:04",
+}
+
+// migrateSessionV1 converts message timestamps stored as free-form strings into RFC 3339
+func migrateSessionV1(doc interface{}) (interface{}, error) {
+	session, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("session document must be a JSON object")
+	}
+
+	fallback, _ := session["created_at"].(string)
+	if _, err := time.Parse(time.RFC3339Nano, fallback); err != nil {
+		fallback = time.Time{}.Format(time.RFC3339Nano)
+	}
+
+	messages, _ := session["messages"].([]interface{})
+	for _, item := range messages {
+		message, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		raw, isString := message["timestamp"].(string)
+		if !isString {
+			message["timestamp"] = fallback
+			continue
+		}
+
+		normalized := fallback
+		for _, layout := range legacyTimestampFormats {
+			if parsed, err := time.Parse(layout, raw); err == nil && parsed.Year() > 1 {
+				normalized = parsed.Format(time.RFC3339Nano)
+				break
+			}
+		}
+		message["timestamp"] = normalized
+	}
+
+	if messages == nil {
+		session["messages"] = []interface{}{}
+	}
+	return session, nil
+}
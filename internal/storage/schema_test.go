@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPreferencesMigrationDropsMaxHistoryLength(t *testing.T) {
+	stored := []byte(`{"schema_version": 2, "theme": "dark", "max_history_length": 100}`)
+
+	result, err := UpgradeDocument(KindPreferences, stored)
+	if err != nil {
+		t.Fatalf("UpgradeDocument: %v", err)
+	}
+	if !result.Upgraded || result.FromVersion != 2 {
+		t.Fatalf("got upgraded=%v from version %d, want an upgrade from 2", result.Upgraded, result.FromVersion)
+	}
+	if strings.Contains(string(result.Data), "max_history_length") {
+		t.Errorf("max_history_length kept: %s", result.Data)
+	}
+
+	var prefs AppPreferences
+	if err := DecodeDocument(KindPreferences, result.Data, &prefs); err != nil {
+		t.Fatalf("DecodeDocument: %v", err)
+	}
+	if prefs.Theme != "dark" {
+		t.Errorf("theme = %q, want the stored %q", prefs.Theme, "dark")
+	}
+}
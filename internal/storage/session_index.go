@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// sessionIndexFile caches session summaries so the session list opens without
+// reading every session file
+const sessionIndexFile = "session_index.json"
+
+// sessionIndexVersion is the layout version of the index file; other versions are rebuilt
+const sessionIndexVersion = 2
+
+// sessionIndexEntry is the summary of one session and the state of its file when the
+// summary was taken, which tells whether the cached summary is still current
+type sessionIndexEntry struct {
+	models.SessionSummary
+	FileSize    int64     `json:"file_size"`
+	FileModTime time.Time `json:"file_mod_time"`
+}
+
+// sessionIndexData is the content of the index file
+type sessionIndexData struct {
+	Version  int                 `json:"version"`
+	Sessions []sessionIndexEntry `json:"sessions"`
+}
+
+// matches reports whether the entry was taken from the file described by info
+func (e sessionIndexEntry) matches(info os.FileInfo) bool {
+	return e.FileSize == info.Size() && e.FileModTime.Equal(info.ModTime())
+}
+
+// ListSessionSummaries returns a page of session summaries from the session index
+func (fs *FileStorage) ListSessionSummaries(ctx context.Context, opts SessionListOptions) (SessionPage, error) {
+	if err := opts.validate(); err != nil {
+		return SessionPage{}, err
+	}
+
+	fs.indexMu.RLock()
+	summaries := make([]models.SessionSummary, 0, len(fs.index))
+	for _, entry := range fs.index {
+		summaries = append(summaries, entry.SessionSummary)
+	}
+	fs.indexMu.RUnlock()
+
+	page := pageSummaries(summaries, opts)
+	fs.logger.Debug("Listed session summaries", "count", len(page.Sessions), "total", page.Total, "sort_by", opts.SortBy)
+	return page, nil
+}
+
+// loadSessionIndex reads the cached index and brings it up to date with the session
+// files, reading only the sessions that changed since the index was written
+func (fs *FileStorage) loadSessionIndex() error {
+	cached := make(map[string]sessionIndexEntry)
+	if data, err := os.ReadFile(filepath.Join(fs.basePath, sessionIndexFile)); err == nil {
+		var stored sessionIndexData
+		if err := json.Unmarshal(data, &stored); err != nil || stored.Version != sessionIndexVersion {
+			fs.logger.Warn("Rebuilding unreadable session index", "error", err, "version", stored.Version)
+		} else {
+			for _, entry := range stored.Sessions {
+				cached[entry.ID] = entry
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		fs.logger.Warn("Failed to read session index", "error", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(fs.basePath, "sessions"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read sessions directory: %w", err)
+	}
+
+	// Entries refreshed during startup (journal compaction) are already current
+	fs.indexMu.RLock()
+	current := make(map[string]sessionIndexEntry, len(fs.index))
+	for id, entry := range fs.index {
+		current[id] = entry
+	}
+	fs.indexMu.RUnlock()
+
+	index := make(map[string]sessionIndexEntry, len(entries))
+	var stale []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") || isTempFile(entry.Name()) {
+			continue
+		}
+		sessionID := strings.TrimSuffix(entry.Name(), ".json")
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if e, ok := current[sessionID]; ok && e.matches(info) {
+			index[sessionID] = e
+		} else if e, ok := cached[sessionID]; ok && e.matches(info) {
+			index[sessionID] = e
+		} else {
+			stale = append(stale, sessionID)
+		}
+	}
+
+	for _, sessionID := range stale {
+		fs.journalMu.Lock()
+		session, err := fs.loadChatSession(context.Background(), sessionID)
+		fs.journalMu.Unlock()
+		if err != nil {
+			fs.logger.Warn("Failed to index session", "session_id", sessionID, "error", err)
+			continue
+		}
+
+		info, err := os.Stat(filepath.Join(fs.basePath, "sessions", sessionID+".json"))
+		if err != nil {
+			continue
+		}
+		summary := session.Summary()
+		summary.Size = info.Size()
+		index[sessionID] = sessionIndexEntry{
+			SessionSummary: summary,
+			FileSize:       info.Size(),
+			FileModTime:    info.ModTime(),
+		}
+	}
+
+	fs.indexMu.Lock()
+	fs.index = index
+	fs.indexDirty = fs.indexDirty || len(stale) > 0 || len(index) != len(cached)
+	fs.indexMu.Unlock()
+
+	if len(stale) > 0 {
+		fs.logger.Info("Updated session index", "sessions", len(index), "reindexed", len(stale))
+	}
+	return fs.saveSessionIndex()
+}
+
+// saveSessionIndex writes the index file if it changed since it was last written
+func (fs *FileStorage) saveSessionIndex() error {
+	fs.indexMu.Lock()
+	defer fs.indexMu.Unlock()
+
+	if !fs.indexDirty {
+		return nil
+	}
+
+	stored := sessionIndexData{Version: sessionIndexVersion, Sessions: make([]sessionIndexEntry, 0, len(fs.index))}
+	for _, entry := range fs.index {
+		stored.Sessions = append(stored.Sessions, entry)
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session index: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(fs.basePath, sessionIndexFile), data, filePerm); err != nil {
+		return fmt.Errorf("failed to write session index: %w", err)
+	}
+
+	fs.indexDirty = false
+	return nil
+}
+
+// indexSnapshot records the summary of a session whose file was just written
+func (fs *FileStorage) indexSnapshot(session models.ChatSession, path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	summary := session.Summary()
+	summary.Size = info.Size()
+
+	fs.indexMu.Lock()
+	defer fs.indexMu.Unlock()
+	fs.index[session.ID] = sessionIndexEntry{
+		SessionSummary: summary,
+		FileSize:       info.Size(),
+		FileModTime:    info.ModTime(),
+	}
+	fs.indexDirty = true
+}
+
+// indexAppend updates a session's summary after written bytes of messages were
+// journaled. The file state is left alone: the journal is folded into the file at the
+// next startup, which changes the file and refreshes the entry.
+func (fs *FileStorage) indexAppend(sessionID string, messageCount int, at time.Time, written int64) {
+	fs.indexMu.Lock()
+	defer fs.indexMu.Unlock()
+
+	entry, ok := fs.index[sessionID]
+	if !ok {
+		return
+	}
+	entry.MessageCount = messageCount
+	entry.UpdatedAt = at
+	entry.Size += written
+	fs.index[sessionID] = entry
+	fs.indexDirty = true
+}
+
+// unindexSession removes a deleted session from the index
+func (fs *FileStorage) unindexSession(sessionID string) {
+	fs.indexMu.Lock()
+	defer fs.indexMu.Unlock()
+
+	delete(fs.index, sessionID)
+	fs.indexDirty = true
+}
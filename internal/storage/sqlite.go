@@ -0,0 +1,1008 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+// DefaultSQLiteFile is the database file name used inside the storage directory
+const DefaultSQLiteFile = "ollamachat.db"
+
+// sqliteSchemaVersion is the table layout version stored in PRAGMA user_version
+const sqliteSchemaVersion = 4
+
+// singletonDocumentID is the row ID of documents that exist only once, such as preferences
+const singletonDocumentID = "default"
+
+// sqliteSchema creates the tables and indexes for schema version 1
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS sessions (
+		id             TEXT PRIMARY KEY,
+		name           TEXT NOT NULL,
+		created_at     INTEGER NOT NULL,
+		updated_at     INTEGER NOT NULL,
+		model          TEXT NOT NULL DEFAULT '',
+		provider       TEXT NOT NULL DEFAULT '',
+		max_messages   INTEGER NOT NULL DEFAULT 0,
+		temperature    REAL NOT NULL DEFAULT 0,
+		system_prompt  TEXT NOT NULL DEFAULT '',
+		persona_id     TEXT NOT NULL DEFAULT '',
+		manually_named INTEGER NOT NULL DEFAULT 0,
+		auto_titled    INTEGER NOT NULL DEFAULT 0,
+		message_count  INTEGER NOT NULL DEFAULT 0
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_sessions_updated_at ON sessions (updated_at DESC)`,
+	`CREATE INDEX IF NOT EXISTS idx_sessions_persona_id ON sessions (persona_id)`,
+	`CREATE TABLE IF NOT EXISTS messages (
+		session_id TEXT NOT NULL REFERENCES sessions (id) ON DELETE CASCADE,
+		seq        INTEGER NOT NULL,
+		sender     TEXT NOT NULL,
+		content    TEXT NOT NULL,
+		timestamp  INTEGER NOT NULL,
+		PRIMARY KEY (session_id, seq)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_messages_timestamp ON messages (timestamp)`,
+	`CREATE TABLE IF NOT EXISTS documents (
+		kind       TEXT NOT NULL,
+		id         TEXT NOT NULL,
+		name       TEXT NOT NULL DEFAULT '',
+		data       TEXT NOT NULL,
+		updated_at INTEGER NOT NULL,
+		PRIMARY KEY (kind, id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_documents_kind_name ON documents (kind, name COLLATE NOCASE)`,
+	`CREATE TABLE IF NOT EXISTS document_backups (
+		kind         TEXT NOT NULL,
+		id           TEXT NOT NULL,
+		version      INTEGER NOT NULL,
+		data         TEXT NOT NULL,
+		backed_up_at INTEGER NOT NULL
+	)`,
+}
+
+// sqliteSchemaV2 adds indexes for sorting the session list by creation time and name
+var sqliteSchemaV2 = []string{
+	`CREATE INDEX IF NOT EXISTS idx_sessions_created_at ON sessions (created_at DESC)`,
+	`CREATE INDEX IF NOT EXISTS idx_sessions_name ON sessions (name COLLATE NOCASE)`,
+}
+
+// sqliteSchemaV3 marks trashed sessions; rows with deleted_at set are in the trash
+var sqliteSchemaV3 = []string{
+	`ALTER TABLE sessions ADD COLUMN deleted_at INTEGER`,
+	`CREATE INDEX IF NOT EXISTS idx_sessions_deleted_at ON sessions (deleted_at)`,
+}
+
+// sqliteSessionSize computes size_bytes for the sessions row it is evaluated on,
+// matching estimateSize
+var sqliteSessionSize = fmt.Sprintf(`(%d + LENGTH(CAST(name AS BLOB)) + LENGTH(CAST(system_prompt AS BLOB)) +
+	(SELECT COALESCE(SUM(%d + LENGTH(CAST(sender AS BLOB)) + LENGTH(CAST(content AS BLOB))), 0)
+	FROM messages WHERE messages.session_id = sessions.id))`, sessionOverhead, messageOverhead)
+
+// sqliteSchemaV4 adds pinned sessions and the size of each session
+var sqliteSchemaV4 = []string{
+	`ALTER TABLE sessions ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE sessions ADD COLUMN size_bytes INTEGER NOT NULL DEFAULT 0`,
+	`UPDATE sessions SET size_bytes = ` + sqliteSessionSize,
+}
+
+// sqliteMigrations holds the statements that upgrade the schema to each version;
+// entry i moves the database from version i to version i+1
+var sqliteMigrations = [][]string{
+	sqliteSchema,
+	sqliteSchemaV2,
+	sqliteSchemaV3,
+	sqliteSchemaV4,
+}
+
+// SQLiteStorage implements the Storage interface using a SQLite database
+type SQLiteStorage struct {
+	db     *sql.DB
+	path   string
+	logger *logger.Logger
+}
+
+// NewSQLiteStorage opens (or creates) the SQLite database at path
+func NewSQLiteStorage(path string, logger *logger.Logger) (*SQLiteStorage, error) {
+	if path == "" {
+		path = filepath.Join("data", DefaultSQLiteFile)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	dsn := fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL", path)
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// A single connection serializes writers and avoids "database is locked" errors
+	db.SetMaxOpenConns(1)
+
+	ss := &SQLiteStorage{
+		db:     db,
+		path:   path,
+		logger: logger.WithComponent("sqlite-storage"),
+	}
+
+	if err := ss.migrate(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// SQLite creates its files with the umask, which usually lets other users read them
+	for _, file := range []string{path, path + "-wal", path + "-shm"} {
+		if err := os.Chmod(file, filePerm); err != nil && !os.IsNotExist(err) {
+			ss.logger.Warn("Failed to restrict database permissions", "path", file, "error", err)
+		}
+	}
+
+	ss.logger.Info("Initialized SQLite storage", "path", path)
+	return ss, nil
+}
+
+// migrate creates the database schema or verifies that it can be used by this build
+func (ss *SQLiteStorage) migrate(ctx context.Context) error {
+	var version int
+	if err := ss.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read database schema version: %w", err)
+	}
+
+	if version > sqliteSchemaVersion {
+		return fmt.Errorf("database schema version %d is newer than supported version %d: %w", version, sqliteSchemaVersion, ErrNewerSchema)
+	}
+	if version == sqliteSchemaVersion {
+		return nil
+	}
+
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin schema migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, step := range sqliteMigrations[version:] {
+		for _, stmt := range step {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("failed to create database schema: %w", err)
+			}
+		}
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", sqliteSchemaVersion)); err != nil {
+		return fmt.Errorf("failed to set database schema version: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit schema migration: %w", err)
+	}
+
+	ss.logger.Info("Migrated database schema", "from_version", version, "to_version", sqliteSchemaVersion)
+	return nil
+}
+
+// SaveChatSession saves a chat session and replaces its messages
+func (ss *SQLiteStorage) SaveChatSession(ctx context.Context, session models.ChatSession) error {
+	ss.logger.Info("Saving chat session", "session_id", session.ID, "message_count", len(session.Messages))
+
+	// Update the session timestamp
+	session.UpdatedAt = time.Now()
+
+	if err := ss.writeChatSession(ctx, session); err != nil {
+		ss.logger.Error("Failed to save session", "session_id", session.ID, "error", err)
+		return err
+	}
+
+	ss.logger.Info("Successfully saved chat session", "session_id", session.ID)
+	return nil
+}
+
+// ImportChatSession stores a session exactly as given, keeping its timestamps
+func (ss *SQLiteStorage) ImportChatSession(ctx context.Context, session models.ChatSession) error {
+	return ss.writeChatSession(ctx, session)
+}
+
+// writeChatSession upserts the session row and rewrites its messages in one transaction
+func (ss *SQLiteStorage) writeChatSession(ctx context.Context, session models.ChatSession) error {
+	if session.ID == "" {
+		return fmt.Errorf("session id cannot be empty")
+	}
+
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO sessions (id, name, created_at, updated_at, model, provider, max_messages,
+			temperature, system_prompt, persona_id, manually_named, auto_titled, pinned, message_count)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name,
+			created_at = excluded.created_at,
+			updated_at = excluded.updated_at,
+			model = excluded.model,
+			provider = excluded.provider,
+			max_messages = excluded.max_messages,
+			temperature = excluded.temperature,
+			system_prompt = excluded.system_prompt,
+			persona_id = excluded.persona_id,
+			manually_named = excluded.manually_named,
+			auto_titled = excluded.auto_titled,
+			pinned = excluded.pinned,
+			message_count = excluded.message_count,
+			deleted_at = NULL`,
+		session.ID, session.Name, toUnixNano(session.CreatedAt), toUnixNano(session.UpdatedAt),
+		session.Model, session.Provider, session.MaxMessages, session.Temperature,
+		session.SystemPrompt, session.PersonaID, session.ManuallyNamed, session.AutoTitled,
+		session.Pinned, len(session.Messages))
+	if err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM messages WHERE session_id = ?", session.ID); err != nil {
+		return fmt.Errorf("failed to clear session messages: %w", err)
+	}
+
+	insert, err := tx.PrepareContext(ctx, "INSERT INTO messages (session_id, seq, sender, content, timestamp) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare message insert: %w", err)
+	}
+	defer insert.Close()
+
+	for i, msg := range session.Messages {
+		if _, err := insert.ExecContext(ctx, session.ID, i, msg.Sender, msg.Content, toUnixNano(msg.Timestamp)); err != nil {
+			return fmt.Errorf("failed to write message %d: %w", i, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE sessions SET size_bytes = "+sqliteSessionSize+" WHERE id = ?", session.ID); err != nil {
+		return fmt.Errorf("failed to update session size: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit session: %w", err)
+	}
+	return nil
+}
+
+// AppendMessages replaces the session's messages from index from onward and updates
+// only the affected rows
+func (ss *SQLiteStorage) AppendMessages(ctx context.Context, sessionID string, from int, messages []models.ChatMessage) error {
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRowContext(ctx, "SELECT message_count FROM sessions WHERE id = ? AND deleted_at IS NULL", sessionID).Scan(&count)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read session: %w", err)
+	}
+	if from < 0 || from > count {
+		return fmt.Errorf("invalid message index %d for session with %d messages", from, count)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM messages WHERE session_id = ? AND seq >= ?", sessionID, from); err != nil {
+		return fmt.Errorf("failed to clear replaced messages: %w", err)
+	}
+	for i, msg := range messages {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO messages (session_id, seq, sender, content, timestamp) VALUES (?, ?, ?, ?, ?)",
+			sessionID, from+i, msg.Sender, msg.Content, toUnixNano(msg.Timestamp)); err != nil {
+			return fmt.Errorf("failed to write message %d: %w", from+i, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE sessions SET message_count = ?, updated_at = ?, size_bytes = "+sqliteSessionSize+" WHERE id = ?",
+		from+len(messages), time.Now().UnixNano(), sessionID); err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit messages: %w", err)
+	}
+	return nil
+}
+
+// sessionColumns lists the session columns in the order scanSession expects
+const sessionColumns = `id, name, created_at, updated_at, model, provider, max_messages,
+	temperature, system_prompt, persona_id, manually_named, auto_titled, pinned`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSession reads a session row selected with sessionColumns
+func scanSession(row rowScanner) (models.ChatSession, error) {
+	var session models.ChatSession
+	var createdAt, updatedAt int64
+	err := row.Scan(&session.ID, &session.Name, &createdAt, &updatedAt, &session.Model,
+		&session.Provider, &session.MaxMessages, &session.Temperature, &session.SystemPrompt,
+		&session.PersonaID, &session.ManuallyNamed, &session.AutoTitled, &session.Pinned)
+	if err != nil {
+		return models.ChatSession{}, err
+	}
+
+	session.CreatedAt = fromUnixNano(createdAt)
+	session.UpdatedAt = fromUnixNano(updatedAt)
+	session.Messages = []models.ChatMessage{}
+	return session, nil
+}
+
+// LoadChatSession loads a chat session and its messages
+func (ss *SQLiteStorage) LoadChatSession(ctx context.Context, sessionID string) (models.ChatSession, error) {
+	ss.logger.Info("Loading chat session", "session_id", sessionID)
+
+	row := ss.db.QueryRowContext(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE id = ? AND deleted_at IS NULL", sessionID)
+	session, err := scanSession(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ss.logger.Warn("Session not found", "session_id", sessionID)
+			return models.ChatSession{}, fmt.Errorf("session not found: %s", sessionID)
+		}
+		ss.logger.Error("Failed to read session", "session_id", sessionID, "error", err)
+		return models.ChatSession{}, fmt.Errorf("failed to read session: %w", err)
+	}
+
+	messages, err := ss.loadMessages(ctx, "WHERE session_id = ?", sessionID)
+	if err != nil {
+		ss.logger.Error("Failed to read session messages", "session_id", sessionID, "error", err)
+		return models.ChatSession{}, err
+	}
+	if msgs, ok := messages[sessionID]; ok {
+		session.Messages = msgs
+	}
+
+	ss.logger.Info("Successfully loaded chat session", "session_id", sessionID, "message_count", len(session.Messages))
+	return session, nil
+}
+
+// loadMessages reads messages matching the given WHERE clause, grouped by session ID
+func (ss *SQLiteStorage) loadMessages(ctx context.Context, where string, args ...interface{}) (map[string][]models.ChatMessage, error) {
+	rows, err := ss.db.QueryContext(ctx, "SELECT session_id, sender, content, timestamp FROM messages "+where+" ORDER BY session_id, seq", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := make(map[string][]models.ChatMessage)
+	for rows.Next() {
+		var sessionID string
+		var msg models.ChatMessage
+		var timestamp int64
+		if err := rows.Scan(&sessionID, &msg.Sender, &msg.Content, &timestamp); err != nil {
+			return nil, fmt.Errorf("failed to read message: %w", err)
+		}
+		msg.Timestamp = fromUnixNano(timestamp)
+		messages[sessionID] = append(messages[sessionID], msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read messages: %w", err)
+	}
+	return messages, nil
+}
+
+// ListChatSessions lists all chat sessions, most recently updated first
+func (ss *SQLiteStorage) ListChatSessions(ctx context.Context) ([]models.ChatSession, error) {
+	ss.logger.Info("Listing chat sessions")
+
+	rows, err := ss.db.QueryContext(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE deleted_at IS NULL ORDER BY updated_at DESC")
+	if err != nil {
+		ss.logger.Error("Failed to query sessions", "error", err)
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []models.ChatSession
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			ss.logger.Error("Failed to read session row", "error", err)
+			return nil, fmt.Errorf("failed to read session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read sessions: %w", err)
+	}
+
+	// Fetch every message in a single query instead of one query per session
+	messages, err := ss.loadMessages(ctx, "WHERE session_id IN (SELECT id FROM sessions WHERE deleted_at IS NULL)")
+	if err != nil {
+		ss.logger.Error("Failed to read messages", "error", err)
+		return nil, err
+	}
+	for i := range sessions {
+		if msgs, ok := messages[sessions[i].ID]; ok {
+			sessions[i].Messages = msgs
+		}
+	}
+
+	ss.logger.Info("Successfully listed chat sessions", "count", len(sessions))
+	return sessions, nil
+}
+
+// sqliteSessionOrders maps sort fields to ORDER BY clauses in their natural direction
+// and reversed; ties are broken by ID so pages never overlap
+var sqliteSessionOrders = map[SessionSortField][2]string{
+	SortByUpdated: {"updated_at DESC, id DESC", "updated_at ASC, id ASC"},
+	SortByCreated: {"created_at DESC, id DESC", "created_at ASC, id ASC"},
+	SortByName:    {"name COLLATE NOCASE ASC, id ASC", "name COLLATE NOCASE DESC, id DESC"},
+}
+
+// ListSessionSummaries returns a page of session summaries without loading messages
+func (ss *SQLiteStorage) ListSessionSummaries(ctx context.Context, opts SessionListOptions) (SessionPage, error) {
+	if err := opts.validate(); err != nil {
+		return SessionPage{}, err
+	}
+
+	sortBy := opts.SortBy
+	if sortBy == "" {
+		sortBy = SortByUpdated
+	}
+	order := sqliteSessionOrders[sortBy][0]
+	if opts.Reverse {
+		order = sqliteSessionOrders[sortBy][1]
+	}
+	limit := -1
+	if opts.Limit > 0 {
+		limit = opts.Limit
+	}
+
+	page := SessionPage{Sessions: []models.SessionSummary{}}
+	if err := ss.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sessions WHERE deleted_at IS NULL").Scan(&page.Total); err != nil {
+		return SessionPage{}, fmt.Errorf("failed to count sessions: %w", err)
+	}
+
+	rows, err := ss.db.QueryContext(ctx, `
+		SELECT id, name, created_at, updated_at, model, provider, persona_id,
+			message_count, manually_named, auto_titled, pinned, size_bytes
+		FROM sessions WHERE deleted_at IS NULL ORDER BY `+order+` LIMIT ? OFFSET ?`, limit, opts.Offset)
+	if err != nil {
+		ss.logger.Error("Failed to query session summaries", "error", err)
+		return SessionPage{}, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var summary models.SessionSummary
+		var createdAt, updatedAt int64
+		if err := rows.Scan(&summary.ID, &summary.Name, &createdAt, &updatedAt, &summary.Model, &summary.Provider,
+			&summary.PersonaID, &summary.MessageCount, &summary.ManuallyNamed, &summary.AutoTitled,
+			&summary.Pinned, &summary.Size); err != nil {
+			return SessionPage{}, fmt.Errorf("failed to read session: %w", err)
+		}
+		summary.CreatedAt = fromUnixNano(createdAt)
+		summary.UpdatedAt = fromUnixNano(updatedAt)
+		page.Sessions = append(page.Sessions, summary)
+	}
+	if err := rows.Err(); err != nil {
+		return SessionPage{}, fmt.Errorf("failed to read sessions: %w", err)
+	}
+
+	ss.logger.Debug("Listed session summaries", "count", len(page.Sessions), "total", page.Total, "sort_by", sortBy)
+	return page, nil
+}
+
+// DeleteChatSession deletes a chat session and its messages
+func (ss *SQLiteStorage) DeleteChatSession(ctx context.Context, sessionID string) error {
+	ss.logger.Info("Deleting chat session", "session_id", sessionID)
+
+	result, err := ss.db.ExecContext(ctx, "DELETE FROM sessions WHERE id = ? AND deleted_at IS NULL", sessionID)
+	if err != nil {
+		ss.logger.Error("Failed to delete session", "session_id", sessionID, "error", err)
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		ss.logger.Warn("Session not found for deletion", "session_id", sessionID)
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	ss.logger.Info("Successfully deleted chat session", "session_id", sessionID)
+	return nil
+}
+
+// TrashChatSession marks a session as deleted, keeping its rows
+func (ss *SQLiteStorage) TrashChatSession(ctx context.Context, sessionID string) error {
+	ss.logger.Info("Moving chat session to trash", "session_id", sessionID)
+
+	result, err := ss.db.ExecContext(ctx, "UPDATE sessions SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL",
+		time.Now().UnixNano(), sessionID)
+	if err != nil {
+		ss.logger.Error("Failed to trash session", "session_id", sessionID, "error", err)
+		return fmt.Errorf("failed to trash session: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	return nil
+}
+
+// ListTrashedSessions lists trashed sessions, most recently deleted first
+func (ss *SQLiteStorage) ListTrashedSessions(ctx context.Context) ([]TrashedSession, error) {
+	rows, err := ss.db.QueryContext(ctx, `
+		SELECT id, name, created_at, updated_at, model, provider, persona_id,
+			message_count, manually_named, auto_titled, pinned, size_bytes, deleted_at
+		FROM sessions WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC`)
+	if err != nil {
+		ss.logger.Error("Failed to query trashed sessions", "error", err)
+		return nil, fmt.Errorf("failed to query trash: %w", err)
+	}
+	defer rows.Close()
+
+	trashed := []TrashedSession{}
+	for rows.Next() {
+		var item TrashedSession
+		var createdAt, updatedAt, deletedAt int64
+		if err := rows.Scan(&item.ID, &item.Name, &createdAt, &updatedAt, &item.Model, &item.Provider,
+			&item.PersonaID, &item.MessageCount, &item.ManuallyNamed, &item.AutoTitled,
+			&item.Pinned, &item.Size, &deletedAt); err != nil {
+			return nil, fmt.Errorf("failed to read trashed session: %w", err)
+		}
+		item.CreatedAt = fromUnixNano(createdAt)
+		item.UpdatedAt = fromUnixNano(updatedAt)
+		item.DeletedAt = fromUnixNano(deletedAt)
+		trashed = append(trashed, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read trash: %w", err)
+	}
+	return trashed, nil
+}
+
+// RestoreChatSession moves a session out of the trash
+func (ss *SQLiteStorage) RestoreChatSession(ctx context.Context, sessionID string) error {
+	ss.logger.Info("Restoring chat session from trash", "session_id", sessionID)
+
+	result, err := ss.db.ExecContext(ctx, "UPDATE sessions SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", sessionID)
+	if err != nil {
+		ss.logger.Error("Failed to restore session", "session_id", sessionID, "error", err)
+		return fmt.Errorf("failed to restore session: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("session not in trash: %s", sessionID)
+	}
+	return nil
+}
+
+// PurgeTrashedSession permanently deletes a trashed session and its messages
+func (ss *SQLiteStorage) PurgeTrashedSession(ctx context.Context, sessionID string) error {
+	result, err := ss.db.ExecContext(ctx, "DELETE FROM sessions WHERE id = ? AND deleted_at IS NOT NULL", sessionID)
+	if err != nil {
+		ss.logger.Error("Failed to purge session", "session_id", sessionID, "error", err)
+		return fmt.Errorf("failed to purge session: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("session not in trash: %s", sessionID)
+	}
+	return nil
+}
+
+// PurgeTrash permanently deletes sessions trashed before deletedBefore
+func (ss *SQLiteStorage) PurgeTrash(ctx context.Context, deletedBefore time.Time) (int, error) {
+	result, err := ss.db.ExecContext(ctx, "DELETE FROM sessions WHERE deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore.UnixNano())
+	if err != nil {
+		ss.logger.Error("Failed to purge trash", "error", err)
+		return 0, fmt.Errorf("failed to purge trash: %w", err)
+	}
+	purged, _ := result.RowsAffected()
+	return int(purged), nil
+}
+
+// SavePersona saves a persona
+func (ss *SQLiteStorage) SavePersona(ctx context.Context, persona models.Persona) error {
+	ss.logger.Info("Saving persona", "persona_id", persona.ID, "name", persona.Name)
+
+	if err := persona.Validate(); err != nil {
+		return fmt.Errorf("invalid persona: %w", err)
+	}
+
+	persona.UpdatedAt = time.Now()
+	if err := ss.saveDocument(ctx, KindPersona, persona.ID, persona.Name, persona); err != nil {
+		ss.logger.Error("Failed to save persona", "persona_id", persona.ID, "error", err)
+		return fmt.Errorf("failed to save persona: %w", err)
+	}
+
+	ss.logger.Info("Successfully saved persona", "persona_id", persona.ID)
+	return nil
+}
+
+// LoadPersona loads a persona
+func (ss *SQLiteStorage) LoadPersona(ctx context.Context, personaID string) (models.Persona, error) {
+	var persona models.Persona
+	found, err := ss.loadDocument(ctx, KindPersona, personaID, &persona)
+	if err != nil {
+		ss.logger.Error("Failed to load persona", "persona_id", personaID, "error", err)
+		return models.Persona{}, fmt.Errorf("failed to decode persona: %w", err)
+	}
+	if !found {
+		ss.logger.Warn("Persona not found", "persona_id", personaID)
+		return models.Persona{}, fmt.Errorf("persona not found: %s", personaID)
+	}
+	return persona, nil
+}
+
+// ListPersonas lists all stored personas sorted by name
+func (ss *SQLiteStorage) ListPersonas(ctx context.Context) ([]models.Persona, error) {
+	personas := []models.Persona{}
+
+	ids, err := ss.listDocumentIDs(ctx, KindPersona)
+	if err != nil {
+		ss.logger.Error("Failed to list personas", "error", err)
+		return personas, err
+	}
+
+	for _, id := range ids {
+		persona, err := ss.LoadPersona(ctx, id)
+		if err != nil {
+			ss.logger.Warn("Failed to load persona", "persona_id", id, "error", err)
+			continue
+		}
+		personas = append(personas, persona)
+	}
+
+	ss.logger.Info("Successfully listed personas", "count", len(personas))
+	return personas, nil
+}
+
+// DeletePersona deletes a persona
+func (ss *SQLiteStorage) DeletePersona(ctx context.Context, personaID string) error {
+	ss.logger.Info("Deleting persona", "persona_id", personaID)
+
+	found, err := ss.deleteDocument(ctx, KindPersona, personaID)
+	if err != nil {
+		ss.logger.Error("Failed to delete persona", "persona_id", personaID, "error", err)
+		return fmt.Errorf("failed to delete persona: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("persona not found: %s", personaID)
+	}
+
+	ss.logger.Info("Successfully deleted persona", "persona_id", personaID)
+	return nil
+}
+
+// SavePromptTemplate saves a prompt template
+func (ss *SQLiteStorage) SavePromptTemplate(ctx context.Context, template models.PromptTemplate) error {
+	ss.logger.Info("Saving prompt template", "template_id", template.ID, "name", template.Name)
+
+	if err := template.Validate(); err != nil {
+		return fmt.Errorf("invalid prompt template: %w", err)
+	}
+
+	template.UpdatedAt = time.Now()
+	if err := ss.saveDocument(ctx, KindTemplate, template.ID, template.Name, template); err != nil {
+		ss.logger.Error("Failed to save prompt template", "template_id", template.ID, "error", err)
+		return fmt.Errorf("failed to save prompt template: %w", err)
+	}
+
+	ss.logger.Info("Successfully saved prompt template", "template_id", template.ID)
+	return nil
+}
+
+// LoadPromptTemplate loads a prompt template
+func (ss *SQLiteStorage) LoadPromptTemplate(ctx context.Context, templateID string) (models.PromptTemplate, error) {
+	var template models.PromptTemplate
+	found, err := ss.loadDocument(ctx, KindTemplate, templateID, &template)
+	if err != nil {
+		ss.logger.Error("Failed to load prompt template", "template_id", templateID, "error", err)
+		return models.PromptTemplate{}, fmt.Errorf("failed to decode prompt template: %w", err)
+	}
+	if !found {
+		ss.logger.Warn("Prompt template not found", "template_id", templateID)
+		return models.PromptTemplate{}, fmt.Errorf("prompt template not found: %s", templateID)
+	}
+	return template, nil
+}
+
+// ListPromptTemplates lists all stored prompt templates sorted by name
+func (ss *SQLiteStorage) ListPromptTemplates(ctx context.Context) ([]models.PromptTemplate, error) {
+	templates := []models.PromptTemplate{}
+
+	ids, err := ss.listDocumentIDs(ctx, KindTemplate)
+	if err != nil {
+		ss.logger.Error("Failed to list prompt templates", "error", err)
+		return templates, err
+	}
+
+	for _, id := range ids {
+		template, err := ss.LoadPromptTemplate(ctx, id)
+		if err != nil {
+			ss.logger.Warn("Failed to load prompt template", "template_id", id, "error", err)
+			continue
+		}
+		templates = append(templates, template)
+	}
+
+	ss.logger.Info("Successfully listed prompt templates", "count", len(templates))
+	return templates, nil
+}
+
+// DeletePromptTemplate deletes a prompt template
+func (ss *SQLiteStorage) DeletePromptTemplate(ctx context.Context, templateID string) error {
+	ss.logger.Info("Deleting prompt template", "template_id", templateID)
+
+	found, err := ss.deleteDocument(ctx, KindTemplate, templateID)
+	if err != nil {
+		ss.logger.Error("Failed to delete prompt template", "template_id", templateID, "error", err)
+		return fmt.Errorf("failed to delete prompt template: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("prompt template not found: %s", templateID)
+	}
+
+	ss.logger.Info("Successfully deleted prompt template", "template_id", templateID)
+	return nil
+}
+
+// SaveAppPreferences saves application preferences
+func (ss *SQLiteStorage) SaveAppPreferences(ctx context.Context, prefs AppPreferences) error {
+	ss.logger.Info("Saving application preferences")
+
+	if err := ss.saveDocument(ctx, KindPreferences, singletonDocumentID, "", prefs); err != nil {
+		ss.logger.Error("Failed to save preferences", "error", err)
+		return fmt.Errorf("failed to save preferences: %w", err)
+	}
+
+	ss.logger.Info("Successfully saved application preferences")
+	return nil
+}
+
+// LoadAppPreferences loads application preferences
+func (ss *SQLiteStorage) LoadAppPreferences(ctx context.Context) (AppPreferences, error) {
+	ss.logger.Info("Loading application preferences")
+
+	var prefs AppPreferences
+	found, err := ss.loadDocument(ctx, KindPreferences, singletonDocumentID, &prefs)
+	if err != nil {
+		ss.logger.Error("Failed to decode preferences", "error", err)
+		return AppPreferences{}, fmt.Errorf("failed to decode preferences: %w", err)
+	}
+	if !found {
+		ss.logger.Info("Preferences not found, returning defaults")
+		return NewDefaultAppPreferences(), nil
+	}
+
+	ss.logger.Info("Successfully loaded application preferences")
+	return prefs, nil
+}
+
+// SaveMCPServers saves MCP server configurations
+func (ss *SQLiteStorage) SaveMCPServers(ctx context.Context, servers []models.MCPServer) error {
+	ss.logger.Info("Saving MCP servers", "count", len(servers))
+
+	if err := ss.saveDocument(ctx, KindMCPServers, singletonDocumentID, "", servers); err != nil {
+		ss.logger.Error("Failed to save MCP servers", "error", err)
+		return fmt.Errorf("failed to save MCP servers: %w", err)
+	}
+
+	ss.logger.Info("Successfully saved MCP servers")
+	return nil
+}
+
+// LoadMCPServers loads MCP server configurations
+func (ss *SQLiteStorage) LoadMCPServers(ctx context.Context) ([]models.MCPServer, error) {
+	ss.logger.Info("Loading MCP servers")
+
+	var servers []models.MCPServer
+	found, err := ss.loadDocument(ctx, KindMCPServers, singletonDocumentID, &servers)
+	if err != nil {
+		ss.logger.Error("Failed to decode MCP servers", "error", err)
+		return nil, fmt.Errorf("failed to decode MCP servers: %w", err)
+	}
+	if !found {
+		ss.logger.Info("MCP servers not found, returning empty list")
+		return []models.MCPServer{}, nil
+	}
+
+	ss.logger.Info("Successfully loaded MCP servers", "count", len(servers))
+	return servers, nil
+}
+
+// SaveAgentConfig saves agent configuration
+func (ss *SQLiteStorage) SaveAgentConfig(ctx context.Context, config models.AgentConfig) error {
+	ss.logger.Info("Saving agent configuration")
+
+	if err := ss.saveDocument(ctx, KindAgentConfig, singletonDocumentID, "", config); err != nil {
+		ss.logger.Error("Failed to save agent config", "error", err)
+		return fmt.Errorf("failed to save agent config: %w", err)
+	}
+
+	ss.logger.Info("Successfully saved agent configuration")
+	return nil
+}
+
+// LoadAgentConfig loads agent configuration
+func (ss *SQLiteStorage) LoadAgentConfig(ctx context.Context) (models.AgentConfig, error) {
+	ss.logger.Info("Loading agent configuration")
+
+	var config models.AgentConfig
+	found, err := ss.loadDocument(ctx, KindAgentConfig, singletonDocumentID, &config)
+	if err != nil {
+		ss.logger.Error("Failed to decode agent config", "error", err)
+		return models.AgentConfig{}, fmt.Errorf("failed to decode agent config: %w", err)
+	}
+	if !found {
+		ss.logger.Info("Agent config not found, returning defaults")
+		return NewDefaultAgentConfig(), nil
+	}
+
+	ss.logger.Info("Successfully loaded agent configuration")
+	return config, nil
+}
+
+// Close closes the database
+func (ss *SQLiteStorage) Close() error {
+	ss.logger.Info("Closing SQLite storage")
+	return ss.db.Close()
+}
+
+// Ping checks if the database is accessible
+func (ss *SQLiteStorage) Ping(ctx context.Context) error {
+	if err := ss.db.PingContext(ctx); err != nil {
+		ss.logger.Error("Storage ping failed", "error", err)
+		return fmt.Errorf("storage ping failed: %w", err)
+	}
+	return nil
+}
+
+// Vacuum rebuilds the database file and empties the write-ahead log, so freed pages
+// no longer hold the previous contents of rewritten sessions
+func (ss *SQLiteStorage) Vacuum(ctx context.Context) error {
+	if _, err := ss.db.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	if _, err := ss.db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("failed to checkpoint database: %w", err)
+	}
+	return nil
+}
+
+// saveDocument encodes v as a versioned document and upserts it, refusing to replace
+// a document written by a newer version of the application
+func (ss *SQLiteStorage) saveDocument(ctx context.Context, kind DocumentKind, id, name string, v interface{}) error {
+	data, err := EncodeDocument(kind, v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", kind, err)
+	}
+
+	var existing []byte
+	err = ss.db.QueryRowContext(ctx, "SELECT data FROM documents WHERE kind = ? AND id = ?", kind, id).Scan(&existing)
+	if err == nil {
+		version, err := DocumentVersion(kind, existing)
+		if err == nil && version > CurrentSchemaVersions[kind] {
+			return &SchemaVersionError{Kind: kind, Version: version, Current: CurrentSchemaVersions[kind]}
+		}
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to read existing %s: %w", kind, err)
+	}
+
+	_, err = ss.db.ExecContext(ctx, `
+		INSERT INTO documents (kind, id, name, data, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (kind, id) DO UPDATE SET
+			name = excluded.name,
+			data = excluded.data,
+			updated_at = excluded.updated_at`,
+		kind, id, name, string(data), time.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", kind, err)
+	}
+	return nil
+}
+
+// loadDocument reads a document, upgrading it to the current schema when needed.
+// The original is kept in document_backups before an upgraded copy is written back.
+func (ss *SQLiteStorage) loadDocument(ctx context.Context, kind DocumentKind, id string, v interface{}) (bool, error) {
+	var data []byte
+	err := ss.db.QueryRowContext(ctx, "SELECT data FROM documents WHERE kind = ? AND id = ?", kind, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", kind, err)
+	}
+
+	result, err := UpgradeDocument(kind, data)
+	if err != nil {
+		return true, err
+	}
+
+	if result.Upgraded {
+		if err := ss.replaceUpgradedDocument(ctx, kind, id, data, result); err != nil {
+			return true, err
+		}
+	}
+
+	return true, DecodeDocument(kind, result.Data, v)
+}
+
+// replaceUpgradedDocument backs up the original document and stores the upgraded one
+func (ss *SQLiteStorage) replaceUpgradedDocument(ctx context.Context, kind DocumentKind, id string, original []byte, result UpgradeResult) error {
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UnixNano()
+	if _, err := tx.ExecContext(ctx, "INSERT INTO document_backups (kind, id, version, data, backed_up_at) VALUES (?, ?, ?, ?, ?)",
+		kind, id, result.FromVersion, string(original), now); err != nil {
+		return fmt.Errorf("failed to back up %s before migration: %w", kind, err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE documents SET data = ?, updated_at = ? WHERE kind = ? AND id = ?",
+		string(result.Data), now, kind, id); err != nil {
+		return fmt.Errorf("failed to write migrated %s: %w", kind, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migrated %s: %w", kind, err)
+	}
+
+	ss.logger.Info("Migrated stored document",
+		"kind", kind,
+		"id", id,
+		"from_version", result.FromVersion,
+		"to_version", CurrentSchemaVersions[kind])
+	return nil
+}
+
+// listDocumentIDs returns the IDs of all documents of a kind, ordered by name
+func (ss *SQLiteStorage) listDocumentIDs(ctx context.Context, kind DocumentKind) ([]string, error) {
+	rows, err := ss.db.QueryContext(ctx, "SELECT id FROM documents WHERE kind = ? ORDER BY name COLLATE NOCASE, id", kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s documents: %w", kind, err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to read %s id: %w", kind, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// deleteDocument removes a document and reports whether it existed
+func (ss *SQLiteStorage) deleteDocument(ctx context.Context, kind DocumentKind, id string) (bool, error) {
+	result, err := ss.db.ExecContext(ctx, "DELETE FROM documents WHERE kind = ? AND id = ?", kind, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// toUnixNano converts a time to the integer representation stored in the database
+func toUnixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// fromUnixNano converts a stored integer timestamp back to a time
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
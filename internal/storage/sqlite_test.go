@@ -0,0 +1,148 @@
+package storage_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/storage"
+	"github.com/ashprao/ollamachat/internal/storage/storagetest"
+)
+
+// newSQLiteStorage opens a SQLite database in a fresh directory
+func newSQLiteStorage(t *testing.T, dir string) *storage.SQLiteStorage {
+	t.Helper()
+	s, err := storage.NewSQLiteStorage(filepath.Join(dir, storage.DefaultSQLiteFile), testLogger())
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	return s
+}
+
+func TestSQLiteStorageConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return newSQLiteStorage(t, t.TempDir())
+	})
+}
+
+func TestCopyFileStorageToSQLite(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	src := newFileStorage(t)
+	defer src.Close()
+
+	// Sessions keep their own timestamps through the copy, so the oldest is first
+	var sessions []models.ChatSession
+	for i, name := range []string{"Oldest", "Middle", "Newest"} {
+		session := models.NewChatSession(name, "llama3.2:latest")
+		session.CreatedAt = time.Date(2024, 1, i+1, 9, 0, 0, 0, time.UTC)
+		session.UpdatedAt = session.CreatedAt.Add(time.Hour)
+		session.Pinned = i == 1
+		session.Messages = []models.ChatMessage{
+			models.NewChatMessage("user", "question "+name),
+			models.NewChatMessage("llm", "answer "+name),
+		}
+		if err := src.ImportChatSession(ctx, session); err != nil {
+			t.Fatalf("ImportChatSession: %v", err)
+		}
+		sessions = append(sessions, session)
+	}
+	// Journaled messages are part of the session that is copied
+	extra := models.NewChatMessage("user", "follow-up")
+	if err := src.AppendMessages(ctx, sessions[2].ID, 2, []models.ChatMessage{extra}); err != nil {
+		t.Fatalf("AppendMessages: %v", err)
+	}
+
+	persona := models.NewPersona("Reviewer", "You review code.", 0.2)
+	if err := src.SavePersona(ctx, persona); err != nil {
+		t.Fatalf("SavePersona: %v", err)
+	}
+	template := models.NewPromptTemplate("Summary", "Summarize {{.Topic}}")
+	if err := src.SavePromptTemplate(ctx, template); err != nil {
+		t.Fatalf("SavePromptTemplate: %v", err)
+	}
+	prefs := storage.NewDefaultAppPreferences()
+	prefs.Theme = "dark"
+	prefs.FontSize = 17
+	prefs.DefaultModel = "qwen2.5:7b"
+	prefs.ShowTimestamps = true
+	if err := src.SaveAppPreferences(ctx, prefs); err != nil {
+		t.Fatalf("SaveAppPreferences: %v", err)
+	}
+
+	dst := newSQLiteStorage(t, dir)
+	defer dst.Close()
+
+	report, err := storage.CopyStorage(ctx, dst, src)
+	if err != nil {
+		t.Fatalf("CopyStorage: %v", err)
+	}
+	if report.Sessions != 3 || report.Messages != 7 || report.Personas != 1 || report.Templates != 1 || len(report.Skipped) != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	for _, want := range sessions {
+		got, err := dst.LoadChatSession(ctx, want.ID)
+		if err != nil {
+			t.Fatalf("LoadChatSession(%s): %v", want.Name, err)
+		}
+		if got.Name != want.Name || got.Pinned != want.Pinned || !got.CreatedAt.Equal(want.CreatedAt) {
+			t.Errorf("session %s: got %q pinned=%v created %v", want.Name, got.Name, got.Pinned, got.CreatedAt)
+		}
+		wantCount := len(want.Messages)
+		if want.ID == sessions[2].ID {
+			wantCount++
+		}
+		if len(got.Messages) != wantCount {
+			t.Errorf("session %s: got %d messages, want %d", want.Name, len(got.Messages), wantCount)
+		}
+	}
+
+	page, err := dst.ListSessionSummaries(ctx, storage.SessionListOptions{SortBy: storage.SortByCreated})
+	if err != nil {
+		t.Fatalf("ListSessionSummaries: %v", err)
+	}
+	if page.Total != 3 || page.Sessions[0].Name != "Newest" || page.Sessions[2].Name != "Oldest" {
+		t.Errorf("sessions out of order after copy: %+v", page.Sessions)
+	}
+
+	gotPersona, err := dst.LoadPersona(ctx, persona.ID)
+	if err != nil {
+		t.Fatalf("LoadPersona: %v", err)
+	}
+	if gotPersona.Name != persona.Name || gotPersona.SystemPrompt != persona.SystemPrompt || gotPersona.Temperature != persona.Temperature {
+		t.Errorf("persona mismatch: got %+v, want %+v", gotPersona, persona)
+	}
+
+	gotTemplate, err := dst.LoadPromptTemplate(ctx, template.ID)
+	if err != nil {
+		t.Fatalf("LoadPromptTemplate: %v", err)
+	}
+	if gotTemplate.Name != template.Name || gotTemplate.Body != template.Body {
+		t.Errorf("template mismatch: got %+v, want %+v", gotTemplate, template)
+	}
+
+	gotPrefs, err := dst.LoadAppPreferences(ctx)
+	if err != nil {
+		t.Fatalf("LoadAppPreferences: %v", err)
+	}
+	if !reflect.DeepEqual(gotPrefs, prefs) {
+		t.Errorf("preferences mismatch: got %+v, want %+v", gotPrefs, prefs)
+	}
+
+	// The database keeps the copy once reopened
+	dst.Close()
+	reopened := newSQLiteStorage(t, dir)
+	defer reopened.Close()
+	page, err = reopened.ListSessionSummaries(ctx, storage.SessionListOptions{})
+	if err != nil {
+		t.Fatalf("ListSessionSummaries after reopen: %v", err)
+	}
+	if page.Total != 3 {
+		t.Errorf("got %d sessions after reopening, want 3", page.Total)
+	}
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/ashprao/ollamachat/internal/constants"
 	"github.com/ashprao/ollamachat/internal/models"
@@ -13,8 +14,34 @@ type Storage interface {
 	SaveChatSession(ctx context.Context, session models.ChatSession) error
 	LoadChatSession(ctx context.Context, sessionID string) (models.ChatSession, error)
 	ListChatSessions(ctx context.Context) ([]models.ChatSession, error)
+	ListSessionSummaries(ctx context.Context, opts SessionListOptions) (SessionPage, error)
 	DeleteChatSession(ctx context.Context, sessionID string) error
 
+	// AppendMessages stores messages at index from onward, replacing any stored at or
+	// after it, without rewriting the rest of the session. It is used to persist new
+	// and streaming messages cheaply; from may not exceed the stored message count.
+	AppendMessages(ctx context.Context, sessionID string, from int, messages []models.ChatMessage) error
+
+	// Trash: deleted sessions are kept until restored or purged. Trashed sessions are
+	// left out of session loads and listings.
+	TrashChatSession(ctx context.Context, sessionID string) error
+	ListTrashedSessions(ctx context.Context) ([]TrashedSession, error)
+	RestoreChatSession(ctx context.Context, sessionID string) error
+	PurgeTrashedSession(ctx context.Context, sessionID string) error
+	PurgeTrash(ctx context.Context, deletedBefore time.Time) (int, error)
+
+	// Persona Library
+	SavePersona(ctx context.Context, persona models.Persona) error
+	LoadPersona(ctx context.Context, personaID string) (models.Persona, error)
+	ListPersonas(ctx context.Context) ([]models.Persona, error)
+	DeletePersona(ctx context.Context, personaID string) error
+
+	// Prompt Templates
+	SavePromptTemplate(ctx context.Context, template models.PromptTemplate) error
+	LoadPromptTemplate(ctx context.Context, templateID string) (models.PromptTemplate, error)
+	ListPromptTemplates(ctx context.Context) ([]models.PromptTemplate, error)
+	DeletePromptTemplate(ctx context.Context, templateID string) error
+
 	// Application Preferences
 	SaveAppPreferences(ctx context.Context, prefs AppPreferences) error
 	LoadAppPreferences(ctx context.Context) (AppPreferences, error)
@@ -35,11 +62,10 @@ type Storage interface {
 // AppPreferences holds user application preferences
 type AppPreferences struct {
 	// UI Preferences
-	WindowWidth      int    `json:"window_width"`
-	WindowHeight     int    `json:"window_height"`
-	Theme            string `json:"theme"` // "light", "dark", "auto"
-	FontSize         int    `json:"font_size"`
-	MaxHistoryLength int    `json:"max_history_length"`
+	WindowWidth  int    `json:"window_width"`
+	WindowHeight int    `json:"window_height"`
+	Theme        string `json:"theme"` // "light", "dark", "auto"
+	FontSize     int    `json:"font_size"`
 
 	// Chat Preferences
 	DefaultModel    string `json:"default_model"`
@@ -56,9 +82,45 @@ type AppPreferences struct {
 	LogLevel          string `json:"log_level"`
 }
 
+// TrashedSession is a deleted session waiting in the trash
+type TrashedSession struct {
+	models.SessionSummary
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// SessionSortField selects the order of session listings
+type SessionSortField string
+
+// Session listing orders
+const (
+	SortByUpdated SessionSortField = "updated_at" // Most recently updated first
+	SortByCreated SessionSortField = "created_at" // Most recently created first
+	SortByName    SessionSortField = "name"       // Alphabetical, ignoring case
+)
+
+// SessionListOptions controls the sorting and pagination of session summaries
+type SessionListOptions struct {
+	SortBy  SessionSortField // Defaults to SortByUpdated
+	Reverse bool             // Oldest first for time orders, Z to A for names
+	Offset  int
+	Limit   int // Zero lists every session after Offset
+}
+
+// SessionPage is one page of session summaries
+type SessionPage struct {
+	Sessions []models.SessionSummary
+	Total    int // Number of sessions across all pages
+}
+
+// IOStats counts writes made by a storage implementation
+type IOStats struct {
+	Writes       int64
+	BytesWritten int64
+}
+
 // StorageConfig holds configuration for storage implementations
 type StorageConfig struct {
-	Type     string                 `json:"type"`      // "file", "sqlite", "memory"
+	Type     string                 `json:"type"`      // "file", "sqlite", "memory", "sync", "s3"
 	BasePath string                 `json:"base_path"` // Base directory for file storage
 	Settings map[string]interface{} `json:"settings"`  // Implementation-specific settings
 }
@@ -67,11 +129,10 @@ type StorageConfig struct {
 func NewDefaultAppPreferences() AppPreferences {
 	return AppPreferences{
 		// UI Preferences
-		WindowWidth:      constants.DefaultWindowWidth,
-		WindowHeight:     constants.DefaultWindowHeight,
-		Theme:            "auto",
-		FontSize:         constants.DefaultFontSize,
-		MaxHistoryLength: 100,
+		WindowWidth:  constants.DefaultWindowWidth,
+		WindowHeight: constants.DefaultWindowHeight,
+		Theme:        "auto",
+		FontSize:     constants.DefaultFontSize,
 
 		// Chat Preferences
 		DefaultModel:    constants.DefaultModelName,
@@ -89,6 +150,21 @@ func NewDefaultAppPreferences() AppPreferences {
 	}
 }
 
+// NewDefaultAgentConfig creates the agent configuration used before one is saved
+func NewDefaultAgentConfig() models.AgentConfig {
+	return models.AgentConfig{
+		Enabled:            false,
+		DefaultFramework:   "eino",
+		MaxConcurrent:      1,
+		Timeout:            30,
+		EnableMCPTools:     false,
+		EnableBuiltinTools: true,
+		LogLevel:           "info",
+		EnableTracing:      false,
+		UpdatedAt:          time.Now(),
+	}
+}
+
 // StorageFactory creates storage implementations based on configuration
 type StorageFactory interface {
 	CreateStorage(config StorageConfig) (Storage, error)
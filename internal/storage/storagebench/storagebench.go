@@ -0,0 +1,140 @@
+// Package storagebench measures the I/O of persisting streamed answers with a
+// storage.Storage. It backs the bench-storage command and the storage benchmarks.
+package storagebench
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/storage"
+)
+
+// StreamingWorkload describes one simulated streamed answer added to an existing session
+type StreamingWorkload struct {
+	HistoryMessages int // Messages already in the session
+	MessageSize     int // Bytes per history message
+	Chunks          int // Chunks in the streamed answer
+	ChunkSize       int // Bytes per chunk
+	ChunksPerFlush  int // Chunks between batched writes, standing in for the flush timer
+}
+
+// DefaultStreamingWorkload is a long answer in a long conversation
+var DefaultStreamingWorkload = StreamingWorkload{
+	HistoryMessages: 200,
+	MessageSize:     500,
+	Chunks:          2000,
+	ChunkSize:       20,
+	ChunksPerFlush:  25,
+}
+
+// StreamingResult reports the cost of persisting a streamed answer
+type StreamingResult struct {
+	Strategy     string
+	Writes       int64 // Zero when the storage does not report I/O statistics
+	BytesWritten int64
+	Elapsed      time.Duration
+}
+
+// ioReporter is implemented by storages that count their writes
+type ioReporter interface {
+	IOStats() storage.IOStats
+}
+
+// MeasureRewritePerChunk persists the answer by saving the whole session after every
+// chunk, which is how streaming was persisted before the message journal
+func MeasureRewritePerChunk(ctx context.Context, s storage.Storage, w StreamingWorkload) (StreamingResult, error) {
+	session, err := seedSession(ctx, s, w)
+	if err != nil {
+		return StreamingResult{}, err
+	}
+
+	return measure(s, "rewrite-per-chunk", func() error {
+		session.Messages = append(session.Messages, models.NewChatMessage("llm", ""))
+		last := len(session.Messages) - 1
+		var content strings.Builder
+		for i := 0; i < w.Chunks; i++ {
+			content.WriteString(chunk(w.ChunkSize))
+			session.Messages[last].Content = content.String()
+			if err := s.SaveChatSession(ctx, session); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MeasureBatchedAppend persists the answer with AppendMessages every ChunksPerFlush
+// chunks and once more when the stream ends, as the chat UI does
+func MeasureBatchedAppend(ctx context.Context, s storage.Storage, w StreamingWorkload) (StreamingResult, error) {
+	session, err := seedSession(ctx, s, w)
+	if err != nil {
+		return StreamingResult{}, err
+	}
+
+	perFlush := w.ChunksPerFlush
+	if perFlush < 1 {
+		perFlush = 1
+	}
+
+	return measure(s, "batched-append", func() error {
+		index := len(session.Messages)
+		message := models.NewChatMessage("llm", "")
+		var content strings.Builder
+		for i := 1; i <= w.Chunks; i++ {
+			content.WriteString(chunk(w.ChunkSize))
+			if i%perFlush == 0 || i == w.Chunks {
+				message.Content = content.String()
+				if err := s.AppendMessages(ctx, session.ID, index, []models.ChatMessage{message}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// seedSession saves a session holding the workload's history
+func seedSession(ctx context.Context, s storage.Storage, w StreamingWorkload) (models.ChatSession, error) {
+	session := models.NewChatSession("Benchmark", "llama3.2:latest")
+	for i := 0; i < w.HistoryMessages; i++ {
+		sender := "user"
+		if i%2 == 1 {
+			sender = "llm"
+		}
+		session.Messages = append(session.Messages, models.NewChatMessage(sender, chunk(w.MessageSize)))
+	}
+	if err := s.SaveChatSession(ctx, session); err != nil {
+		return models.ChatSession{}, fmt.Errorf("failed to seed session: %w", err)
+	}
+	return session, nil
+}
+
+// measure runs fn and reports the writes it caused
+func measure(s storage.Storage, strategy string, fn func() error) (StreamingResult, error) {
+	var before storage.IOStats
+	reporter, reports := s.(ioReporter)
+	if reports {
+		before = reporter.IOStats()
+	}
+
+	start := time.Now()
+	if err := fn(); err != nil {
+		return StreamingResult{}, fmt.Errorf("%s: %w", strategy, err)
+	}
+	result := StreamingResult{Strategy: strategy, Elapsed: time.Since(start)}
+
+	if reports {
+		after := reporter.IOStats()
+		result.Writes = after.Writes - before.Writes
+		result.BytesWritten = after.BytesWritten - before.BytesWritten
+	}
+	return result, nil
+}
+
+// chunk returns n bytes of filler text
+func chunk(n int) string {
+	return strings.Repeat("lorem ", n/6+1)[:n]
+}
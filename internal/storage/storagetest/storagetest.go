@@ -0,0 +1,900 @@
+// Package storagetest implements a conformance suite for storage.Storage
+// implementations. A backend's tests call Run with a factory that returns a
+// fresh, empty storage:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Storage {
+//			return storage.NewMemoryStorage(logger.NewLogger(slog.LevelError))
+//		})
+//	}
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/storage"
+)
+
+// Factory creates a fresh, empty storage for a single test
+type Factory func(t *testing.T) storage.Storage
+
+// Run runs every conformance test against storages created by newStorage
+func Run(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Storage)
+	}{
+		{"SessionCRUD", testSessionCRUD},
+		{"SessionNotFound", testSessionNotFound},
+		{"SessionOrdering", testSessionOrdering},
+		{"SessionIsolation", testSessionIsolation},
+		{"SessionSummaries", testSessionSummaries},
+		{"SessionSummaryPaging", testSessionSummaryPaging},
+		{"AppendMessages", testAppendMessages},
+		{"AppendMessagesStreaming", testAppendMessagesStreaming},
+		{"Trash", testTrash},
+		{"PreferenceDefaults", testPreferenceDefaults},
+		{"PreferencesRoundTrip", testPreferencesRoundTrip},
+		{"MCPServers", testMCPServers},
+		{"AgentConfig", testAgentConfig},
+		{"Personas", testPersonas},
+		{"PromptTemplates", testPromptTemplates},
+		{"ConcurrentAccess", testConcurrentAccess},
+		{"Ping", testPing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStorage(t)
+			t.Cleanup(func() { s.Close() })
+			tt.fn(t, s)
+		})
+	}
+}
+
+// newSession builds a session with the given number of messages
+func newSession(name string, messageCount int) models.ChatSession {
+	session := models.NewChatSession(name, "llama3.2:latest")
+	session.Provider = "ollama"
+	session.MaxMessages = 10
+	session.Temperature = 0.7
+	session.SystemPrompt = "You are terse."
+	for i := 0; i < messageCount; i++ {
+		sender := "user"
+		if i%2 == 1 {
+			sender = "llm"
+		}
+		session.Messages = append(session.Messages, models.NewChatMessage(sender, fmt.Sprintf("message %d", i)))
+	}
+	return session
+}
+
+// assertSessionEqual compares the stored fields of two sessions, ignoring UpdatedAt
+func assertSessionEqual(t *testing.T, want, got models.ChatSession) {
+	t.Helper()
+
+	if got.ID != want.ID || got.Name != want.Name || got.Model != want.Model || got.Provider != want.Provider {
+		t.Fatalf("session identity mismatch: want %s/%q/%s/%s, got %s/%q/%s/%s",
+			want.ID, want.Name, want.Model, want.Provider, got.ID, got.Name, got.Model, got.Provider)
+	}
+	if got.MaxMessages != want.MaxMessages || got.Temperature != want.Temperature || got.SystemPrompt != want.SystemPrompt {
+		t.Fatalf("session settings mismatch: want %d/%v/%q, got %d/%v/%q",
+			want.MaxMessages, want.Temperature, want.SystemPrompt, got.MaxMessages, got.Temperature, got.SystemPrompt)
+	}
+	if got.PersonaID != want.PersonaID || got.ManuallyNamed != want.ManuallyNamed || got.AutoTitled != want.AutoTitled {
+		t.Fatalf("session naming state mismatch: want %q/%v/%v, got %q/%v/%v",
+			want.PersonaID, want.ManuallyNamed, want.AutoTitled, got.PersonaID, got.ManuallyNamed, got.AutoTitled)
+	}
+	if got.Pinned != want.Pinned {
+		t.Fatalf("pinned mismatch: want %v, got %v", want.Pinned, got.Pinned)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) {
+		t.Fatalf("created_at mismatch: want %v, got %v", want.CreatedAt, got.CreatedAt)
+	}
+	if len(got.Messages) != len(want.Messages) {
+		t.Fatalf("message count mismatch: want %d, got %d", len(want.Messages), len(got.Messages))
+	}
+	for i := range want.Messages {
+		w, g := want.Messages[i], got.Messages[i]
+		if g.Sender != w.Sender || g.Content != w.Content || !g.Timestamp.Equal(w.Timestamp) {
+			t.Fatalf("message %d mismatch: want %+v, got %+v", i, w, g)
+		}
+	}
+}
+
+func testSessionCRUD(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	session := newSession("First chat", 3)
+	session.PersonaID = models.NewID()
+	session.ManuallyNamed = true
+	if err := s.SaveChatSession(ctx, session); err != nil {
+		t.Fatalf("SaveChatSession: %v", err)
+	}
+
+	loaded, err := s.LoadChatSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession: %v", err)
+	}
+	assertSessionEqual(t, session, loaded)
+	if loaded.UpdatedAt.IsZero() {
+		t.Fatal("SaveChatSession should set UpdatedAt")
+	}
+
+	// Updating replaces messages and settings
+	session.Name = "Renamed"
+	session.Messages = append(session.Messages[:1], models.NewChatMessage("llm", "replacement"))
+	session.Temperature = 0.1
+	if err := s.SaveChatSession(ctx, session); err != nil {
+		t.Fatalf("SaveChatSession (update): %v", err)
+	}
+	loaded, err = s.LoadChatSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession (after update): %v", err)
+	}
+	assertSessionEqual(t, session, loaded)
+
+	// Empty sessions round-trip with no messages
+	empty := newSession("Empty", 0)
+	if err := s.SaveChatSession(ctx, empty); err != nil {
+		t.Fatalf("SaveChatSession (empty): %v", err)
+	}
+	loaded, err = s.LoadChatSession(ctx, empty.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession (empty): %v", err)
+	}
+	if len(loaded.Messages) != 0 {
+		t.Fatalf("expected no messages, got %d", len(loaded.Messages))
+	}
+
+	sessions, err := s.ListChatSessions(ctx)
+	if err != nil {
+		t.Fatalf("ListChatSessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(sessions))
+	}
+
+	if err := s.DeleteChatSession(ctx, session.ID); err != nil {
+		t.Fatalf("DeleteChatSession: %v", err)
+	}
+	if _, err := s.LoadChatSession(ctx, session.ID); err == nil {
+		t.Fatal("expected an error loading a deleted session")
+	}
+
+	sessions, err = s.ListChatSessions(ctx)
+	if err != nil {
+		t.Fatalf("ListChatSessions (after delete): %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != empty.ID {
+		t.Fatalf("expected only %s to remain, got %d sessions", empty.ID, len(sessions))
+	}
+}
+
+func testSessionNotFound(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	missing := models.NewID()
+
+	if _, err := s.LoadChatSession(ctx, missing); err == nil {
+		t.Fatal("expected an error loading a missing session")
+	}
+	if err := s.DeleteChatSession(ctx, missing); err == nil {
+		t.Fatal("expected an error deleting a missing session")
+	}
+
+	sessions, err := s.ListChatSessions(ctx)
+	if err != nil {
+		t.Fatalf("ListChatSessions on empty storage: %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Fatalf("expected no sessions, got %d", len(sessions))
+	}
+}
+
+func testSessionOrdering(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		session := newSession(fmt.Sprintf("Session %d", i), 1)
+		if err := s.SaveChatSession(ctx, session); err != nil {
+			t.Fatalf("SaveChatSession: %v", err)
+		}
+		ids = append(ids, session.ID)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	assertOrder := func(want ...string) {
+		t.Helper()
+		sessions, err := s.ListChatSessions(ctx)
+		if err != nil {
+			t.Fatalf("ListChatSessions: %v", err)
+		}
+		if len(sessions) != len(want) {
+			t.Fatalf("expected %d sessions, got %d", len(want), len(sessions))
+		}
+		for i, id := range want {
+			if sessions[i].ID != id {
+				t.Fatalf("position %d: want %s, got %s", i, id, sessions[i].ID)
+			}
+		}
+	}
+
+	// Most recently updated first
+	assertOrder(ids[2], ids[1], ids[0])
+
+	// Saving an older session again moves it to the top
+	oldest, err := s.LoadChatSession(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("LoadChatSession: %v", err)
+	}
+	oldest.Messages = append(oldest.Messages, models.NewChatMessage("llm", "reply"))
+	if err := s.SaveChatSession(ctx, oldest); err != nil {
+		t.Fatalf("SaveChatSession: %v", err)
+	}
+	assertOrder(ids[0], ids[2], ids[1])
+}
+
+func testSessionSummaries(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	page, err := s.ListSessionSummaries(ctx, storage.SessionListOptions{})
+	if err != nil {
+		t.Fatalf("ListSessionSummaries on empty storage: %v", err)
+	}
+	if page.Total != 0 || len(page.Sessions) != 0 {
+		t.Fatalf("expected no summaries, got %d of %d", len(page.Sessions), page.Total)
+	}
+
+	session := newSession("Summarized", 3)
+	session.PersonaID = "persona-1"
+	session.Pinned = true
+	session.Rename("Summarized")
+	if err := s.SaveChatSession(ctx, session); err != nil {
+		t.Fatalf("SaveChatSession: %v", err)
+	}
+
+	page, err = s.ListSessionSummaries(ctx, storage.SessionListOptions{})
+	if err != nil {
+		t.Fatalf("ListSessionSummaries: %v", err)
+	}
+	if page.Total != 1 || len(page.Sessions) != 1 {
+		t.Fatalf("expected 1 summary, got %d of %d", len(page.Sessions), page.Total)
+	}
+	got := page.Sessions[0]
+	if got.ID != session.ID || got.Name != session.Name || got.Model != session.Model || got.Provider != session.Provider ||
+		got.PersonaID != session.PersonaID || got.MessageCount != 3 || !got.ManuallyNamed || got.AutoTitled || !got.Pinned {
+		t.Fatalf("summary mismatch: %+v", got)
+	}
+	if got.Size <= 0 {
+		t.Fatalf("expected a positive session size, got %d", got.Size)
+	}
+	if !got.CreatedAt.Equal(session.CreatedAt) {
+		t.Fatalf("CreatedAt: want %v, got %v", session.CreatedAt, got.CreatedAt)
+	}
+
+	// Appended messages are reflected without a full save
+	before := got.UpdatedAt
+	time.Sleep(5 * time.Millisecond)
+	if err := s.AppendMessages(ctx, session.ID, 3, []models.ChatMessage{models.NewChatMessage("llm", "more")}); err != nil {
+		t.Fatalf("AppendMessages: %v", err)
+	}
+	page, err = s.ListSessionSummaries(ctx, storage.SessionListOptions{})
+	if err != nil {
+		t.Fatalf("ListSessionSummaries: %v", err)
+	}
+	if page.Sessions[0].MessageCount != 4 {
+		t.Fatalf("expected 4 messages after append, got %d", page.Sessions[0].MessageCount)
+	}
+	if !page.Sessions[0].UpdatedAt.After(before) {
+		t.Fatalf("expected UpdatedAt to advance after append")
+	}
+	if page.Sessions[0].Size <= got.Size {
+		t.Fatalf("expected the session size to grow after append: %d, then %d", got.Size, page.Sessions[0].Size)
+	}
+
+	// Deleted sessions disappear from the listing
+	if err := s.DeleteChatSession(ctx, session.ID); err != nil {
+		t.Fatalf("DeleteChatSession: %v", err)
+	}
+	page, err = s.ListSessionSummaries(ctx, storage.SessionListOptions{})
+	if err != nil {
+		t.Fatalf("ListSessionSummaries: %v", err)
+	}
+	if page.Total != 0 {
+		t.Fatalf("expected no summaries after delete, got %d", page.Total)
+	}
+
+	if _, err := s.ListSessionSummaries(ctx, storage.SessionListOptions{SortBy: "size"}); err == nil {
+		t.Fatalf("expected an error for an unknown sort field")
+	}
+}
+
+func testSessionSummaryPaging(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	// Saved in this order, so by update time the last one comes first
+	names := []string{"delta", "Alpha", "charlie", "Bravo", "echo"}
+	ids := make(map[string]string)
+	for _, name := range names {
+		session := newSession(name, 1)
+		if err := s.SaveChatSession(ctx, session); err != nil {
+			t.Fatalf("SaveChatSession: %v", err)
+		}
+		ids[name] = session.ID
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	collect := func(opts storage.SessionListOptions) []string {
+		t.Helper()
+		var got []string
+		for {
+			page, err := s.ListSessionSummaries(ctx, opts)
+			if err != nil {
+				t.Fatalf("ListSessionSummaries: %v", err)
+			}
+			if page.Total != len(names) {
+				t.Fatalf("expected total %d, got %d", len(names), page.Total)
+			}
+			if len(page.Sessions) == 0 {
+				return got
+			}
+			for _, summary := range page.Sessions {
+				got = append(got, summary.Name)
+			}
+			opts.Offset += len(page.Sessions)
+		}
+	}
+	assertNames := func(got []string, want ...string) {
+		t.Helper()
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("want %v, got %v", want, got)
+		}
+	}
+
+	assertNames(collect(storage.SessionListOptions{Limit: 2}), "echo", "Bravo", "charlie", "Alpha", "delta")
+	assertNames(collect(storage.SessionListOptions{Limit: 2, Reverse: true}), "delta", "Alpha", "charlie", "Bravo", "echo")
+	assertNames(collect(storage.SessionListOptions{SortBy: storage.SortByName, Limit: 3}), "Alpha", "Bravo", "charlie", "delta", "echo")
+	assertNames(collect(storage.SessionListOptions{SortBy: storage.SortByName, Reverse: true}), "echo", "delta", "charlie", "Bravo", "Alpha")
+
+	// Updating an old session moves it up by update time but not by creation time
+	session, err := s.LoadChatSession(ctx, ids["delta"])
+	if err != nil {
+		t.Fatalf("LoadChatSession: %v", err)
+	}
+	if err := s.SaveChatSession(ctx, session); err != nil {
+		t.Fatalf("SaveChatSession: %v", err)
+	}
+	assertNames(collect(storage.SessionListOptions{Limit: 4}), "delta", "echo", "Bravo", "charlie", "Alpha")
+	assertNames(collect(storage.SessionListOptions{SortBy: storage.SortByCreated, Limit: 4}), "echo", "Bravo", "charlie", "Alpha", "delta")
+
+	// A page past the end is empty but still reports the total
+	page, err := s.ListSessionSummaries(ctx, storage.SessionListOptions{Offset: 10, Limit: 5})
+	if err != nil {
+		t.Fatalf("ListSessionSummaries: %v", err)
+	}
+	if page.Total != len(names) || len(page.Sessions) != 0 {
+		t.Fatalf("expected an empty page of %d, got %d of %d", len(names), len(page.Sessions), page.Total)
+	}
+}
+
+func testSessionIsolation(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	session := newSession("Isolated", 2)
+	if err := s.SaveChatSession(ctx, session); err != nil {
+		t.Fatalf("SaveChatSession: %v", err)
+	}
+
+	// Mutating the caller's copy must not change what is stored
+	session.Messages[0].Content = "changed after save"
+	session.Name = "changed after save"
+
+	loaded, err := s.LoadChatSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession: %v", err)
+	}
+	if loaded.Name != "Isolated" || loaded.Messages[0].Content != "message 0" {
+		t.Fatal("stored session changed when the saved value was mutated")
+	}
+
+	// Mutating a loaded copy must not change what is stored either
+	loaded.Messages[1].Content = "changed after load"
+	reloaded, err := s.LoadChatSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession: %v", err)
+	}
+	if reloaded.Messages[1].Content != "message 1" {
+		t.Fatal("stored session changed when a loaded value was mutated")
+	}
+}
+
+func testAppendMessages(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	session := newSession("Appended", 2)
+	other := newSession("Other", 1)
+	for _, sess := range []models.ChatSession{session, other} {
+		if err := s.SaveChatSession(ctx, sess); err != nil {
+			t.Fatalf("SaveChatSession: %v", err)
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	reply := models.NewChatMessage("llm", "partial")
+	if err := s.AppendMessages(ctx, session.ID, 2, []models.ChatMessage{reply}); err != nil {
+		t.Fatalf("AppendMessages: %v", err)
+	}
+
+	// Writing at the same index replaces the last message, as streaming does
+	reply.Content = "partial answer, now complete"
+	if err := s.AppendMessages(ctx, session.ID, 2, []models.ChatMessage{reply}); err != nil {
+		t.Fatalf("AppendMessages (replace): %v", err)
+	}
+	session.Messages = append(session.Messages, reply)
+
+	loaded, err := s.LoadChatSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession: %v", err)
+	}
+	assertSessionEqual(t, session, loaded)
+
+	// Appending moves the session to the top of the list
+	sessions, err := s.ListChatSessions(ctx)
+	if err != nil {
+		t.Fatalf("ListChatSessions: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != session.ID {
+		t.Fatal("expected the appended session to be listed first")
+	}
+	if len(sessions[0].Messages) != 3 {
+		t.Fatalf("expected 3 listed messages, got %d", len(sessions[0].Messages))
+	}
+
+	if err := s.AppendMessages(ctx, session.ID, 10, []models.ChatMessage{reply}); err == nil {
+		t.Fatal("expected an error appending past the end of the session")
+	}
+	if err := s.AppendMessages(ctx, models.NewID(), 0, []models.ChatMessage{reply}); err == nil {
+		t.Fatal("expected an error appending to a missing session")
+	}
+
+	// A full save supersedes earlier appends
+	session.Messages = session.Messages[:1]
+	if err := s.SaveChatSession(ctx, session); err != nil {
+		t.Fatalf("SaveChatSession: %v", err)
+	}
+	loaded, err = s.LoadChatSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession: %v", err)
+	}
+	assertSessionEqual(t, session, loaded)
+
+	// Appends after a full save build on it
+	extra := []models.ChatMessage{models.NewChatMessage("user", "again"), models.NewChatMessage("llm", "sure")}
+	if err := s.AppendMessages(ctx, session.ID, 1, extra); err != nil {
+		t.Fatalf("AppendMessages: %v", err)
+	}
+	session.Messages = append(session.Messages, extra...)
+	loaded, err = s.LoadChatSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession: %v", err)
+	}
+	assertSessionEqual(t, session, loaded)
+}
+
+func testAppendMessagesStreaming(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	session := newSession("Streaming", 1)
+	if err := s.SaveChatSession(ctx, session); err != nil {
+		t.Fatalf("SaveChatSession: %v", err)
+	}
+
+	// Enough growing updates to push journal-based backends past compaction
+	reply := models.NewChatMessage("llm", "")
+	for i := 1; i <= 300; i++ {
+		reply.Content = strings.Repeat("x", i*10)
+		if err := s.AppendMessages(ctx, session.ID, 1, []models.ChatMessage{reply}); err != nil {
+			t.Fatalf("AppendMessages (update %d): %v", i, err)
+		}
+	}
+	session.Messages = append(session.Messages, reply)
+
+	loaded, err := s.LoadChatSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession: %v", err)
+	}
+	assertSessionEqual(t, session, loaded)
+}
+
+func testTrash(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	kept := newSession("Kept", 1)
+	first := newSession("First", 2)
+	second := newSession("Second", 3)
+	for _, sess := range []models.ChatSession{kept, first, second} {
+		if err := s.SaveChatSession(ctx, sess); err != nil {
+			t.Fatalf("SaveChatSession: %v", err)
+		}
+	}
+	// Journaled messages must move to the trash with their session
+	extra := models.NewChatMessage("user", "journaled")
+	if err := s.AppendMessages(ctx, second.ID, 3, []models.ChatMessage{extra}); err != nil {
+		t.Fatalf("AppendMessages: %v", err)
+	}
+	second.Messages = append(second.Messages, extra)
+
+	if err := s.TrashChatSession(ctx, models.NewID()); err == nil {
+		t.Fatal("expected an error trashing a missing session")
+	}
+	if err := s.TrashChatSession(ctx, first.ID); err != nil {
+		t.Fatalf("TrashChatSession: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	if err := s.TrashChatSession(ctx, second.ID); err != nil {
+		t.Fatalf("TrashChatSession: %v", err)
+	}
+
+	// Trashed sessions leave the live views
+	if _, err := s.LoadChatSession(ctx, first.ID); err == nil {
+		t.Fatal("expected an error loading a trashed session")
+	}
+	page, err := s.ListSessionSummaries(ctx, storage.SessionListOptions{})
+	if err != nil {
+		t.Fatalf("ListSessionSummaries: %v", err)
+	}
+	if page.Total != 1 || len(page.Sessions) != 1 || page.Sessions[0].ID != kept.ID {
+		t.Fatalf("expected only the kept session to be listed, got %+v", page)
+	}
+
+	trashed, err := s.ListTrashedSessions(ctx)
+	if err != nil {
+		t.Fatalf("ListTrashedSessions: %v", err)
+	}
+	if len(trashed) != 2 || trashed[0].ID != second.ID || trashed[1].ID != first.ID {
+		t.Fatalf("expected the trash newest first, got %+v", trashed)
+	}
+	if trashed[0].Name != "Second" || trashed[0].MessageCount != 4 || trashed[0].DeletedAt.IsZero() {
+		t.Fatalf("unexpected trashed summary: %+v", trashed[0])
+	}
+
+	// Restoring brings the whole session back
+	if err := s.RestoreChatSession(ctx, second.ID); err != nil {
+		t.Fatalf("RestoreChatSession: %v", err)
+	}
+	loaded, err := s.LoadChatSession(ctx, second.ID)
+	if err != nil {
+		t.Fatalf("LoadChatSession after restore: %v", err)
+	}
+	assertSessionEqual(t, second, loaded)
+	if err := s.RestoreChatSession(ctx, second.ID); err == nil {
+		t.Fatal("expected an error restoring a session not in the trash")
+	}
+
+	// Purging by cutoff only removes sessions trashed before it
+	if err := s.TrashChatSession(ctx, second.ID); err != nil {
+		t.Fatalf("TrashChatSession: %v", err)
+	}
+	purged, err := s.PurgeTrash(ctx, cutoff)
+	if err != nil {
+		t.Fatalf("PurgeTrash: %v", err)
+	}
+	if purged != 1 {
+		t.Fatalf("expected 1 purged session, got %d", purged)
+	}
+	if err := s.RestoreChatSession(ctx, first.ID); err == nil {
+		t.Fatal("expected an error restoring a purged session")
+	}
+
+	if err := s.PurgeTrashedSession(ctx, second.ID); err != nil {
+		t.Fatalf("PurgeTrashedSession: %v", err)
+	}
+	if trashed, err = s.ListTrashedSessions(ctx); err != nil || len(trashed) != 0 {
+		t.Fatalf("expected an empty trash, got %+v (%v)", trashed, err)
+	}
+
+	// Saving a trashed session again brings it back
+	if err := s.TrashChatSession(ctx, kept.ID); err != nil {
+		t.Fatalf("TrashChatSession: %v", err)
+	}
+	if err := s.SaveChatSession(ctx, kept); err != nil {
+		t.Fatalf("SaveChatSession: %v", err)
+	}
+	if _, err := s.LoadChatSession(ctx, kept.ID); err != nil {
+		t.Fatalf("LoadChatSession after saving a trashed session: %v", err)
+	}
+	if trashed, err = s.ListTrashedSessions(ctx); err != nil || len(trashed) != 0 {
+		t.Fatalf("expected saving to empty the trash, got %+v (%v)", trashed, err)
+	}
+}
+
+func testPreferenceDefaults(t *testing.T, s storage.Storage) {
+	prefs, err := s.LoadAppPreferences(context.Background())
+	if err != nil {
+		t.Fatalf("LoadAppPreferences: %v", err)
+	}
+	if want := storage.NewDefaultAppPreferences(); prefs != want {
+		t.Fatalf("expected default preferences %+v, got %+v", want, prefs)
+	}
+}
+
+func testPreferencesRoundTrip(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	prefs := storage.NewDefaultAppPreferences()
+	prefs.Theme = "dark"
+	prefs.FontSize = 16
+	prefs.DefaultModel = "mistral:latest"
+	prefs.EnableAgents = true
+	prefs.LogLevel = "debug"
+	if err := s.SaveAppPreferences(ctx, prefs); err != nil {
+		t.Fatalf("SaveAppPreferences: %v", err)
+	}
+
+	loaded, err := s.LoadAppPreferences(ctx)
+	if err != nil {
+		t.Fatalf("LoadAppPreferences: %v", err)
+	}
+	if loaded != prefs {
+		t.Fatalf("preferences mismatch: want %+v, got %+v", prefs, loaded)
+	}
+}
+
+func testMCPServers(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	servers, err := s.LoadMCPServers(ctx)
+	if err != nil {
+		t.Fatalf("LoadMCPServers on empty storage: %v", err)
+	}
+	if servers == nil || len(servers) != 0 {
+		t.Fatalf("expected an empty, non-nil server list, got %#v", servers)
+	}
+
+	want := []models.MCPServer{
+		{Name: "files", Command: "mcp-files", Args: []string{"--root", "/tmp"}, Env: map[string]string{"DEBUG": "1"}, Enabled: true},
+		{Name: "web", Command: "mcp-web", Args: []string{}, Env: map[string]string{}, Enabled: false},
+	}
+	if err := s.SaveMCPServers(ctx, want); err != nil {
+		t.Fatalf("SaveMCPServers: %v", err)
+	}
+
+	got, err := s.LoadMCPServers(ctx)
+	if err != nil {
+		t.Fatalf("LoadMCPServers: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("MCP servers mismatch: want %+v, got %+v", want, got)
+	}
+
+	// Saving an empty list clears the servers
+	if err := s.SaveMCPServers(ctx, []models.MCPServer{}); err != nil {
+		t.Fatalf("SaveMCPServers (empty): %v", err)
+	}
+	got, err = s.LoadMCPServers(ctx)
+	if err != nil {
+		t.Fatalf("LoadMCPServers (after clear): %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("expected no servers, got %d", len(got))
+	}
+}
+
+func testAgentConfig(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	defaults, err := s.LoadAgentConfig(ctx)
+	if err != nil {
+		t.Fatalf("LoadAgentConfig on empty storage: %v", err)
+	}
+	want := storage.NewDefaultAgentConfig()
+	defaults.UpdatedAt, want.UpdatedAt = time.Time{}, time.Time{}
+	if !reflect.DeepEqual(want, defaults) {
+		t.Fatalf("expected default agent config %+v, got %+v", want, defaults)
+	}
+
+	config := storage.NewDefaultAgentConfig()
+	config.Enabled = true
+	config.MaxConcurrent = 4
+	config.Timeout = 120
+	config.EnableMCPTools = true
+	config.EinoConfig = map[string]interface{}{"model": "llama3.2:latest"}
+	if err := s.SaveAgentConfig(ctx, config); err != nil {
+		t.Fatalf("SaveAgentConfig: %v", err)
+	}
+
+	loaded, err := s.LoadAgentConfig(ctx)
+	if err != nil {
+		t.Fatalf("LoadAgentConfig: %v", err)
+	}
+	if loaded.Enabled != config.Enabled || loaded.MaxConcurrent != config.MaxConcurrent ||
+		loaded.Timeout != config.Timeout || loaded.EnableMCPTools != config.EnableMCPTools ||
+		!loaded.UpdatedAt.Equal(config.UpdatedAt) || !reflect.DeepEqual(loaded.EinoConfig, config.EinoConfig) {
+		t.Fatalf("agent config mismatch: want %+v, got %+v", config, loaded)
+	}
+}
+
+func testPersonas(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	if err := s.SavePersona(ctx, models.Persona{}); err == nil {
+		t.Fatal("expected an error saving an invalid persona")
+	}
+
+	writer := models.NewPersona("writer", "You write clearly.", 0.9)
+	analyst := models.NewPersona("Analyst", "You analyse data.", 0.2)
+	for _, persona := range []models.Persona{writer, analyst} {
+		if err := s.SavePersona(ctx, persona); err != nil {
+			t.Fatalf("SavePersona: %v", err)
+		}
+	}
+
+	loaded, err := s.LoadPersona(ctx, writer.ID)
+	if err != nil {
+		t.Fatalf("LoadPersona: %v", err)
+	}
+	if loaded.Name != writer.Name || loaded.SystemPrompt != writer.SystemPrompt || loaded.Temperature != writer.Temperature {
+		t.Fatalf("persona mismatch: want %+v, got %+v", writer, loaded)
+	}
+
+	// Sorted by name, case-insensitively
+	personas, err := s.ListPersonas(ctx)
+	if err != nil {
+		t.Fatalf("ListPersonas: %v", err)
+	}
+	if len(personas) != 2 || personas[0].ID != analyst.ID || personas[1].ID != writer.ID {
+		t.Fatalf("unexpected persona order: %+v", personas)
+	}
+
+	if err := s.DeletePersona(ctx, writer.ID); err != nil {
+		t.Fatalf("DeletePersona: %v", err)
+	}
+	if _, err := s.LoadPersona(ctx, writer.ID); err == nil {
+		t.Fatal("expected an error loading a deleted persona")
+	}
+	if err := s.DeletePersona(ctx, writer.ID); err == nil {
+		t.Fatal("expected an error deleting a missing persona")
+	}
+}
+
+func testPromptTemplates(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	if err := s.SavePromptTemplate(ctx, models.PromptTemplate{}); err == nil {
+		t.Fatal("expected an error saving an invalid template")
+	}
+
+	review := models.NewPromptTemplate("review", "Review {{.Diff}}")
+	summary := models.NewPromptTemplate("Summary", "Summarize {{.Text}}")
+	for _, template := range []models.PromptTemplate{review, summary} {
+		if err := s.SavePromptTemplate(ctx, template); err != nil {
+			t.Fatalf("SavePromptTemplate: %v", err)
+		}
+	}
+
+	loaded, err := s.LoadPromptTemplate(ctx, review.ID)
+	if err != nil {
+		t.Fatalf("LoadPromptTemplate: %v", err)
+	}
+	if loaded.Name != review.Name || loaded.Body != review.Body {
+		t.Fatalf("template mismatch: want %+v, got %+v", review, loaded)
+	}
+
+	templates, err := s.ListPromptTemplates(ctx)
+	if err != nil {
+		t.Fatalf("ListPromptTemplates: %v", err)
+	}
+	if len(templates) != 2 || templates[0].ID != review.ID || templates[1].ID != summary.ID {
+		t.Fatalf("unexpected template order: %+v", templates)
+	}
+
+	if err := s.DeletePromptTemplate(ctx, review.ID); err != nil {
+		t.Fatalf("DeletePromptTemplate: %v", err)
+	}
+	if err := s.DeletePromptTemplate(ctx, review.ID); err == nil {
+		t.Fatal("expected an error deleting a missing template")
+	}
+}
+
+func testConcurrentAccess(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	const workers = 8
+	const updates = 10
+
+	sessions := make([]models.ChatSession, workers)
+	for i := range sessions {
+		sessions[i] = newSession(fmt.Sprintf("Worker %d", i), 0)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*updates*2)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(session models.ChatSession) {
+			defer wg.Done()
+			for n := 0; n < updates; n++ {
+				session.Messages = append(session.Messages, models.NewChatMessage("user", fmt.Sprintf("update %d", n)))
+				if err := s.SaveChatSession(ctx, session); err != nil {
+					errs <- fmt.Errorf("save %s: %w", session.ID, err)
+					return
+				}
+				if _, err := s.LoadChatSession(ctx, session.ID); err != nil {
+					errs <- fmt.Errorf("load %s: %w", session.ID, err)
+					return
+				}
+			}
+		}(sessions[i])
+	}
+
+	// Readers and settings writers run alongside the session writers
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for n := 0; n < updates; n++ {
+			if _, err := s.ListChatSessions(ctx); err != nil {
+				errs <- fmt.Errorf("list: %w", err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		prefs := storage.NewDefaultAppPreferences()
+		for n := 0; n < updates; n++ {
+			prefs.FontSize = 10 + n
+			if err := s.SaveAppPreferences(ctx, prefs); err != nil {
+				errs <- fmt.Errorf("save preferences: %w", err)
+				return
+			}
+			if _, err := s.LoadAppPreferences(ctx); err != nil {
+				errs <- fmt.Errorf("load preferences: %w", err)
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if t.Failed() {
+		return
+	}
+
+	for _, session := range sessions {
+		loaded, err := s.LoadChatSession(ctx, session.ID)
+		if err != nil {
+			t.Fatalf("LoadChatSession: %v", err)
+		}
+		if len(loaded.Messages) != updates {
+			t.Fatalf("session %s: expected %d messages, got %d", session.ID, updates, len(loaded.Messages))
+		}
+	}
+
+	listed, err := s.ListChatSessions(ctx)
+	if err != nil {
+		t.Fatalf("ListChatSessions: %v", err)
+	}
+	if len(listed) != workers {
+		t.Fatalf("expected %d sessions, got %d", workers, len(listed))
+	}
+}
+
+func testPing(t *testing.T, s storage.Storage) {
+	if err := s.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// validate checks the listing options
+func (o SessionListOptions) validate() error {
+	switch o.SortBy {
+	case "", SortByUpdated, SortByCreated, SortByName:
+	default:
+		return fmt.Errorf("unsupported session sort field: %s", o.SortBy)
+	}
+	if o.Offset < 0 || o.Limit < 0 {
+		return fmt.Errorf("invalid session page: offset %d, limit %d", o.Offset, o.Limit)
+	}
+	return nil
+}
+
+// summaryLess reports whether a sorts before b in the requested order. Ties are broken
+// by ID so pages never overlap or skip sessions.
+func summaryLess(a, b models.SessionSummary, opts SessionListOptions) bool {
+	// Reversing swaps the operands, which keeps the order strict
+	if opts.Reverse {
+		a, b = b, a
+	}
+	switch opts.SortBy {
+	case SortByName:
+		an, bn := strings.ToLower(a.Name), strings.ToLower(b.Name)
+		if an == bn {
+			return a.ID < b.ID
+		}
+		return an < bn
+	case SortByCreated:
+		if a.CreatedAt.Equal(b.CreatedAt) {
+			return a.ID > b.ID
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	default:
+		if a.UpdatedAt.Equal(b.UpdatedAt) {
+			return a.ID > b.ID
+		}
+		return a.UpdatedAt.After(b.UpdatedAt)
+	}
+}
+
+// pageSummaries sorts summaries in place and returns the requested page
+func pageSummaries(summaries []models.SessionSummary, opts SessionListOptions) SessionPage {
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaryLess(summaries[i], summaries[j], opts)
+	})
+
+	page := SessionPage{Total: len(summaries), Sessions: []models.SessionSummary{}}
+	if opts.Offset >= len(summaries) {
+		return page
+	}
+	end := len(summaries)
+	if opts.Limit > 0 && opts.Offset+opts.Limit < end {
+		end = opts.Offset + opts.Limit
+	}
+	page.Sessions = summaries[opts.Offset:end]
+	return page
+}
+
+// sortTrash orders trashed sessions most recently deleted first
+func sortTrash(trashed []TrashedSession) {
+	sort.Slice(trashed, func(i, j int) bool {
+		if trashed[i].DeletedAt.Equal(trashed[j].DeletedAt) {
+			return trashed[i].ID > trashed[j].ID
+		}
+		return trashed[i].DeletedAt.After(trashed[j].DeletedAt)
+	})
+}
@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+func TestSummaryLessIsStrict(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	a := models.SessionSummary{ID: models.NewIDAt(at), Name: "Same", CreatedAt: at, UpdatedAt: at}
+	b := models.SessionSummary{ID: models.NewIDAt(at), Name: "same", CreatedAt: at, UpdatedAt: at}
+
+	for _, sortBy := range []SessionSortField{SortByUpdated, SortByCreated, SortByName} {
+		for _, reverse := range []bool{false, true} {
+			opts := SessionListOptions{SortBy: sortBy, Reverse: reverse}
+			if summaryLess(a, a, opts) {
+				t.Errorf("%s reverse=%v: a session sorts before itself", sortBy, reverse)
+			}
+			if summaryLess(a, b, opts) == summaryLess(b, a, opts) {
+				t.Errorf("%s reverse=%v: sessions tied on every field are not ordered by ID", sortBy, reverse)
+			}
+		}
+	}
+}
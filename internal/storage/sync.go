@@ -0,0 +1,989 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+// SyncLogDir is the directory of a sync store holding one operation log per device
+const SyncLogDir = "oplog"
+
+// syncLogExt is the extension of device operation logs
+const syncLogExt = ".jsonl"
+
+// syncCompactMinOps is the number of operations in this device's log below which it is
+// never compacted. Above it, the log is rewritten at startup once more than half of its
+// operations have been superseded.
+const syncCompactMinOps = 256
+
+// SyncStorage stores everything as operations appended to a per-device log in a folder
+// kept in step across devices by an external sync tool. Each device only ever writes its
+// own log, so the sync tool never sees two devices change one file, and every device
+// merges all logs into the same state no matter in which order they arrive.
+type SyncStorage struct {
+	basePath string
+	device   string
+	logger   *logger.Logger
+	lock     *dirLock
+
+	mu       sync.Mutex // Guards everything below
+	state    *syncState
+	known    map[string]map[string]bool // Message keys handed to or written by callers, per session
+	logs     map[string]syncLogPos      // How far each log has been applied
+	ownOps   int                        // Operations in this device's log
+	closed   bool
+	subs     map[int]func(Change)
+	nextSub  int
+	watcher  *fsnotify.Watcher
+	watchEnd chan struct{}
+
+	writes atomic.Int64
+	bytes  atomic.Int64
+}
+
+// NewSyncStorage opens the sync store in basePath as device. An empty device uses
+// LocalDeviceID. All device logs are read and merged before it returns.
+func NewSyncStorage(basePath, device string, logger *logger.Logger) (*SyncStorage, error) {
+	if device == "" {
+		device = LocalDeviceID()
+	}
+	if !validDeviceID(device) {
+		return nil, fmt.Errorf("invalid sync device name %q: use letters, digits, '-' and '_'", device)
+	}
+	// Sealed values differ on every save, so each device would see every message change
+	if IsEncrypted(basePath) {
+		return nil, fmt.Errorf("sync storage does not support encryption: %s has a key file", basePath)
+	}
+
+	logDir := filepath.Join(basePath, SyncLogDir)
+	if err := os.MkdirAll(logDir, dirPerm); err != nil {
+		return nil, fmt.Errorf("failed to create sync log directory: %w", err)
+	}
+
+	// The lock lives beside this device's log so the sync tool never sees two devices write it
+	lock, err := acquireLockFile(filepath.Join(logDir, device+lockFileName), basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	ss := &SyncStorage{
+		basePath: basePath,
+		device:   device,
+		logger:   logger.WithComponent("sync-storage"),
+		lock:     lock,
+		state:    newSyncState(device),
+		known:    make(map[string]map[string]bool),
+		logs:     make(map[string]syncLogPos),
+		subs:     make(map[int]func(Change)),
+	}
+
+	if _, err := ss.readLogs(); err != nil {
+		lock.release()
+		return nil, err
+	}
+	ss.compact()
+
+	ss.logger.Info("Initialized sync storage", "base_path", basePath, "device", device,
+		"devices", len(ss.state.seen), "sessions", len(ss.state.sessions))
+	return ss, nil
+}
+
+// Device returns the name this store writes its operations under
+func (ss *SyncStorage) Device() string {
+	return ss.device
+}
+
+// logPath returns the operation log of a device
+func (ss *SyncStorage) logPath(device string) string {
+	return filepath.Join(ss.basePath, SyncLogDir, device+syncLogExt)
+}
+
+// readLogs applies the operations added to every device log since the last read and
+// returns the sessions and documents they touched. Logs are read up to their last
+// complete line, since a sync tool may still be writing the rest.
+func (ss *SyncStorage) readLogs() (syncTouched, error) {
+	touched := newSyncTouched()
+	entries, err := os.ReadDir(filepath.Join(ss.basePath, SyncLogDir))
+	if err != nil {
+		return touched, fmt.Errorf("failed to read sync log directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		// Conflict copies made by sync tools (such as "laptop.sync-conflict-….jsonl")
+		// are read too; operations are deduplicated by ID
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), syncLogExt) || isTempFile(entry.Name()) {
+			continue
+		}
+		if err := ss.readLog(filepath.Join(ss.basePath, SyncLogDir, entry.Name()), touched); err != nil {
+			return touched, err
+		}
+	}
+	return touched, nil
+}
+
+// syncLogPos is how far a log has been read
+type syncLogPos struct {
+	offset int64
+	last   []byte // The last line read, which ends at offset
+}
+
+// continuedBy reports whether file still holds the part of the log already read. A log
+// compacted by its device, then grown past the old offset, holds other lines there.
+// Operations are unique and compaction only drops lines, so finding the last line read
+// at the same place means nothing before it changed.
+func (pos syncLogPos) continuedBy(file *os.File, size int64) bool {
+	if pos.offset == 0 {
+		return true
+	}
+	if size < pos.offset {
+		return false
+	}
+	at := make([]byte, len(pos.last))
+	if _, err := file.ReadAt(at, pos.offset-int64(len(at))); err != nil {
+		return false
+	}
+	return bytes.Equal(at, pos.last)
+}
+
+// lastLine returns the last line of data, which ends with a newline
+func lastLine(data []byte) []byte {
+	start := bytes.LastIndexByte(data[:len(data)-1], '\n') + 1
+	return append([]byte(nil), data[start:]...)
+}
+
+// readLog applies the operations in one log from the last read offset onward
+func (ss *SyncStorage) readLog(path string, touched syncTouched) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			delete(ss.logs, path)
+			return nil
+		}
+		return fmt.Errorf("failed to open sync log: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat sync log: %w", err)
+	}
+	own := path == ss.logPath(ss.device)
+	pos := ss.logs[path]
+	if !pos.continuedBy(file, info.Size()) {
+		// The log was replaced. Operations already applied are skipped by ID, so it
+		// is read again from the start.
+		ss.logger.Info("Reading replaced sync log again", "log", filepath.Base(path))
+		pos = syncLogPos{}
+		if own {
+			ss.ownOps = 0
+		}
+	}
+	if _, err := file.Seek(pos.offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read sync log: %w", err)
+	}
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break // An incomplete last line is read again once it is finished
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read sync log: %w", err)
+		}
+		pos.offset += int64(len(line))
+		pos.last = line
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var op syncOp
+		if err := json.Unmarshal(line, &op); err != nil {
+			ss.logger.Warn("Skipping unreadable sync operation", "log", filepath.Base(path), "error", err)
+			continue
+		}
+		if op.V > syncOpVersion {
+			return &SchemaVersionError{Kind: "sync operation", Version: op.V, Current: syncOpVersion}
+		}
+		if own {
+			ss.ownOps++
+		}
+		ss.state.apply(&op, touched)
+	}
+	ss.logs[path] = pos
+	return nil
+}
+
+// write assigns sequence numbers and clocks to ops, appends them to this device's log
+// and applies them. The caller must hold mu.
+func (ss *SyncStorage) write(ops []*syncOp) error {
+	if ss.closed {
+		return errStorageClosed
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	var written []*syncOp
+	seq, clock := ss.state.seen[ss.device], ss.state.clock
+	now := time.Now()
+	for _, op := range ops {
+		seq++
+		clock++
+		op.V = syncOpVersion
+		if op.ID == "" {
+			op.ID = models.NewID()
+		}
+		op.Device, op.Seq, op.Clock = ss.device, seq, clock
+		if op.Time.IsZero() {
+			op.Time = now
+		}
+		line, err := json.Marshal(op)
+		if err != nil {
+			return fmt.Errorf("failed to marshal sync operation: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+
+		// Apply the operation as other devices will read it, so values compare equal
+		var decoded syncOp
+		if err := json.Unmarshal(line, &decoded); err != nil {
+			return fmt.Errorf("failed to decode sync operation: %w", err)
+		}
+		written = append(written, &decoded)
+	}
+
+	path := ss.logPath(ss.device)
+	size, err := ss.appendLog(path, buf.Bytes())
+	if err != nil {
+		ss.logger.Error("Failed to append to sync log", "error", err)
+		return fmt.Errorf("failed to append to sync log: %w", err)
+	}
+	ss.logs[path] = syncLogPos{offset: size, last: lastLine(buf.Bytes())}
+	ss.ownOps += len(ops)
+
+	touched := newSyncTouched()
+	for _, op := range written {
+		ss.state.apply(op, touched)
+	}
+	return nil
+}
+
+// appendLog appends data to a log, syncs it, and returns the new size
+func (ss *SyncStorage) appendLog(path string, data []byte) (int64, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, filePerm)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	// A crash can leave a torn final line; start on a fresh line so it stays isolated
+	if info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			data = append([]byte{'\n'}, data...)
+		}
+	}
+	if _, err := file.Write(data); err != nil {
+		return 0, err
+	}
+	ss.writes.Add(1)
+	ss.bytes.Add(int64(len(data)))
+	if err := file.Sync(); err != nil {
+		return 0, err
+	}
+	return info.Size() + int64(len(data)), nil
+}
+
+// compact rewrites this device's log without its superseded operations once most of
+// them are. Other devices' logs are left to their owners.
+func (ss *SyncStorage) compact() {
+	if ss.ownOps < syncCompactMinOps {
+		return
+	}
+	path := ss.logPath(ss.device)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		ss.logger.Warn("Skipping sync log compaction", "error", err)
+		return
+	}
+
+	var buf bytes.Buffer
+	kept := 0
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		var op syncOp
+		if len(bytes.TrimSpace(line)) == 0 || json.Unmarshal(line, &op) != nil {
+			continue
+		}
+		if ss.state.keepOwn(&op) {
+			buf.Write(line)
+			buf.WriteByte('\n')
+			kept++
+		}
+	}
+	if kept*2 > ss.ownOps {
+		return
+	}
+
+	if err := writeFileAtomic(path, buf.Bytes(), filePerm); err != nil {
+		// The log is intact; compaction is retried at the next start
+		ss.logger.Warn("Failed to compact sync log", "error", err)
+		return
+	}
+	ss.writes.Add(1)
+	ss.bytes.Add(int64(buf.Len()))
+	ss.logger.Info("Compacted sync log", "operations", ss.ownOps, "kept", kept)
+	ss.logs[path] = syncLogPos{}
+	if kept > 0 {
+		ss.logs[path] = syncLogPos{offset: int64(buf.Len()), last: lastLine(buf.Bytes())}
+	}
+	ss.ownOps = kept
+}
+
+// SaveChatSession records the changes between the stored session and this one
+func (ss *SyncStorage) SaveChatSession(ctx context.Context, session models.ChatSession) error {
+	session.UpdatedAt = time.Now()
+	return ss.ImportChatSession(ctx, session)
+}
+
+// ImportChatSession records a session exactly as given, keeping its timestamps
+func (ss *SyncStorage) ImportChatSession(ctx context.Context, session models.ChatSession) error {
+	if session.ID == "" {
+		return fmt.Errorf("session id cannot be empty")
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	s := ss.state.session(session.ID)
+	ops, err := s.fieldOps(session)
+	if err != nil {
+		return err
+	}
+	// Saving a trashed session brings it back
+	if s.exists() && s.status() != syncActive {
+		ops = append(ops, ss.stateOp(session.ID, syncActive))
+	}
+	messageOps, err := ss.messageOps(s, 0, session.Messages)
+	if err != nil {
+		return err
+	}
+	ops = append(ops, messageOps...)
+
+	if err := ss.write(ops); err != nil {
+		ss.logger.Error("Failed to save session", "session_id", session.ID, "error", err)
+		return err
+	}
+	ss.logger.Debug("Saved session", "session_id", session.ID, "operations", len(ops))
+	return nil
+}
+
+// LoadChatSession returns the merged state of a session
+func (ss *SyncStorage) LoadChatSession(ctx context.Context, sessionID string) (models.ChatSession, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	s, ok := ss.state.sessions[sessionID]
+	if !ok || !s.exists() || s.status() != syncActive {
+		return models.ChatSession{}, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	// The caller now knows every message, so later writes may replace them
+	known := make(map[string]bool)
+	for _, m := range s.visible() {
+		known[m.key] = true
+	}
+	ss.known[sessionID] = known
+	return s.view(), nil
+}
+
+// ListChatSessions lists all sessions, most recently updated first
+func (ss *SyncStorage) ListChatSessions(ctx context.Context) ([]models.ChatSession, error) {
+	ss.mu.Lock()
+	var sessions []models.ChatSession
+	for _, s := range ss.state.sessions {
+		if s.exists() && s.status() == syncActive {
+			sessions = append(sessions, s.view())
+		}
+	}
+	ss.mu.Unlock()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+// ListSessionSummaries returns a page of session summaries
+func (ss *SyncStorage) ListSessionSummaries(ctx context.Context, opts SessionListOptions) (SessionPage, error) {
+	if err := opts.validate(); err != nil {
+		return SessionPage{}, err
+	}
+
+	ss.mu.Lock()
+	summaries := make([]models.SessionSummary, 0, len(ss.state.sessions))
+	for _, s := range ss.state.sessions {
+		if s.exists() && s.status() == syncActive {
+			summaries = append(summaries, s.summary())
+		}
+	}
+	ss.mu.Unlock()
+
+	return pageSummaries(summaries, opts), nil
+}
+
+// AppendMessages records messages from index from onward. Indexes count the messages
+// the caller has loaded or written; messages merged in from other devices since then
+// are kept where they are.
+func (ss *SyncStorage) AppendMessages(ctx context.Context, sessionID string, from int, messages []models.ChatMessage) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	s, ok := ss.state.sessions[sessionID]
+	if !ok || !s.exists() || s.status() != syncActive {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	ops, err := ss.messageOps(s, from, messages)
+	if err != nil {
+		return err
+	}
+	updated, err := s.updatedOp(time.Now())
+	if err != nil {
+		return err
+	}
+	return ss.write(append(ops, updated))
+}
+
+// messageOps returns the operations that make the caller's messages from index from
+// onward equal to messages. A message at the same position with the same sender and
+// timestamp is updated in place; others are deleted and the new ones inserted.
+func (ss *SyncStorage) messageOps(s *syncSession, from int, messages []models.ChatMessage) ([]*syncOp, error) {
+	current := ss.knownMessages(s)
+	if from < 0 || from > len(current) {
+		return nil, fmt.Errorf("invalid message index %d for session with %d messages", from, len(current))
+	}
+
+	var ops []*syncOp
+	after := ""
+	if from > 0 {
+		after = current[from-1].key
+	}
+
+	i := 0
+	for ; i < len(messages) && from+i < len(current); i++ {
+		m := current[from+i]
+		stored := m.message()
+		if stored.Sender != messages[i].Sender || !stored.Timestamp.Equal(messages[i].Timestamp) {
+			break
+		}
+		if stored.Content != messages[i].Content {
+			value, err := json.Marshal(messages[i])
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal message: %w", err)
+			}
+			ops = append(ops, &syncOp{Type: opMessageSet, Session: s.id, Key: m.key, Prev: m.content.winner.ID, Value: value})
+		}
+		after = m.key
+	}
+	for _, m := range current[from+i:] {
+		ops = append(ops, &syncOp{Type: opMessageDelete, Session: s.id, Key: m.key, Prev: m.content.winner.ID})
+	}
+
+	known := ss.known[s.id]
+	if known == nil {
+		known = make(map[string]bool)
+		ss.known[s.id] = known
+	}
+	for _, message := range messages[i:] {
+		value, err := json.Marshal(message)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal message: %w", err)
+		}
+		op := &syncOp{ID: models.NewID(), Type: opMessageAdd, Session: s.id, After: after, Value: value}
+		ops = append(ops, op)
+		known[op.ID] = true
+		after = op.ID
+	}
+	return ops, nil
+}
+
+// knownMessages returns the visible messages of a session that the caller knows
+// about. A session the caller has never loaded or written is known in full.
+func (ss *SyncStorage) knownMessages(s *syncSession) []*syncMessage {
+	visible := s.visible()
+	known, ok := ss.known[s.id]
+	if !ok {
+		return visible
+	}
+	filtered := visible[:0:0]
+	for _, m := range visible {
+		if known[m.key] {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+// stateOp returns an operation moving a session to status. It records the operations
+// seen so far, so messages added elsewhere meanwhile can be told apart.
+func (ss *SyncStorage) stateOp(sessionID, status string) *syncOp {
+	value, _ := json.Marshal(syncSessionState{Status: status, DeletedAt: time.Now()})
+	seen := make(map[string]uint64, len(ss.state.seen))
+	for device, seq := range ss.state.seen {
+		seen[device] = seq
+	}
+	op := &syncOp{Type: opSessionState, Session: sessionID, Value: value, Seen: seen}
+	if s, ok := ss.state.sessions[sessionID]; ok && s.state.winner != nil {
+		op.Prev = s.state.winner.ID
+	}
+	return op
+}
+
+// setStatus moves a session from one of the statuses in from to status
+func (ss *SyncStorage) setStatus(sessionID, status string, from ...string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	s, ok := ss.state.sessions[sessionID]
+	if ok && s.exists() {
+		current := s.status()
+		for _, allowed := range from {
+			if current == allowed {
+				delete(ss.known, sessionID)
+				return ss.write([]*syncOp{ss.stateOp(sessionID, status)})
+			}
+		}
+	}
+	if len(from) == 1 && from[0] == syncTrashed {
+		return fmt.Errorf("session not in trash: %s", sessionID)
+	}
+	return fmt.Errorf("session not found: %s", sessionID)
+}
+
+// DeleteChatSession permanently deletes a session
+func (ss *SyncStorage) DeleteChatSession(ctx context.Context, sessionID string) error {
+	return ss.setStatus(sessionID, syncPurged, syncActive)
+}
+
+// TrashChatSession moves a session to the trash
+func (ss *SyncStorage) TrashChatSession(ctx context.Context, sessionID string) error {
+	return ss.setStatus(sessionID, syncTrashed, syncActive)
+}
+
+// ListTrashedSessions lists trashed sessions, most recently deleted first
+func (ss *SyncStorage) ListTrashedSessions(ctx context.Context) ([]TrashedSession, error) {
+	ss.mu.Lock()
+	var trashed []TrashedSession
+	for _, s := range ss.state.sessions {
+		if s.exists() && s.status() == syncTrashed {
+			trashed = append(trashed, TrashedSession{SessionSummary: s.summary(), DeletedAt: s.deletedAt()})
+		}
+	}
+	ss.mu.Unlock()
+
+	sortTrash(trashed)
+	return trashed, nil
+}
+
+// RestoreChatSession moves a session out of the trash
+func (ss *SyncStorage) RestoreChatSession(ctx context.Context, sessionID string) error {
+	return ss.setStatus(sessionID, syncActive, syncTrashed)
+}
+
+// PurgeTrashedSession permanently deletes a trashed session
+func (ss *SyncStorage) PurgeTrashedSession(ctx context.Context, sessionID string) error {
+	return ss.setStatus(sessionID, syncPurged, syncTrashed)
+}
+
+// PurgeTrash permanently deletes sessions trashed before deletedBefore
+func (ss *SyncStorage) PurgeTrash(ctx context.Context, deletedBefore time.Time) (int, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	var ops []*syncOp
+	for id, s := range ss.state.sessions {
+		if s.exists() && s.status() == syncTrashed && s.deletedAt().Before(deletedBefore) {
+			ops = append(ops, ss.stateOp(id, syncPurged))
+		}
+	}
+	if err := ss.write(ops); err != nil {
+		return 0, err
+	}
+	return len(ops), nil
+}
+
+// saveDocument records a document, or its deletion when v is nil
+func (ss *SyncStorage) saveDocument(key string, kind DocumentKind, v interface{}) error {
+	var value json.RawMessage = []byte("null")
+	if v != nil {
+		data, err := EncodeDocument(kind, v)
+		if err != nil {
+			return err
+		}
+		value = data
+	}
+	value = compactJSON(value)
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	op := &syncOp{Type: opDocument, Key: key, Value: value}
+	if reg, ok := ss.state.docs[key]; ok {
+		if bytes.Equal(reg.winner.Value, value) {
+			return nil
+		}
+		op.Prev = reg.winner.ID
+	}
+	return ss.write([]*syncOp{op})
+}
+
+// loadDocument decodes a stored document into v and reports whether it exists
+func (ss *SyncStorage) loadDocument(key string, kind DocumentKind, v interface{}) (bool, error) {
+	ss.mu.Lock()
+	reg, ok := ss.state.docs[key]
+	var value json.RawMessage
+	if ok {
+		value = reg.winner.Value
+	}
+	ss.mu.Unlock()
+
+	if !ok || string(value) == "null" {
+		return false, nil
+	}
+	if err := DecodeDocument(kind, value, v); err != nil {
+		return false, fmt.Errorf("failed to decode %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// listDocuments decodes every stored document whose key starts with prefix
+func (ss *SyncStorage) listDocuments(prefix string, kind DocumentKind, decode func(json.RawMessage) error) error {
+	ss.mu.Lock()
+	var values []json.RawMessage
+	for key, reg := range ss.state.docs {
+		if strings.HasPrefix(key, prefix) && string(reg.winner.Value) != "null" {
+			values = append(values, reg.winner.Value)
+		}
+	}
+	ss.mu.Unlock()
+
+	for _, value := range values {
+		if err := decode(value); err != nil {
+			ss.logger.Warn("Skipping unreadable document", "kind", kind, "error", err)
+		}
+	}
+	return nil
+}
+
+// SavePersona stores a persona
+func (ss *SyncStorage) SavePersona(ctx context.Context, persona models.Persona) error {
+	if err := persona.Validate(); err != nil {
+		return fmt.Errorf("invalid persona: %w", err)
+	}
+	persona.UpdatedAt = time.Now()
+	return ss.saveDocument("persona/"+persona.ID, KindPersona, persona)
+}
+
+// LoadPersona returns a stored persona
+func (ss *SyncStorage) LoadPersona(ctx context.Context, personaID string) (models.Persona, error) {
+	var persona models.Persona
+	ok, err := ss.loadDocument("persona/"+personaID, KindPersona, &persona)
+	if err != nil {
+		return models.Persona{}, err
+	}
+	if !ok {
+		return models.Persona{}, fmt.Errorf("persona not found: %s", personaID)
+	}
+	return persona, nil
+}
+
+// ListPersonas lists all stored personas sorted by name
+func (ss *SyncStorage) ListPersonas(ctx context.Context) ([]models.Persona, error) {
+	personas := []models.Persona{}
+	err := ss.listDocuments("persona/", KindPersona, func(value json.RawMessage) error {
+		var persona models.Persona
+		if err := DecodeDocument(KindPersona, value, &persona); err != nil {
+			return err
+		}
+		personas = append(personas, persona)
+		return nil
+	})
+
+	sort.Slice(personas, func(i, j int) bool {
+		return strings.ToLower(personas[i].Name) < strings.ToLower(personas[j].Name)
+	})
+	return personas, err
+}
+
+// DeletePersona removes a persona
+func (ss *SyncStorage) DeletePersona(ctx context.Context, personaID string) error {
+	if _, err := ss.LoadPersona(ctx, personaID); err != nil {
+		return err
+	}
+	return ss.saveDocument("persona/"+personaID, KindPersona, nil)
+}
+
+// SavePromptTemplate stores a prompt template
+func (ss *SyncStorage) SavePromptTemplate(ctx context.Context, template models.PromptTemplate) error {
+	if err := template.Validate(); err != nil {
+		return fmt.Errorf("invalid prompt template: %w", err)
+	}
+	template.UpdatedAt = time.Now()
+	return ss.saveDocument("template/"+template.ID, KindTemplate, template)
+}
+
+// LoadPromptTemplate returns a stored prompt template
+func (ss *SyncStorage) LoadPromptTemplate(ctx context.Context, templateID string) (models.PromptTemplate, error) {
+	var template models.PromptTemplate
+	ok, err := ss.loadDocument("template/"+templateID, KindTemplate, &template)
+	if err != nil {
+		return models.PromptTemplate{}, err
+	}
+	if !ok {
+		return models.PromptTemplate{}, fmt.Errorf("prompt template not found: %s", templateID)
+	}
+	return template, nil
+}
+
+// ListPromptTemplates lists all stored prompt templates sorted by name
+func (ss *SyncStorage) ListPromptTemplates(ctx context.Context) ([]models.PromptTemplate, error) {
+	templates := []models.PromptTemplate{}
+	err := ss.listDocuments("template/", KindTemplate, func(value json.RawMessage) error {
+		var template models.PromptTemplate
+		if err := DecodeDocument(KindTemplate, value, &template); err != nil {
+			return err
+		}
+		templates = append(templates, template)
+		return nil
+	})
+
+	sort.Slice(templates, func(i, j int) bool {
+		return strings.ToLower(templates[i].Name) < strings.ToLower(templates[j].Name)
+	})
+	return templates, err
+}
+
+// DeletePromptTemplate removes a prompt template
+func (ss *SyncStorage) DeletePromptTemplate(ctx context.Context, templateID string) error {
+	if _, err := ss.LoadPromptTemplate(ctx, templateID); err != nil {
+		return err
+	}
+	return ss.saveDocument("template/"+templateID, KindTemplate, nil)
+}
+
+// SaveAppPreferences stores application preferences
+func (ss *SyncStorage) SaveAppPreferences(ctx context.Context, prefs AppPreferences) error {
+	return ss.saveDocument(string(KindPreferences), KindPreferences, prefs)
+}
+
+// LoadAppPreferences returns the stored preferences, or defaults if none were saved
+func (ss *SyncStorage) LoadAppPreferences(ctx context.Context) (AppPreferences, error) {
+	prefs := NewDefaultAppPreferences()
+	if _, err := ss.loadDocument(string(KindPreferences), KindPreferences, &prefs); err != nil {
+		return NewDefaultAppPreferences(), err
+	}
+	return prefs, nil
+}
+
+// SaveMCPServers stores MCP server configurations
+func (ss *SyncStorage) SaveMCPServers(ctx context.Context, servers []models.MCPServer) error {
+	if servers == nil {
+		servers = []models.MCPServer{}
+	}
+	return ss.saveDocument(string(KindMCPServers), KindMCPServers, servers)
+}
+
+// LoadMCPServers returns the stored MCP server configurations
+func (ss *SyncStorage) LoadMCPServers(ctx context.Context) ([]models.MCPServer, error) {
+	var servers []models.MCPServer
+	if _, err := ss.loadDocument(string(KindMCPServers), KindMCPServers, &servers); err != nil {
+		return nil, err
+	}
+	if servers == nil {
+		servers = []models.MCPServer{}
+	}
+	return servers, nil
+}
+
+// SaveAgentConfig stores agent configuration
+func (ss *SyncStorage) SaveAgentConfig(ctx context.Context, config models.AgentConfig) error {
+	return ss.saveDocument(string(KindAgentConfig), KindAgentConfig, config)
+}
+
+// LoadAgentConfig returns the stored agent configuration, or defaults if none was saved
+func (ss *SyncStorage) LoadAgentConfig(ctx context.Context) (models.AgentConfig, error) {
+	config := NewDefaultAgentConfig()
+	ok, err := ss.loadDocument(string(KindAgentConfig), KindAgentConfig, &config)
+	if err != nil {
+		return models.AgentConfig{}, err
+	}
+	if !ok {
+		return NewDefaultAgentConfig(), nil
+	}
+	return config, nil
+}
+
+// SyncConflicts returns the conflicts found while merging the device logs that have
+// not been dismissed on this device, newest first
+func (ss *SyncStorage) SyncConflicts() []SyncConflict {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	var conflicts []SyncConflict
+	for _, conflict := range ss.state.conflicts() {
+		if !ss.state.acks[conflict.ID] {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	return conflicts
+}
+
+// DismissSyncConflicts stops reporting the given conflicts on this device
+func (ss *SyncStorage) DismissSyncConflicts(ids []string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	var ops []*syncOp
+	for _, id := range ids {
+		if !ss.state.acks[id] {
+			ops = append(ops, &syncOp{Type: opConflictAck, Key: id})
+		}
+	}
+	return ss.write(ops)
+}
+
+// SyncDevices describes every device that has written to the store
+func (ss *SyncStorage) SyncDevices() []SyncDevice {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	devices := make([]SyncDevice, 0, len(ss.state.seen))
+	for name, seq := range ss.state.seen {
+		devices = append(devices, SyncDevice{Name: name, Operations: seq, LastSeen: ss.state.lastSeen[name], Local: name == ss.device})
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Name < devices[j].Name })
+	return devices
+}
+
+// CacheDir returns a directory outside the synced folder for this device's indexes,
+// or an empty string if none is available
+func (ss *SyncStorage) CacheDir() string {
+	cacheRoot, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	abs, err := filepath.Abs(ss.basePath)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(abs))
+	dir := filepath.Join(cacheRoot, "ollamachat", "sync-"+hex.EncodeToString(sum[:6]))
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		ss.logger.Warn("Failed to create cache directory", "dir", dir, "error", err)
+		return ""
+	}
+	return dir
+}
+
+// IOStats returns the number of writes and bytes written since the storage was opened
+func (ss *SyncStorage) IOStats() IOStats {
+	return IOStats{Writes: ss.writes.Load(), BytesWritten: ss.bytes.Load()}
+}
+
+// Close stops watching the logs and releases the store
+func (ss *SyncStorage) Close() error {
+	ss.stopWatching()
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.closed {
+		return nil
+	}
+	ss.closed = true
+	ss.logger.Info("Closing sync storage")
+	return ss.lock.release()
+}
+
+// Ping checks if the sync folder is accessible
+func (ss *SyncStorage) Ping(ctx context.Context) error {
+	if _, err := os.Stat(filepath.Join(ss.basePath, SyncLogDir)); err != nil {
+		ss.logger.Error("Storage ping failed", "error", err)
+		return fmt.Errorf("storage ping failed: %w", err)
+	}
+	return nil
+}
+
+// deviceIDFile holds this machine's sync device name, outside any synced folder
+const deviceIDFile = "device-id"
+
+// deviceIDPattern matches valid device names, which are also log file names
+var deviceIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// hostnameSeparators matches the characters of a host name replaced in device names
+var hostnameSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+// validDeviceID reports whether name can be used as a device name
+func validDeviceID(name string) bool {
+	return deviceIDPattern.MatchString(name)
+}
+
+// LocalDeviceID returns the name this machine writes sync logs under: the host name
+// with a random suffix, chosen once and kept in the user configuration directory
+func LocalDeviceID() string {
+	hostname, _ := os.Hostname()
+	hostname = strings.Trim(hostnameSeparators.ReplaceAllString(strings.ToLower(hostname), "-"), "-")
+	if hostname == "" {
+		hostname = "device"
+	}
+	if len(hostname) > 40 {
+		hostname = hostname[:40]
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return hostname
+	}
+	path := filepath.Join(configDir, "ollamachat", deviceIDFile)
+	if data, err := os.ReadFile(path); err == nil {
+		if id := strings.TrimSpace(string(data)); validDeviceID(id) {
+			return id
+		}
+	}
+
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return hostname
+	}
+	id := hostname + "-" + hex.EncodeToString(suffix)
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err == nil {
+		writeFileAtomic(path, []byte(id+"\n"), filePerm)
+	}
+	return id
+}
@@ -0,0 +1,744 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// syncOpVersion is the format version of sync operations written by this build
+const syncOpVersion = 1
+
+// Operation types written to sync logs
+const (
+	opSessionField  = "session.field"  // Key: JSON field name, Value: field value
+	opSessionState  = "session.state"  // Value: syncSessionState, Seen: operations seen by the writer
+	opMessageAdd    = "message.add"    // After: key of the preceding message, Value: message
+	opMessageSet    = "message.set"    // Key: message key, Value: message
+	opMessageDelete = "message.delete" // Key: message key
+	opDocument      = "doc"            // Key: document key, Value: document, or null once deleted
+	opConflictAck   = "conflict.ack"   // Key: ID of a conflict dismissed on the writing device
+)
+
+// syncUpdatedField is the session field resolved by taking the latest time rather than
+// the latest write, so concurrent activity never moves a session back in the list
+const syncUpdatedField = "updated_at"
+
+// Session statuses recorded by opSessionState
+const (
+	syncActive  = "active"
+	syncTrashed = "trashed"
+	syncPurged  = "purged"
+)
+
+// syncOp is one line of a device operation log. A message's key is the ID of the
+// operation that added it.
+type syncOp struct {
+	V       int               `json:"v"`
+	ID      string            `json:"id"`
+	Device  string            `json:"device"`
+	Seq     uint64            `json:"seq"`   // Position in the device's log
+	Clock   uint64            `json:"clock"` // Lamport clock ordering operations across devices
+	Time    time.Time         `json:"time"`
+	Type    string            `json:"type"`
+	Session string            `json:"session,omitempty"`
+	Key     string            `json:"key,omitempty"`
+	After   string            `json:"after,omitempty"`
+	Prev    string            `json:"prev,omitempty"` // Write this one replaces, as seen by its device
+	Value   json.RawMessage   `json:"value,omitempty"`
+	Seen    map[string]uint64 `json:"seen,omitempty"`
+}
+
+// wins reports whether op takes precedence over other in last-writer-wins order
+func (op *syncOp) wins(other *syncOp) bool {
+	if op.Clock != other.Clock {
+		return op.Clock > other.Clock
+	}
+	if op.Device != other.Device {
+		return op.Device > other.Device
+	}
+	return op.ID > other.ID
+}
+
+// syncSessionState is the value of an opSessionState operation
+type syncSessionState struct {
+	Status    string    `json:"status"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// SyncConflict describes data changed on two devices before they synced, and how the
+// merge resolved it
+type SyncConflict struct {
+	ID        string
+	SessionID string // Empty for personas and templates
+	Subject   string // Name of the session, persona or template involved
+	Detail    string
+	Devices   []string
+	Time      time.Time
+}
+
+// SyncDevice describes a device that has written to a sync store
+type SyncDevice struct {
+	Name       string
+	Operations uint64 // Operations written by the device
+	LastSeen   time.Time
+	Local      bool
+}
+
+// SyncConflictReporter is implemented by storages that merge changes from several
+// devices and report the conflicting ones
+type SyncConflictReporter interface {
+	SyncConflicts() []SyncConflict
+	DismissSyncConflicts(ids []string) error
+}
+
+// syncWrite is what a register keeps of each write for conflict detection
+type syncWrite struct {
+	id     string
+	device string
+	prev   string
+	value  [8]byte // Hash of the written value
+	time   time.Time
+}
+
+// syncRegister is a last-writer-wins value. Every write is remembered so writes made
+// on different devices from the same starting point can be reported.
+type syncRegister struct {
+	winner     *syncOp
+	writes     []syncWrite
+	lastOwn    string // This device's latest write
+	lastOwnSeq uint64
+}
+
+// apply records a write and reports whether it became the current value
+func (r *syncRegister) apply(op *syncOp, own bool) bool {
+	sum := sha256.Sum256(op.Value)
+	w := syncWrite{id: op.ID, device: op.Device, prev: op.Prev, time: op.Time}
+	copy(w.value[:], sum[:])
+	r.writes = append(r.writes, w)
+
+	if own && op.Seq > r.lastOwnSeq {
+		r.lastOwn, r.lastOwnSeq = op.ID, op.Seq
+	}
+	if r.winner == nil || op.wins(r.winner) {
+		r.winner = op
+		return true
+	}
+	return false
+}
+
+// forks returns groups of writes made on different devices on top of the same
+// previous value with different results
+func (r *syncRegister) forks() [][]syncWrite {
+	byPrev := make(map[string][]syncWrite)
+	for _, w := range r.writes {
+		byPrev[w.prev] = append(byPrev[w.prev], w)
+	}
+
+	var forks [][]syncWrite
+	for _, group := range byPrev {
+		devices, values := make(map[string]bool), make(map[[8]byte]bool)
+		for _, w := range group {
+			devices[w.device] = true
+			values[w.value] = true
+		}
+		if len(devices) > 1 && len(values) > 1 {
+			forks = append(forks, group)
+		}
+	}
+	return forks
+}
+
+// syncMessage is a message in a session's message tree. Each message follows the one
+// it was added after; messages added after the same one are ordered by their clocks.
+type syncMessage struct {
+	key     string
+	after   string
+	clock   uint64
+	device  string
+	placed  bool // The add operation has been seen
+	content syncRegister
+	deletes []*syncOp
+}
+
+// message decodes the current content of the message
+func (m *syncMessage) message() models.ChatMessage {
+	var message models.ChatMessage
+	if m.content.winner != nil {
+		json.Unmarshal(m.content.winner.Value, &message)
+	}
+	return message
+}
+
+// deleted reports whether the message was deleted by a device that had seen its
+// current content. A deletion that crossed an edit made elsewhere keeps the message.
+func (m *syncMessage) deleted() bool {
+	for _, op := range m.deletes {
+		if m.content.winner != nil && op.Prev == m.content.winner.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// before orders messages added after the same message
+func (m *syncMessage) before(other *syncMessage) bool {
+	if m.clock != other.clock {
+		return m.clock < other.clock
+	}
+	if m.device != other.device {
+		return m.device < other.device
+	}
+	return m.key < other.key
+}
+
+// syncSession is the merged state of one session
+type syncSession struct {
+	id         string
+	fields     map[string]*syncRegister
+	updated    time.Time
+	updatedOwn string
+	state      syncRegister
+	messages   map[string]*syncMessage
+	children   map[string][]*syncMessage // Messages by the key they were added after
+	contentSeq map[string]uint64         // Latest content operation per device
+
+	dirty  bool
+	order  []*syncMessage // Visible messages, while not dirty
+	cached models.ChatSession
+}
+
+// newSyncSession creates an empty session state
+func newSyncSession(id string) *syncSession {
+	return &syncSession{
+		id:         id,
+		fields:     make(map[string]*syncRegister),
+		messages:   make(map[string]*syncMessage),
+		children:   make(map[string][]*syncMessage),
+		contentSeq: make(map[string]uint64),
+		dirty:      true,
+	}
+}
+
+// message returns the message with key, creating a placeholder until its add arrives
+func (s *syncSession) message(key string) *syncMessage {
+	m, ok := s.messages[key]
+	if !ok {
+		m = &syncMessage{key: key}
+		s.messages[key] = m
+	}
+	return m
+}
+
+// apply merges one operation into the session
+func (s *syncSession) apply(op *syncOp, own bool) {
+	s.dirty = true
+	if op.Type != opSessionState && op.Seq > s.contentSeq[op.Device] {
+		s.contentSeq[op.Device] = op.Seq
+	}
+
+	switch op.Type {
+	case opSessionField:
+		if op.Key == syncUpdatedField {
+			var t time.Time
+			if json.Unmarshal(op.Value, &t) == nil && t.After(s.updated) {
+				s.updated = t
+			}
+			if own {
+				s.updatedOwn = op.ID
+			}
+			return
+		}
+		reg, ok := s.fields[op.Key]
+		if !ok {
+			reg = &syncRegister{}
+			s.fields[op.Key] = reg
+		}
+		reg.apply(op, own)
+	case opSessionState:
+		s.state.apply(op, own)
+	case opMessageAdd:
+		m := s.message(op.ID)
+		m.after, m.clock, m.device, m.placed = op.After, op.Clock, op.Device, true
+		m.content.apply(op, own)
+		s.children[op.After] = append(s.children[op.After], m)
+	case opMessageSet:
+		s.message(op.Key).content.apply(op, own)
+	case opMessageDelete:
+		m := s.message(op.Key)
+		m.deletes = append(m.deletes, &syncOp{ID: op.ID, Device: op.Device, Prev: op.Prev, Time: op.Time})
+	}
+}
+
+// exists reports whether the session has been saved, as opposed to only referenced
+func (s *syncSession) exists() bool {
+	return len(s.fields) > 0
+}
+
+// stateValue decodes the winning status operation
+func (s *syncSession) stateValue() syncSessionState {
+	state := syncSessionState{Status: syncActive}
+	if s.state.winner != nil {
+		json.Unmarshal(s.state.winner.Value, &state)
+	}
+	return state
+}
+
+// status returns whether the session is active, trashed or purged. Content added on a
+// device after it last saw the session before it was deleted keeps the session active.
+func (s *syncSession) status() string {
+	status := s.stateValue().Status
+	if status != syncActive && len(s.unseenBy(s.state.winner)) > 0 {
+		return syncActive
+	}
+	return status
+}
+
+// unseenBy returns the devices that changed the session's content after op was written
+// without its device having seen the change
+func (s *syncSession) unseenBy(op *syncOp) []string {
+	var devices []string
+	for device, seq := range s.contentSeq {
+		if seq > op.Seen[device] {
+			devices = append(devices, device)
+		}
+	}
+	sort.Strings(devices)
+	return devices
+}
+
+// deletedAt returns when the session was last moved to the trash
+func (s *syncSession) deletedAt() time.Time {
+	return s.stateValue().DeletedAt
+}
+
+// visible returns the messages in order, without deleted ones
+func (s *syncSession) visible() []*syncMessage {
+	if !s.dirty {
+		return s.order
+	}
+
+	// Messages whose predecessor is unknown, such as after a purge, start the list
+	roots := append([]*syncMessage(nil), s.children[""]...)
+	for after, children := range s.children {
+		if after == "" {
+			continue
+		}
+		if parent, ok := s.messages[after]; !ok || !parent.placed {
+			roots = append(roots, children...)
+		}
+	}
+
+	var order []*syncMessage
+	stack := sortedMessages(roots)
+	reverse(stack)
+	for len(stack) > 0 {
+		m := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !m.deleted() {
+			order = append(order, m)
+		}
+		children := sortedMessages(s.children[m.key])
+		reverse(children)
+		stack = append(stack, children...)
+	}
+
+	s.order = order
+	s.cached = s.build(order)
+	s.dirty = false
+	return order
+}
+
+// build assembles the session from its fields and messages
+func (s *syncSession) build(order []*syncMessage) models.ChatSession {
+	fields := make(map[string]json.RawMessage, len(s.fields))
+	for key, reg := range s.fields {
+		fields[key] = reg.winner.Value
+	}
+
+	var session models.ChatSession
+	if data, err := json.Marshal(fields); err == nil {
+		json.Unmarshal(data, &session)
+	}
+	session.ID = s.id
+	session.UpdatedAt = s.updated
+	session.Messages = make([]models.ChatMessage, len(order))
+	for i, m := range order {
+		session.Messages[i] = m.message()
+	}
+	return session
+}
+
+// view returns a copy of the merged session
+func (s *syncSession) view() models.ChatSession {
+	s.visible()
+	return copySession(s.cached)
+}
+
+// summary returns the listing form of the merged session
+func (s *syncSession) summary() models.SessionSummary {
+	s.visible()
+	summary := s.cached.Summary()
+	summary.Size = estimateSize(s.cached)
+	return summary
+}
+
+// fieldOps returns operations recording the fields of session that differ from the
+// merged state. Fields left out of the JSON form are recorded as null.
+func (s *syncSession) fieldOps(session models.ChatSession) ([]*syncOp, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal session: %w", err)
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to split session fields: %w", err)
+	}
+	delete(fields, "id")
+	delete(fields, "messages")
+	delete(fields, syncUpdatedField)
+	for key := range s.fields {
+		if _, ok := fields[key]; !ok {
+			fields[key] = json.RawMessage("null")
+		}
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var ops []*syncOp
+	for _, key := range keys {
+		value := compactJSON(fields[key])
+		op := &syncOp{Type: opSessionField, Session: s.id, Key: key, Value: value}
+		if reg, ok := s.fields[key]; ok {
+			if bytes.Equal(reg.winner.Value, value) {
+				continue
+			}
+			op.Prev = reg.winner.ID
+		}
+		ops = append(ops, op)
+	}
+
+	if !session.UpdatedAt.Equal(s.updated) {
+		updated, err := s.updatedOp(session.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, updated)
+	}
+	return ops, nil
+}
+
+// updatedOp returns an operation recording activity in the session at t
+func (s *syncSession) updatedOp(t time.Time) (*syncOp, error) {
+	value, err := json.Marshal(t)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal update time: %w", err)
+	}
+	return &syncOp{Type: opSessionField, Session: s.id, Key: syncUpdatedField, Value: value}, nil
+}
+
+// syncTouched collects the sessions and documents changed by applied operations
+type syncTouched struct {
+	sessions map[string]bool
+	docs     map[string]bool
+}
+
+// newSyncTouched creates an empty change set
+func newSyncTouched() syncTouched {
+	return syncTouched{sessions: make(map[string]bool), docs: make(map[string]bool)}
+}
+
+// syncState is the merge of every device log read so far. Operations can be applied
+// in any order and the result is the same.
+type syncState struct {
+	device   string
+	ops      map[string]bool // IDs of applied operations
+	clock    uint64
+	seen     map[string]uint64 // Latest sequence number applied per device
+	lastSeen map[string]time.Time
+	sessions map[string]*syncSession
+	docs     map[string]*syncRegister
+	acks     map[string]bool // Conflicts dismissed on this device
+}
+
+// newSyncState creates an empty state for device
+func newSyncState(device string) *syncState {
+	return &syncState{
+		device:   device,
+		ops:      make(map[string]bool),
+		seen:     make(map[string]uint64),
+		lastSeen: make(map[string]time.Time),
+		sessions: make(map[string]*syncSession),
+		docs:     make(map[string]*syncRegister),
+		acks:     make(map[string]bool),
+	}
+}
+
+// session returns the state of a session, creating it if needed
+func (st *syncState) session(id string) *syncSession {
+	s, ok := st.sessions[id]
+	if !ok {
+		s = newSyncSession(id)
+		st.sessions[id] = s
+	}
+	return s
+}
+
+// apply merges one operation, skipping ones already applied
+func (st *syncState) apply(op *syncOp, touched syncTouched) {
+	if op.ID == "" || st.ops[op.ID] {
+		return
+	}
+	st.ops[op.ID] = true
+	if op.Clock > st.clock {
+		st.clock = op.Clock
+	}
+	if op.Seq > st.seen[op.Device] {
+		st.seen[op.Device] = op.Seq
+	}
+	if op.Time.After(st.lastSeen[op.Device]) {
+		st.lastSeen[op.Device] = op.Time
+	}
+	own := op.Device == st.device
+
+	switch op.Type {
+	case opSessionField, opSessionState, opMessageAdd, opMessageSet, opMessageDelete:
+		st.session(op.Session).apply(op, own)
+		touched.sessions[op.Session] = true
+	case opDocument:
+		reg, ok := st.docs[op.Key]
+		if !ok {
+			reg = &syncRegister{}
+			st.docs[op.Key] = reg
+		}
+		op.Value = compactJSON(op.Value)
+		reg.apply(op, own)
+		touched.docs[op.Key] = true
+	case opConflictAck:
+		if own {
+			st.acks[op.Key] = true
+		}
+	}
+}
+
+// keepOwn reports whether compacting this device's log must keep op: it is still the
+// device's latest write to its value, or it places or deletes a message
+func (st *syncState) keepOwn(op *syncOp) bool {
+	switch op.Type {
+	case opDocument:
+		reg, ok := st.docs[op.Key]
+		return !ok || reg.lastOwn == op.ID
+	case opSessionField, opSessionState, opMessageAdd, opMessageSet, opMessageDelete:
+	default:
+		return true
+	}
+
+	s, ok := st.sessions[op.Session]
+	if !ok {
+		return true
+	}
+	if s.exists() && s.status() == syncPurged {
+		// Only the deletion itself needs to stay
+		return op.Type == opSessionState && s.state.lastOwn == op.ID
+	}
+
+	switch op.Type {
+	case opSessionField:
+		if op.Key == syncUpdatedField {
+			return s.updatedOwn == op.ID
+		}
+		reg, ok := s.fields[op.Key]
+		return !ok || reg.lastOwn == op.ID
+	case opSessionState:
+		return s.state.lastOwn == op.ID
+	case opMessageSet:
+		m, ok := s.messages[op.Key]
+		return !ok || m.content.lastOwn == op.ID
+	}
+	return true
+}
+
+// conflicts lists every conflict in the merged state, newest first
+func (st *syncState) conflicts() []SyncConflict {
+	var conflicts []SyncConflict
+	add := func(kind, sessionID, subject, detail string, writes []syncWrite) {
+		ids := make([]string, 0, len(writes))
+		devices := make(map[string]bool)
+		var latest time.Time
+		for _, w := range writes {
+			// Writes without an ID only name a device involved
+			if w.id != "" {
+				ids = append(ids, w.id)
+			}
+			devices[w.device] = true
+			if w.time.After(latest) {
+				latest = w.time
+			}
+		}
+		sort.Strings(ids)
+		sum := sha256.Sum256([]byte(kind + ":" + strings.Join(ids, ",")))
+		conflicts = append(conflicts, SyncConflict{
+			ID:        hex.EncodeToString(sum[:8]),
+			SessionID: sessionID,
+			Subject:   subject,
+			Detail:    detail,
+			Devices:   sortedKeys(devices),
+			Time:      latest,
+		})
+	}
+
+	for id, s := range st.sessions {
+		if !s.exists() || s.status() == syncPurged {
+			continue
+		}
+		subject := s.view().Name
+
+		for _, key := range sortedKeys(s.fields) {
+			reg := s.fields[key]
+			for _, fork := range reg.forks() {
+				add("field", id, subject, fmt.Sprintf("%s was changed on %s at the same time; kept the value from %s",
+					fieldLabel(key), joinDevices(fork), reg.winner.Device), fork)
+			}
+		}
+		for _, fork := range s.state.forks() {
+			add("state", id, subject, fmt.Sprintf("Deleted or restored on %s at the same time; the session is now %s",
+				joinDevices(fork), s.status()), fork)
+		}
+		if state := s.state.winner; state != nil && s.stateValue().Status != syncActive {
+			if devices := s.unseenBy(state); len(devices) > 0 {
+				writes := []syncWrite{{id: state.ID, device: state.Device, time: state.Time}}
+				for _, device := range devices {
+					writes = append(writes, syncWrite{device: device})
+				}
+				add("kept", id, subject, fmt.Sprintf("Deleted on %s while %s added to it; the session was kept",
+					state.Device, strings.Join(devices, " and ")), writes)
+			}
+		}
+
+		for after, children := range s.children {
+			devices := make(map[string]bool)
+			var writes []syncWrite
+			for _, m := range children {
+				if m.deleted() {
+					continue
+				}
+				devices[m.device] = true
+				writes = append(writes, syncWrite{id: m.key, device: m.device, time: m.content.winner.Time})
+			}
+			if len(devices) > 1 {
+				add("branch:"+after, id, subject, fmt.Sprintf("Messages were added on %s at the same time; all were kept, in the order they were written",
+					strings.Join(sortedKeys(devices), " and ")), writes)
+			}
+		}
+		for _, m := range s.messages {
+			if !m.placed {
+				continue
+			}
+			for _, fork := range m.content.forks() {
+				add("message", id, subject, fmt.Sprintf("A message was changed on %s at the same time; kept the version from %s",
+					joinDevices(fork), m.content.winner.Device), fork)
+			}
+			if len(m.deletes) > 0 && !m.deleted() {
+				writes := []syncWrite{{id: m.content.winner.ID, device: m.content.winner.Device, time: m.content.winner.Time}}
+				for _, op := range m.deletes {
+					writes = append(writes, syncWrite{id: op.ID, device: op.Device, time: op.Time})
+				}
+				add("undelete", id, subject, fmt.Sprintf("A message deleted on %s was changed on %s; the message was kept",
+					joinDevices(writes[1:]), m.content.winner.Device), writes)
+			}
+		}
+	}
+
+	for _, key := range sortedKeys(st.docs) {
+		// Preferences and other settings are rewritten often and simply follow the last change
+		if !strings.HasPrefix(key, "persona/") && !strings.HasPrefix(key, "template/") {
+			continue
+		}
+		reg := st.docs[key]
+		var named struct{ Name string }
+		json.Unmarshal(reg.winner.Value, &named)
+		subject := named.Name
+		if subject == "" {
+			subject = key
+		}
+		for _, fork := range reg.forks() {
+			add("doc", "", subject, fmt.Sprintf("Changed on %s at the same time; kept the version from %s",
+				joinDevices(fork), reg.winner.Device), fork)
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		if !conflicts[i].Time.Equal(conflicts[j].Time) {
+			return conflicts[i].Time.After(conflicts[j].Time)
+		}
+		return conflicts[i].ID < conflicts[j].ID
+	})
+	return conflicts
+}
+
+// fieldLabel names a session field for conflict reports
+func fieldLabel(key string) string {
+	switch key {
+	case "name", "manually_named", "auto_titled":
+		return "The name"
+	case "system_prompt":
+		return "The system prompt"
+	default:
+		return "The " + strings.ReplaceAll(key, "_", " ") + " setting"
+	}
+}
+
+// joinDevices lists the devices of writes for conflict reports
+func joinDevices(writes []syncWrite) string {
+	devices := make(map[string]bool)
+	for _, w := range writes {
+		devices[w.device] = true
+	}
+	return strings.Join(sortedKeys(devices), " and ")
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedMessages returns messages in sibling order
+func sortedMessages(messages []*syncMessage) []*syncMessage {
+	sorted := append([]*syncMessage(nil), messages...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].before(sorted[j]) })
+	return sorted
+}
+
+// reverse reverses messages in place
+func reverse(messages []*syncMessage) {
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+}
+
+// compactJSON returns value as json.Marshal writes it into a log line, without
+// insignificant whitespace and with HTML characters escaped, so equal values compare equal
+func compactJSON(value json.RawMessage) json.RawMessage {
+	var compacted, escaped bytes.Buffer
+	if err := json.Compact(&compacted, value); err != nil {
+		return value
+	}
+	json.HTMLEscape(&escaped, compacted.Bytes())
+	return escaped.Bytes()
+}
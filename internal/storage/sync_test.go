@@ -0,0 +1,306 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+// newSyncDevice opens a sync store for device in its own folder, as on its own machine
+func newSyncDevice(t *testing.T, device string) *SyncStorage {
+	t.Helper()
+	ss, err := NewSyncStorage(t.TempDir(), device, logger.NewLogger(slog.LevelError))
+	if err != nil {
+		t.Fatalf("NewSyncStorage(%s): %v", device, err)
+	}
+	t.Cleanup(func() { ss.Close() })
+	return ss
+}
+
+// syncFolders copies each device's log to the other folders, replacing the copy there
+// as sync tools do, then merges the logs on every device
+func syncFolders(t *testing.T, devices ...*SyncStorage) {
+	t.Helper()
+	for _, src := range devices {
+		data, err := os.ReadFile(src.logPath(src.device))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, dst := range devices {
+			if dst != src {
+				if err := writeFileAtomic(dst.logPath(src.device), data, filePerm); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	for _, ss := range devices {
+		ss.processLogs()
+	}
+}
+
+// sharedSession saves a session with one exchange on a, syncs it to b and loads it on
+// both, as each device's UI would
+func sharedSession(t *testing.T, a, b *SyncStorage) models.ChatSession {
+	t.Helper()
+	ctx := context.Background()
+	session := models.NewChatSession("Shared", "llama3.2:latest")
+	session.Messages = []models.ChatMessage{models.NewChatMessage("user", "question"), models.NewChatMessage("llm", "answer")}
+	if err := a.SaveChatSession(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+	syncFolders(t, a, b)
+	for _, ss := range []*SyncStorage{a, b} {
+		if _, err := ss.LoadChatSession(ctx, session.ID); err != nil {
+			t.Fatalf("LoadChatSession on %s: %v", ss.device, err)
+		}
+	}
+	return session
+}
+
+// contents returns the message contents of a session as loaded on ss
+func contents(t *testing.T, ss *SyncStorage, sessionID string) []string {
+	t.Helper()
+	session, err := ss.LoadChatSession(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("LoadChatSession on %s: %v", ss.device, err)
+	}
+	var out []string
+	for _, msg := range session.Messages {
+		out = append(out, msg.Content)
+	}
+	return out
+}
+
+// assertConverged checks both devices hold the same messages, and that they are want
+func assertConverged(t *testing.T, a, b *SyncStorage, sessionID string, want ...string) {
+	t.Helper()
+	onA, onB := contents(t, a, sessionID), contents(t, b, sessionID)
+	if strings.Join(onA, "|") != strings.Join(onB, "|") {
+		t.Fatalf("devices disagree: %s has %q, %s has %q", a.device, onA, b.device, onB)
+	}
+	if strings.Join(onA, "|") != strings.Join(want, "|") {
+		t.Fatalf("messages %q, want %q", onA, want)
+	}
+}
+
+// assertConflict checks ss reports one conflict whose detail contains detail
+func assertConflict(t *testing.T, ss *SyncStorage, detail string) SyncConflict {
+	t.Helper()
+	conflicts := ss.SyncConflicts()
+	if len(conflicts) != 1 || !strings.Contains(conflicts[0].Detail, detail) {
+		t.Fatalf("conflicts on %s = %+v, want one about %q", ss.device, conflicts, detail)
+	}
+	return conflicts[0]
+}
+
+func TestSyncKeepsMessagesAddedOnBothDevices(t *testing.T) {
+	ctx := context.Background()
+	a, b := newSyncDevice(t, "desktop"), newSyncDevice(t, "laptop")
+	session := sharedSession(t, a, b)
+
+	if err := a.AppendMessages(ctx, session.ID, 2, []models.ChatMessage{models.NewChatMessage("user", "from desktop")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AppendMessages(ctx, session.ID, 2, []models.ChatMessage{models.NewChatMessage("user", "from laptop")}); err != nil {
+		t.Fatal(err)
+	}
+	syncFolders(t, a, b)
+
+	// Messages added after the same one are ordered by when they were written
+	assertConverged(t, a, b, session.ID, "question", "answer", "from desktop", "from laptop")
+	assertConflict(t, a, "all were kept")
+	assertConflict(t, b, "all were kept")
+}
+
+func TestSyncKeepsSessionTrashedWhileAddedTo(t *testing.T) {
+	ctx := context.Background()
+	a, b := newSyncDevice(t, "desktop"), newSyncDevice(t, "laptop")
+	session := sharedSession(t, a, b)
+
+	if err := a.TrashChatSession(ctx, session.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AppendMessages(ctx, session.ID, 2, []models.ChatMessage{models.NewChatMessage("user", "still typing")}); err != nil {
+		t.Fatal(err)
+	}
+	syncFolders(t, a, b)
+
+	assertConverged(t, a, b, session.ID, "question", "answer", "still typing")
+	assertConflict(t, a, "the session was kept")
+	if trashed, _ := a.ListTrashedSessions(ctx); len(trashed) != 0 {
+		t.Errorf("session both kept and trashed: %+v", trashed)
+	}
+}
+
+func TestSyncKeepsMessageEditedWhileDeleted(t *testing.T) {
+	ctx := context.Background()
+	a, b := newSyncDevice(t, "desktop"), newSyncDevice(t, "laptop")
+	session := sharedSession(t, a, b)
+
+	// The answer is edited on one device and deleted on the other
+	edited := session
+	edited.Messages = append([]models.ChatMessage(nil), session.Messages...)
+	edited.Messages[1].Content = "better answer"
+	if err := a.SaveChatSession(ctx, edited); err != nil {
+		t.Fatal(err)
+	}
+	truncated := session
+	truncated.Messages = session.Messages[:1]
+	if err := b.SaveChatSession(ctx, truncated); err != nil {
+		t.Fatal(err)
+	}
+	syncFolders(t, a, b)
+
+	assertConverged(t, a, b, session.ID, "question", "better answer")
+	assertConflict(t, b, "the message was kept")
+
+	// A deletion made after seeing the edit removes the message
+	if _, err := b.LoadChatSession(ctx, session.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SaveChatSession(ctx, truncated); err != nil {
+		t.Fatal(err)
+	}
+	syncFolders(t, a, b)
+	assertConverged(t, a, b, session.ID, "question")
+}
+
+func TestSyncResolvesFieldConflicts(t *testing.T) {
+	ctx := context.Background()
+	a, b := newSyncDevice(t, "desktop"), newSyncDevice(t, "laptop")
+	session := sharedSession(t, a, b)
+
+	for _, rename := range []struct {
+		ss   *SyncStorage
+		name string
+	}{{a, "Named on desktop"}, {b, "Named on laptop"}} {
+		renamed, err := rename.ss.LoadChatSession(ctx, session.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		renamed.Rename(rename.name)
+		if err := rename.ss.SaveChatSession(ctx, renamed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	syncFolders(t, a, b)
+
+	// Both devices pick the same winner, and each reports the conflict
+	onA, _ := a.LoadChatSession(ctx, session.ID)
+	onB, _ := b.LoadChatSession(ctx, session.ID)
+	if onA.Name != onB.Name {
+		t.Fatalf("devices disagree on the name: %q and %q", onA.Name, onB.Name)
+	}
+	conflict := assertConflict(t, a, "The name was changed")
+	if other := assertConflict(t, b, "The name was changed"); other.ID != conflict.ID {
+		t.Errorf("devices report the conflict as %s and %s", conflict.ID, other.ID)
+	}
+
+	// Dismissing it on one device leaves it reported on the other
+	if err := a.DismissSyncConflicts([]string{conflict.ID}); err != nil {
+		t.Fatal(err)
+	}
+	syncFolders(t, a, b)
+	if conflicts := a.SyncConflicts(); len(conflicts) != 0 {
+		t.Errorf("dismissed conflict still reported: %+v", conflicts)
+	}
+	assertConflict(t, b, "The name was changed")
+}
+
+func TestSyncReadsCompactedLogAgain(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	log := logger.NewLogger(slog.LevelError)
+	a, err := NewSyncStorage(dir, "desktop", log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := newSyncDevice(t, "laptop")
+
+	// Renaming over and over leaves a log made mostly of superseded operations
+	session := models.NewChatSession("Renamed", "llama3.2:latest")
+	for i := 0; i < syncCompactMinOps; i++ {
+		session.Rename(strings.Repeat("x", i%7+1))
+		if err := a.SaveChatSession(ctx, session); err != nil {
+			t.Fatal(err)
+		}
+	}
+	syncFolders(t, a, b)
+	readSize := b.logs[b.logPath("desktop")].offset
+
+	// Reopening compacts the log, which then grows past where the other device stopped
+	a.Close()
+	if a, err = NewSyncStorage(dir, "desktop", log); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if info, _ := os.Stat(a.logPath("desktop")); info.Size() >= readSize {
+		t.Fatalf("log not compacted: %d bytes, %d before", info.Size(), readSize)
+	}
+	var want []string
+	for i := 0; ; i++ {
+		content := strings.Repeat("message ", 20) + string(rune('a'+i%26))
+		if err := a.AppendMessages(ctx, session.ID, i, []models.ChatMessage{models.NewChatMessage("user", content)}); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, content)
+		if info, _ := os.Stat(a.logPath("desktop")); info.Size() > readSize {
+			break
+		}
+	}
+	syncFolders(t, a, b)
+
+	if got := contents(t, b, session.ID); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("messages lost reading the compacted log: got %d of %d", len(got), len(want))
+	}
+	if got, _ := b.LoadChatSession(ctx, session.ID); got.Name != session.Name {
+		t.Errorf("name %q, want %q", got.Name, session.Name)
+	}
+}
+
+func TestSyncLogPosContinuedBy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "device.jsonl")
+	write := func(data string) *os.File {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), filePerm); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { file.Close() })
+		return file
+	}
+	pos := syncLogPos{offset: int64(len("one\ntwo\n")), last: []byte("two\n")}
+
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"appended", "one\ntwo\nthree\n", true},
+		{"unchanged", "one\ntwo\n", true},
+		{"shrunk", "two\n", false},
+		{"compacted and regrown", "two\nthree\nfour\n", false},
+	}
+	for _, tt := range tests {
+		file := write(tt.data)
+		if got := pos.continuedBy(file, int64(len(tt.data))); got != tt.want {
+			t.Errorf("%s: continuedBy = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if !(syncLogPos{}).continuedBy(write("anything\n"), 9) {
+		t.Error("a log never read is not continued from the start")
+	}
+}
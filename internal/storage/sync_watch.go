@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchChanges publishes the changes other devices make as the sync tool brings their
+// logs in. The log directory is watched from the first call until the storage is closed.
+func (ss *SyncStorage) WatchChanges(fn func(Change)) (stop func()) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.closed {
+		return func() {}
+	}
+	if ss.watcher == nil {
+		if err := ss.startWatching(); err != nil {
+			ss.logger.Warn("Live sync is unavailable", "error", err)
+			return func() {}
+		}
+	}
+
+	id := ss.nextSub
+	ss.nextSub++
+	ss.subs[id] = fn
+	return func() {
+		ss.mu.Lock()
+		delete(ss.subs, id)
+		ss.mu.Unlock()
+	}
+}
+
+// startWatching starts watching the log directory. The caller must hold mu.
+func (ss *SyncStorage) startWatching() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	dir := filepath.Join(ss.basePath, SyncLogDir)
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+
+	ss.watcher = watcher
+	ss.watchEnd = make(chan struct{})
+	go ss.watchLoop(watcher, ss.watchEnd)
+
+	ss.logger.Info("Watching sync logs for changes", "dir", dir)
+	return nil
+}
+
+// stopWatching stops the watcher, if started, and waits for it to finish
+func (ss *SyncStorage) stopWatching() {
+	ss.mu.Lock()
+	watcher, done := ss.watcher, ss.watchEnd
+	ss.watcher = nil
+	ss.subs = make(map[int]func(Change))
+	ss.mu.Unlock()
+
+	if watcher != nil {
+		watcher.Close()
+		<-done
+	}
+}
+
+// watchLoop reads other devices' logs once the directory has been quiet for a moment
+func (ss *SyncStorage) watchLoop(watcher *fsnotify.Watcher, done chan struct{}) {
+	defer close(done)
+
+	own := filepath.Base(ss.logPath(ss.device))
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			name := filepath.Base(event.Name)
+			if name == own || isTempFile(name) || !strings.HasSuffix(name, syncLogExt) {
+				continue
+			}
+			timer.Reset(watchDebounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			ss.logger.Warn("File watcher error", "error", err)
+
+		case <-timer.C:
+			ss.processLogs()
+		}
+	}
+}
+
+// processLogs merges the operations other devices added and publishes the sessions
+// and preferences they changed
+func (ss *SyncStorage) processLogs() {
+	ss.mu.Lock()
+	touched, err := ss.readLogs()
+	if err != nil {
+		ss.mu.Unlock()
+		ss.logger.Warn("Failed to read sync logs", "error", err)
+		return
+	}
+
+	var changes []Change
+	for _, id := range sortedKeys(touched.sessions) {
+		s := ss.state.sessions[id]
+		if s.exists() && s.status() == syncActive {
+			changes = append(changes, Change{Kind: SessionChanged, SessionID: id, Session: s.view()})
+		} else {
+			changes = append(changes, Change{Kind: SessionDeleted, SessionID: id})
+		}
+	}
+	if touched.docs[string(KindPreferences)] {
+		changes = append(changes, Change{Kind: PreferencesChanged})
+	}
+	subscribers := make([]func(Change), 0, len(ss.subs))
+	for _, fn := range ss.subs {
+		subscribers = append(subscribers, fn)
+	}
+	ss.mu.Unlock()
+
+	for _, change := range changes {
+		ss.logger.Info("Merged changes from another device", "kind", change.Kind, "session_id", change.SessionID)
+		for _, fn := range subscribers {
+			fn(change)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// trashDir holds the files of trashed sessions
+const trashDir = "trash"
+
+// trashManifestFile records when each session in the trash was deleted
+const trashManifestFile = "trash.json"
+
+// trashManifestVersion is the layout version of the trash manifest
+const trashManifestVersion = 1
+
+// trashManifest is the content of the trash manifest
+type trashManifest struct {
+	Version  int              `json:"version"`
+	Sessions []TrashedSession `json:"sessions"`
+}
+
+// trashManifestPath returns the trash manifest file
+func (fs *FileStorage) trashManifestPath() string {
+	return filepath.Join(fs.basePath, trashDir, trashManifestFile)
+}
+
+// trashPath returns the file of a trashed session
+func (fs *FileStorage) trashPath(sessionID string) (string, error) {
+	if err := checkID("session", sessionID); err != nil {
+		return "", err
+	}
+	return filepath.Join(fs.basePath, trashDir, sessionID+".json"), nil
+}
+
+// sessionPath returns the file of a live session
+func (fs *FileStorage) sessionPath(sessionID string) (string, error) {
+	if err := checkID("session", sessionID); err != nil {
+		return "", err
+	}
+	return filepath.Join(fs.basePath, "sessions", sessionID+".json"), nil
+}
+
+// sessionFilePaths are the files a session may be stored in
+type sessionFilePaths struct {
+	session, journal, trash string
+}
+
+// sessionPaths returns the files of a session
+func (fs *FileStorage) sessionPaths(sessionID string) (sessionFilePaths, error) {
+	if err := checkID("session", sessionID); err != nil {
+		return sessionFilePaths{}, err
+	}
+	return sessionFilePaths{
+		session: filepath.Join(fs.basePath, "sessions", sessionID+".json"),
+		journal: filepath.Join(fs.basePath, "sessions", sessionID+journalExt),
+		trash:   filepath.Join(fs.basePath, trashDir, sessionID+".json"),
+	}, nil
+}
+
+// TrashChatSession moves a session file into the trash
+func (fs *FileStorage) TrashChatSession(ctx context.Context, sessionID string) error {
+	fs.logger.Info("Moving chat session to trash", "session_id", sessionID)
+
+	paths, err := fs.sessionPaths(sessionID)
+	if err != nil {
+		return err
+	}
+
+	fs.journalMu.Lock()
+	defer fs.journalMu.Unlock()
+
+	// Fold journaled messages in so the trashed file holds the whole session
+	if _, err := os.Stat(paths.journal); err == nil {
+		if err := fs.compactJournal(sessionID); err != nil {
+			return fmt.Errorf("failed to compact session journal: %w", err)
+		}
+	}
+
+	session, err := fs.loadChatSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Join(fs.basePath, trashDir), dirPerm); err != nil {
+		return fmt.Errorf("failed to create trash directory: %w", err)
+	}
+	if err := os.Rename(paths.session, paths.trash); err != nil {
+		fs.logger.Error("Failed to move session to trash", "session_id", sessionID, "error", err)
+		return fmt.Errorf("failed to move session to trash: %w", err)
+	}
+	delete(fs.messageCounts, sessionID)
+	delete(fs.journalSeqs, sessionID)
+	fs.forgetSession(sessionID)
+	fs.unindexSession(sessionID)
+
+	summary := session.Summary()
+	if info, err := os.Stat(paths.trash); err == nil {
+		summary.Size = info.Size()
+	}
+	fs.trash[sessionID] = TrashedSession{SessionSummary: summary, DeletedAt: time.Now()}
+	fs.saveTrashManifest()
+	return nil
+}
+
+// ListTrashedSessions lists trashed sessions, most recently deleted first
+func (fs *FileStorage) ListTrashedSessions(ctx context.Context) ([]TrashedSession, error) {
+	fs.journalMu.Lock()
+	trashed := make([]TrashedSession, 0, len(fs.trash))
+	for _, item := range fs.trash {
+		trashed = append(trashed, item)
+	}
+	fs.journalMu.Unlock()
+
+	sortTrash(trashed)
+	return trashed, nil
+}
+
+// RestoreChatSession moves a session file out of the trash
+func (fs *FileStorage) RestoreChatSession(ctx context.Context, sessionID string) error {
+	fs.logger.Info("Restoring chat session from trash", "session_id", sessionID)
+
+	paths, err := fs.sessionPaths(sessionID)
+	if err != nil {
+		return err
+	}
+
+	fs.journalMu.Lock()
+	defer fs.journalMu.Unlock()
+
+	if _, ok := fs.trash[sessionID]; !ok {
+		return fmt.Errorf("session not in trash: %s", sessionID)
+	}
+	if _, err := os.Stat(paths.session); err == nil {
+		return fmt.Errorf("session already exists: %s", sessionID)
+	}
+
+	if err := os.MkdirAll(filepath.Join(fs.basePath, "sessions"), dirPerm); err != nil {
+		return fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+	if err := os.Rename(paths.trash, paths.session); err != nil {
+		fs.logger.Error("Failed to restore session", "session_id", sessionID, "error", err)
+		return fmt.Errorf("failed to restore session: %w", err)
+	}
+	delete(fs.trash, sessionID)
+	fs.saveTrashManifest()
+
+	session, err := fs.loadChatSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	fs.messageCounts[sessionID] = len(session.Messages)
+	fs.noteSession(sessionID, false)
+	fs.indexSnapshot(session, paths.session)
+	return nil
+}
+
+// PurgeTrashedSession permanently deletes a trashed session file
+func (fs *FileStorage) PurgeTrashedSession(ctx context.Context, sessionID string) error {
+	fs.journalMu.Lock()
+	defer fs.journalMu.Unlock()
+
+	if _, ok := fs.trash[sessionID]; !ok {
+		return fmt.Errorf("session not in trash: %s", sessionID)
+	}
+	if err := fs.purgeTrashed(sessionID); err != nil {
+		return err
+	}
+	fs.saveTrashManifest()
+	return nil
+}
+
+// PurgeTrash permanently deletes sessions trashed before deletedBefore
+func (fs *FileStorage) PurgeTrash(ctx context.Context, deletedBefore time.Time) (int, error) {
+	fs.journalMu.Lock()
+	defer fs.journalMu.Unlock()
+
+	purged := 0
+	for id, item := range fs.trash {
+		if !item.DeletedAt.Before(deletedBefore) {
+			continue
+		}
+		if err := fs.purgeTrashed(id); err != nil {
+			fs.saveTrashManifest()
+			return purged, err
+		}
+		purged++
+	}
+	if purged > 0 {
+		fs.saveTrashManifest()
+	}
+	return purged, nil
+}
+
+// purgeTrashed removes a trashed session file and its manifest entry. The caller
+// must hold journalMu and save the manifest.
+func (fs *FileStorage) purgeTrashed(sessionID string) error {
+	trashPath, err := fs.trashPath(sessionID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(trashPath); err != nil && !os.IsNotExist(err) {
+		fs.logger.Error("Failed to purge trashed session", "session_id", sessionID, "error", err)
+		return fmt.Errorf("failed to purge session: %w", err)
+	}
+	delete(fs.trash, sessionID)
+	fs.logger.Info("Purged trashed session", "session_id", sessionID)
+	return nil
+}
+
+// discardTrashed drops the trashed copy of a session saved again under the same ID.
+// The caller must hold journalMu.
+func (fs *FileStorage) discardTrashed(sessionID string) {
+	if _, ok := fs.trash[sessionID]; !ok {
+		return
+	}
+	if err := fs.purgeTrashed(sessionID); err != nil {
+		fs.logger.Warn("Failed to discard trashed copy of saved session", "session_id", sessionID, "error", err)
+		return
+	}
+	fs.saveTrashManifest()
+}
+
+// loadTrash reads the trash manifest and reconciles it with the trashed files: entries
+// without a file are dropped and files without an entry are dated by their mtime
+func (fs *FileStorage) loadTrash() {
+	recorded := make(map[string]TrashedSession)
+	if data, err := os.ReadFile(fs.trashManifestPath()); err == nil {
+		var manifest trashManifest
+		if err := json.Unmarshal(data, &manifest); err != nil || manifest.Version != trashManifestVersion {
+			fs.logger.Warn("Rebuilding unreadable trash manifest", "error", err, "version", manifest.Version)
+		} else {
+			for _, item := range manifest.Sessions {
+				recorded[item.ID] = item
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(fs.basePath, trashDir))
+	if err != nil {
+		return
+	}
+
+	changed := false
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") || entry.Name() == trashManifestFile {
+			continue
+		}
+		sessionID := strings.TrimSuffix(entry.Name(), ".json")
+		if item, ok := recorded[sessionID]; ok {
+			// Sessions trashed by older versions were recorded without their size
+			if info, err := entry.Info(); err == nil && item.Size == 0 {
+				item.Size = info.Size()
+				changed = true
+			}
+			fs.trash[sessionID] = item
+			continue
+		}
+
+		trashPath, err := fs.trashPath(sessionID)
+		if err != nil {
+			fs.logger.Warn("Skipping trashed file with an invalid session ID", "session_id", sessionID)
+			continue
+		}
+		data, err := os.ReadFile(trashPath)
+		if err != nil {
+			continue
+		}
+		var session models.ChatSession
+		if err := DecodeDocument(KindSession, data, &session); err != nil {
+			fs.logger.Warn("Skipping unreadable trashed session", "session_id", sessionID, "error", err)
+			continue
+		}
+		deletedAt := time.Now()
+		if info, err := entry.Info(); err == nil {
+			deletedAt = info.ModTime()
+		}
+		summary := session.Summary()
+		summary.Size = int64(len(data))
+		fs.trash[sessionID] = TrashedSession{SessionSummary: summary, DeletedAt: deletedAt}
+		changed = true
+	}
+
+	if changed || len(fs.trash) != len(recorded) {
+		fs.saveTrashManifest()
+	}
+}
+
+// saveTrashManifest writes the trash manifest. Failures are logged: the manifest is
+// rebuilt from the trashed files on the next start. The caller must hold journalMu.
+func (fs *FileStorage) saveTrashManifest() {
+	manifest := trashManifest{Version: trashManifestVersion, Sessions: make([]TrashedSession, 0, len(fs.trash))}
+	for _, item := range fs.trash {
+		manifest.Sessions = append(manifest.Sessions, item)
+	}
+	sortTrash(manifest.Sessions)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(fs.trashManifestPath(), data, filePerm)
+	}
+	if err != nil {
+		fs.logger.Warn("Failed to save trash manifest", "error", err)
+	}
+	fs.trashState = statFile(fs.trashManifestPath())
+}
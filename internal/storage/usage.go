@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// messageOverhead approximates the bytes a stored message takes beyond its text
+const messageOverhead = 64
+
+// sessionOverhead approximates the bytes a stored session takes beyond its name,
+// system prompt and messages
+const sessionOverhead = 256
+
+// estimateSize approximates the bytes a session takes in storages that do not keep
+// each session in a file of its own
+func estimateSize(session models.ChatSession) int64 {
+	size := int64(sessionOverhead + len(session.Name) + len(session.SystemPrompt))
+	for _, msg := range session.Messages {
+		size += int64(messageOverhead + len(msg.Sender) + len(msg.Content))
+	}
+	return size
+}
+
+// Usage describes the space a storage takes
+type Usage struct {
+	Sessions     []models.SessionSummary // Largest first
+	SessionBytes int64
+	TrashCount   int
+	TrashBytes   int64
+	DiskBytes    int64 // Every file of the storage, including indexes and settings; 0 if unknown
+}
+
+// MeasureUsage reports the size of every session in s and of its trash. paths are the
+// files and directories holding the storage; their total size is reported as DiskBytes.
+func MeasureUsage(ctx context.Context, s Storage, paths ...string) (Usage, error) {
+	page, err := s.ListSessionSummaries(ctx, SessionListOptions{})
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	trashed, err := s.ListTrashedSessions(ctx)
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to list trash: %w", err)
+	}
+
+	usage := Usage{Sessions: page.Sessions, TrashCount: len(trashed)}
+	sort.SliceStable(usage.Sessions, func(i, j int) bool {
+		return usage.Sessions[i].Size > usage.Sessions[j].Size
+	})
+	for _, summary := range usage.Sessions {
+		usage.SessionBytes += summary.Size
+	}
+	for _, item := range trashed {
+		usage.TrashBytes += item.Size
+	}
+
+	if usage.DiskBytes, err = DiskUsage(paths...); err != nil {
+		return Usage{}, err
+	}
+	return usage, nil
+}
+
+// DiskUsage returns the total size of the files in paths, counting each file once
+// even when paths overlap. Missing paths count as empty.
+func DiskUsage(paths ...string) (int64, error) {
+	seen := make(map[string]bool)
+	var total int64
+	for _, root := range paths {
+		if root == "" {
+			continue
+		}
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			abs, err := filepath.Abs(path)
+			if err != nil || seen[abs] {
+				return err
+			}
+			seen[abs] = true
+			if info, err := entry.Info(); err == nil {
+				total += info.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to measure %s: %w", root, err)
+		}
+	}
+	return total, nil
+}
+
+// FormatBytes formats a byte count for display, such as "1.5 MB"
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
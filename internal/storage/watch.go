@@ -0,0 +1,315 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// watchDebounce is how long the data directory must be quiet before changes are
+// published. Sync clients and atomic writes touch a file several times in a row.
+const watchDebounce = 300 * time.Millisecond
+
+// ErrSessionConflict is returned when a session is written after another process
+// changed it, by a caller that has not loaded the new version. Loading the session
+// again clears the conflict.
+var ErrSessionConflict = errors.New("session was changed by another instance")
+
+// ChangeKind identifies what changed in the store
+type ChangeKind int
+
+// Kinds of changes published by ChangeWatcher
+const (
+	SessionChanged     ChangeKind = iota // A session was created or updated
+	SessionDeleted                       // A session was deleted or moved to the trash
+	PreferencesChanged                   // The application preferences were saved
+)
+
+// Change describes a change made to the store by another process
+type Change struct {
+	Kind      ChangeKind
+	SessionID string
+	Session   models.ChatSession // The new version, for SessionChanged
+}
+
+// ChangeWatcher is implemented by storages that publish changes made by other
+// processes, such as another instance or a sync client writing to the data directory.
+// Changes made through the storage itself are not published.
+type ChangeWatcher interface {
+	// WatchChanges calls fn for each change until stop is called or the storage is
+	// closed. fn runs on a background goroutine.
+	WatchChanges(fn func(Change)) (stop func())
+}
+
+// fileState is the size and modification time of a file, or its absence
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// statFile returns the current state of path
+func statFile(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+// sessionFiles is the state of a session's snapshot and journal
+type sessionFiles struct {
+	snapshot fileState
+	journal  fileState
+}
+
+// sessionFilesState returns the current state of a session's files
+func (fs *FileStorage) sessionFilesState(sessionID string) sessionFiles {
+	paths, err := fs.sessionPaths(sessionID)
+	if err != nil {
+		return sessionFiles{}
+	}
+	return sessionFiles{
+		snapshot: statFile(paths.session),
+		journal:  statFile(paths.journal),
+	}
+}
+
+// noteSession records the state of a session's files after this instance read or
+// wrote them, so the watcher skips the change and, if seen is set, later writes are
+// not taken for conflicts. The caller must hold journalMu.
+func (fs *FileStorage) noteSession(sessionID string, seen bool) {
+	state := fs.sessionFilesState(sessionID)
+	fs.observed[sessionID] = state
+	if seen {
+		fs.seen[sessionID] = state
+	}
+}
+
+// forgetSession records that this instance removed a session's files. The caller
+// must hold journalMu.
+func (fs *FileStorage) forgetSession(sessionID string) {
+	fs.observed[sessionID] = fs.sessionFilesState(sessionID)
+	delete(fs.seen, sessionID)
+}
+
+// checkSession returns ErrSessionConflict if a session's files changed since this
+// instance last read or wrote them. The caller must hold journalMu.
+func (fs *FileStorage) checkSession(sessionID string) error {
+	seen, ok := fs.seen[sessionID]
+	if !ok || seen == fs.sessionFilesState(sessionID) {
+		return nil
+	}
+	fs.logger.Warn("Rejected write to a session changed elsewhere", "session_id", sessionID)
+	return fmt.Errorf("%w: %s", ErrSessionConflict, sessionID)
+}
+
+// WatchChanges publishes changes other processes make to sessions and preferences.
+// The data directory is watched from the first call until the storage is closed.
+func (fs *FileStorage) WatchChanges(fn func(Change)) (stop func()) {
+	fs.watchMu.Lock()
+	defer fs.watchMu.Unlock()
+
+	if fs.watcher == nil {
+		if err := fs.startWatching(); err != nil {
+			fs.logger.Warn("Live sync is unavailable", "error", err)
+			return func() {}
+		}
+	}
+
+	id := fs.nextWatchID
+	fs.nextWatchID++
+	fs.subscribers[id] = fn
+	return func() {
+		fs.watchMu.Lock()
+		delete(fs.subscribers, id)
+		fs.watchMu.Unlock()
+	}
+}
+
+// startWatching starts watching the data directory. The caller must hold watchMu.
+func (fs *FileStorage) startWatching() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	// Watch the directories other instances write to, creating them so that the
+	// first session or trashed session from elsewhere is seen
+	for _, dir := range []string{fs.basePath, filepath.Join(fs.basePath, "sessions"), filepath.Join(fs.basePath, trashDir)} {
+		if err := os.MkdirAll(dir, dirPerm); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+
+	fs.prefsState = statFile(filepath.Join(fs.basePath, "preferences.json"))
+	fs.watcher = watcher
+	fs.watchDone = make(chan struct{})
+	go fs.watchLoop(watcher, fs.watchDone)
+
+	fs.logger.Info("Watching data directory for changes", "base_path", fs.basePath)
+	return nil
+}
+
+// stopWatching stops the watcher, if started, and waits for it to finish
+func (fs *FileStorage) stopWatching() {
+	fs.watchMu.Lock()
+	watcher, done := fs.watcher, fs.watchDone
+	fs.watcher = nil
+	fs.subscribers = make(map[int]func(Change))
+	fs.watchMu.Unlock()
+
+	if watcher != nil {
+		watcher.Close()
+		<-done
+	}
+}
+
+// watchLoop collects file events and processes them once the directory is quiet
+func (fs *FileStorage) watchLoop(watcher *fsnotify.Watcher, done chan struct{}) {
+	defer close(done)
+
+	pending := make(map[string]bool)
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if isTempFile(filepath.Base(event.Name)) {
+				continue
+			}
+			pending[event.Name] = true
+			timer.Reset(watchDebounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			fs.logger.Warn("File watcher error", "error", err)
+
+		case <-timer.C:
+			fs.processChanges(pending)
+			pending = make(map[string]bool)
+		}
+	}
+}
+
+// trashChangedElsewhere reports whether the trash manifest or the set of trashed
+// files no longer match what this instance knows. The caller must hold journalMu.
+func (fs *FileStorage) trashChangedElsewhere(trashedIDs map[string]bool) bool {
+	if statFile(fs.trashManifestPath()) != fs.trashState {
+		return true
+	}
+	for sessionID := range trashedIDs {
+		trashPath, err := fs.trashPath(sessionID)
+		if err != nil {
+			continue
+		}
+		_, known := fs.trash[sessionID]
+		if statFile(trashPath).exists != known {
+			return true
+		}
+	}
+	return false
+}
+
+// processChanges brings the storage's caches up to date with files changed by other
+// processes and publishes the changes. Files whose state matches what this instance
+// last wrote are skipped.
+func (fs *FileStorage) processChanges(paths map[string]bool) {
+	sessionIDs := make(map[string]bool)
+	trashedIDs := make(map[string]bool)
+	prefsChanged := false
+	sessionsDir := filepath.Join(fs.basePath, "sessions")
+	trashPath := filepath.Join(fs.basePath, trashDir)
+	for path := range paths {
+		name := filepath.Base(path)
+		switch filepath.Dir(path) {
+		case sessionsDir:
+			if strings.HasSuffix(name, ".json") {
+				sessionIDs[strings.TrimSuffix(name, ".json")] = true
+			} else if strings.HasSuffix(name, journalExt) {
+				sessionIDs[strings.TrimSuffix(name, journalExt)] = true
+			}
+		case trashPath:
+			if strings.HasSuffix(name, ".json") && name != trashManifestFile {
+				trashedIDs[strings.TrimSuffix(name, ".json")] = true
+			}
+		case filepath.Clean(fs.basePath):
+			prefsChanged = prefsChanged || name == "preferences.json"
+		}
+	}
+
+	var changes []Change
+	fs.journalMu.Lock()
+	for sessionID := range sessionIDs {
+		state := fs.sessionFilesState(sessionID)
+		if state == fs.observed[sessionID] {
+			continue
+		}
+		fs.observed[sessionID] = state
+		delete(fs.messageCounts, sessionID)
+		delete(fs.journalSeqs, sessionID)
+
+		if !state.snapshot.exists {
+			fs.unindexSession(sessionID)
+			changes = append(changes, Change{Kind: SessionDeleted, SessionID: sessionID})
+			continue
+		}
+		session, err := fs.loadChatSession(context.Background(), sessionID)
+		if err != nil {
+			// Possibly still being synced; the next event retries
+			fs.logger.Warn("Failed to load session changed elsewhere", "session_id", sessionID, "error", err)
+			delete(fs.observed, sessionID)
+			continue
+		}
+		sessionPath, _ := fs.sessionPath(sessionID)
+		fs.indexSnapshot(session, sessionPath)
+		changes = append(changes, Change{Kind: SessionChanged, SessionID: sessionID, Session: session})
+	}
+	if fs.trashChangedElsewhere(trashedIDs) {
+		fs.trash = make(map[string]TrashedSession)
+		fs.loadTrash()
+		fs.trashState = statFile(fs.trashManifestPath())
+	}
+	fs.journalMu.Unlock()
+
+	fs.watchMu.Lock()
+	if prefsChanged {
+		state := statFile(filepath.Join(fs.basePath, "preferences.json"))
+		if state != fs.prefsState {
+			fs.prefsState = state
+			changes = append(changes, Change{Kind: PreferencesChanged})
+		}
+	}
+	subscribers := make([]func(Change), 0, len(fs.subscribers))
+	for _, fn := range fs.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	fs.watchMu.Unlock()
+
+	for _, change := range changes {
+		fs.logger.Info("Data changed elsewhere", "kind", change.Kind, "session_id", change.SessionID)
+		for _, fn := range subscribers {
+			fn(change)
+		}
+	}
+}
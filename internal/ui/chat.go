@@ -2,6 +2,7 @@ package ui
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -19,6 +20,8 @@ import (
 	"github.com/ashprao/ollamachat/internal/constants"
 	"github.com/ashprao/ollamachat/internal/llm"
 	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/search"
+	"github.com/ashprao/ollamachat/internal/semantic"
 	"github.com/ashprao/ollamachat/internal/storage"
 	"github.com/ashprao/ollamachat/pkg/logger"
 )
@@ -32,6 +35,11 @@ type AppInterface interface {
 	GetConfigPath() string
 	UpdateWindowSize(width, height int)
 	UpdateFontSize(fontSize int)
+	StorageUsage(ctx context.Context) (storage.Usage, error)
+	UpdateRetention() error
+	GetProfiles() []string
+	GetCurrentProfile() string
+	SwitchProfile(name string) error
 }
 
 // NewChatUI creates a new chat UI instance
@@ -52,7 +60,6 @@ func NewChatUI(window fyne.Window, provider llm.Provider, storage storage.Storag
 		currentSession:      models.NewChatSessionWithConfig("Default Session", "", DefaultMaxMessages, DefaultTemperature), // Use constants for initial session
 	}
 
-	ui.currentSession.ID = "default" // For backward compatibility - TBD: remove later
 	ui.logger.Info("Chat UI created")
 	return ui
 }
@@ -78,6 +85,7 @@ type ChatUI struct {
 	statusLabel       *widget.Label
 	modelSelect       *widget.Select
 	providerSelect    *widget.Select
+	profileSelect     *widget.Select // Hidden unless the config file defines profiles
 	providerLabel     *widget.Label
 	sessionModelLabel *widget.Label // Indicates when session has specific model
 	sendButton        *widget.Button
@@ -86,18 +94,34 @@ type ChatUI struct {
 	cancelButton      *widget.Button
 	settingsButton    *widget.Button
 	quitButton        *widget.Button
+	templateButton    *widget.Button
 
 	// Session management UI
 	sessionList      *widget.List
 	newSessionButton *widget.Button
 	sessionSidebar   *fyne.Container
-	sessions         []models.ChatSession
+	sessions         []models.SessionSummary // Loaded page(s) of the session list
+	sessionTotal     int                     // Number of stored sessions, loaded or not
+	moreSessions     *widget.Button
+	searchEntry      *widget.Entry
+	searchList       *widget.List
+	searchResults    []search.Result
+	semantic         *semantic.Indexer // Nil unless embeddings are enabled
+	similarButton    *widget.Button
+	similarList      *widget.List
+	similarResults   []search.VectorMatch
+	trashButton      *widget.Button
+	undoBar          *widget.PopUp // Offers to undo the last session deletion
+	undoTimer        *time.Timer
+	undoGeneration   uint64           // Changes whenever the undo bar is shown or removed
 	mainSplit        *container.Split // Store reference to main split container
 
 	// State
-	cancelFunc      context.CancelFunc
-	queryInProgress bool
-	currentSession  models.ChatSession
+	cancelFunc          context.CancelFunc
+	queryInProgress     bool
+	currentSession      models.ChatSession
+	remoteChangePending bool            // The current session changed elsewhere during a response
+	shownConflicts      map[string]bool // Sync conflicts already reported in this run
 }
 
 // Initialize sets up the UI components and loads initial data
@@ -131,6 +155,15 @@ func (ui *ChatUI) Initialize() error {
 	// Select current session in the sidebar
 	ui.selectCurrentSessionInList()
 
+	// Follow changes made by other instances and sync clients; the watch ends when
+	// the storage is closed
+	if watcher, ok := ui.storage.(storage.ChangeWatcher); ok {
+		watcher.WatchChanges(func(change storage.Change) {
+			ui.runOnUI(func() { ui.onStorageChange(change) })
+		})
+	}
+	ui.showSyncConflicts()
+
 	ui.logger.Info("Chat UI initialized successfully")
 	return nil
 }
@@ -143,6 +176,7 @@ func (ui *ChatUI) setupUI() {
 
 	ui.initButtons()
 	ui.initProviderUI()
+	ui.initProfileUI()
 	ui.setupSessionSidebar()
 
 	// Create model selection container
@@ -157,8 +191,8 @@ func (ui *ChatUI) setupUI() {
 	// Button area - Group by importance: High, Medium (grouped together), Danger
 	buttons := container.NewVBox(ui.sendButton, ui.saveButton, ui.clearButton, ui.settingsButton, ui.quitButton)
 
-	// Input area
-	inputArea := container.NewBorder(nil, nil, nil, buttons, ui.inputField)
+	// Input area with the template picker next to the input field
+	inputArea := container.NewBorder(nil, nil, container.NewVBox(ui.templateButton), buttons, ui.inputField)
 
 	// Scroll container for messages
 	ui.scrollContainer = container.NewScroll(ui.chatContainer)
@@ -171,6 +205,7 @@ func (ui *ChatUI) setupUI() {
 		statusArea,
 		container.NewVBox(
 			container.NewHBox(
+				ui.profileSelect,
 				ui.providerLabel,
 				modelSelectContainer,
 				widget.NewLabel("Model to be used."),
@@ -256,7 +291,7 @@ func (ui *ChatUI) initButtons() {
 	ui.sendButton = widget.NewButtonWithIcon("Send", theme.ConfirmIcon(), ui.onSendButtonTapped)
 	ui.sendButton.Importance = widget.HighImportance // Blue/primary color when enabled
 
-	ui.saveButton = widget.NewButtonWithIcon("Save", theme.DocumentSaveIcon(), ui.onSaveButtonTapped)
+	ui.saveButton = widget.NewButtonWithIcon("Export", theme.DocumentSaveIcon(), ui.onExportButtonTapped)
 	ui.saveButton.Importance = widget.MediumImportance // Secondary color when enabled
 
 	ui.clearButton = widget.NewButtonWithIcon("Delete", theme.DeleteIcon(), ui.onClearButtonTapped)
@@ -273,12 +308,15 @@ func (ui *ChatUI) initButtons() {
 	})
 	ui.quitButton.Importance = widget.MediumImportance
 
+	ui.templateButton = widget.NewButtonWithIcon("", theme.ContentPasteIcon(), ui.onTemplateButtonTapped)
+	ui.templateButton.Importance = widget.LowImportance
+
 	ui.cancelButton.Hide()
 
 	// Set initial button states
 	ui.sendButton.Disable() // Will be enabled when input is provided
 	ui.clearButton.Enable() // Always allow session deletion
-	ui.saveButton.Disable() // Will be enabled when there are messages to save
+	ui.saveButton.Disable() // Will be enabled when there are messages to export
 }
 
 // initProviderUI initializes provider-related UI components
@@ -307,9 +345,13 @@ func (ui *ChatUI) initProviderUI() {
 func (ui *ChatUI) loadCurrentSession() error {
 	// If we have sessions loaded, use the most recent one (first in sorted list)
 	if len(ui.sessions) > 0 {
-		ui.currentSession = ui.sessions[0]
-		ui.logger.Info("Loaded most recent session", "session_id", ui.currentSession.ID, "message_count", len(ui.currentSession.Messages))
-		return nil
+		session, err := ui.storage.LoadChatSession(context.Background(), ui.sessions[0].ID)
+		if err == nil {
+			ui.currentSession = session
+			ui.logger.Info("Loaded most recent session", "session_id", ui.currentSession.ID, "message_count", len(ui.currentSession.Messages))
+			return nil
+		}
+		ui.logger.Error("Failed to load most recent session, starting a new one", "session_id", ui.sessions[0].ID, "error", err)
 	}
 
 	// No existing sessions, create a new one
@@ -326,6 +368,10 @@ func (ui *ChatUI) loadCurrentSession() error {
 func (ui *ChatUI) saveCurrentSession() error {
 	ctx := context.Background()
 	if err := ui.storage.SaveChatSession(ctx, ui.currentSession); err != nil {
+		if errors.Is(err, storage.ErrSessionConflict) {
+			ui.reconcileCurrentSession(true)
+			return nil
+		}
 		ui.logger.Error("Failed to save session", "error", err)
 		return err
 	}
@@ -372,47 +418,10 @@ func (ui *ChatUI) onSendButtonTapped() {
 	go ui.sendMessageToLLM(ctx, selectedModel, fullPrompt, query, session, chatContainer)
 }
 
+// onClearButtonTapped moves the current session to the trash; the undo bar and the
+// trash view bring it back
 func (ui *ChatUI) onClearButtonTapped() {
-	// Show confirmation dialog for session deletion
-	dialog.ShowConfirm("Delete Session",
-		fmt.Sprintf("Are you sure you want to delete the session '%s'? This action cannot be undone.", ui.currentSession.Name),
-		func(confirmed bool) {
-			if !confirmed {
-				return
-			}
-
-			ui.deleteCurrentSession()
-		}, ui.window)
-}
-
-func (ui *ChatUI) onSaveButtonTapped() {
-	dialog.ShowFileSave(func(writer fyne.URIWriteCloser, err error) {
-		if err != nil || writer == nil {
-			return
-		}
-
-		filename := writer.URI().Name()
-		if !strings.HasSuffix(filename, ".txt") {
-			filename += ".txt"
-		}
-		defer writer.Close()
-
-		// Save chat history to file
-		for _, msg := range ui.currentSession.Messages {
-			var prefix string
-			if msg.Sender == "user" {
-				prefix = "You:"
-			} else {
-				prefix = "LLM:"
-			}
-
-			content := fmt.Sprintf("%s\n%s\n\n", prefix, msg.Content)
-			writer.Write([]byte(content))
-		}
-
-		ui.saveButton.Disable()
-		ui.logger.Info("Chat saved to file", "filename", filename)
-	}, ui.window)
+	ui.deleteCurrentSession()
 }
 
 func (ui *ChatUI) onCancelButtonTapped() {
@@ -536,6 +545,7 @@ func (ui *ChatUI) refreshProviderInfo() {
 // UpdateConfig updates the ChatUI configuration
 func (ui *ChatUI) UpdateConfig(newConfig *config.Config) {
 	ui.config = newConfig
+	ui.refreshProfiles()
 	ui.logger.Info("ChatUI configuration updated")
 }
 
@@ -658,16 +668,20 @@ func (ui *ChatUI) extractModelNames(models []models.Model) []string {
 	return modelNames
 }
 
-// generateDefaultSessionName creates a default session name with incremental counter
+// generateDefaultSessionName creates a default session name with incremental counter.
+// Every stored session is counted, not only the pages loaded into the sidebar.
 func (ui *ChatUI) generateDefaultSessionName() string {
-	// If no sessions exist, always start with "Session 1"
-	if len(ui.sessions) == 0 {
-		return "Session 1"
+	sessions := ui.sessions
+	page, err := ui.storage.ListSessionSummaries(context.Background(), storage.SessionListOptions{})
+	if err != nil {
+		ui.logger.Warn("Failed to list sessions for a default name, using the loaded ones", "error", err)
+	} else {
+		sessions = page.Sessions
 	}
 
 	// Find the highest existing session number
 	maxNumber := 0
-	for _, session := range ui.sessions {
+	for _, session := range sessions {
 		// Parse session names like "Session 1", "Session 2", etc.
 		if strings.HasPrefix(session.Name, "Session ") {
 			numberStr := strings.TrimPrefix(session.Name, "Session ")
@@ -679,7 +693,7 @@ func (ui *ChatUI) generateDefaultSessionName() string {
 		}
 	}
 
-	// Next session number
+	// Next session number, "Session 1" when there are none
 	nextNumber := maxNumber + 1
 	return fmt.Sprintf("Session %d", nextNumber)
 }
@@ -764,11 +778,11 @@ func (ui *ChatUI) addMessageCard(content string, isUserMessage, saveToHistory bo
 		message := models.NewChatMessage(sender, content)
 		session.AddMessage(message)
 
-		// Auto-save session
-		ui.autoSaveCurrentSession()
+		// Auto-save the new message
+		ui.saveLastMessage(session)
 
 		// Refresh sessions list to show updated timestamp
-		go ui.refreshSessionsList()
+		ui.refreshSessionsListInBackground()
 	}
 
 	return messageCard
@@ -794,18 +808,18 @@ func (ui *ChatUI) addMessageCardFromChatMessage(msg models.ChatMessage, saveToHi
 	if saveToHistory {
 		ui.currentSession.AddMessage(msg)
 
-		// Auto-save session
-		ui.autoSaveCurrentSession()
+		// Auto-save the new message
+		ui.saveLastMessage(&ui.currentSession)
 
 		// Refresh sessions list to show updated timestamp
-		go ui.refreshSessionsList()
+		ui.refreshSessionsListInBackground()
 	}
 
 	return messageCard
 }
 
 func (ui *ChatUI) updateSaveButtonState() {
-	if len(ui.currentSession.Messages) > 0 {
+	if len(ui.currentSession.Messages) > 0 || ui.sessionTotal > 1 {
 		ui.saveButton.Enable()
 	} else {
 		ui.saveButton.Disable()
@@ -859,7 +873,7 @@ func (ui *ChatUI) handleLLMResponseError(err error) {
 
 func (ui *ChatUI) buildPromptWithHistory(newUserMessage string, maxMessages int) string {
 	var prompt strings.Builder
-	prompt.WriteString("You are a helpful assistant.\n\n")
+	prompt.WriteString(ui.currentSession.GetSystemPrompt() + "\n\n")
 
 	// Add the last `maxMessages` messages from the history
 	messages := ui.currentSession.Messages
@@ -887,6 +901,9 @@ func (ui *ChatUI) sendMessageToLLM(ctx context.Context, selectedModel, query, us
 	llmResponse := ""
 	var llmMessage *models.ChatMessage
 
+	// Streamed content is written in batches rather than on every chunk
+	flusher := newMessageFlusher(ui.storage, ui.logger, session.ID, streamFlushInterval)
+
 	shouldAutoScroll := func() bool {
 		offset := ui.scrollContainer.Offset.Y
 		maxOffset := ui.scrollContainer.Content.Size().Height - ui.scrollContainer.Size().Height
@@ -912,7 +929,7 @@ func (ui *ChatUI) sendMessageToLLM(ctx context.Context, selectedModel, query, us
 				Timestamp: time.Now(),
 			}
 			ui.currentSession.AddMessage(*llmMessage)
-			ui.autoSaveCurrentSession()
+			flusher.Set(len(ui.currentSession.Messages)-1, *llmMessage)
 		} else {
 			llmResponse += chunk
 			ui.updateRichText(card, llmResponse)
@@ -922,7 +939,7 @@ func (ui *ChatUI) sendMessageToLLM(ctx context.Context, selectedModel, query, us
 				lastIdx := len(ui.currentSession.Messages) - 1
 				if ui.currentSession.Messages[lastIdx].Sender == "llm" {
 					ui.currentSession.Messages[lastIdx].Content = llmResponse
-					ui.autoSaveCurrentSession()
+					flusher.Set(lastIdx, ui.currentSession.Messages[lastIdx])
 				}
 			}
 		}
@@ -934,39 +951,165 @@ func (ui *ChatUI) sendMessageToLLM(ctx context.Context, selectedModel, query, us
 		}
 	})
 
-	// Save the final session state
-	if err := ui.saveCurrentSession(); err != nil {
-		dialog.ShowError(err, ui.window)
+	// Write the rest of the response, then finish it on the UI event path, where
+	// changes made elsewhere meanwhile are handled
+	flushErr := flusher.Flush()
+	ui.runOnUI(func() { ui.finishResponse(session.ID, selectedModel, err, flushErr) })
+}
+
+// finishResponse ends a query once its response is written with flushErr, falling
+// back to a full save if appending failed. If the session changed elsewhere meanwhile,
+// the two versions are merged instead.
+func (ui *ChatUI) finishResponse(sessionID, selectedModel string, err, flushErr error) {
+	if ui.currentSession.ID == sessionID && (ui.remoteChangePending || errors.Is(flushErr, storage.ErrSessionConflict)) {
+		ui.reconcileCurrentSession(true)
+	} else if flushErr != nil {
+		ui.logger.Warn("Failed to append streamed response, saving full session", "session_id", sessionID, "error", flushErr)
+		if ui.currentSession.ID == sessionID {
+			if err := ui.saveCurrentSession(); err != nil {
+				dialog.ShowError(err, ui.window)
+			}
+		}
 	}
 
 	ui.queryInProgress = false
 	ui.updateSendButtonState()
 	ui.handleLLMResponseError(err)
+
+	ui.notifySemanticIndex(sessionID)
+
+	// Title the session after its first complete exchange
+	if err == nil && ui.config.LLM.AutoTitle.Enabled && ui.currentSession.ID == sessionID && ui.currentSession.NeedsAutoTitle() {
+		model := ui.config.LLM.AutoTitle.Model
+		if model == "" {
+			model = selectedModel
+		}
+		messages := append([]models.ChatMessage(nil), ui.currentSession.Messages...)
+		go ui.generateSessionTitle(ui.provider, model, sessionID, messages)
+	}
+}
+
+// generateSessionTitle asks provider's model for a short title for the session's
+// messages, then applies it on the UI event path. It runs on its own goroutine, so
+// everything it needs from the UI is passed in.
+func (ui *ChatUI) generateSessionTitle(provider llm.Provider, model, sessionID string, messages []models.ChatMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), autoTitleTimeout)
+	defer cancel()
+
+	title, err := llm.GenerateTitle(ctx, provider, model, messages)
+	if err != nil {
+		ui.logger.Warn("Failed to generate session title", "session_id", sessionID, "model", model, "error", err)
+		return
+	}
+	ui.runOnUI(func() { ui.applySessionTitle(sessionID, title) })
+}
+
+// applySessionTitle saves a generated title for the session, changing nothing else.
+// The stored session is updated rather than the one shown, which may hold a response
+// that is still streaming.
+func (ui *ChatUI) applySessionTitle(sessionID, title string) {
+	ctx := context.Background()
+
+	// Re-read the session so a rename or new messages since the request are preserved
+	latest, err := ui.storage.LoadChatSession(ctx, sessionID)
+	if err != nil {
+		ui.logger.Warn("Failed to load session for titling", "session_id", sessionID, "error", err)
+		return
+	}
+	if ui.currentSession.ID == sessionID && ui.currentSession.ManuallyNamed {
+		latest.ManuallyNamed = true
+	}
+	if !latest.SetAutoTitle(title) {
+		ui.logger.Info("Skipping generated title for manually named session", "session_id", sessionID)
+		return
+	}
+
+	if err := ui.storage.SaveChatSession(ctx, latest); err != nil {
+		ui.logger.Error("Failed to save generated session title", "session_id", sessionID, "error", err)
+		return
+	}
+
+	if ui.currentSession.ID == sessionID {
+		ui.currentSession.Name = latest.Name
+		ui.currentSession.AutoTitled = true
+	}
+
+	ui.logger.Info("Generated session title", "session_id", sessionID, "title", title)
+	ui.refreshSessionsList()
 }
 
 // Session Management Methods
 
-// loadAllSessions loads all available chat sessions from storage
+// loadAllSessions loads the session summaries shown in the sidebar, keeping at least
+// as many as are already listed so "Show more" pages are not lost on refresh
 func (ui *ChatUI) loadAllSessions() error {
-	ctx := context.Background()
-	sessions, err := ui.storage.ListChatSessions(ctx)
+	page, err := ui.listSessions(ui.sessionListLimit())
+	ui.applySessionPage(page, err)
+	return nil
+}
+
+// sessionListLimit returns how many summaries a refresh loads, which is at least as
+// many as are already listed
+func (ui *ChatUI) sessionListLimit() int {
+	if len(ui.sessions) > sessionPageSize {
+		return len(ui.sessions)
+	}
+	return sessionPageSize
+}
+
+// listSessions reads the first limit session summaries. It only touches storage, so
+// it may run on any goroutine.
+func (ui *ChatUI) listSessions(limit int) (storage.SessionPage, error) {
+	return ui.storage.ListSessionSummaries(context.Background(), storage.SessionListOptions{Limit: limit})
+}
+
+// applySessionPage makes a page read by listSessions the sidebar's sessions
+func (ui *ChatUI) applySessionPage(page storage.SessionPage, err error) {
 	if err != nil {
 		ui.logger.Error("Failed to list sessions", "error", err)
 		// Initialize with empty sessions list
-		ui.sessions = []models.ChatSession{}
-		return nil
+		ui.sessions = []models.SessionSummary{}
+		ui.sessionTotal = 0
+		ui.updateMoreSessionsButton()
+		return
 	}
 
-	ui.sessions = sessions
-	ui.logger.Info("Loaded sessions", "count", len(sessions))
+	ui.sessions = page.Sessions
+	ui.sessionTotal = page.Total
+	ui.updateMoreSessionsButton()
+	ui.logger.Debug("Loaded sessions", "count", len(ui.sessions), "total", ui.sessionTotal)
+}
 
-	// Debug logging for UI session order
-	ui.logger.Info("UI Session order:")
-	for i, session := range ui.sessions {
-		ui.logger.Info("UI Session", "index", i, "id", session.ID, "name", session.Name, "updated_at", session.UpdatedAt.Format("2006-01-02 15:04:05"))
+// loadMoreSessions appends the next page of session summaries to the sidebar
+func (ui *ChatUI) loadMoreSessions() {
+	page, err := ui.storage.ListSessionSummaries(context.Background(), storage.SessionListOptions{
+		Offset: len(ui.sessions),
+		Limit:  sessionPageSize,
+	})
+	if err != nil {
+		ui.logger.Error("Failed to load more sessions", "error", err)
+		dialog.ShowError(fmt.Errorf("failed to load more sessions: %v", err), ui.window)
+		return
 	}
 
-	return nil
+	ui.sessions = append(ui.sessions, page.Sessions...)
+	ui.sessionTotal = page.Total
+	ui.updateMoreSessionsButton()
+	ui.sessionList.Refresh()
+}
+
+// updateMoreSessionsButton shows the "Show more" button while sessions remain unlisted
+func (ui *ChatUI) updateMoreSessionsButton() {
+	if ui.moreSessions == nil {
+		return
+	}
+	searching := ui.searchEntry != nil && ui.searchEntry.Text != ""
+	if remaining := ui.sessionTotal - len(ui.sessions); remaining > 0 && !searching {
+		ui.moreSessions.SetText(fmt.Sprintf("Show more (%d)", remaining))
+		ui.moreSessions.Show()
+	} else {
+		ui.moreSessions.Hide()
+	}
 }
 
 // setupSessionSidebar creates and configures the session management sidebar
@@ -996,7 +1139,11 @@ func (ui *ChatUI) setupSessionSidebar() {
 			nameLabel := container.Objects[0].(*widget.Label)
 			timeLabel := container.Objects[1].(*widget.Label)
 
-			nameLabel.SetText(session.Name)
+			if session.Pinned {
+				nameLabel.SetText(pinnedPrefix + session.Name)
+			} else {
+				nameLabel.SetText(session.Name)
+			}
 			timeLabel.SetText(session.UpdatedAt.Format("Jan 2, 15:04"))
 
 			// Highlight current session
@@ -1012,17 +1159,43 @@ func (ui *ChatUI) setupSessionSidebar() {
 
 	ui.sessionList.OnSelected = ui.onSessionSelected
 
+	// Older sessions are listed a page at a time
+	ui.moreSessions = widget.NewButton("Show more", ui.loadMoreSessions)
+	ui.moreSessions.Importance = widget.LowImportance
+	ui.updateMoreSessionsButton()
+
 	// Session sidebar container with elegant border
 	sidebarTitle := widget.NewLabel("Chat Sessions")
 	sidebarTitle.TextStyle = fyne.TextStyle{Bold: true}
 
+	// Search box, shown when the storage keeps a message index
+	sidebarHeader := container.NewVBox(sidebarTitle, ui.newSessionButton)
+	lists := fyne.CanvasObject(ui.sessionList)
+	if searchBox := ui.setupSessionSearch(); searchBox != nil {
+		if similarButton := ui.setupSimilarSearch(); similarButton != nil {
+			sidebarHeader.Add(container.NewBorder(nil, nil, nil, similarButton, searchBox))
+			lists = container.NewStack(ui.sessionList, ui.searchList, ui.similarList)
+		} else {
+			sidebarHeader.Add(searchBox)
+			lists = container.NewStack(ui.sessionList, ui.searchList)
+		}
+	}
+
+	// Deleted sessions can be restored from the trash
+	ui.trashButton = widget.NewButtonWithIcon("Trash", theme.DeleteIcon(), ui.showTrashDialog)
+	ui.trashButton.Importance = widget.LowImportance
+
+	// Conversations from other chat tools
+	importButton := widget.NewButtonWithIcon("Import", theme.DownloadIcon(), ui.onImportButtonTapped)
+	importButton.Importance = widget.LowImportance
+
 	// Create sidebar content
 	sidebarContent := container.NewBorder(
-		container.NewVBox(sidebarTitle, ui.newSessionButton),
-		nil,
+		sidebarHeader,
+		container.NewVBox(ui.moreSessions, container.NewGridWithColumns(2, importButton, ui.trashButton)),
 		nil,
 		nil,
-		ui.sessionList,
+		lists,
 	)
 
 	// Add padding and create a container with visual separation
@@ -1039,6 +1212,22 @@ func (ui *ChatUI) setupSessionSidebar() {
 
 // onNewSessionTapped handles creating a new chat session
 func (ui *ChatUI) onNewSessionTapped() {
+	// Offer the persona library when it has entries
+	personas, err := ui.storage.ListPersonas(context.Background())
+	if err != nil {
+		ui.logger.Warn("Failed to list personas, creating session without persona", "error", err)
+	}
+
+	if len(personas) == 0 {
+		ui.createNewSession(nil)
+		return
+	}
+
+	showPersonaPicker(ui.window, personas, ui.createNewSession)
+}
+
+// createNewSession creates, saves and switches to a new session, optionally based on a persona
+func (ui *ChatUI) createNewSession(persona *models.Persona) {
 	// Save current session before switching
 	if err := ui.saveCurrentSession(); err != nil {
 		ui.logger.Error("Failed to save current session", "error", err)
@@ -1052,12 +1241,18 @@ func (ui *ChatUI) onNewSessionTapped() {
 		"", // No specific model preference - will use global selection
 	)
 
+	// Apply persona prompt and sampling defaults
+	if persona != nil {
+		persona.ApplyToSession(&newSession)
+	}
+
 	// Save the new session immediately
 	ui.currentSession = newSession
 	ui.autoSaveCurrentSession()
 
 	// Add to beginning of sessions list (most recent first)
-	ui.sessions = append([]models.ChatSession{newSession}, ui.sessions...)
+	ui.sessions = append([]models.SessionSummary{newSession.Summary()}, ui.sessions...)
+	ui.sessionTotal++
 
 	// Clear UI and update session selection
 	ui.chatContainer.Objects = nil
@@ -1066,44 +1261,48 @@ func (ui *ChatUI) onNewSessionTapped() {
 	// Update session selection using helper method
 	ui.updateSessionSelection(newSession)
 
-	// Update session model indicator (should be hidden since new session has no specific model)
-	ui.updateSessionModelIndicator()
+	// Update model selection (a persona may carry a session-specific model)
+	ui.updateModelSelectionForSession()
 
-	ui.logger.Info("Created new session", "session_id", newSession.ID, "session_name", newSession.Name)
+	ui.logger.Info("Created new session", "session_id", newSession.ID, "session_name", newSession.Name, "persona_id", newSession.PersonaID)
 }
 
 // onSessionSelected handles switching to a selected session
 func (ui *ChatUI) onSessionSelected(id widget.ListItemID) {
-	if ui.cancelFunc != nil {
-		ui.cancelFunc() // Cancel any ongoing query before switching sessions
-		ui.cancelFunc = nil
-	}
-
 	if id >= len(ui.sessions) {
 		return
 	}
+	ui.switchToSession(ui.sessions[id].ID)
+}
 
-	selectedSession := ui.sessions[id]
-
+// switchToSession saves the current session and opens the one with the given ID.
+// It reports whether that session is now shown.
+func (ui *ChatUI) switchToSession(sessionID string) bool {
 	// Don't switch if it's the same session
-	if selectedSession.ID == ui.currentSession.ID {
-		return
+	if sessionID == ui.currentSession.ID {
+		return true
+	}
+
+	if ui.cancelFunc != nil {
+		ui.cancelFunc() // Cancel any ongoing query before switching sessions
+		ui.cancelFunc = nil
 	}
 
 	// Save current session before switching
 	if err := ui.saveCurrentSession(); err != nil {
 		ui.logger.Error("Failed to save current session", "error", err)
 		dialog.ShowError(err, ui.window)
-		return
+		return false
 	}
 
 	// Load the latest version of the selected session from storage to ensure we have any recent updates
 	ctx := context.Background()
-	latestSession, err := ui.storage.LoadChatSession(ctx, selectedSession.ID)
+	latestSession, err := ui.storage.LoadChatSession(ctx, sessionID)
 	if err != nil {
-		ui.logger.Error("Failed to load latest session data", "session_id", selectedSession.ID, "error", err)
-		// Fall back to the session from the list if loading fails
-		latestSession = selectedSession
+		ui.logger.Error("Failed to load session", "session_id", sessionID, "error", err)
+		dialog.ShowError(fmt.Errorf("failed to load session: %v", err), ui.window)
+		ui.selectCurrentSessionInList()
+		return false
 	}
 
 	// Switch to selected session using the helper method
@@ -1128,7 +1327,8 @@ func (ui *ChatUI) onSessionSelected(id widget.ListItemID) {
 
 	ui.scrollContainer.ScrollToBottom()
 
-	ui.logger.Info("Switched to session", "session_id", selectedSession.ID, "session_name", selectedSession.Name)
+	ui.logger.Info("Switched to session", "session_id", latestSession.ID, "session_name", latestSession.Name)
+	return true
 }
 
 // refreshSessionsList updates the sessions list and refreshes the UI
@@ -1137,10 +1337,34 @@ func (ui *ChatUI) refreshSessionsList() {
 		ui.logger.Error("Failed to refresh sessions list", "error", err)
 		return
 	}
+	ui.showSessionsList()
+}
+
+// refreshSessionsListInBackground reads the sessions list on its own goroutine, then
+// shows it on the UI event path
+func (ui *ChatUI) refreshSessionsListInBackground() {
+	limit := ui.sessionListLimit()
+	go func() {
+		page, err := ui.listSessions(limit)
+		ui.runOnUI(func() {
+			ui.applySessionPage(page, err)
+			ui.showSessionsList()
+		})
+	}()
+}
+
+// showSessionsList redraws the loaded sessions, selecting the current one and
+// running the search again if one is shown
+func (ui *ChatUI) showSessionsList() {
 	ui.sessionList.Refresh()
 
 	// Select current session in the list if it exists
 	ui.selectCurrentSessionInList()
+
+	// Keep search results in step with renamed and deleted sessions
+	if ui.searchEntry != nil && ui.searchEntry.Text != "" && !ui.similarShown() {
+		ui.runSessionSearch()
+	}
 }
 
 // selectCurrentSessionInList finds and selects the current session in the session list
@@ -1161,41 +1385,91 @@ func (ui *ChatUI) autoSaveCurrentSession() {
 	}
 }
 
-// deleteCurrentSession deletes the current chat session and updates the UI
+// saveLastMessage appends the session's newest message to storage without rewriting
+// the session, falling back to a full save if the session is not stored yet
+func (ui *ChatUI) saveLastMessage(session *models.ChatSession) {
+	last := len(session.Messages) - 1
+	if last < 0 {
+		return
+	}
+
+	err := ui.storage.AppendMessages(context.Background(), session.ID, last, session.Messages[last:])
+	if errors.Is(err, storage.ErrSessionConflict) && session == &ui.currentSession {
+		ui.reconcileCurrentSession(true)
+	} else if err != nil {
+		ui.logger.Warn("Failed to append message, saving full session", "session_id", session.ID, "error", err)
+		if err := ui.storage.SaveChatSession(context.Background(), *session); err != nil {
+			ui.logger.Error("Auto-save failed", "error", err)
+		}
+	}
+}
+
+// deleteCurrentSession moves the current chat session to the trash and updates the UI
 func (ui *ChatUI) deleteCurrentSession() {
 	sessionID := ui.currentSession.ID
+	sessionName := ui.currentSession.Name
 	ui.logger.Info("Deleting current session", "session_id", sessionID)
 
-	// Delete the session from storage
-	if err := ui.storage.DeleteChatSession(context.Background(), sessionID); err != nil {
+	// A response still streaming would save the session again
+	if ui.cancelFunc != nil {
+		ui.cancelFunc()
+		ui.cancelFunc = nil
+	}
+
+	// Move the session to the trash
+	if err := ui.storage.TrashChatSession(context.Background(), sessionID); err != nil {
 		ui.logger.Error("Failed to delete session", "session_id", sessionID, "error", err)
 		dialog.ShowError(fmt.Errorf("failed to delete session: %v", err), ui.window)
 		return
 	}
+	ui.notifySemanticIndex(sessionID)
+
+	if !ui.showFirstSession() {
+		return
+	}
+	ui.logger.Info("Session deleted successfully", "deleted_session_id", sessionID, "current_session_id", ui.currentSession.ID)
 
+	ui.showUndoBar(sessionID, sessionName)
+}
+
+// showFirstSession replaces the current session, which is no longer stored, with the
+// most recent remaining one, creating a new session if none remain. It reports
+// whether the session list could be loaded.
+func (ui *ChatUI) showFirstSession() bool {
 	// Get updated sessions list
-	sessions, err := ui.storage.ListChatSessions(context.Background())
+	page, err := ui.storage.ListSessionSummaries(context.Background(), storage.SessionListOptions{Limit: sessionPageSize})
 	if err != nil {
 		ui.logger.Error("Failed to list sessions after deletion", "error", err)
 		dialog.ShowError(fmt.Errorf("failed to refresh sessions: %v", err), ui.window)
-		return
+		return false
 	}
 
 	// Update the local sessions list to reflect the deletion
-	ui.sessions = sessions
+	ui.sessions = page.Sessions
+	ui.sessionTotal = page.Total
+	ui.updateMoreSessionsButton()
+
+	// Load the next session to show, if any remain
+	var next models.ChatSession
+	if len(ui.sessions) > 0 {
+		next, err = ui.storage.LoadChatSession(context.Background(), ui.sessions[0].ID)
+		if err != nil {
+			ui.logger.Error("Failed to load next session after deletion", "session_id", ui.sessions[0].ID, "error", err)
+		}
+	}
 
 	// If no sessions remain, create a new session
-	if len(sessions) == 0 {
+	if next.ID == "" {
 		ui.logger.Info("No sessions remaining, creating new session")
 		newSession := ui.createNewSessionWithDefaults(
-			ui.generateDefaultSessionName(), // This will now return "Session 1" since ui.sessions is empty
+			ui.generateDefaultSessionName(),
 			ui.currentSession.Model,
 		)
 		ui.currentSession = newSession
 		ui.autoSaveCurrentSession()
 	} else {
 		// Switch to the first available session (most recent due to sorting)
-		ui.currentSession = sessions[0]
+		ui.currentSession = next
 		ui.logger.Info("Switched to next available session", "session_id", ui.currentSession.ID)
 	}
 
@@ -1220,23 +1494,14 @@ func (ui *ChatUI) deleteCurrentSession() {
 	ui.scrollContainer.ScrollToBottom()
 
 	// Refresh sessions list and automatically select the current session
-	go func() {
-		ui.refreshSessionsList()
-		// Find and select the current session in the list
-		for i, session := range ui.sessions {
-			if session.ID == ui.currentSession.ID {
-				ui.sessionList.Select(i)
-				break
-			}
-		}
-	}()
+	ui.refreshSessionsListInBackground()
 
 	// Always enable delete button, conditionally enable save button based on messages
 	ui.clearButton.Enable()
 	ui.updateSaveButtonState()
 
 	ui.window.Content().Refresh()
-	ui.logger.Info("Session deleted successfully", "deleted_session_id", sessionID, "current_session_id", ui.currentSession.ID)
+	return true
 }
 
 // loadCurrentSessionMessages loads messages from the current session into the UI
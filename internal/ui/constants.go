@@ -1,12 +1,17 @@
 package ui
 
-import "github.com/ashprao/ollamachat/internal/constants"
+import (
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/constants"
+)
 
 // Re-export constants for backward compatibility
 const (
 	DefaultModelName    = constants.DefaultModelName
 	DefaultProvider     = constants.DefaultProvider
 	DefaultTemperature  = constants.DefaultTemperature
+	DefaultSystemPrompt = constants.DefaultSystemPrompt
 	DefaultMaxMessages  = constants.DefaultMaxMessages
 	DefaultMaxTokens    = constants.DefaultMaxTokens
 	DefaultWindowWidth  = constants.DefaultWindowWidth
@@ -17,3 +22,18 @@ const (
 	UserMessageTitle    = constants.UserMessageTitle
 	LLMMessageTitle     = constants.LLMMessageTitle
 )
+
+// autoTitleTimeout bounds the background request that names a new session
+const autoTitleTimeout = 60 * time.Second
+
+// streamFlushInterval is how often streamed response text is written to storage
+const streamFlushInterval = 500 * time.Millisecond
+
+// sessionPageSize is how many sessions the sidebar lists before "Show more" is needed
+const sessionPageSize = 100
+
+// pinnedPrefix marks pinned sessions in the sidebar
+const pinnedPrefix = "★ "
+
+// usageListLimit is how many of the largest sessions the storage usage report lists
+const usageListLimit = 20
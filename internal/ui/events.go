@@ -0,0 +1,27 @@
+package ui
+
+import (
+	"fyne.io/fyne/v2"
+)
+
+// eventQueuer is implemented by the windows of the Fyne drivers, which run the
+// handlers for user events one at a time from a queue
+type eventQueuer interface {
+	QueueEvent(fn func())
+}
+
+// RunOnUI runs f on the event path of window, after the events already queued and
+// never at the same time as another event handler. Work done on other goroutines
+// hands its results to the UI this way. Without an event queue f runs at once.
+func RunOnUI(window fyne.Window, f func()) {
+	if queue, ok := window.(eventQueuer); ok {
+		queue.QueueEvent(f)
+		return
+	}
+	f()
+}
+
+// runOnUI runs f on the event path of the chat window
+func (ui *ChatUI) runOnUI(f func()) {
+	RunOnUI(ui.window, f)
+}
@@ -0,0 +1,135 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ashprao/ollamachat/internal/export"
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/storage"
+)
+
+// Export scopes offered by the export dialog
+const (
+	exportCurrent  = "Current session"
+	exportSelected = "Selected sessions"
+	exportAll      = "All sessions"
+)
+
+// onExportButtonTapped asks for an export format and which sessions to export, then
+// for the file to write
+func (ui *ChatUI) onExportButtonTapped() {
+	formats := export.Formats()
+	titles := make([]string, len(formats))
+	for i, f := range formats {
+		titles[i] = f.Title()
+	}
+	formatSelect := widget.NewSelect(titles, nil)
+	formatSelect.SetSelectedIndex(0)
+
+	// Every stored session can be picked, not just the loaded pages of the sidebar
+	page, err := ui.storage.ListSessionSummaries(context.Background(), storage.SessionListOptions{})
+	if err != nil {
+		ui.logger.Error("Failed to list sessions for export", "error", err)
+		dialog.ShowError(fmt.Errorf("failed to list sessions: %v", err), ui.window)
+		return
+	}
+	labels, idsByLabel := sessionChoices(page.Sessions)
+	sessionChecks := widget.NewCheckGroup(labels, nil)
+	sessionScroll := container.NewVScroll(sessionChecks)
+	sessionScroll.SetMinSize(fyne.NewSize(360, 220))
+	sessionScroll.Hide()
+
+	scope := widget.NewRadioGroup([]string{exportCurrent, exportSelected, exportAll}, func(selected string) {
+		if selected == exportSelected {
+			sessionScroll.Show()
+		} else {
+			sessionScroll.Hide()
+		}
+	})
+	scope.Required = true
+	scope.SetSelected(exportCurrent)
+
+	content := container.NewBorder(
+		container.NewVBox(widget.NewForm(widget.NewFormItem("Format", formatSelect)), scope),
+		nil, nil, nil,
+		sessionScroll,
+	)
+
+	exportDialog := dialog.NewCustomConfirm("Export Sessions", "Export…", "Cancel", content, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		exporter := formats[formatSelect.SelectedIndex()]
+
+		var sessions []models.ChatSession
+		switch scope.Selected {
+		case exportCurrent:
+			sessions = []models.ChatSession{ui.currentSession}
+		case exportSelected:
+			ids := make([]string, 0, len(sessionChecks.Selected))
+			for _, label := range sessionChecks.Selected {
+				ids = append(ids, idsByLabel[label])
+			}
+			if len(ids) == 0 {
+				dialog.ShowInformation("Export Sessions", "Select at least one session.", ui.window)
+				return
+			}
+			if sessions, err = export.LoadSessions(context.Background(), ui.storage, ids); err != nil {
+				ui.logger.Error("Failed to load sessions for export", "error", err)
+				dialog.ShowError(err, ui.window)
+				return
+			}
+		default:
+			if sessions, err = export.LoadSessions(context.Background(), ui.storage, nil); err != nil {
+				ui.logger.Error("Failed to load sessions for export", "error", err)
+				dialog.ShowError(err, ui.window)
+				return
+			}
+		}
+
+		ui.saveExport(exporter, sessions)
+	}, ui.window)
+	exportDialog.Resize(fyne.NewSize(420, 420))
+	exportDialog.Show()
+}
+
+// saveExport asks for a file and writes sessions to it with exporter
+func (ui *ChatUI) saveExport(exporter export.Exporter, sessions []models.ChatSession) {
+	fileDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil || writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		if err := exporter.Export(writer, sessions); err != nil {
+			ui.logger.Error("Failed to export sessions", "format", exporter.Name(), "error", err)
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		ui.logger.Info("Exported sessions", "format", exporter.Name(), "count", len(sessions), "file", writer.URI().Name())
+	}, ui.window)
+	fileDialog.SetFileName(export.FileName(exporter, sessions))
+	fileDialog.Show()
+}
+
+// sessionChoices labels sessions for the export picker, keeping labels unique, and
+// maps each label back to its session ID
+func sessionChoices(summaries []models.SessionSummary) ([]string, map[string]string) {
+	labels := make([]string, 0, len(summaries))
+	idsByLabel := make(map[string]string, len(summaries))
+	for _, summary := range summaries {
+		label := fmt.Sprintf("%s (%s)", summary.Name, summary.UpdatedAt.Format("Jan 2, 15:04"))
+		if _, taken := idsByLabel[label]; taken {
+			label = fmt.Sprintf("%s [%s]", label, summary.ID)
+		}
+		labels = append(labels, label)
+		idsByLabel[label] = summary.ID
+	}
+	return labels, idsByLabel
+}
@@ -0,0 +1,87 @@
+package ui
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/storage"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+// messageFlusher batches updates to the tail of a session's messages during streaming
+// and writes them with Storage.AppendMessages on a timer and when flushed explicitly,
+// instead of rewriting the whole session for every chunk.
+type messageFlusher struct {
+	storage   storage.Storage
+	logger    *logger.Logger
+	sessionID string
+	interval  time.Duration
+
+	mu      sync.Mutex // Guards the pending state below
+	from    int
+	pending []models.ChatMessage
+	timer   *time.Timer
+
+	flushMu sync.Mutex // Keeps writes in order when the timer and Flush race
+}
+
+// newMessageFlusher creates a flusher for one session
+func newMessageFlusher(store storage.Storage, logger *logger.Logger, sessionID string, interval time.Duration) *messageFlusher {
+	return &messageFlusher{
+		storage:   store,
+		logger:    logger,
+		sessionID: sessionID,
+		interval:  interval,
+	}
+}
+
+// Set records the latest content of the message at index and schedules a flush
+func (f *messageFlusher) Set(index int, message models.ChatMessage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case f.pending == nil:
+		f.from = index
+		f.pending = []models.ChatMessage{message}
+	case index >= f.from && index < f.from+len(f.pending):
+		f.pending[index-f.from] = message
+	case index == f.from+len(f.pending):
+		f.pending = append(f.pending, message)
+	default:
+		// Not contiguous with the pending range; restart from the lower index
+		f.logger.Warn("Non-contiguous streaming update", "session_id", f.sessionID, "index", index, "from", f.from)
+		f.from = index
+		f.pending = []models.ChatMessage{message}
+	}
+
+	if f.timer == nil {
+		f.timer = time.AfterFunc(f.interval, func() {
+			if err := f.Flush(); err != nil {
+				f.logger.Error("Periodic flush of streamed messages failed", "session_id", f.sessionID, "error", err)
+			}
+		})
+	}
+}
+
+// Flush writes any pending messages immediately
+func (f *messageFlusher) Flush() error {
+	f.flushMu.Lock()
+	defer f.flushMu.Unlock()
+
+	f.mu.Lock()
+	from, pending := f.from, f.pending
+	f.pending = nil
+	if f.timer != nil {
+		f.timer.Stop()
+		f.timer = nil
+	}
+	f.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+	return f.storage.AppendMessages(context.Background(), f.sessionID, from, pending)
+}
@@ -0,0 +1,140 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	fynestorage "fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ashprao/ollamachat/internal/importer"
+	"github.com/ashprao/ollamachat/internal/models"
+)
+
+// onImportButtonTapped asks for a file exported from another chat tool and previews
+// the conversations found in it
+func (ui *ChatUI) onImportButtonTapped() {
+	fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil || reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			ui.logger.Error("Failed to read import file", "file", reader.URI().Name(), "error", err)
+			dialog.ShowError(fmt.Errorf("failed to read %s: %v", reader.URI().Name(), err), ui.window)
+			return
+		}
+
+		format, sessions, err := importer.Parse("auto", data)
+		if err != nil {
+			ui.logger.Error("Failed to parse import file", "file", reader.URI().Name(), "error", err)
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		if len(sessions) == 0 {
+			dialog.ShowInformation("Import Conversations", fmt.Sprintf("No conversations found in this %s.", format.Title()), ui.window)
+			return
+		}
+
+		candidates, err := importer.Preview(context.Background(), ui.storage, sessions)
+		if err != nil {
+			ui.logger.Error("Failed to check imported sessions", "error", err)
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		ui.showImportPreview(format, candidates)
+	}, ui.window)
+
+	fileDialog.SetFilter(fynestorage.NewExtensionFileFilter([]string{".json", ".txt", ".log"}))
+	fileDialog.Show()
+}
+
+// showImportPreview lists the parsed conversations so the user can pick which to
+// import. Duplicates of stored conversations start unselected.
+func (ui *ChatUI) showImportPreview(format importer.Importer, candidates []importer.Candidate) {
+	labels := make([]string, len(candidates))
+	byLabel := make(map[string]models.ChatSession, len(candidates))
+	var selected []string
+	duplicates := 0
+	for i, candidate := range candidates {
+		session := candidate.Session
+		label := fmt.Sprintf("%d. %s · %d messages · %s", i+1, truncateText(session.Name, 50),
+			len(session.Messages), session.CreatedAt.Format("Jan 2, 2006"))
+		switch {
+		case candidate.Existing != "":
+			label += fmt.Sprintf(" (already stored as \"%s\")", truncateText(candidate.Existing, 30))
+		case candidate.Duplicate:
+			label += " (repeated in this file)"
+		}
+
+		labels[i] = label
+		byLabel[label] = session
+		if candidate.Duplicate {
+			duplicates++
+		} else {
+			selected = append(selected, label)
+		}
+	}
+
+	checks := widget.NewCheckGroup(labels, nil)
+	checks.SetSelected(selected)
+	listScroll := container.NewVScroll(checks)
+	listScroll.SetMinSize(fyne.NewSize(520, 300))
+
+	summary := fmt.Sprintf("Found %d conversation(s) in this %s.", len(candidates), format.Title())
+	if duplicates > 0 {
+		summary += fmt.Sprintf(" %d are already stored or repeated and are not selected.", duplicates)
+	}
+	header := widget.NewLabel(summary)
+	header.Wrapping = fyne.TextWrapWord
+
+	selectAll := widget.NewButton("Select All", func() { checks.SetSelected(labels) })
+	selectNone := widget.NewButton("Select None", func() { checks.SetSelected(nil) })
+
+	content := container.NewBorder(header, container.NewHBox(selectAll, selectNone), nil, nil, listScroll)
+	previewDialog := dialog.NewCustomConfirm("Import Conversations", "Import", "Cancel", content, func(confirmed bool) {
+		if !confirmed || len(checks.Selected) == 0 {
+			return
+		}
+
+		sessions := make([]models.ChatSession, 0, len(checks.Selected))
+		for _, label := range labels {
+			for _, chosen := range checks.Selected {
+				if chosen == label {
+					sessions = append(sessions, byLabel[label])
+					break
+				}
+			}
+		}
+		ui.importSessions(sessions)
+	}, ui.window)
+	previewDialog.Resize(fyne.NewSize(600, 480))
+	previewDialog.Show()
+}
+
+// importSessions saves imported sessions and refreshes the session list
+func (ui *ChatUI) importSessions(sessions []models.ChatSession) {
+	report, err := importer.Save(context.Background(), ui.storage, sessions)
+	if err != nil {
+		ui.logger.Error("Failed to import sessions", "error", err)
+		dialog.ShowError(fmt.Errorf("failed to import sessions: %v", err), ui.window)
+	}
+	for _, session := range sessions {
+		ui.notifySemanticIndex(session.ID)
+	}
+	ui.refreshSessionsList()
+
+	ui.logger.Info("Imported sessions", "sessions", report.Sessions, "messages", report.Messages, "skipped", len(report.Skipped))
+	message := fmt.Sprintf("Imported %d conversation(s) with %d messages.", report.Sessions, report.Messages)
+	if len(report.Skipped) > 0 {
+		message += fmt.Sprintf("\n\n%d could not be saved:\n%s", len(report.Skipped), strings.Join(report.Skipped, "\n"))
+	}
+	dialog.ShowInformation("Import Conversations", message, ui.window)
+}
@@ -0,0 +1,327 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	fynestorage "fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/storage"
+	"github.com/ashprao/ollamachat/internal/validation"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+// noPersonaOption is the picker entry used to create a session without a persona
+const noPersonaOption = "(No persona)"
+
+// PersonaManager provides the UI for browsing and editing the persona library
+type PersonaManager struct {
+	logger  *logger.Logger
+	window  fyne.Window
+	storage storage.Storage
+
+	personas []models.Persona
+	selected int
+	list     *widget.List
+}
+
+// NewPersonaManager creates a new persona manager
+func NewPersonaManager(window fyne.Window, storage storage.Storage, logger *logger.Logger) *PersonaManager {
+	return &PersonaManager{
+		logger:   logger.WithComponent("persona-manager"),
+		window:   window,
+		storage:  storage,
+		selected: -1,
+	}
+}
+
+// Content builds the persona library view used inside the settings dialog
+func (pm *PersonaManager) Content() fyne.CanvasObject {
+	pm.reload()
+
+	pm.list = widget.NewList(
+		func() int {
+			return len(pm.personas)
+		},
+		func() fyne.CanvasObject {
+			return container.NewVBox(
+				widget.NewLabel("Persona Name"),
+				widget.NewLabel("Description"),
+			)
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id >= len(pm.personas) {
+				return
+			}
+
+			persona := pm.personas[id]
+			row := obj.(*fyne.Container)
+			nameLabel := row.Objects[0].(*widget.Label)
+			descLabel := row.Objects[1].(*widget.Label)
+
+			nameLabel.SetText(persona.Name)
+			nameLabel.TextStyle = fyne.TextStyle{Bold: true}
+			nameLabel.Refresh()
+
+			description := persona.Description
+			if description == "" {
+				description = truncateText(persona.SystemPrompt, 60)
+			}
+			descLabel.SetText(description)
+		},
+	)
+	pm.list.OnSelected = func(id widget.ListItemID) {
+		pm.selected = id
+	}
+	pm.list.OnUnselected = func(widget.ListItemID) {
+		pm.selected = -1
+	}
+
+	newButton := widget.NewButtonWithIcon("New", theme.ContentAddIcon(), func() {
+		pm.showEditForm(models.NewPersona("", "", DefaultTemperature), true)
+	})
+	editButton := widget.NewButtonWithIcon("Edit", theme.DocumentCreateIcon(), func() {
+		if persona, ok := pm.selectedPersona(); ok {
+			pm.showEditForm(persona, false)
+		}
+	})
+	deleteButton := widget.NewButtonWithIcon("Delete", theme.DeleteIcon(), pm.onDeleteTapped)
+	importButton := widget.NewButtonWithIcon("Import", theme.FolderOpenIcon(), pm.onImportTapped)
+	exportButton := widget.NewButtonWithIcon("Export", theme.DocumentSaveIcon(), pm.onExportTapped)
+
+	buttons := container.NewHBox(newButton, editButton, deleteButton, importButton, exportButton)
+
+	listScroll := container.NewVScroll(pm.list)
+	listScroll.SetMinSize(fyne.NewSize(400, 250))
+
+	return container.NewBorder(nil, buttons, nil, nil, listScroll)
+}
+
+// reload refreshes the persona list from storage
+func (pm *PersonaManager) reload() {
+	personas, err := pm.storage.ListPersonas(context.Background())
+	if err != nil {
+		pm.logger.Error("Failed to list personas", "error", err)
+		personas = []models.Persona{}
+	}
+	pm.personas = personas
+	pm.selected = -1
+
+	if pm.list != nil {
+		pm.list.UnselectAll()
+		pm.list.Refresh()
+	}
+}
+
+// selectedPersona returns the currently selected persona, if any
+func (pm *PersonaManager) selectedPersona() (models.Persona, bool) {
+	if pm.selected < 0 || pm.selected >= len(pm.personas) {
+		dialog.ShowInformation("Personas", "Select a persona first.", pm.window)
+		return models.Persona{}, false
+	}
+	return pm.personas[pm.selected], true
+}
+
+// showEditForm shows a form to create or edit a persona
+func (pm *PersonaManager) showEditForm(persona models.Persona, isNew bool) {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(persona.Name)
+
+	descriptionEntry := widget.NewEntry()
+	descriptionEntry.SetText(persona.Description)
+
+	promptEntry := widget.NewMultiLineEntry()
+	promptEntry.Wrapping = fyne.TextWrapWord
+	promptEntry.SetMinRowsVisible(6)
+	promptEntry.SetText(persona.SystemPrompt)
+
+	modelEntry := widget.NewEntry()
+	modelEntry.SetPlaceHolder("Leave empty to use the global model")
+	modelEntry.SetText(persona.Model)
+
+	temperatureEntry := widget.NewEntry()
+	temperatureEntry.SetText(fmt.Sprintf("%.2f", persona.Temperature))
+
+	maxMessagesEntry := widget.NewEntry()
+	maxMessagesEntry.SetPlaceHolder("0 uses the configured default")
+	maxMessagesEntry.SetText(strconv.Itoa(persona.MaxMessages))
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Description", descriptionEntry),
+		widget.NewFormItem("System Prompt", promptEntry),
+		widget.NewFormItem("Model", modelEntry),
+		widget.NewFormItem("Temperature", temperatureEntry),
+		widget.NewFormItem("Max Messages", maxMessagesEntry),
+	}
+
+	title := "Edit Persona"
+	if isNew {
+		title = "New Persona"
+	}
+
+	formDialog := dialog.NewForm(title, "Save", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		name := strings.TrimSpace(nameEntry.Text)
+		if name == "" {
+			dialog.ShowError(fmt.Errorf("persona name cannot be empty"), pm.window)
+			return
+		}
+
+		temperature, err := validation.ValidateFloat(temperatureEntry.Text, "temperature", 0, 2)
+		if err != nil {
+			dialog.ShowError(err, pm.window)
+			return
+		}
+
+		maxMessages, err := validation.ValidateNonNegativeInt(maxMessagesEntry.Text, "max messages")
+		if err != nil {
+			dialog.ShowError(err, pm.window)
+			return
+		}
+
+		persona.Name = name
+		persona.Description = strings.TrimSpace(descriptionEntry.Text)
+		persona.SystemPrompt = promptEntry.Text
+		persona.Model = strings.TrimSpace(modelEntry.Text)
+		persona.Temperature = temperature
+		persona.MaxMessages = maxMessages
+
+		if err := pm.storage.SavePersona(context.Background(), persona); err != nil {
+			pm.logger.Error("Failed to save persona", "persona_id", persona.ID, "error", err)
+			dialog.ShowError(fmt.Errorf("failed to save persona: %w", err), pm.window)
+			return
+		}
+
+		pm.logger.Info("Saved persona", "persona_id", persona.ID, "name", persona.Name)
+		pm.reload()
+	}, pm.window)
+
+	formDialog.Resize(fyne.NewSize(500, 450))
+	formDialog.Show()
+}
+
+// onDeleteTapped deletes the selected persona after confirmation
+func (pm *PersonaManager) onDeleteTapped() {
+	persona, ok := pm.selectedPersona()
+	if !ok {
+		return
+	}
+
+	dialog.ShowConfirm("Delete Persona",
+		fmt.Sprintf("Are you sure you want to delete the persona '%s'? Existing sessions keep their system prompt.", persona.Name),
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+
+			if err := pm.storage.DeletePersona(context.Background(), persona.ID); err != nil {
+				pm.logger.Error("Failed to delete persona", "persona_id", persona.ID, "error", err)
+				dialog.ShowError(fmt.Errorf("failed to delete persona: %w", err), pm.window)
+				return
+			}
+
+			pm.reload()
+		}, pm.window)
+}
+
+// onImportTapped imports personas from a persona bundle file
+func (pm *PersonaManager) onImportTapped() {
+	fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil || reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		count, err := storage.ImportPersonas(context.Background(), pm.storage, reader)
+		if err != nil {
+			pm.logger.Error("Failed to import personas", "error", err)
+			dialog.ShowError(err, pm.window)
+			return
+		}
+
+		pm.logger.Info("Imported personas", "count", count, "source", reader.URI().Name())
+		pm.reload()
+		dialog.ShowInformation("Personas Imported", fmt.Sprintf("Imported %d persona(s).", count), pm.window)
+	}, pm.window)
+
+	fileDialog.SetFilter(fynestorage.NewExtensionFileFilter([]string{".json"}))
+	fileDialog.Show()
+}
+
+// onExportTapped exports the whole persona library to a persona bundle file
+func (pm *PersonaManager) onExportTapped() {
+	if len(pm.personas) == 0 {
+		dialog.ShowInformation("Personas", "There are no personas to export.", pm.window)
+		return
+	}
+
+	fileDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil || writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		if err := storage.ExportPersonas(writer, pm.personas); err != nil {
+			pm.logger.Error("Failed to export personas", "error", err)
+			dialog.ShowError(err, pm.window)
+			return
+		}
+
+		pm.logger.Info("Exported personas", "count", len(pm.personas), "filename", writer.URI().Name())
+	}, pm.window)
+
+	fileDialog.SetFileName("personas.json")
+	fileDialog.Show()
+}
+
+// showPersonaPicker asks which persona a new session should start from.
+// onPicked receives nil when the user chooses to start without a persona.
+func showPersonaPicker(window fyne.Window, personas []models.Persona, onPicked func(*models.Persona)) {
+	options := make([]string, 0, len(personas)+1)
+	options = append(options, noPersonaOption)
+	for _, persona := range personas {
+		options = append(options, persona.Name)
+	}
+
+	picker := widget.NewSelect(options, nil)
+	picker.SetSelectedIndex(0)
+
+	dialog.ShowCustomConfirm("New Session", "Create", "Cancel",
+		container.NewVBox(widget.NewLabel("Start the session with a persona:"), picker),
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+
+			index := picker.SelectedIndex()
+			if index <= 0 {
+				onPicked(nil)
+				return
+			}
+
+			persona := personas[index-1]
+			onPicked(&persona)
+		}, window)
+}
+
+// truncateText shortens text to maxLen runes on a single line
+func truncateText(text string, maxLen int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= maxLen {
+		return text
+	}
+	return string(runes[:maxLen]) + "…"
+}
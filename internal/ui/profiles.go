@@ -0,0 +1,75 @@
+package ui
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// noProfileOption is the profile selector entry for the config file without a profile
+const noProfileOption = "(no profile)"
+
+// initProfileUI creates the profile selector, hidden when the config file defines
+// no profiles
+func (ui *ChatUI) initProfileUI() {
+	ui.profileSelect = widget.NewSelect(nil, nil)
+	ui.refreshProfiles()
+	ui.profileSelect.OnChanged = ui.onProfileSelected
+}
+
+// refreshProfiles lists the configured profiles in the selector and selects the one in use
+func (ui *ChatUI) refreshProfiles() {
+	if ui.profileSelect == nil {
+		return
+	}
+	names := ui.app.GetProfiles()
+	ui.profileSelect.Options = append([]string{noProfileOption}, names...)
+
+	selected := ui.app.GetCurrentProfile()
+	if selected == "" {
+		selected = noProfileOption
+	}
+	callback := ui.profileSelect.OnChanged
+	ui.profileSelect.OnChanged = nil
+	ui.profileSelect.SetSelected(selected)
+	ui.profileSelect.OnChanged = callback
+
+	if len(names) == 0 {
+		ui.profileSelect.Hide()
+	} else {
+		ui.profileSelect.Show()
+	}
+}
+
+// onProfileSelected switches to the selected profile. When the profile keeps its
+// sessions apart, the app replaces this chat UI with one for the profile's storage.
+func (ui *ChatUI) onProfileSelected(selected string) {
+	name := selected
+	if selected == noProfileOption {
+		name = ""
+	}
+	if name == ui.app.GetCurrentProfile() {
+		return // No change
+	}
+
+	if ui.queryInProgress {
+		dialog.ShowInformation("Profile Not Switched", "Wait for the current response to finish before switching profiles.", ui.window)
+		ui.refreshProfiles()
+		return
+	}
+
+	ui.logger.Info("Profile selection changed", "from", ui.app.GetCurrentProfile(), "to", name)
+	if err := ui.app.SwitchProfile(name); err != nil {
+		ui.logger.Error("Failed to switch profile", "profile", name, "error", err)
+		dialog.ShowError(err, ui.window)
+		ui.refreshProfiles()
+		return
+	}
+	ui.statusLabel.SetText(fmt.Sprintf("Switched to %s", selected))
+}
+
+// Busy reports whether a response is being received
+func (ui *ChatUI) Busy() bool {
+	return ui.queryInProgress
+}
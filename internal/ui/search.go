@@ -0,0 +1,162 @@
+package ui
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ashprao/ollamachat/internal/search"
+	"github.com/ashprao/ollamachat/internal/storage"
+)
+
+// searchResultLimit caps the number of sessions listed for a search
+const searchResultLimit = 50
+
+// snippetLead is how many bytes of a snippet are kept before the first match, so the
+// match stays visible in the narrow sidebar
+const snippetLead = 20
+
+// setupSessionSearch creates the sidebar search box and its result list. It returns
+// nil if the storage cannot search messages.
+func (ui *ChatUI) setupSessionSearch() fyne.CanvasObject {
+	if _, ok := ui.storage.(storage.Searcher); !ok {
+		return nil
+	}
+
+	ui.searchEntry = widget.NewEntry()
+	ui.searchEntry.SetPlaceHolder("Search messages…")
+	ui.searchEntry.OnChanged = func(string) { ui.runSessionSearch() }
+
+	ui.searchList = widget.NewList(
+		func() int {
+			return len(ui.searchResults)
+		},
+		func() fyne.CanvasObject {
+			name := widget.NewLabel("Session Name")
+			name.TextStyle = fyne.TextStyle{Bold: true}
+			name.Truncation = fyne.TextTruncateEllipsis
+			snippet := widget.NewRichText()
+			snippet.Truncation = fyne.TextTruncateEllipsis
+			return container.NewVBox(name, snippet)
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id >= len(ui.searchResults) {
+				return
+			}
+
+			result := ui.searchResults[id]
+			row := obj.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(result.SessionName)
+			snippet := row.Objects[1].(*widget.RichText)
+			snippet.Segments = snippetSegments(result)
+			snippet.Refresh()
+		},
+	)
+	ui.searchList.OnSelected = ui.onSearchResultSelected
+	ui.searchList.Hide()
+
+	return ui.searchEntry
+}
+
+// runSessionSearch searches messages for the text in the search box and shows the
+// results in place of the session list, or the session list again when it is empty.
+// It changes widgets, so it runs on the UI event path only.
+func (ui *ChatUI) runSessionSearch() {
+	if ui.searchEntry == nil {
+		return
+	}
+
+	if ui.similarShown() {
+		ui.closeSimilarResults()
+		return
+	}
+
+	query := ui.searchEntry.Text
+	if query == "" {
+		ui.searchResults = nil
+		ui.searchList.Hide()
+		ui.sessionList.Show()
+		ui.updateMoreSessionsButton()
+		return
+	}
+
+	searcher, ok := ui.storage.(storage.Searcher)
+	if !ok {
+		return
+	}
+	results, err := searcher.SearchMessages(context.Background(), query, searchResultLimit)
+	if err != nil {
+		ui.logger.Error("Search failed", "query", query, "error", err)
+		results = nil
+	}
+
+	ui.searchResults = results
+	ui.searchList.UnselectAll()
+	ui.searchList.Refresh()
+	ui.sessionList.Hide()
+	ui.moreSessions.Hide()
+	ui.searchList.Show()
+}
+
+// onSearchResultSelected opens the session of a search result at the matching message
+func (ui *ChatUI) onSearchResultSelected(id widget.ListItemID) {
+	if id >= len(ui.searchResults) {
+		return
+	}
+
+	result := ui.searchResults[id]
+	if !ui.switchToSession(result.SessionID) {
+		return
+	}
+	ui.scrollToMessage(result.MessageIndex)
+	ui.logger.Info("Opened search result", "session_id", result.SessionID, "message_index", result.MessageIndex)
+}
+
+// scrollToMessage scrolls the chat so the message at index is at the top
+func (ui *ChatUI) scrollToMessage(index int) {
+	if index < 0 || index >= len(ui.chatContainer.Objects) {
+		return
+	}
+
+	// Lay the messages out so their positions are known
+	ui.chatContainer.Refresh()
+	ui.scrollContainer.Offset = fyne.NewPos(0, ui.chatContainer.Objects[index].Position().Y)
+	ui.scrollContainer.Refresh()
+}
+
+// snippetSegments renders a result snippet with its matches in bold, starting
+// shortly before the first match
+func snippetSegments(result search.Result) []widget.RichTextSegment {
+	text, highlights := result.Snippet, result.Highlights
+
+	if len(highlights) > 0 && highlights[0].Start > snippetLead {
+		cut := highlights[0].Start - snippetLead
+		for cut < len(text) && !utf8.RuneStart(text[cut]) {
+			cut++
+		}
+		text = "…" + text[cut:]
+		shift := len("…") - cut
+		shifted := make([]search.Span, 0, len(highlights))
+		for _, span := range highlights {
+			shifted = append(shifted, search.Span{Start: span.Start + shift, End: span.End + shift})
+		}
+		highlights = shifted
+	}
+
+	var segments []widget.RichTextSegment
+	pos := 0
+	for _, span := range highlights {
+		if span.Start > pos {
+			segments = append(segments, &widget.TextSegment{Text: text[pos:span.Start], Style: widget.RichTextStyleInline})
+		}
+		segments = append(segments, &widget.TextSegment{Text: text[span.Start:span.End], Style: widget.RichTextStyleStrong})
+		pos = span.End
+	}
+	if pos < len(text) {
+		segments = append(segments, &widget.TextSegment{Text: text[pos:], Style: widget.RichTextStyleInline})
+	}
+	return segments
+}
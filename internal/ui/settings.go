@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -32,11 +33,19 @@ type SettingsDialog struct {
 	showTimestampsCheck *widget.Check
 	sidebarWidthEntry   *widget.Entry
 
+	// Retention rules
+	maxSessionsEntry *widget.Entry
+	maxAgeDaysEntry  *widget.Entry
+	maxSizeMBEntry   *widget.Entry
+
 	// Session-specific settings
 	session                 *models.ChatSession
+	sessionNameEntry        *widget.Entry
 	modelSelect             *widget.Select
 	temperatureEntry        *widget.Entry
 	sessionMaxMessagesEntry *widget.Entry
+	systemPromptEntry       *widget.Entry
+	pinnedCheck             *widget.Check
 }
 
 // NewSettingsDialog creates a new settings dialog
@@ -54,9 +63,15 @@ func NewSettingsDialog(window fyne.Window, config *config.Config, session *model
 		fontSizeEntry:           widget.NewEntry(),
 		showTimestampsCheck:     widget.NewCheck("Show timestamps in chat", nil),
 		sidebarWidthEntry:       widget.NewEntry(),
+		maxSessionsEntry:        widget.NewEntry(),
+		maxAgeDaysEntry:         widget.NewEntry(),
+		maxSizeMBEntry:          widget.NewEntry(),
+		sessionNameEntry:        widget.NewEntry(),
 		modelSelect:             widget.NewSelect(availableModels, nil),
 		temperatureEntry:        widget.NewEntry(),
 		sessionMaxMessagesEntry: widget.NewEntry(),
+		systemPromptEntry:       widget.NewMultiLineEntry(),
+		pinnedCheck:             widget.NewCheck("Pinned (never removed by retention rules)", nil),
 	}
 }
 
@@ -72,8 +87,18 @@ func (sd *SettingsDialog) Show() {
 	tabs := container.NewAppTabs(
 		container.NewTabItem("Global Settings", globalTab),
 		container.NewTabItem("Session Settings", sessionTab),
+		container.NewTabItem("Storage", sd.createStorageTab()),
 	)
 
+	// Persona and template edits are saved immediately, independently of the Save button
+	if sd.chatUI != nil {
+		personaManager := NewPersonaManager(sd.window, sd.chatUI.storage, sd.logger)
+		tabs.Append(container.NewTabItem("Personas", personaManager.Content()))
+
+		templateManager := NewTemplateManager(sd.window, sd.chatUI.storage, sd.logger)
+		tabs.Append(container.NewTabItem("Templates", templateManager.Content()))
+	}
+
 	// Use ShowCustomConfirm with proper callback
 	dialog.ShowCustomConfirm(
 		"Settings",
@@ -126,7 +151,7 @@ func (sd *SettingsDialog) createSessionSettingsTab() *fyne.Container {
 
 	sessionInfo := widget.NewCard("Session Information", "",
 		container.NewVBox(
-			widget.NewLabel("Session: "+sd.session.Name),
+			container.NewBorder(nil, nil, widget.NewLabel("Name:"), nil, sd.sessionNameEntry),
 			widget.NewLabel("ID: "+sd.session.ID),
 			widget.NewLabel("Created: "+sd.session.CreatedAt.Format("2006-01-02 15:04:05")),
 		),
@@ -153,6 +178,10 @@ func (sd *SettingsDialog) createSessionSettingsTab() *fyne.Container {
 	}
 	modelInfo.Wrapping = fyne.TextWrapWord
 
+	sd.systemPromptEntry.Wrapping = fyne.TextWrapWord
+	sd.systemPromptEntry.SetMinRowsVisible(4)
+	sd.systemPromptEntry.SetPlaceHolder(DefaultSystemPrompt)
+
 	sessionSettings := widget.NewCard("Session Settings", "",
 		container.NewVBox(
 			modelInfo,
@@ -161,12 +190,36 @@ func (sd *SettingsDialog) createSessionSettingsTab() *fyne.Container {
 				widget.NewLabel("Max Context Messages:"), sd.sessionMaxMessagesEntry,
 				widget.NewLabel("Temperature:"), sd.temperatureEntry,
 			),
+			widget.NewLabel("System Prompt:"),
+			sd.systemPromptEntry,
+			sd.pinnedCheck,
 		),
 	)
 
 	return container.NewVBox(sessionInfo, sessionSettings)
 }
 
+// createStorageTab creates the retention rules and storage usage tab content
+func (sd *SettingsDialog) createStorageTab() fyne.CanvasObject {
+	hint := widget.NewLabel("0 means no limit. Pinned sessions are never removed; other sessions beyond a limit " +
+		"are moved to the trash, oldest first.")
+	hint.Wrapping = fyne.TextWrapWord
+
+	retentionGroup := widget.NewCard("Retention Rules", "",
+		container.NewVBox(
+			container.NewGridWithColumns(2,
+				widget.NewLabel("Max Sessions:"), sd.maxSessionsEntry,
+				widget.NewLabel("Max Age (days):"), sd.maxAgeDaysEntry,
+				widget.NewLabel("Max Total Size (MB):"), sd.maxSizeMBEntry,
+			),
+			hint,
+		),
+	)
+
+	usageGroup := widget.NewCard("Storage Usage", "", sd.createUsageReport())
+	return container.NewBorder(retentionGroup, nil, nil, nil, usageGroup)
+}
+
 // populateCurrentValues fills the form fields with current values
 func (sd *SettingsDialog) populateCurrentValues() {
 	// Global settings
@@ -176,6 +229,9 @@ func (sd *SettingsDialog) populateCurrentValues() {
 	sd.fontSizeEntry.SetText(strconv.Itoa(sd.config.UI.FontSize))
 	sd.showTimestampsCheck.SetChecked(sd.config.UI.ShowTimestamps)
 	sd.sidebarWidthEntry.SetText(strconv.Itoa(sd.config.UI.SidebarWidth))
+	sd.maxSessionsEntry.SetText(strconv.Itoa(sd.config.Storage.Retention.MaxSessions))
+	sd.maxAgeDaysEntry.SetText(strconv.Itoa(sd.config.Storage.Retention.MaxAgeDays))
+	sd.maxSizeMBEntry.SetText(strconv.Itoa(sd.config.Storage.Retention.MaxSizeMB))
 
 	// Session settings
 	if sd.session != nil {
@@ -183,6 +239,9 @@ func (sd *SettingsDialog) populateCurrentValues() {
 		sd.modelSelect.SetSelected(sd.session.Model) // This will be empty string if no session-specific model
 		sd.sessionMaxMessagesEntry.SetText(strconv.Itoa(sd.session.MaxMessages))
 		sd.temperatureEntry.SetText(fmt.Sprintf("%.2f", sd.session.Temperature))
+		sd.systemPromptEntry.SetText(sd.session.SystemPrompt)
+		sd.sessionNameEntry.SetText(sd.session.Name)
+		sd.pinnedCheck.SetChecked(sd.session.Pinned)
 	}
 }
 
@@ -226,6 +285,25 @@ func (sd *SettingsDialog) parseAndValidateFields() (map[string]interface{}, erro
 	}
 	values["sidebarWidth"] = sidebarWidth
 
+	// Parse and validate retention rules (0 disables a rule)
+	maxSessions, err := validation.ValidateNonNegativeInt(sd.maxSessionsEntry.Text, "max sessions")
+	if err != nil {
+		return nil, err
+	}
+	values["maxSessions"] = maxSessions
+
+	maxAgeDays, err := validation.ValidateNonNegativeInt(sd.maxAgeDaysEntry.Text, "max age")
+	if err != nil {
+		return nil, err
+	}
+	values["maxAgeDays"] = maxAgeDays
+
+	maxSizeMB, err := validation.ValidateNonNegativeInt(sd.maxSizeMBEntry.Text, "max total size")
+	if err != nil {
+		return nil, err
+	}
+	values["maxSizeMB"] = maxSizeMB
+
 	// Parse session-specific settings if session exists
 	if sd.session != nil {
 		// Parse and validate session max messages (allow 0 to disable context)
@@ -263,6 +341,11 @@ func (sd *SettingsDialog) saveSettings() error {
 
 	sd.config.UI.ShowTimestamps = sd.showTimestampsCheck.Checked
 
+	retention := sd.config.Storage.Retention
+	sd.config.Storage.Retention.MaxSessions = values["maxSessions"].(int)
+	sd.config.Storage.Retention.MaxAgeDays = values["maxAgeDays"].(int)
+	sd.config.Storage.Retention.MaxSizeMB = values["maxSizeMB"].(int)
+
 	// Validate the updated configuration
 	if err := sd.config.ValidateConfig(); err != nil {
 		return fmt.Errorf("configuration validation failed: %w", err)
@@ -302,8 +385,15 @@ func (sd *SettingsDialog) saveSettings() error {
 			maxMessages,
 			temperature,
 		)
+		sd.session.SystemPrompt = sd.systemPromptEntry.Text
+		sd.session.Pinned = sd.pinnedCheck.Checked
+
+		// A name typed by the user is kept even when automatic titling is enabled
+		if name := strings.TrimSpace(sd.sessionNameEntry.Text); name != "" && name != sd.session.Name {
+			sd.session.Rename(name)
+		}
 
-		sd.logger.Info("Updated session settings", "session_id", sd.session.ID, "model", sd.modelSelect.Selected, "max_messages", maxMessages, "temperature", temperature)
+		sd.logger.Info("Updated session settings", "session_id", sd.session.ID, "model", sd.modelSelect.Selected, "max_messages", maxMessages, "temperature", temperature, "custom_system_prompt", sd.session.SystemPrompt != "")
 
 		// Save the updated session
 		if sd.chatUI != nil {
@@ -319,6 +409,13 @@ func (sd *SettingsDialog) saveSettings() error {
 		}
 	}
 
+	// Apply changed retention rules once the session, which may have been pinned, is saved
+	if sd.config.Storage.Retention != retention {
+		if err := sd.app.UpdateRetention(); err != nil {
+			return fmt.Errorf("failed to apply retention rules: %w", err)
+		}
+	}
+
 	sd.logger.Info("Settings saved successfully")
 	return nil
 }
@@ -345,6 +442,9 @@ func (sd *SettingsDialog) setupRealTimeValidation() {
 	sd.maxMessagesEntry.OnChanged = func(string) { validateCallback() }
 	sd.fontSizeEntry.OnChanged = func(string) { validateCallback() }
 	sd.sidebarWidthEntry.OnChanged = func(string) { validateCallback() }
+	sd.maxSessionsEntry.OnChanged = func(string) { validateCallback() }
+	sd.maxAgeDaysEntry.OnChanged = func(string) { validateCallback() }
+	sd.maxSizeMBEntry.OnChanged = func(string) { validateCallback() }
 	sd.sessionMaxMessagesEntry.OnChanged = func(string) { validateCallback() }
 	sd.temperatureEntry.OnChanged = func(string) { validateCallback() }
 }
@@ -0,0 +1,165 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ashprao/ollamachat/internal/search"
+	"github.com/ashprao/ollamachat/internal/semantic"
+)
+
+// similarResultLimit caps the number of exchanges listed for a similarity query
+const similarResultLimit = 20
+
+// similarQueryTimeout bounds embedding the similarity query
+const similarQueryTimeout = 2 * time.Minute
+
+// SetSemanticIndex enables the "Similar" sidebar query; call before Initialize
+func (ui *ChatUI) SetSemanticIndex(index *semantic.Indexer) {
+	ui.semantic = index
+}
+
+// setupSimilarSearch creates the "Similar" button and its result list. It returns nil
+// if semantic search is not enabled.
+func (ui *ChatUI) setupSimilarSearch() fyne.CanvasObject {
+	if ui.semantic == nil {
+		return nil
+	}
+
+	ui.similarButton = widget.NewButton("Similar", ui.onFindSimilarTapped)
+
+	ui.similarList = widget.NewList(
+		func() int {
+			return len(ui.similarResults)
+		},
+		func() fyne.CanvasObject {
+			name := widget.NewLabel("Session Name")
+			name.TextStyle = fyne.TextStyle{Bold: true}
+			name.Truncation = fyne.TextTruncateEllipsis
+			score := widget.NewLabel("100%")
+			preview := widget.NewLabel("Preview")
+			preview.Truncation = fyne.TextTruncateEllipsis
+			return container.NewVBox(container.NewBorder(nil, nil, nil, score, name), preview)
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id >= len(ui.similarResults) {
+				return
+			}
+
+			match := ui.similarResults[id]
+			row := obj.(*fyne.Container)
+			header := row.Objects[0].(*fyne.Container)
+			header.Objects[0].(*widget.Label).SetText(match.SessionName)
+			header.Objects[1].(*widget.Label).SetText(fmt.Sprintf("%.0f%%", match.Score*100))
+			row.Objects[1].(*widget.Label).SetText(match.Preview)
+		},
+	)
+	ui.similarList.OnSelected = ui.onSimilarResultSelected
+	ui.similarList.Hide()
+
+	return ui.similarButton
+}
+
+// onFindSimilarTapped finds past exchanges similar to the text in the search box, or
+// to the last message sent in the current session. Tapping again while results are
+// shown closes them.
+func (ui *ChatUI) onFindSimilarTapped() {
+	if ui.similarList.Visible() {
+		ui.closeSimilarResults()
+		return
+	}
+
+	text := ui.searchEntry.Text
+	if text == "" {
+		text = ui.lastUserMessage()
+	}
+	if text == "" {
+		dialog.ShowInformation("Find Similar", "Type in the search box or send a message first.", ui.window)
+		return
+	}
+
+	ui.similarButton.Disable()
+	ui.statusLabel.SetText("Finding similar conversations...")
+
+	indexer := ui.semantic
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), similarQueryTimeout)
+		defer cancel()
+
+		matches, err := indexer.FindSimilar(ctx, text, similarResultLimit)
+		ui.runOnUI(func() { ui.showSimilarResults(indexer.Model(), matches, err) })
+	}()
+}
+
+// showSimilarResults shows the matches of a similarity search in place of the session list
+func (ui *ChatUI) showSimilarResults(model string, matches []search.VectorMatch, err error) {
+	ui.similarButton.Enable()
+	ui.statusLabel.SetText("")
+	if err != nil {
+		ui.logger.Error("Similarity search failed", "model", model, "error", err)
+		dialog.ShowError(fmt.Errorf("failed to find similar conversations (is the embedding model %q pulled in Ollama?): %w", model, err), ui.window)
+		return
+	}
+
+	ui.logger.Info("Found similar exchanges", "matches", len(matches))
+	ui.similarResults = matches
+	ui.similarList.UnselectAll()
+	ui.similarList.Refresh()
+	ui.similarList.ScrollToTop()
+	ui.sessionList.Hide()
+	ui.searchList.Hide()
+	ui.moreSessions.Hide()
+	ui.similarList.Show()
+	ui.similarButton.SetText("Close")
+}
+
+// closeSimilarResults hides the similarity results and shows what the search box selects
+func (ui *ChatUI) closeSimilarResults() {
+	ui.similarResults = nil
+	ui.similarList.Hide()
+	ui.similarButton.SetText("Similar")
+	ui.runSessionSearch()
+}
+
+// similarShown reports whether similarity results are in place of the session list
+func (ui *ChatUI) similarShown() bool {
+	return ui.similarList != nil && ui.similarList.Visible()
+}
+
+// lastUserMessage returns the latest message the user sent in the current session
+func (ui *ChatUI) lastUserMessage() string {
+	messages := ui.currentSession.Messages
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Sender == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// onSimilarResultSelected opens the session of a similar exchange at its first message
+func (ui *ChatUI) onSimilarResultSelected(id widget.ListItemID) {
+	if id >= len(ui.similarResults) {
+		return
+	}
+
+	match := ui.similarResults[id]
+	if !ui.switchToSession(match.SessionID) {
+		return
+	}
+	ui.scrollToMessage(match.MessageIndex)
+	ui.logger.Info("Opened similar exchange", "session_id", match.SessionID, "message_index", match.MessageIndex, "score", match.Score)
+}
+
+// notifySemanticIndex schedules a changed or deleted session for embedding
+func (ui *ChatUI) notifySemanticIndex(sessionID string) {
+	if ui.semantic != nil {
+		ui.semantic.Notify(sessionID)
+	}
+}
@@ -0,0 +1,196 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/storage"
+)
+
+// conflictedCopySuffix marks a session saved aside because it was changed here and
+// elsewhere at the same time
+const conflictedCopySuffix = " (conflicted copy)"
+
+// onStorageChange updates the UI for a change made by another instance or a sync client.
+// It runs on the UI event path; the watcher hands each change over.
+func (ui *ChatUI) onStorageChange(change storage.Change) {
+	if change.Kind == storage.PreferencesChanged {
+		// Sessions without their own model follow the global default
+		if ui.currentSession.Model == "" {
+			ui.setModelSelectWithoutCallback(ui.loadGlobalModelPreference(context.Background()))
+		}
+		return
+	}
+
+	defer ui.showSyncConflicts()
+	ui.refreshSessionsList()
+	if change.SessionID != ui.currentSession.ID {
+		ui.notifySemanticIndex(change.SessionID)
+		return
+	}
+
+	// A streaming response is merged with the change once it completes
+	if ui.queryInProgress {
+		ui.remoteChangePending = true
+		return
+	}
+	ui.reconcileCurrentSession(false)
+}
+
+// reconcileCurrentSession merges the stored version of the current session, changed
+// elsewhere, with the one shown here. If only one side added messages the longer
+// version wins, as does a stored version that merged this window's messages with
+// others; otherwise this window's version is saved as a conflicted copy.
+// localChanged reports whether this window has changes that could not be saved.
+func (ui *ChatUI) reconcileCurrentSession(localChanged bool) {
+	ui.remoteChangePending = false
+	local := ui.currentSession
+	ctx := context.Background()
+
+	remote, err := ui.storage.LoadChatSession(ctx, local.ID)
+	if err != nil {
+		ui.logger.Info("Open session was removed elsewhere", "session_id", local.ID, "error", err)
+		if localChanged && len(local.Messages) > 0 {
+			ui.saveConflictedCopy(local, fmt.Sprintf("\"%s\" was deleted elsewhere while this window was changing it. "+
+				"This window's version was saved as a new session.", truncateText(local.Name, 40)))
+			return
+		}
+		if ui.showFirstSession() {
+			dialog.ShowInformation("Session Deleted", fmt.Sprintf("\"%s\" was deleted elsewhere. "+
+				"If it was moved to the trash, it can be restored from there.", truncateText(local.Name, 40)), ui.window)
+		}
+		return
+	}
+
+	switch {
+	case containsMessages(remote.Messages, local.Messages):
+		// Nothing here that the stored version lacks
+		ui.currentSession = remote
+		if len(remote.Messages) != len(local.Messages) {
+			ui.loadCurrentSessionMessages()
+		}
+		ui.updateSessionModelIndicator()
+		ui.logger.Info("Loaded session changed elsewhere", "session_id", remote.ID, "message_count", len(remote.Messages))
+
+	case sharedMessages(local.Messages, remote.Messages) == len(remote.Messages):
+		// Only this window added messages; the session is now up to date, so save again
+		if err := ui.storage.SaveChatSession(ctx, local); err != nil {
+			ui.logger.Error("Failed to save merged session", "session_id", local.ID, "error", err)
+			dialog.ShowError(fmt.Errorf("failed to save session: %v", err), ui.window)
+			return
+		}
+		ui.logger.Info("Saved session over older version from elsewhere", "session_id", local.ID)
+
+	default:
+		ui.saveConflictedCopy(local, fmt.Sprintf("\"%s\" was changed here and elsewhere at the same time. "+
+			"The other version was kept under the original name and this window's version was saved as a copy.",
+			truncateText(local.Name, 40)))
+		ui.notifySemanticIndex(local.ID)
+		return
+	}
+
+	ui.refreshSessionsList()
+	ui.notifySemanticIndex(ui.currentSession.ID)
+}
+
+// saveConflictedCopy saves session under a new ID, opens it and explains why
+func (ui *ChatUI) saveConflictedCopy(session models.ChatSession, message string) {
+	session.ID = models.NewID()
+	session.Rename(session.Name + conflictedCopySuffix)
+	if err := ui.storage.SaveChatSession(context.Background(), session); err != nil {
+		ui.logger.Error("Failed to save conflicted copy", "session_id", session.ID, "error", err)
+		dialog.ShowError(fmt.Errorf("failed to save conflicted copy: %v", err), ui.window)
+		return
+	}
+	ui.logger.Warn("Saved conflicting changes as a copy", "session_id", session.ID, "session_name", session.Name)
+
+	ui.currentSession = session
+	ui.loadCurrentSessionMessages()
+	ui.updateSessionModelIndicator()
+	ui.refreshSessionsList()
+	ui.notifySemanticIndex(session.ID)
+	dialog.ShowInformation("Conflicting Changes", message, ui.window)
+}
+
+// showSyncConflicts reports conflicts between devices sharing a sync folder that have
+// not been reported in this run
+func (ui *ChatUI) showSyncConflicts() {
+	stor := ui.storage
+	for {
+		wrapped, ok := stor.(interface{ Unwrap() storage.Storage })
+		if !ok {
+			break
+		}
+		stor = wrapped.Unwrap()
+	}
+	reporter, ok := stor.(storage.SyncConflictReporter)
+	if !ok {
+		return
+	}
+
+	if ui.shownConflicts == nil {
+		ui.shownConflicts = make(map[string]bool)
+	}
+	var ids, entries []string
+	for _, conflict := range reporter.SyncConflicts() {
+		if ui.shownConflicts[conflict.ID] {
+			continue
+		}
+		ui.shownConflicts[conflict.ID] = true
+		ids = append(ids, conflict.ID)
+		entries = append(entries, fmt.Sprintf("%s (%s)\n%s", truncateText(conflict.Subject, 60),
+			conflict.Time.Local().Format("Jan 2 15:04"), conflict.Detail))
+	}
+	if len(ids) == 0 {
+		return
+	}
+	ui.logger.Warn("Changes from different devices conflicted", "count", len(ids))
+
+	header := widget.NewLabel(fmt.Sprintf("%d change(s) were made on different devices before they synced. "+
+		"No messages were lost; where both devices changed the same thing, one version was kept as described below.", len(ids)))
+	header.Wrapping = fyne.TextWrapWord
+	details := widget.NewLabel(strings.Join(entries, "\n\n"))
+	details.Wrapping = fyne.TextWrapWord
+	detailsScroll := container.NewVScroll(details)
+	detailsScroll.SetMinSize(fyne.NewSize(520, 260))
+
+	content := container.NewBorder(header, nil, nil, nil, detailsScroll)
+	conflictDialog := dialog.NewCustomConfirm("Sync Conflicts", "Dismiss", "Later", content, func(dismiss bool) {
+		if !dismiss {
+			return
+		}
+		if err := reporter.DismissSyncConflicts(ids); err != nil {
+			ui.logger.Error("Failed to dismiss sync conflicts", "error", err)
+			dialog.ShowError(fmt.Errorf("failed to dismiss conflicts: %v", err), ui.window)
+		}
+	}, ui.window)
+	conflictDialog.Resize(fyne.NewSize(600, 440))
+	conflictDialog.Show()
+}
+
+// containsMessages reports whether every message in b appears in a, in the same order
+func containsMessages(a, b []models.ChatMessage) bool {
+	i := 0
+	for _, msg := range a {
+		if i < len(b) && msg.Sender == b[i].Sender && msg.Content == b[i].Content {
+			i++
+		}
+	}
+	return i == len(b)
+}
+
+// sharedMessages returns how many leading messages a and b have in common
+func sharedMessages(a, b []models.ChatMessage) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n].Sender == b[n].Sender && a[n].Content == b[n].Content {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,355 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ashprao/ollamachat/internal/models"
+	"github.com/ashprao/ollamachat/internal/prompts"
+	"github.com/ashprao/ollamachat/internal/storage"
+	"github.com/ashprao/ollamachat/pkg/logger"
+)
+
+// maxTemplateFileSize limits how much of a selected file is inserted into a prompt
+const maxTemplateFileSize = 512 * 1024
+
+// TemplateManager provides the UI for browsing and editing the prompt template library
+type TemplateManager struct {
+	logger  *logger.Logger
+	window  fyne.Window
+	storage storage.Storage
+
+	templates []models.PromptTemplate
+	selected  int
+	list      *widget.List
+}
+
+// NewTemplateManager creates a new template manager
+func NewTemplateManager(window fyne.Window, storage storage.Storage, logger *logger.Logger) *TemplateManager {
+	return &TemplateManager{
+		logger:   logger.WithComponent("template-manager"),
+		window:   window,
+		storage:  storage,
+		selected: -1,
+	}
+}
+
+// Content builds the template library view used inside the settings dialog
+func (tm *TemplateManager) Content() fyne.CanvasObject {
+	tm.reload()
+
+	tm.list = widget.NewList(
+		func() int {
+			return len(tm.templates)
+		},
+		func() fyne.CanvasObject {
+			return container.NewVBox(
+				widget.NewLabel("Template Name"),
+				widget.NewLabel("Description"),
+			)
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id >= len(tm.templates) {
+				return
+			}
+
+			template := tm.templates[id]
+			row := obj.(*fyne.Container)
+			nameLabel := row.Objects[0].(*widget.Label)
+			descLabel := row.Objects[1].(*widget.Label)
+
+			nameLabel.SetText(template.Name)
+			nameLabel.TextStyle = fyne.TextStyle{Bold: true}
+			nameLabel.Refresh()
+
+			description := template.Description
+			if description == "" {
+				description = truncateText(template.Body, 60)
+			}
+			descLabel.SetText(description)
+		},
+	)
+	tm.list.OnSelected = func(id widget.ListItemID) {
+		tm.selected = id
+	}
+	tm.list.OnUnselected = func(widget.ListItemID) {
+		tm.selected = -1
+	}
+
+	newButton := widget.NewButtonWithIcon("New", theme.ContentAddIcon(), func() {
+		tm.showEditForm(models.NewPromptTemplate("", ""), true)
+	})
+	editButton := widget.NewButtonWithIcon("Edit", theme.DocumentCreateIcon(), func() {
+		if template, ok := tm.selectedTemplate(); ok {
+			tm.showEditForm(template, false)
+		}
+	})
+	deleteButton := widget.NewButtonWithIcon("Delete", theme.DeleteIcon(), tm.onDeleteTapped)
+	examplesButton := widget.NewButtonWithIcon("Add Examples", theme.ContentPasteIcon(), tm.onAddExamplesTapped)
+
+	buttons := container.NewHBox(newButton, editButton, deleteButton, examplesButton)
+
+	help := widget.NewLabel(fmt.Sprintf("Use {{.Name}} placeholders. Built-ins: {{.%s}}, {{.%s}}, {{.%s}}, {{.%s}}, {{.%s}}.",
+		prompts.VarDate, prompts.VarTime, prompts.VarClipboard, prompts.VarFile, prompts.VarFileName))
+	help.Wrapping = fyne.TextWrapWord
+	help.Importance = widget.LowImportance
+
+	listScroll := container.NewVScroll(tm.list)
+	listScroll.SetMinSize(fyne.NewSize(400, 250))
+
+	return container.NewBorder(help, buttons, nil, nil, listScroll)
+}
+
+// reload refreshes the template list from storage
+func (tm *TemplateManager) reload() {
+	templates, err := tm.storage.ListPromptTemplates(context.Background())
+	if err != nil {
+		tm.logger.Error("Failed to list prompt templates", "error", err)
+		templates = []models.PromptTemplate{}
+	}
+	tm.templates = templates
+	tm.selected = -1
+
+	if tm.list != nil {
+		tm.list.UnselectAll()
+		tm.list.Refresh()
+	}
+}
+
+// selectedTemplate returns the currently selected template, if any
+func (tm *TemplateManager) selectedTemplate() (models.PromptTemplate, bool) {
+	if tm.selected < 0 || tm.selected >= len(tm.templates) {
+		dialog.ShowInformation("Templates", "Select a template first.", tm.window)
+		return models.PromptTemplate{}, false
+	}
+	return tm.templates[tm.selected], true
+}
+
+// showEditForm shows a form to create or edit a prompt template
+func (tm *TemplateManager) showEditForm(template models.PromptTemplate, isNew bool) {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(template.Name)
+
+	descriptionEntry := widget.NewEntry()
+	descriptionEntry.SetText(template.Description)
+
+	bodyEntry := widget.NewMultiLineEntry()
+	bodyEntry.Wrapping = fyne.TextWrapWord
+	bodyEntry.SetMinRowsVisible(8)
+	bodyEntry.SetPlaceHolder("Translate the following text into {{.Language}}:\n\n{{.Text}}")
+	bodyEntry.SetText(template.Body)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Description", descriptionEntry),
+		widget.NewFormItem("Template", bodyEntry),
+	}
+
+	title := "Edit Template"
+	if isNew {
+		title = "New Template"
+	}
+
+	formDialog := dialog.NewForm(title, "Save", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		name := strings.TrimSpace(nameEntry.Text)
+		if name == "" {
+			dialog.ShowError(fmt.Errorf("template name cannot be empty"), tm.window)
+			return
+		}
+
+		if _, err := prompts.Parse(bodyEntry.Text); err != nil {
+			dialog.ShowError(err, tm.window)
+			return
+		}
+
+		template.Name = name
+		template.Description = strings.TrimSpace(descriptionEntry.Text)
+		template.Body = bodyEntry.Text
+
+		if err := tm.storage.SavePromptTemplate(context.Background(), template); err != nil {
+			tm.logger.Error("Failed to save prompt template", "template_id", template.ID, "error", err)
+			dialog.ShowError(fmt.Errorf("failed to save template: %w", err), tm.window)
+			return
+		}
+
+		tm.logger.Info("Saved prompt template", "template_id", template.ID, "name", template.Name)
+		tm.reload()
+	}, tm.window)
+
+	formDialog.Resize(fyne.NewSize(550, 450))
+	formDialog.Show()
+}
+
+// onDeleteTapped deletes the selected template after confirmation
+func (tm *TemplateManager) onDeleteTapped() {
+	template, ok := tm.selectedTemplate()
+	if !ok {
+		return
+	}
+
+	dialog.ShowConfirm("Delete Template",
+		fmt.Sprintf("Are you sure you want to delete the template '%s'?", template.Name),
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+
+			if err := tm.storage.DeletePromptTemplate(context.Background(), template.ID); err != nil {
+				tm.logger.Error("Failed to delete prompt template", "template_id", template.ID, "error", err)
+				dialog.ShowError(fmt.Errorf("failed to delete template: %w", err), tm.window)
+				return
+			}
+
+			tm.reload()
+		}, tm.window)
+}
+
+// onAddExamplesTapped saves the example templates into the library
+func (tm *TemplateManager) onAddExamplesTapped() {
+	ctx := context.Background()
+	for _, template := range prompts.ExampleTemplates() {
+		if err := tm.storage.SavePromptTemplate(ctx, template); err != nil {
+			tm.logger.Error("Failed to save example template", "name", template.Name, "error", err)
+			dialog.ShowError(fmt.Errorf("failed to add example templates: %w", err), tm.window)
+			break
+		}
+	}
+	tm.reload()
+}
+
+// onTemplateButtonTapped shows the template picker next to the input field
+func (ui *ChatUI) onTemplateButtonTapped() {
+	templates, err := ui.storage.ListPromptTemplates(context.Background())
+	if err != nil {
+		ui.logger.Error("Failed to list prompt templates", "error", err)
+		dialog.ShowError(fmt.Errorf("failed to load templates: %w", err), ui.window)
+		return
+	}
+
+	if len(templates) == 0 {
+		dialog.ShowInformation("Prompt Templates",
+			"No templates yet. Create some in Settings → Templates.", ui.window)
+		return
+	}
+
+	var pickerDialog dialog.Dialog
+	list := widget.NewList(
+		func() int {
+			return len(templates)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("Template Name")
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(templates[id].Name)
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		pickerDialog.Hide()
+		ui.fillTemplate(templates[id])
+	}
+
+	pickerDialog = dialog.NewCustom("Insert Template", "Cancel", list, ui.window)
+	pickerDialog.Resize(fyne.NewSize(350, 300))
+	pickerDialog.Show()
+}
+
+// fillTemplate asks for the template's variables and inserts the rendered prompt
+func (ui *ChatUI) fillTemplate(template models.PromptTemplate) {
+	vars, err := prompts.Parse(template.Body)
+	if err != nil {
+		dialog.ShowError(err, ui.window)
+		return
+	}
+
+	var renderCtx prompts.Context
+	if vars.NeedsClipboard() {
+		renderCtx.Clipboard = ui.window.Clipboard().Content()
+	}
+
+	// Nothing to ask for - insert straight away
+	if len(vars.User) == 0 && !vars.NeedsFile() {
+		ui.insertRenderedTemplate(template, nil, renderCtx)
+		return
+	}
+
+	entries := make(map[string]*widget.Entry, len(vars.User))
+	items := make([]*widget.FormItem, 0, len(vars.User)+1)
+	for _, name := range vars.User {
+		entry := widget.NewMultiLineEntry()
+		entry.Wrapping = fyne.TextWrapWord
+		entry.SetMinRowsVisible(2)
+		entries[name] = entry
+		items = append(items, widget.NewFormItem(name, entry))
+	}
+
+	if vars.NeedsFile() {
+		fileLabel := widget.NewLabel("No file selected")
+		chooseButton := widget.NewButtonWithIcon("Choose File…", theme.FolderOpenIcon(), func() {
+			dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+				if err != nil || reader == nil {
+					return
+				}
+				defer reader.Close()
+
+				data, err := io.ReadAll(io.LimitReader(reader, maxTemplateFileSize))
+				if err != nil {
+					ui.logger.Error("Failed to read file for template", "error", err)
+					dialog.ShowError(fmt.Errorf("failed to read file: %w", err), ui.window)
+					return
+				}
+
+				renderCtx.File = string(data)
+				renderCtx.FileName = reader.URI().Name()
+				fileLabel.SetText(renderCtx.FileName)
+			}, ui.window)
+		})
+		items = append(items, widget.NewFormItem("File", container.NewBorder(nil, nil, nil, chooseButton, fileLabel)))
+	}
+
+	formDialog := dialog.NewForm(template.Name, "Insert", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		values := make(map[string]string, len(entries))
+		for name, entry := range entries {
+			values[name] = entry.Text
+		}
+		ui.insertRenderedTemplate(template, values, renderCtx)
+	}, ui.window)
+
+	formDialog.Resize(fyne.NewSize(500, 200+float32(len(items))*60))
+	formDialog.Show()
+}
+
+// insertRenderedTemplate renders the template and appends it to the input field
+func (ui *ChatUI) insertRenderedTemplate(template models.PromptTemplate, values map[string]string, renderCtx prompts.Context) {
+	rendered, err := prompts.Render(template, values, renderCtx)
+	if err != nil {
+		ui.logger.Error("Failed to render prompt template", "template_id", template.ID, "error", err)
+		dialog.ShowError(err, ui.window)
+		return
+	}
+
+	text := ui.inputField.Text
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	ui.inputField.SetText(text + rendered)
+	ui.window.Canvas().Focus(ui.inputField)
+
+	ui.logger.Info("Inserted prompt template", "template_id", template.ID, "name", template.Name)
+}
@@ -0,0 +1,228 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ashprao/ollamachat/internal/storage"
+)
+
+// undoBarTimeout is how long the undo bar stays up after a session is deleted
+const undoBarTimeout = 8 * time.Second
+
+// showUndoBar shows a bar at the bottom of the window offering to restore a session
+// that was just moved to the trash
+func (ui *ChatUI) showUndoBar(sessionID, sessionName string) {
+	ui.hideUndoBar()
+
+	message := widget.NewLabel(fmt.Sprintf("Deleted \"%s\"", truncateText(sessionName, 40)))
+	undoButton := widget.NewButtonWithIcon("Undo", theme.ContentUndoIcon(), func() {
+		ui.hideUndoBar()
+		ui.restoreTrashedSession(sessionID, true)
+	})
+	undoButton.Importance = widget.HighImportance
+
+	canvas := ui.window.Canvas()
+	bar := widget.NewPopUp(container.NewHBox(message, undoButton), canvas)
+	barSize := bar.MinSize()
+	bar.ShowAtPosition(fyne.NewPos(
+		(canvas.Size().Width-barSize.Width)/2,
+		canvas.Size().Height-barSize.Height-theme.Padding()*4,
+	))
+
+	ui.undoBar = bar
+	ui.undoGeneration++
+	generation := ui.undoGeneration
+
+	// The bar may have been hidden, or replaced for another deletion, by the time
+	// the timer fires
+	ui.undoTimer = time.AfterFunc(undoBarTimeout, func() {
+		ui.runOnUI(func() { ui.expireUndoBar(generation) })
+	})
+}
+
+// expireUndoBar hides the undo bar when it times out, if it is still the one shown
+// for generation
+func (ui *ChatUI) expireUndoBar(generation uint64) {
+	if ui.undoGeneration == generation {
+		ui.hideUndoBar()
+	}
+}
+
+// hideUndoBar removes the undo bar, if shown, and stops its timer
+func (ui *ChatUI) hideUndoBar() {
+	ui.undoGeneration++
+	if ui.undoTimer != nil {
+		ui.undoTimer.Stop()
+		ui.undoTimer = nil
+	}
+	if ui.undoBar != nil {
+		ui.undoBar.Hide()
+		ui.undoBar = nil
+	}
+}
+
+// restoreTrashedSession brings a session back from the trash, opening it if open is set
+func (ui *ChatUI) restoreTrashedSession(sessionID string, open bool) bool {
+	if err := ui.storage.RestoreChatSession(context.Background(), sessionID); err != nil {
+		ui.logger.Error("Failed to restore session", "session_id", sessionID, "error", err)
+		dialog.ShowError(fmt.Errorf("failed to restore session: %v", err), ui.window)
+		return false
+	}
+	ui.notifySemanticIndex(sessionID)
+	ui.logger.Info("Restored session from trash", "session_id", sessionID)
+
+	if open {
+		ui.switchToSession(sessionID)
+	}
+	ui.refreshSessionsList()
+	return true
+}
+
+// SessionsRemoved updates the UI after sessions were moved to the trash or purged
+// outside of it, such as by retention rules
+func (ui *ChatUI) SessionsRemoved(sessionIDs []string) {
+	current := false
+	for _, id := range sessionIDs {
+		ui.notifySemanticIndex(id)
+		current = current || id == ui.currentSession.ID
+	}
+	if !current {
+		ui.refreshSessionsList()
+		return
+	}
+
+	// A response still streaming would save the session again
+	if ui.cancelFunc != nil {
+		ui.cancelFunc()
+		ui.cancelFunc = nil
+	}
+	ui.logger.Info("Open session was moved to the trash", "session_id", ui.currentSession.ID)
+	ui.showFirstSession()
+}
+
+// showTrashDialog lists deleted sessions so they can be restored or deleted for good
+func (ui *ChatUI) showTrashDialog() {
+	var trashed []storage.TrashedSession
+	selected := -1
+
+	list := widget.NewList(
+		func() int {
+			return len(trashed)
+		},
+		func() fyne.CanvasObject {
+			name := widget.NewLabel("Session Name")
+			name.TextStyle = fyne.TextStyle{Bold: true}
+			name.Truncation = fyne.TextTruncateEllipsis
+			return container.NewVBox(name, widget.NewLabel("Deleted"))
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id >= len(trashed) {
+				return
+			}
+
+			item := trashed[id]
+			row := obj.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(item.Name)
+			row.Objects[1].(*widget.Label).SetText(fmt.Sprintf("Deleted %s · %d messages",
+				item.DeletedAt.Format("Jan 2, 15:04"), item.MessageCount))
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		selected = id
+	}
+	list.OnUnselected = func(widget.ListItemID) {
+		selected = -1
+	}
+
+	reload := func() {
+		items, err := ui.storage.ListTrashedSessions(context.Background())
+		if err != nil {
+			ui.logger.Error("Failed to list trashed sessions", "error", err)
+			dialog.ShowError(fmt.Errorf("failed to list trash: %v", err), ui.window)
+		}
+		trashed = items
+		selected = -1
+		list.UnselectAll()
+		list.Refresh()
+	}
+	reload()
+
+	selectedSession := func() (storage.TrashedSession, bool) {
+		if selected < 0 || selected >= len(trashed) {
+			dialog.ShowInformation("Trash", "Select a session first.", ui.window)
+			return storage.TrashedSession{}, false
+		}
+		return trashed[selected], true
+	}
+
+	restoreButton := widget.NewButtonWithIcon("Restore", theme.ContentUndoIcon(), func() {
+		if item, ok := selectedSession(); ok && ui.restoreTrashedSession(item.ID, false) {
+			reload()
+		}
+	})
+	purgeButton := widget.NewButtonWithIcon("Delete Forever", theme.DeleteIcon(), func() {
+		item, ok := selectedSession()
+		if !ok {
+			return
+		}
+		dialog.ShowConfirm("Delete Forever",
+			fmt.Sprintf("Permanently delete the session '%s'? This action cannot be undone.", item.Name),
+			func(confirmed bool) {
+				if !confirmed {
+					return
+				}
+				if err := ui.storage.PurgeTrashedSession(context.Background(), item.ID); err != nil {
+					ui.logger.Error("Failed to purge session", "session_id", item.ID, "error", err)
+					dialog.ShowError(fmt.Errorf("failed to delete session: %v", err), ui.window)
+				}
+				reload()
+			}, ui.window)
+	})
+	emptyButton := widget.NewButtonWithIcon("Empty Trash", theme.ContentClearIcon(), func() {
+		if len(trashed) == 0 {
+			return
+		}
+		dialog.ShowConfirm("Empty Trash",
+			fmt.Sprintf("Permanently delete all %d session(s) in the trash? This action cannot be undone.", len(trashed)),
+			func(confirmed bool) {
+				if !confirmed {
+					return
+				}
+				purged, err := ui.storage.PurgeTrash(context.Background(), time.Now())
+				if err != nil {
+					ui.logger.Error("Failed to empty trash", "error", err)
+					dialog.ShowError(fmt.Errorf("failed to empty trash: %v", err), ui.window)
+				}
+				ui.logger.Info("Emptied trash", "purged", purged)
+				reload()
+			}, ui.window)
+	})
+
+	help := widget.NewLabel(ui.trashRetentionText())
+	help.Wrapping = fyne.TextWrapWord
+	help.Importance = widget.LowImportance
+
+	listScroll := container.NewVScroll(list)
+	listScroll.SetMinSize(fyne.NewSize(400, 300))
+
+	content := container.NewBorder(help, container.NewHBox(restoreButton, purgeButton, emptyButton), nil, nil, listScroll)
+	trashDialog := dialog.NewCustom("Trash", "Close", content, ui.window)
+	trashDialog.Show()
+}
+
+// trashRetentionText describes how long deleted sessions are kept
+func (ui *ChatUI) trashRetentionText() string {
+	retention := ui.config.TrashRetention()
+	if retention == 0 {
+		return "Deleted sessions are kept until the trash is emptied."
+	}
+	return fmt.Sprintf("Deleted sessions are removed permanently after %d days.", int(retention.Hours()/24))
+}
@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ashprao/ollamachat/internal/storage"
+)
+
+// createUsageReport shows the space taken by the stored sessions, the trash and the
+// storage as a whole, and lists the largest sessions
+func (sd *SettingsDialog) createUsageReport() fyne.CanvasObject {
+	usage, err := sd.app.StorageUsage(context.Background())
+	if err != nil {
+		sd.logger.Error("Failed to measure storage usage", "error", err)
+		return widget.NewLabel(fmt.Sprintf("Storage usage is unavailable: %v", err))
+	}
+
+	totals := container.NewGridWithColumns(2,
+		widget.NewLabel("Sessions:"),
+		widget.NewLabel(fmt.Sprintf("%d (%s)", len(usage.Sessions), storage.FormatBytes(usage.SessionBytes))),
+		widget.NewLabel("Trash:"),
+		widget.NewLabel(fmt.Sprintf("%d (%s)", usage.TrashCount, storage.FormatBytes(usage.TrashBytes))),
+	)
+	if usage.DiskBytes > 0 {
+		totals.Add(widget.NewLabel("Total on disk:"))
+		totals.Add(widget.NewLabel(storage.FormatBytes(usage.DiskBytes)))
+	}
+
+	largest := container.NewVBox()
+	for i, summary := range usage.Sessions {
+		if i == usageListLimit {
+			break
+		}
+		name := truncateText(summary.Name, 50)
+		if summary.Pinned {
+			name = pinnedPrefix + name
+		}
+		size := widget.NewLabel(storage.FormatBytes(summary.Size))
+		size.TextStyle = fyne.TextStyle{Monospace: true}
+		largest.Add(container.NewBorder(nil, nil, size, nil, widget.NewLabel(name)))
+	}
+	largestScroll := container.NewVScroll(largest)
+	largestScroll.SetMinSize(fyne.NewSize(400, 180))
+
+	return container.NewBorder(
+		container.NewVBox(totals, widget.NewLabel("Largest sessions:")),
+		nil, nil, nil, largestScroll)
+}
@@ -8,20 +8,33 @@ import (
 // Logger wraps slog.Logger with additional functionality
 type Logger struct {
 	*slog.Logger
+	level *slog.LevelVar // Shared by every logger derived from the same root
 }
 
 // NewLogger creates a new logger with the specified level
 func NewLogger(level slog.Level) *Logger {
+	levelVar := new(slog.LevelVar)
+	levelVar.Set(level)
 	opts := &slog.HandlerOptions{
-		Level: level,
+		Level: levelVar,
 	}
 	handler := slog.NewTextHandler(os.Stdout, opts)
-	return &Logger{slog.New(handler)}
+	return &Logger{slog.New(handler), levelVar}
+}
+
+// SetLevel changes the minimum level of this logger and every logger derived from it
+func (l *Logger) SetLevel(level slog.Level) {
+	l.level.Set(level)
+}
+
+// Level returns the current minimum level
+func (l *Logger) Level() slog.Level {
+	return l.level.Level()
 }
 
 // WithComponent returns a new logger with a component field
 func (l *Logger) WithComponent(component string) *Logger {
-	return &Logger{l.With("component", component)}
+	return &Logger{l.With("component", component), l.level}
 }
 
 // WithFields returns a new logger with additional fields
@@ -30,5 +43,5 @@ func (l *Logger) WithFields(fields map[string]interface{}) *Logger {
 	for k, v := range fields {
 		args = append(args, k, v)
 	}
-	return &Logger{l.With(args...)}
+	return &Logger{l.With(args...), l.level}
 }